@@ -0,0 +1,42 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hulutech-web/workflow-engine/app/api/schemas/req"
+	"github.com/hulutech-web/workflow-engine/app/api/service"
+	"github.com/hulutech-web/workflow-engine/app/api/types"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/response"
+	"github.com/hulutech-web/workflow-engine/pkg/util"
+	"go.uber.org/fx"
+)
+
+type cc struct {
+	fx.In
+	Srv service.CcService
+}
+
+func ccRoutes(t cc, r *types.ApiRouter) {
+	api := r.Group("/cc")
+
+	api.GET("/list", t.list)
+	api.GET("/detail", t.detail)
+}
+
+func (t cc) list(ctx *gin.Context) {
+	var pageReq req.PageReq
+	var queryReq req.CcQueryReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &queryReq, &pageReq)) {
+		return
+	}
+	res, err := t.Srv.List(&pageReq, &queryReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t cc) detail(ctx *gin.Context) {
+	var detailReq req.CcDetailReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &detailReq)) {
+		return
+	}
+	res, err := t.Srv.Detail(&detailReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
@@ -0,0 +1,51 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hulutech-web/workflow-engine/app/api/schemas/req"
+	"github.com/hulutech-web/workflow-engine/app/api/service"
+	"github.com/hulutech-web/workflow-engine/app/api/types"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/response"
+	"github.com/hulutech-web/workflow-engine/pkg/util"
+	"go.uber.org/fx"
+)
+
+type delegation struct {
+	fx.In
+	Srv service.DelegationService
+}
+
+func delegationRoutes(t delegation, r *types.ApiRouter) {
+	api := r.Group("/delegation")
+
+	api.GET("/list", t.list)
+	api.POST("/add", t.add)
+	api.POST("/delete", t.delete)
+}
+
+func (t delegation) list(ctx *gin.Context) {
+	var queryReq req.DelegationQueryReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &queryReq)) {
+		return
+	}
+	res, err := t.Srv.List(&queryReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t delegation) add(ctx *gin.Context) {
+	var addReq req.DelegationAddReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &addReq)) {
+		return
+	}
+	err := t.Srv.Add(&addReq)
+	response.CheckAndResp(ctx, err)
+}
+
+func (t delegation) delete(ctx *gin.Context) {
+	var idReq req.IdReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &idReq)) {
+		return
+	}
+	err := t.Srv.Delete(idReq.ID)
+	response.CheckAndResp(ctx, err)
+}
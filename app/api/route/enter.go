@@ -10,6 +10,11 @@ var Module = fx.Module("api.route",
 	fx.Provide(NewRoutes),
 	fx.Invoke(accountRoutes),
 	fx.Invoke(userRoutes),
+	fx.Invoke(flowRoutes),
+	fx.Invoke(procRoutes),
+	fx.Invoke(delegationRoutes),
+	fx.Invoke(entryRoutes),
+	fx.Invoke(ccRoutes),
 )
 
 type Routes struct {
@@ -0,0 +1,142 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hulutech-web/workflow-engine/app/api/schemas/req"
+	"github.com/hulutech-web/workflow-engine/app/api/service"
+	"github.com/hulutech-web/workflow-engine/app/api/types"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/response"
+	"github.com/hulutech-web/workflow-engine/pkg/util"
+	"go.uber.org/fx"
+)
+
+type entry struct {
+	fx.In
+	Srv service.EntryService
+}
+
+func entryRoutes(t entry, r *types.ApiRouter) {
+	api := r.Group("/entry")
+
+	api.POST("/recall", t.recall)
+	api.POST("/resubmit", t.resubmit)
+	api.GET("/branches", t.branches)
+	api.POST("/suspend", t.suspend)
+	api.POST("/resume", t.resume)
+	api.POST("/terminate", t.terminate)
+	api.GET("/transitions", t.transitions)
+	api.GET("/events", t.events)
+	api.GET("/rebuild", t.rebuild)
+	api.GET("/diagram", t.diagram)
+	api.GET("/list", t.list)
+	api.GET("/detail", t.detail)
+}
+
+func (t entry) recall(ctx *gin.Context) {
+	var recallReq req.EntryRecallReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &recallReq)) {
+		return
+	}
+	err := t.Srv.Recall(&recallReq)
+	response.CheckAndResp(ctx, err)
+}
+
+func (t entry) resubmit(ctx *gin.Context) {
+	var resubmitReq req.EntryResubmitReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &resubmitReq)) {
+		return
+	}
+	res, err := t.Srv.Resubmit(&resubmitReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t entry) branches(ctx *gin.Context) {
+	var branchesReq req.EntryBranchesReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &branchesReq)) {
+		return
+	}
+	res, err := t.Srv.Branches(&branchesReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t entry) suspend(ctx *gin.Context) {
+	var suspendReq req.EntrySuspendReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &suspendReq)) {
+		return
+	}
+	err := t.Srv.Suspend(&suspendReq)
+	response.CheckAndResp(ctx, err)
+}
+
+func (t entry) resume(ctx *gin.Context) {
+	var resumeReq req.EntryResumeReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &resumeReq)) {
+		return
+	}
+	err := t.Srv.Resume(&resumeReq)
+	response.CheckAndResp(ctx, err)
+}
+
+func (t entry) terminate(ctx *gin.Context) {
+	var terminateReq req.EntryTerminateReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &terminateReq)) {
+		return
+	}
+	err := t.Srv.Terminate(&terminateReq)
+	response.CheckAndResp(ctx, err)
+}
+
+func (t entry) transitions(ctx *gin.Context) {
+	var transitionsReq req.EntryTransitionsReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &transitionsReq)) {
+		return
+	}
+	res, err := t.Srv.Transitions(&transitionsReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t entry) events(ctx *gin.Context) {
+	var eventsReq req.EntryEventsReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &eventsReq)) {
+		return
+	}
+	res, err := t.Srv.Events(&eventsReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t entry) rebuild(ctx *gin.Context) {
+	var rebuildReq req.EntryRebuildReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &rebuildReq)) {
+		return
+	}
+	res, err := t.Srv.Rebuild(&rebuildReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t entry) diagram(ctx *gin.Context) {
+	var diagramReq req.EntryDiagramReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &diagramReq)) {
+		return
+	}
+	res, err := t.Srv.Diagram(&diagramReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t entry) list(ctx *gin.Context) {
+	var pageReq req.PageReq
+	var listReq req.EntryListReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &listReq, &pageReq)) {
+		return
+	}
+	res, err := t.Srv.List(&pageReq, &listReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t entry) detail(ctx *gin.Context) {
+	var detailReq req.EntryDetailReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &detailReq)) {
+		return
+	}
+	res, err := t.Srv.Detail(&detailReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
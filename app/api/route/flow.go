@@ -0,0 +1,136 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hulutech-web/workflow-engine/app/api/schemas/req"
+	"github.com/hulutech-web/workflow-engine/app/api/service"
+	"github.com/hulutech-web/workflow-engine/app/api/types"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/response"
+	"github.com/hulutech-web/workflow-engine/pkg/util"
+	"go.uber.org/fx"
+)
+
+type flow struct {
+	fx.In
+	Srv service.FlowService
+}
+
+func flowRoutes(t flow, r *types.ApiRouter) {
+	api := r.Group("/flow")
+
+	api.GET("/validate", t.validate)
+	api.POST("/publish", t.publish)
+	api.GET("/versions", t.versions)
+	api.GET("/version", t.version)
+	api.GET("/version/diff", t.diff)
+	api.POST("/version/rollback", t.rollback)
+	api.GET("/auditor/types", t.auditorTypes)
+	api.POST("/simulate", t.simulate)
+	api.GET("/bpmn/export", t.exportBpmn)
+	api.POST("/bpmn/import", t.importBpmn)
+	api.GET("/diagram", t.diagram)
+	api.POST("/form/validate", t.validateForm)
+}
+
+func (t flow) validate(ctx *gin.Context) {
+	var idReq req.IdReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &idReq)) {
+		return
+	}
+	res, err := t.Srv.Validate(idReq.ID)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t flow) publish(ctx *gin.Context) {
+	var publishReq req.FlowPublishReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &publishReq)) {
+		return
+	}
+	res, err := t.Srv.Publish(&publishReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t flow) versions(ctx *gin.Context) {
+	var idReq req.IdReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &idReq)) {
+		return
+	}
+	res, err := t.Srv.Versions(idReq.ID)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t flow) version(ctx *gin.Context) {
+	var idReq req.IdReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &idReq)) {
+		return
+	}
+	res, err := t.Srv.Version(idReq.ID)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t flow) diff(ctx *gin.Context) {
+	var diffReq req.FlowVersionDiffReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &diffReq)) {
+		return
+	}
+	res, err := t.Srv.Diff(&diffReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t flow) rollback(ctx *gin.Context) {
+	var idReq req.IdReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &idReq)) {
+		return
+	}
+	res, err := t.Srv.Rollback(idReq.ID)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t flow) auditorTypes(ctx *gin.Context) {
+	response.OkWithData(ctx, t.Srv.AuditorTypes())
+}
+
+func (t flow) simulate(ctx *gin.Context) {
+	var simulateReq req.FlowSimulateReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &simulateReq)) {
+		return
+	}
+	res, err := t.Srv.Simulate(&simulateReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t flow) exportBpmn(ctx *gin.Context) {
+	var idReq req.IdReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &idReq)) {
+		return
+	}
+	res, err := t.Srv.ExportBpmn(idReq.ID)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t flow) importBpmn(ctx *gin.Context) {
+	var importReq req.FlowBpmnImportReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &importReq)) {
+		return
+	}
+	res, err := t.Srv.ImportBpmn(&importReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t flow) diagram(ctx *gin.Context) {
+	var diagramReq req.FlowDiagramReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &diagramReq)) {
+		return
+	}
+	res, err := t.Srv.Diagram(&diagramReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t flow) validateForm(ctx *gin.Context) {
+	var validateReq req.FlowFormValidateReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &validateReq)) {
+		return
+	}
+	res, err := t.Srv.ValidateForm(&validateReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
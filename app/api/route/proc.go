@@ -0,0 +1,102 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/hulutech-web/workflow-engine/app/api/schemas/req"
+	"github.com/hulutech-web/workflow-engine/app/api/service"
+	"github.com/hulutech-web/workflow-engine/app/api/types"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/response"
+	"github.com/hulutech-web/workflow-engine/pkg/util"
+	"go.uber.org/fx"
+)
+
+type proc struct {
+	fx.In
+	Srv service.ProcService
+}
+
+func procRoutes(t proc, r *types.ApiRouter) {
+	api := r.Group("/proc")
+
+	api.GET("/overdue", t.overdue)
+	api.GET("/delegated", t.delegated)
+	api.GET("/reject/targets", t.rejectTargets)
+	api.POST("/reject/back", t.rejectBack)
+	api.POST("/sign/before", t.signBefore)
+	api.POST("/sign/after", t.signAfter)
+	api.POST("/reassign", t.reassign)
+	api.POST("/pass", t.pass)
+}
+
+func (t proc) overdue(ctx *gin.Context) {
+	var pageReq req.PageReq
+	var overdueReq req.ProcOverdueReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &overdueReq, &pageReq)) {
+		return
+	}
+	res, err := t.Srv.Overdue(&pageReq, &overdueReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t proc) delegated(ctx *gin.Context) {
+	var delegatedReq req.ProcDelegatedReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &delegatedReq)) {
+		return
+	}
+	res, err := t.Srv.Delegated(&delegatedReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t proc) rejectTargets(ctx *gin.Context) {
+	var targetsReq req.ProcRejectTargetsReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &targetsReq)) {
+		return
+	}
+	res, err := t.Srv.RejectTargets(&targetsReq)
+	response.CheckAndRespWithData(ctx, res, err)
+}
+
+func (t proc) rejectBack(ctx *gin.Context) {
+	var backReq req.ProcRejectBackReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &backReq)) {
+		return
+	}
+	err := t.Srv.RejectBack(&backReq)
+	response.CheckAndResp(ctx, err)
+}
+
+func (t proc) signBefore(ctx *gin.Context) {
+	var signReq req.ProcSignReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &signReq)) {
+		return
+	}
+	err := t.Srv.SignBefore(&signReq)
+	response.CheckAndResp(ctx, err)
+}
+
+func (t proc) signAfter(ctx *gin.Context) {
+	var signReq req.ProcSignReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &signReq)) {
+		return
+	}
+	err := t.Srv.SignAfter(&signReq)
+	response.CheckAndResp(ctx, err)
+}
+
+func (t proc) reassign(ctx *gin.Context) {
+	var reassignReq req.ProcReassignReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &reassignReq)) {
+		return
+	}
+	err := t.Srv.Reassign(&reassignReq)
+	response.CheckAndResp(ctx, err)
+}
+
+func (t proc) pass(ctx *gin.Context) {
+	var passReq req.ProcPassReq
+	if response.IsFailWithResp(ctx, util.VerifyUtil.Verify(ctx, &passReq)) {
+		return
+	}
+	err := t.Srv.Pass(&passReq)
+	response.CheckAndResp(ctx, err)
+}
@@ -0,0 +1,11 @@
+package req
+
+type CcQueryReq struct {
+	EmpID  uint `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"抄送人ID"`
+	IsRead int  `json:"is_read" form:"is_read" validate:"oneof=0 1 -1" default:"-1" label:"是否查看"`
+}
+
+type CcDetailReq struct {
+	ID    uint `json:"id" form:"id" validate:"required,gte=1" label:"抄送记录ID"`
+	EmpID uint `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"抄送人ID"`
+}
@@ -0,0 +1,14 @@
+package req
+
+type DelegationAddReq struct {
+	EmpID      uint   `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"委托人ID"`
+	DelegateID uint   `json:"delegate_id" form:"delegate_id" validate:"required,gte=1" label:"受托人ID"`
+	StartTime  int64  `json:"start_time" form:"start_time" validate:"required,gte=1" label:"开始时间"`
+	EndTime    int64  `json:"end_time" form:"end_time" validate:"required,gtfield=StartTime" label:"结束时间"`
+	FlowIds    string `json:"flow_ids" form:"flow_ids" label:"委托流程"`
+	Remark     string `json:"remark" form:"remark" validate:"max=255" label:"备注"`
+}
+
+type DelegationQueryReq struct {
+	EmpID uint `json:"emp_id" form:"emp_id" validate:"gte=0" label:"委托人ID"`
+}
@@ -0,0 +1,61 @@
+package req
+
+type EntryRecallReq struct {
+	ID     uint   `json:"id" form:"id" validate:"required,gte=1" label:"流程实例ID"`
+	EmpID  uint   `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"发起人ID"`
+	Reason string `json:"reason" form:"reason" validate:"max=255" label:"撤回原因"`
+}
+
+type EntryResubmitReq struct {
+	ID    uint              `json:"id" form:"id" validate:"required,gte=1" label:"流程实例ID"`
+	EmpID uint              `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"发起人ID"`
+	Data  map[string]string `json:"data" form:"data" label:"表单数据"`
+}
+
+type EntryBranchesReq struct {
+	ID uint `json:"id" form:"id" validate:"required,gte=1" label:"流程实例ID"`
+}
+
+type EntrySuspendReq struct {
+	ID     uint   `json:"id" form:"id" validate:"required,gte=1" label:"流程实例ID"`
+	Reason string `json:"reason" form:"reason" validate:"max=255" label:"挂起原因"`
+}
+
+type EntryResumeReq struct {
+	ID uint `json:"id" form:"id" validate:"required,gte=1" label:"流程实例ID"`
+}
+
+type EntryTerminateReq struct {
+	ID     uint   `json:"id" form:"id" validate:"required,gte=1" label:"流程实例ID"`
+	Reason string `json:"reason" form:"reason" validate:"max=255" label:"终止原因"`
+}
+
+type EntryTransitionsReq struct {
+	ID uint `json:"id" form:"id" validate:"required,gte=1" label:"流程实例ID"`
+}
+
+type EntryEventsReq struct {
+	ID    uint `json:"id" form:"id" validate:"required,gte=1" label:"流程实例ID"`
+	EmpID uint `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"查看人ID"`
+}
+
+type EntryRebuildReq struct {
+	ID uint `json:"id" form:"id" validate:"required,gte=1" label:"流程实例ID"`
+}
+
+type EntryDiagramReq struct {
+	ID     uint   `json:"id" form:"id" validate:"required,gte=1" label:"流程实例ID"`
+	Format string `json:"format" form:"format" validate:"required,oneof=dot mermaid svg" label:"图形格式"`
+}
+
+type EntryListReq struct {
+	FlowID  uint   `json:"flow_id" form:"flow_id" validate:"gte=0" label:"流程ID"`
+	EmpID   uint   `json:"emp_id" form:"emp_id" validate:"gte=0" label:"发起人ID"`
+	Status  *int   `json:"status" form:"status" validate:"omitempty,oneof=0 1 9 -1 -2 -3 -4" label:"流程状态"`
+	Filters string `json:"filters" form:"filters" validate:"omitempty,json" label:"表单字段筛选"`
+}
+
+type EntryDetailReq struct {
+	ID    uint `json:"id" form:"id" validate:"required,gte=1" label:"流程实例ID"`
+	EmpID uint `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"查看人ID"`
+}
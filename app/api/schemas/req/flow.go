@@ -0,0 +1,33 @@
+package req
+
+type FlowPublishReq struct {
+	ID     uint   `json:"id" form:"id" validate:"required,gte=1" label:"流程ID"`
+	Remark string `json:"remark" form:"remark" validate:"max=255" label:"发布说明"`
+}
+
+type FlowVersionDiffReq struct {
+	From uint `json:"from" form:"from" validate:"required,gte=1" label:"对比版本ID"`
+	To   uint `json:"to" form:"to" validate:"required,gte=1" label:"目标版本ID"`
+}
+
+type FlowSimulateReq struct {
+	ID     uint              `json:"id" form:"id" validate:"required,gte=1" label:"流程ID"`
+	EmpID  uint              `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"发起人ID"`
+	DeptID int               `json:"dept_id" form:"dept_id" validate:"gte=0" label:"发起人部门ID"`
+	Data   map[string]string `json:"data" form:"data" label:"表单数据"`
+}
+
+type FlowBpmnImportReq struct {
+	ID  uint   `json:"id" form:"id" validate:"gte=0" label:"流程ID"`
+	Xml string `json:"xml" form:"xml" validate:"required" label:"BPMN内容"`
+}
+
+type FlowFormValidateReq struct {
+	ID   uint              `json:"id" form:"id" validate:"required,gte=1" label:"流程ID"`
+	Data map[string]string `json:"data" form:"data" label:"表单数据"`
+}
+
+type FlowDiagramReq struct {
+	ID     uint   `json:"id" form:"id" validate:"required,gte=1" label:"流程ID"`
+	Format string `json:"format" form:"format" validate:"required,oneof=dot mermaid svg" label:"图形格式"`
+}
@@ -0,0 +1,43 @@
+package req
+
+type ProcOverdueReq struct {
+	EmpID uint `json:"emp_id" form:"emp_id" validate:"gte=0" label:"审核人ID"`
+}
+
+type ProcDelegatedReq struct {
+	EmpID uint `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"受托人ID"`
+}
+
+type ProcRejectTargetsReq struct {
+	ID    uint `json:"id" form:"id" validate:"required,gte=1" label:"待办ID"`
+	EmpID uint `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"审核人ID"`
+}
+
+type ProcRejectBackReq struct {
+	ID        uint   `json:"id" form:"id" validate:"required,gte=1" label:"待办ID"`
+	EmpID     uint   `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"审核人ID"`
+	Content   string `json:"content" form:"content" label:"驳回意见"`
+	ProcessID int    `json:"process_id" form:"process_id" validate:"gte=0" label:"退回步骤ID"`
+}
+
+type ProcSignReq struct {
+	ID       uint   `json:"id" form:"id" validate:"required,gte=1" label:"待办ID"`
+	EmpID    uint   `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"审核人ID"`
+	SignerID uint   `json:"signer_id" form:"signer_id" validate:"required,gte=1" label:"加签人ID"`
+	Reason   string `json:"reason" form:"reason" validate:"required,max=255" label:"加签原因"`
+	Content  string `json:"content" form:"content" label:"审批意见"`
+}
+
+type ProcReassignReq struct {
+	ID      uint   `json:"id" form:"id" validate:"required,gte=1" label:"待办ID"`
+	EmpID   uint   `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"审核人ID"`
+	ToEmpID uint   `json:"to_emp_id" form:"to_emp_id" validate:"required,gte=1" label:"转办人ID"`
+	Reason  string `json:"reason" form:"reason" validate:"required,max=255" label:"转办原因"`
+}
+
+type ProcPassReq struct {
+	ID      uint              `json:"id" form:"id" validate:"required,gte=1" label:"待办ID"`
+	EmpID   uint              `json:"emp_id" form:"emp_id" validate:"required,gte=1" label:"审核人ID"`
+	Content string            `json:"content" form:"content" label:"审批意见"`
+	Data    map[string]string `json:"data" form:"data" label:"表单数据"`
+}
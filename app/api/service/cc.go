@@ -0,0 +1,44 @@
+package service
+
+import (
+	"github.com/hulutech-web/workflow-engine/app/api/schemas/req"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/response"
+	"gorm.io/gorm"
+)
+
+type CcService interface {
+	List(page *req.PageReq, query *req.CcQueryReq) (response.PageResp, error)
+	Detail(detailReq *req.CcDetailReq) (*models.Entry, error)
+}
+
+type ccServiceImpl struct {
+	db *gorm.DB
+	wf *workflow.Service
+}
+
+// List 抄送我的
+func (c ccServiceImpl) List(page *req.PageReq, query *req.CcQueryReq) (response.PageResp, error) {
+	limit := page.Limit
+	offset := page.Limit * (page.Page - 1)
+	records, count, err := c.wf.CarbonCopies(query.EmpID, query.IsRead, limit, offset)
+	if err != nil {
+		return response.PageResp{}, err
+	}
+	return response.PageResp{
+		Count:    count,
+		PageNo:   page.Page,
+		PageSize: page.Limit,
+		Lists:    records,
+	}, nil
+}
+
+// Detail 查看抄送的流程并标记已读
+func (c ccServiceImpl) Detail(detailReq *req.CcDetailReq) (*models.Entry, error) {
+	return c.wf.ReadCarbonCopy(detailReq.ID, detailReq.EmpID)
+}
+
+func NewCcService(db *gorm.DB, wf *workflow.Service) CcService {
+	return &ccServiceImpl{db: db, wf: wf}
+}
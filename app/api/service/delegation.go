@@ -0,0 +1,41 @@
+package service
+
+import (
+	"github.com/hulutech-web/workflow-engine/app/api/schemas/req"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/response"
+	"gorm.io/gorm"
+)
+
+type DelegationService interface {
+	List(query *req.DelegationQueryReq) ([]models.Delegation, error)
+	Add(addReq *req.DelegationAddReq) error
+	Delete(id uint) error
+}
+
+type delegationServiceImpl struct {
+	db *gorm.DB
+	wf *workflow.Service
+}
+
+// List 委托规则列表
+func (d delegationServiceImpl) List(query *req.DelegationQueryReq) ([]models.Delegation, error) {
+	return d.wf.Delegations(query.EmpID)
+}
+
+// Add 新增委托规则
+func (d delegationServiceImpl) Add(addReq *req.DelegationAddReq) error {
+	var delegation models.Delegation
+	response.Copy(&delegation, addReq)
+	return d.wf.AddDelegation(&delegation)
+}
+
+// Delete 删除委托规则
+func (d delegationServiceImpl) Delete(id uint) error {
+	return d.wf.DeleteDelegation(id)
+}
+
+func NewDelegationService(db *gorm.DB, wf *workflow.Service) DelegationService {
+	return &delegationServiceImpl{db: db, wf: wf}
+}
@@ -5,4 +5,9 @@ import "go.uber.org/fx"
 var Module = fx.Module("api.service",
 	fx.Provide(NewUserService),
 	fx.Provide(NewAccountService),
+	fx.Provide(NewFlowService),
+	fx.Provide(NewProcService),
+	fx.Provide(NewDelegationService),
+	fx.Provide(NewEntryService),
+	fx.Provide(NewCcService),
 )
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/hulutech-web/workflow-engine/app/api/schemas/req"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/response"
+	"gorm.io/gorm"
+)
+
+type EntryService interface {
+	Recall(recallReq *req.EntryRecallReq) error
+	Resubmit(resubmitReq *req.EntryResubmitReq) (*models.Entry, error)
+	Branches(branchesReq *req.EntryBranchesReq) ([]models.EntryBranch, error)
+	Suspend(suspendReq *req.EntrySuspendReq) error
+	Resume(resumeReq *req.EntryResumeReq) error
+	Terminate(terminateReq *req.EntryTerminateReq) error
+	Transitions(transitionsReq *req.EntryTransitionsReq) ([]models.StateTransition, error)
+	Events(eventsReq *req.EntryEventsReq) ([]models.EntryEvent, error)
+	Rebuild(rebuildReq *req.EntryRebuildReq) (*workflow.StateCheck, error)
+	Diagram(diagramReq *req.EntryDiagramReq) (string, error)
+	List(page *req.PageReq, listReq *req.EntryListReq) (response.PageResp, error)
+	Detail(detailReq *req.EntryDetailReq) (*workflow.EntryView, error)
+}
+
+type entryServiceImpl struct {
+	db *gorm.DB
+	wf *workflow.Service
+}
+
+// Recall 发起人撤回流程
+func (e entryServiceImpl) Recall(recallReq *req.EntryRecallReq) error {
+	return e.wf.Recall(recallReq.ID, recallReq.EmpID, recallReq.Reason)
+}
+
+// Resubmit 重新提交已撤回的流程，表单校验未通过时将问题列表随响应返回
+func (e entryServiceImpl) Resubmit(resubmitReq *req.EntryResubmitReq) (*models.Entry, error) {
+	entry, err := e.wf.Resubmit(resubmitReq.ID, resubmitReq.EmpID, resubmitReq.Data)
+	var formErrs workflow.FormErrors
+	if errors.As(err, &formErrs) {
+		return nil, response.ParamsValidError.Make("表单校验未通过").MakeData(formErrs)
+	}
+	return entry, err
+}
+
+// Branches 流程当前轮次的并行分支
+func (e entryServiceImpl) Branches(branchesReq *req.EntryBranchesReq) ([]models.EntryBranch, error) {
+	return e.wf.Branches(branchesReq.ID)
+}
+
+// Suspend 挂起流程
+func (e entryServiceImpl) Suspend(suspendReq *req.EntrySuspendReq) error {
+	return e.wf.Suspend(suspendReq.ID, suspendReq.Reason)
+}
+
+// Resume 恢复已挂起的流程
+func (e entryServiceImpl) Resume(resumeReq *req.EntryResumeReq) error {
+	return e.wf.Resume(resumeReq.ID)
+}
+
+// Terminate 终止流程
+func (e entryServiceImpl) Terminate(terminateReq *req.EntryTerminateReq) error {
+	return e.wf.Terminate(terminateReq.ID, terminateReq.Reason)
+}
+
+// Transitions 流程及其待办的状态变更记录
+func (e entryServiceImpl) Transitions(transitionsReq *req.EntryTransitionsReq) ([]models.StateTransition, error) {
+	return e.wf.Transitions(transitionsReq.ID)
+}
+
+// Events 流程的完整事件日志
+func (e entryServiceImpl) Events(eventsReq *req.EntryEventsReq) ([]models.EntryEvent, error) {
+	return e.wf.Events(eventsReq.ID, eventsReq.EmpID)
+}
+
+// Rebuild 按事件日志重建流程状态并与当前状态比对
+func (e entryServiceImpl) Rebuild(rebuildReq *req.EntryRebuildReq) (*workflow.StateCheck, error) {
+	return e.wf.RebuildState(rebuildReq.ID)
+}
+
+// Diagram 标出审批进度的流程图
+func (e entryServiceImpl) Diagram(diagramReq *req.EntryDiagramReq) (string, error) {
+	return e.wf.RenderEntry(diagramReq.ID, diagramReq.Format)
+}
+
+// List 流程列表，filters 为 JSON 数组，如 [{"field":"amount","op":">","value":"1000"}]
+func (e entryServiceImpl) List(page *req.PageReq, listReq *req.EntryListReq) (response.PageResp, error) {
+	query := workflow.EntryQuery{FlowID: listReq.FlowID, EmpID: listReq.EmpID}
+	if listReq.Status != nil {
+		status := models.EntryStatus(*listReq.Status)
+		query.Status = &status
+	}
+	if listReq.Filters != "" {
+		if err := json.Unmarshal([]byte(listReq.Filters), &query.Filters); err != nil {
+			return response.PageResp{}, response.ParamsValidError.MakeData("表单字段筛选格式错误")
+		}
+	}
+	limit := page.Limit
+	offset := page.Limit * (page.Page - 1)
+	entries, count, err := e.wf.Entries(query, limit, offset)
+	if err != nil {
+		return response.PageResp{}, err
+	}
+	return response.PageResp{
+		Count:    count,
+		PageNo:   page.Page,
+		PageSize: page.Limit,
+		Lists:    entries,
+	}, nil
+}
+
+// Detail 流程详情，表单数据按查看人所在步骤的字段权限过滤
+func (e entryServiceImpl) Detail(detailReq *req.EntryDetailReq) (*workflow.EntryView, error) {
+	return e.wf.EntryDetail(detailReq.ID, detailReq.EmpID)
+}
+
+func NewEntryService(db *gorm.DB, wf *workflow.Service) EntryService {
+	return &entryServiceImpl{db: db, wf: wf}
+}
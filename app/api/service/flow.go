@@ -0,0 +1,120 @@
+package service
+
+import (
+	"errors"
+	"github.com/hulutech-web/workflow-engine/app/api/schemas/req"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/response"
+	"gorm.io/gorm"
+)
+
+type FlowService interface {
+	Validate(flowId uint) (workflow.FlowErrors, error)
+	Publish(publishReq *req.FlowPublishReq) (*models.FlowVersion, error)
+	Versions(flowId uint) ([]models.FlowVersion, error)
+	Version(versionId uint) (*models.FlowVersion, error)
+	Diff(diffReq *req.FlowVersionDiffReq) (*workflow.VersionDiff, error)
+	Rollback(versionId uint) (*models.FlowVersion, error)
+	AuditorTypes() []string
+	Simulate(simulateReq *req.FlowSimulateReq) (*workflow.Simulation, error)
+	ExportBpmn(flowId uint) (string, error)
+	ImportBpmn(importReq *req.FlowBpmnImportReq) (*models.Flow, error)
+	Diagram(diagramReq *req.FlowDiagramReq) (string, error)
+	ValidateForm(validateReq *req.FlowFormValidateReq) (workflow.FormErrors, error)
+}
+
+type flowServiceImpl struct {
+	db *gorm.DB
+	wf *workflow.Service
+}
+
+// Validate 校验流程定义，返回按步骤、流转定位的问题列表
+func (f flowServiceImpl) Validate(flowId uint) (workflow.FlowErrors, error) {
+	return f.wf.ValidateFlow(flowId)
+}
+
+// Publish 发布流程，校验未通过时将问题列表随响应返回
+func (f flowServiceImpl) Publish(publishReq *req.FlowPublishReq) (*models.FlowVersion, error) {
+	version, err := f.wf.Publish(publishReq.ID, publishReq.Remark)
+	var flowErrs workflow.FlowErrors
+	if errors.As(err, &flowErrs) {
+		return nil, response.ParamsValidError.Make("流程校验未通过").MakeData(flowErrs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	version.Snapshot = ""
+	return version, nil
+}
+
+// Versions 流程版本列表
+func (f flowServiceImpl) Versions(flowId uint) ([]models.FlowVersion, error) {
+	return f.wf.Versions(flowId)
+}
+
+// Version 流程版本详情
+func (f flowServiceImpl) Version(versionId uint) (*models.FlowVersion, error) {
+	return f.wf.Version(versionId)
+}
+
+// Diff 比较两个流程版本
+func (f flowServiceImpl) Diff(diffReq *req.FlowVersionDiffReq) (*workflow.VersionDiff, error) {
+	return f.wf.DiffVersions(diffReq.From, diffReq.To)
+}
+
+// Rollback 回滚到指定版本
+func (f flowServiceImpl) Rollback(versionId uint) (*models.FlowVersion, error) {
+	version, err := f.wf.Rollback(versionId)
+	if err != nil {
+		return nil, err
+	}
+	version.Snapshot = ""
+	return version, nil
+}
+
+// AuditorTypes 流程定义中可引用的人员类型，含应用注册的解析器
+func (f flowServiceImpl) AuditorTypes() []string {
+	return workflow.AuditorResolverNames()
+}
+
+// Simulate 以指定发起人及表单数据模拟流程，dept_id 不为0时按该部门模拟发起人
+func (f flowServiceImpl) Simulate(simulateReq *req.FlowSimulateReq) (*workflow.Simulation, error) {
+	var emp models.Emp
+	if err := f.db.Preload("Dept").First(&emp, simulateReq.EmpID).Error; err != nil {
+		return nil, errors.New("未找到发起人员工信息")
+	}
+	if simulateReq.DeptID > 0 && simulateReq.DeptID != emp.DeptID {
+		emp.DeptID = simulateReq.DeptID
+		emp.Dept = models.Dept{}
+	}
+	return f.wf.Simulate(simulateReq.ID, emp, simulateReq.Data)
+}
+
+// ExportBpmn 导出流程当前定义为 BPMN 2.0 XML
+func (f flowServiceImpl) ExportBpmn(flowId uint) (string, error) {
+	data, err := f.wf.ExportBPMN(flowId)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ImportBpmn 导入 BPMN 2.0 XML，id 为0时新建流程，否则覆盖该流程当前定义
+func (f flowServiceImpl) ImportBpmn(importReq *req.FlowBpmnImportReq) (*models.Flow, error) {
+	return f.wf.ImportBPMN(importReq.ID, []byte(importReq.Xml))
+}
+
+// Diagram 流程当前定义的流程图
+func (f flowServiceImpl) Diagram(diagramReq *req.FlowDiagramReq) (string, error) {
+	return f.wf.RenderFlow(diagramReq.ID, diagramReq.Format)
+}
+
+// ValidateForm 发起前按流程当前发布版本的表单校验数据，返回按字段定位的问题列表
+func (f flowServiceImpl) ValidateForm(validateReq *req.FlowFormValidateReq) (workflow.FormErrors, error) {
+	return f.wf.ValidateFormData(validateReq.ID, validateReq.Data)
+}
+
+func NewFlowService(db *gorm.DB, wf *workflow.Service) FlowService {
+	return &flowServiceImpl{db: db, wf: wf}
+}
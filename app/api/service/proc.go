@@ -0,0 +1,86 @@
+package service
+
+import (
+	"errors"
+	"github.com/hulutech-web/workflow-engine/app/api/schemas/req"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/response"
+	"gorm.io/gorm"
+)
+
+type ProcService interface {
+	Overdue(page *req.PageReq, query *req.ProcOverdueReq) (response.PageResp, error)
+	Delegated(query *req.ProcDelegatedReq) ([]models.Proc, error)
+	RejectTargets(query *req.ProcRejectTargetsReq) ([]workflow.RejectTarget, error)
+	RejectBack(backReq *req.ProcRejectBackReq) error
+	SignBefore(signReq *req.ProcSignReq) error
+	SignAfter(signReq *req.ProcSignReq) error
+	Reassign(reassignReq *req.ProcReassignReq) error
+	Pass(passReq *req.ProcPassReq) error
+}
+
+type procServiceImpl struct {
+	db *gorm.DB
+	wf *workflow.Service
+}
+
+// Overdue 超过处理期限仍未处理的待办
+func (p procServiceImpl) Overdue(page *req.PageReq, query *req.ProcOverdueReq) (response.PageResp, error) {
+	limit := page.Limit
+	offset := page.Limit * (page.Page - 1)
+	procs, count, err := p.wf.OverdueProcs(query.EmpID, limit, offset)
+	if err != nil {
+		return response.PageResp{}, err
+	}
+	return response.PageResp{
+		Count:    count,
+		PageNo:   page.Page,
+		PageSize: page.Limit,
+		Lists:    procs,
+	}, nil
+}
+
+// Delegated 受托人可代为处理的待办
+func (p procServiceImpl) Delegated(query *req.ProcDelegatedReq) ([]models.Proc, error) {
+	return p.wf.DelegatedProcs(query.EmpID)
+}
+
+// RejectTargets 驳回时可退回的步骤
+func (p procServiceImpl) RejectTargets(query *req.ProcRejectTargetsReq) ([]workflow.RejectTarget, error) {
+	return p.wf.RejectTargets(query.ID, query.EmpID)
+}
+
+// RejectBack 驳回并退回到指定步骤
+func (p procServiceImpl) RejectBack(backReq *req.ProcRejectBackReq) error {
+	return p.wf.RejectBack(backReq.ID, backReq.EmpID, backReq.Content, backReq.ProcessID)
+}
+
+// SignBefore 前加签
+func (p procServiceImpl) SignBefore(signReq *req.ProcSignReq) error {
+	return p.wf.AddSignBefore(signReq.ID, signReq.EmpID, signReq.SignerID, signReq.Reason)
+}
+
+// SignAfter 后加签
+func (p procServiceImpl) SignAfter(signReq *req.ProcSignReq) error {
+	return p.wf.AddSignAfter(signReq.ID, signReq.EmpID, signReq.SignerID, signReq.Reason, signReq.Content)
+}
+
+// Reassign 转办
+func (p procServiceImpl) Reassign(reassignReq *req.ProcReassignReq) error {
+	return p.wf.Reassign(reassignReq.ID, reassignReq.EmpID, reassignReq.ToEmpID, reassignReq.Reason)
+}
+
+// Pass 审批通过，可同时修改本步骤可编辑的表单字段，表单校验未通过时将问题列表随响应返回
+func (p procServiceImpl) Pass(passReq *req.ProcPassReq) error {
+	err := p.wf.PassWithData(passReq.ID, passReq.EmpID, passReq.Content, passReq.Data)
+	var formErrs workflow.FormErrors
+	if errors.As(err, &formErrs) {
+		return response.ParamsValidError.Make("表单校验未通过").MakeData(formErrs)
+	}
+	return err
+}
+
+func NewProcService(db *gorm.DB, wf *workflow.Service) ProcService {
+	return &procServiceImpl{db: db, wf: wf}
+}
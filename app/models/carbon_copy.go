@@ -0,0 +1,25 @@
+package models
+
+// CarbonCopy 抄送设置：步骤通过或流程结束时抄送相关人员，抄送人只能查看流程，不能处理
+type CarbonCopy struct {
+	Model
+	FlowID     uint   `gorm:"column:flow_id;not null;default:0;index;comment:'流程id'" json:"flow_id" form:"flow_id"`
+	ProcessID  uint   `gorm:"column:process_id;not null;default:0;comment:'步骤通过时抄送的步骤id，0为流程结束时抄送'" json:"process_id" form:"process_id"`
+	Type       string `gorm:"column:type;not null;default:'Emp';comment:'抄送人类型：Sys系统自动 Emp指定人员 Dept指定部门'" json:"type" form:"type"`
+	Recipient  string `gorm:"column:recipient;not null;default:'';comment:'抄送人，同审批人设置：Sys时为-1000发起人 -1001发起人部门主管 -1002发起人部门经理，Emp、Dept时为逗号分隔的id'" json:"recipient" form:"recipient"`
+	Expression string `gorm:"column:expression;not null;default:'';comment:'抄送条件表达式，为空时总是抄送'" json:"expression" form:"expression"`
+}
+
+// CarbonCopyRecord 抄送记录，即抄送人“抄送我的”列表
+type CarbonCopyRecord struct {
+	Model
+	EntryID     uint   `gorm:"column:entry_id;not null;default:0;index" json:"entry_id" form:"entry_id"`
+	FlowID      uint   `gorm:"column:flow_id;not null;default:0" json:"flow_id" form:"flow_id"`
+	ProcessID   uint   `gorm:"column:process_id;not null;default:0;comment:'抄送时通过的步骤id，0为流程结束'" json:"process_id" form:"process_id"`
+	ProcessName string `gorm:"column:process_name;not null;default:'';comment:'抄送时通过的步骤名称'" json:"process_name" form:"process_name"`
+	Circle      int    `gorm:"column:circle;not null;default:1" json:"circle" form:"circle"`
+	EmpID       uint   `gorm:"column:emp_id;not null;default:0;index;comment:'抄送人'" json:"emp_id" form:"emp_id"`
+	IsRead      int    `gorm:"column:is_read;not null;default:0;comment:'是否查看'" json:"is_read" form:"is_read"`
+	ReadAt      int64  `gorm:"column:read_at;not null;default:0;comment:'查看时间戳'" json:"read_at" form:"read_at"`
+	Entry       Entry  `gorm:"foreignKey:EntryID" json:"entry"`
+}
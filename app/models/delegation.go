@@ -0,0 +1,14 @@
+package models
+
+// Delegation 审批委托规则：委托期间内，委托人的待办由受托人代为处理
+type Delegation struct {
+	Model
+	EmpID      uint   `gorm:"column:emp_id;not null;default:0;index;comment:'委托人'" json:"emp_id" form:"emp_id"`
+	DelegateID uint   `gorm:"column:delegate_id;not null;default:0;index;comment:'受托人'" json:"delegate_id" form:"delegate_id"`
+	StartTime  int64  `gorm:"column:start_time;not null;default:0;comment:'委托开始时间戳'" json:"start_time" form:"start_time"`
+	EndTime    int64  `gorm:"column:end_time;not null;default:0;comment:'委托结束时间戳'" json:"end_time" form:"end_time"`
+	FlowIds    string `gorm:"column:flow_ids;not null;default:'';comment:'委托范围，逗号分隔的流程id，为空时委托全部流程'" json:"flow_ids" form:"flow_ids"`
+	Remark     string `gorm:"column:remark;not null;default:'';comment:'委托说明'" json:"remark" form:"remark"`
+	Emp        Emp    `gorm:"foreignKey:EmpID" json:"emp"`
+	Delegate   Emp    `gorm:"foreignKey:DelegateID" json:"delegate"`
+}
@@ -2,22 +2,25 @@ package models
 
 type Entry struct {
 	Model
-	Title          string      `gorm:"column:title;not null;default:''" json:"title" form:"title"`
-	FlowID         uint        `gorm:"column:flow_id;not null;default:0" json:"flow_id" form:"flow_id"`
-	EmpID          uint        `gorm:"column:emp_id;not null;default:0" json:"emp_id" form:"emp_id"`
-	ProcessID      uint        `gorm:"column:process_id;not null;default:0" json:"process_id" form:"process_id"`
-	Circle         int         `gorm:"column:circle;not null;default:1" json:"circle" form:"circle"`
-	Status         int         `gorm:"column:status;not_null" json:"status" form:"status"`
-	Pid            int         `gorm:"column:pid;not null;default:0" json:"pid" form:"pid"`
-	EnterProcessID int         `gorm:"column:enter_process_id;not null;default:0" json:"enter_process_id" form:"enter_process_id"`
-	EnterProcID    int         `gorm:"column:enter_proc_id;not null;default:0" json:"enter_proc_id" form:"enter_proc_id"`
-	Child          int         `gorm:"column:child;not null;default:0" json:"child" form:"child"`
-	Flow           Flow        `gorm:"foreignKey:flow_id"` // 关联的Flow
-	Emp            Emp         `gorm:"foreignKey:emp_id"`  // 关联的Emp
-	Procs          []*Proc     // HasMany Proc
-	Process        Process     `gorm:"foreignKey:process_id"` // 关联的Process
-	EntryDatas     []EntryData // HasMany EntryData
-	ParentEntry    *Entry      `gorm:"foreignKey:pid"`              // 关联的父Entry
-	Children       []Entry     `gorm:"foreignKey:pid"`              // HasMany Entry, 级联删除
-	EnterProcess   Process     `gorm:"foreignKey:enter_process_id"` // 关联的进入步骤Process
+	Title           string      `gorm:"column:title;not null;default:''" json:"title" form:"title"`
+	FlowID          uint        `gorm:"column:flow_id;not null;default:0" json:"flow_id" form:"flow_id"`
+	FlowVersionID   uint        `gorm:"column:flow_version_id;not null;default:0;comment:'发起时的流程版本id'" json:"flow_version_id" form:"flow_version_id"`
+	EmpID           uint        `gorm:"column:emp_id;not null;default:0" json:"emp_id" form:"emp_id"`
+	ProcessID       uint        `gorm:"column:process_id;not null;default:0" json:"process_id" form:"process_id"`
+	Circle          int         `gorm:"column:circle;not null;default:1" json:"circle" form:"circle"`
+	Status          EntryStatus `gorm:"column:status;not_null;comment:'0审批中 1已挂起 9通过 -1驳回 -2草稿 -3已撤回 -4已终止'" json:"status" form:"status"`
+	Pid             int         `gorm:"column:pid;not null;default:0" json:"pid" form:"pid"`
+	EnterProcessID  int         `gorm:"column:enter_process_id;not null;default:0" json:"enter_process_id" form:"enter_process_id"`
+	EnterProcID     int         `gorm:"column:enter_proc_id;not null;default:0" json:"enter_proc_id" form:"enter_proc_id"`
+	ChildSeq        int         `gorm:"column:child_seq;not null;default:0;comment:'多实例子流程对应的列表行序号，从0开始'" json:"child_seq" form:"child_seq"`
+	ReturnProcessID int         `gorm:"column:return_process_id;not null;default:0;comment:'退回后处理完成需直接回到的步骤id'" json:"return_process_id" form:"return_process_id"`
+	Child           int         `gorm:"column:child;not null;default:0" json:"child" form:"child"`
+	Flow            Flow        `gorm:"foreignKey:flow_id"` // 关联的Flow
+	Emp             Emp         `gorm:"foreignKey:emp_id"`  // 关联的Emp
+	Procs           []*Proc     // HasMany Proc
+	Process         Process     `gorm:"foreignKey:process_id"` // 关联的Process
+	EntryDatas      []EntryData // HasMany EntryData
+	ParentEntry     *Entry      `gorm:"foreignKey:pid"`              // 关联的父Entry
+	Children        []Entry     `gorm:"foreignKey:pid"`              // HasMany Entry, 级联删除
+	EnterProcess    Process     `gorm:"foreignKey:enter_process_id"` // 关联的进入步骤Process
 }
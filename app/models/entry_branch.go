@@ -0,0 +1,15 @@
+package models
+
+// EntryBranch 并行分支：并行网关拆分出的每条分支，分支各自记录当前所在步骤
+type EntryBranch struct {
+	Model
+	EntryID         uint `gorm:"column:entry_id;not null;default:0;index" json:"entry_id" form:"entry_id"`
+	Circle          int  `gorm:"column:circle;not null;default:1" json:"circle" form:"circle"`
+	ForkProcessID   int  `gorm:"column:fork_process_id;not null;default:0;comment:'拆分网关步骤id'" json:"fork_process_id" form:"fork_process_id"`
+	ForkSeq         int  `gorm:"column:fork_seq;not null;default:0;comment:'拆分批次，同一次拆分出的分支相同'" json:"fork_seq" form:"fork_seq"`
+	ParentID        int  `gorm:"column:parent_id;not null;default:0;comment:'嵌套并行时所在的上级分支id，0为主干'" json:"parent_id" form:"parent_id"`
+	ProcessID       int  `gorm:"column:process_id;not null;default:0;comment:'分支当前步骤id'" json:"process_id" form:"process_id"`
+	JoinProcessID   int  `gorm:"column:join_process_id;not null;default:0;comment:'分支到达的合并网关步骤id'" json:"join_process_id" form:"join_process_id"`
+	ReturnProcessID int  `gorm:"column:return_process_id;not null;default:0;comment:'分支内退回后处理完成需直接回到的步骤id'" json:"return_process_id" form:"return_process_id"`
+	Status          int  `gorm:"column:status;not null;default:0;comment:'0进行中 9已到达合并网关 -2已取消'" json:"status" form:"status"`
+}
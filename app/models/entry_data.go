@@ -1,10 +1,42 @@
 package models
 
+// 表单数据的值类型，按模板字段类型写入对应的类型化列
+const (
+	EntryValueText   = "text"   // 文本，仅 field_value
+	EntryValueNumber = "number" // 数字，num_value
+	EntryValueMoney  = "money"  // 金额，num_value
+	EntryValueDate   = "date"   // 日期时间，time_value 为时间戳
+	EntryValueBool   = "bool"   // 布尔，num_value 为 0 或 1
+	EntryValueList   = "list"   // 列表，field_value 为规范化的 JSON 数组
+	EntryValueEmp    = "emp"    // 员工引用，ref_id
+	EntryValueDept   = "dept"   // 部门引用，ref_id
+	EntryValueTable  = "table"  // 明细表格，field_value 为 JSON 对象数组，各单元格另存于 EntryDataCell
+)
+
 type EntryData struct {
 	Model
-	EntryID     int    `gorm:"column:entry_id;not null;default:0" form:"entry_id" json:"entry_id"`
-	FlowID      int    `gorm:"column:flow_id;not null;default:0" form:"flow_id" json:"flow_id"`
-	FieldName   string `gorm:"column:field_name;not null;default:''" form:"field_name" json:"field_name"`
-	FieldValue  string `gorm:"column:field_value" json:"field_value" form:"field_value" json:"field_value"`
-	FieldRemark string `gorm:"column:field_remark;not null;default:''" form:"field_remark" json:"field_remark"`
+	EntryID     int      `gorm:"column:entry_id;not null;default:0" form:"entry_id" json:"entry_id"`
+	FlowID      int      `gorm:"column:flow_id;not null;default:0;index:idx_entry_data_num,priority:1;index:idx_entry_data_time,priority:1;index:idx_entry_data_ref,priority:1" form:"flow_id" json:"flow_id"`
+	FieldName   string   `gorm:"column:field_name;not null;default:'';size:191;index:idx_entry_data_num,priority:2;index:idx_entry_data_time,priority:2;index:idx_entry_data_ref,priority:2" form:"field_name" json:"field_name"`
+	FieldValue  string   `gorm:"column:field_value" json:"field_value" form:"field_value" json:"field_value"`
+	FieldRemark string   `gorm:"column:field_remark;not null;default:''" form:"field_remark" json:"field_remark"`
+	ValueType   string   `gorm:"column:value_type;not null;default:'';comment:'值类型，为空表示尚未迁移'" form:"value_type" json:"value_type"`
+	NumValue    *float64 `gorm:"column:num_value;type:decimal(30,6);index:idx_entry_data_num,priority:3;comment:'数字、金额、布尔值'" form:"num_value" json:"num_value"`
+	TimeValue   *int64   `gorm:"column:time_value;type:bigint;index:idx_entry_data_time,priority:3;comment:'日期时间戳'" form:"time_value" json:"time_value"`
+	RefID       *int64   `gorm:"column:ref_id;type:bigint;index:idx_entry_data_ref,priority:3;comment:'员工或部门ID'" form:"ref_id" json:"ref_id"`
+}
+
+// EntryDataCell 明细表格的单元格，按列类型写入类型化的值，便于按明细查询
+type EntryDataCell struct {
+	Model
+	EntryID    int      `gorm:"column:entry_id;not null;default:0;index" form:"entry_id" json:"entry_id"`
+	FlowID     int      `gorm:"column:flow_id;not null;default:0;index:idx_entry_data_cell_num,priority:1;index:idx_entry_data_cell_time,priority:1" form:"flow_id" json:"flow_id"`
+	FieldName  string   `gorm:"column:field_name;not null;default:'';size:191;index:idx_entry_data_cell_num,priority:2;index:idx_entry_data_cell_time,priority:2;comment:'明细表格字段'" form:"field_name" json:"field_name"`
+	ColumnName string   `gorm:"column:column_name;not null;default:'';size:191;index:idx_entry_data_cell_num,priority:3;index:idx_entry_data_cell_time,priority:3;comment:'列字段'" form:"column_name" json:"column_name"`
+	RowIndex   int      `gorm:"column:row_index;not null;default:0;comment:'行序号，从0开始'" form:"row_index" json:"row_index"`
+	FieldValue string   `gorm:"column:field_value" form:"field_value" json:"field_value"`
+	ValueType  string   `gorm:"column:value_type;not null;default:''" form:"value_type" json:"value_type"`
+	NumValue   *float64 `gorm:"column:num_value;type:decimal(30,6);index:idx_entry_data_cell_num,priority:4" form:"num_value" json:"num_value"`
+	TimeValue  *int64   `gorm:"column:time_value;type:bigint;index:idx_entry_data_cell_time,priority:4" form:"time_value" json:"time_value"`
+	RefID      *int64   `gorm:"column:ref_id;type:bigint" form:"ref_id" json:"ref_id"`
 }
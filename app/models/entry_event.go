@@ -0,0 +1,19 @@
+package models
+
+// EntryEvent 流程事件日志：流程的每次操作追加一条，只增不改，可按日志重建流程状态
+type EntryEvent struct {
+	Model
+	EntryID       uint   `gorm:"column:entry_id;not null;default:0;index" json:"entry_id" form:"entry_id"`
+	ProcID        uint   `gorm:"column:proc_id;not null;default:0;comment:'相关待办id'" json:"proc_id" form:"proc_id"`
+	Action        string `gorm:"column:action;not null;default:'';comment:'操作类型'" json:"action" form:"action"`
+	ActorID       uint   `gorm:"column:actor_id;not null;default:0;comment:'操作人id，系统操作为0'" json:"actor_id" form:"actor_id"`
+	ActorName     string `gorm:"column:actor_name;not null;default:''" json:"actor_name" form:"actor_name"`
+	FromProcessID int    `gorm:"column:from_process_id;not null;default:0" json:"from_process_id" form:"from_process_id"`
+	ToProcessID   int    `gorm:"column:to_process_id;not null;default:0" json:"to_process_id" form:"to_process_id"`
+	BranchID      int    `gorm:"column:branch_id;not null;default:0;comment:'并行分支id，0为主干'" json:"branch_id" form:"branch_id"`
+	Circle        int    `gorm:"column:circle;not null;default:0" json:"circle" form:"circle"`
+	FromStatus    int    `gorm:"column:from_status;not null;default:0;comment:'流程状态变更事件的原状态'" json:"from_status" form:"from_status"`
+	ToStatus      int    `gorm:"column:to_status;not null;default:0;comment:'流程状态变更事件的新状态'" json:"to_status" form:"to_status"`
+	DataDiff      string `gorm:"column:data_diff;type:text;comment:'表单数据变更，JSON：字段 => [原值, 新值]，新增时原值为null，删除时新值为null'" json:"data_diff" form:"data_diff"`
+	Content       string `gorm:"column:content;not null;default:''" json:"content" form:"content"`
+}
@@ -2,16 +2,22 @@ package models
 
 type Flow struct {
 	Model
-	FlowNo      string       `gorm:"column:flow_no;not null" json:"flow_no" form:"flow_no"`
-	FlowName    string       `gorm:"column:flow_name;not null;default:''" json:"flow_name" form:"flow_name"`
-	TemplateID  int          `gorm:"column:template_id;not null;default:0" json:"template_id" form:"template_id"`
-	Flowchart   string       `gorm:"column:flowchart" json:"flowchart" form:"flowchart"`
-	Jsplumb     string       `gorm:"column:jsplumb;comment:'jsplumb流程图数据'" json:"jsplumb" form:"jsplumb"`
-	TypeID      int          `gorm:"column:type_id;not null;default:0" json:"type_id" form:"type_id"`
-	IsPublish   bool         `gorm:"column:is_publish;not null;default:0" json:"is_publish" form:"is_publish"`
-	IsShow      bool         `gorm:"column:is_show;not null;default:1" json:"is_show" form:"is_show"`
-	Processes   []Process    `gorm:"foreignKey:FlowID"`     // HasMany Process
-	ProcessVars []ProcessVar `gorm:"foreignKey:FlowID"`     // HasMany ProcessVar
-	Template    Template     `gorm:"foreignKey:TemplateID"` // BelongsTo Template
-	Flowtype    Flowtype     `gorm:"foreignKey:TypeID"`     // BelongsTo FlowType
+	FlowNo        string       `gorm:"column:flow_no;not null" json:"flow_no" form:"flow_no"`
+	FlowName      string       `gorm:"column:flow_name;not null;default:''" json:"flow_name" form:"flow_name"`
+	TemplateID    int          `gorm:"column:template_id;not null;default:0" json:"template_id" form:"template_id"`
+	Flowchart     string       `gorm:"column:flowchart" json:"flowchart" form:"flowchart"`
+	Jsplumb       string       `gorm:"column:jsplumb;comment:'jsplumb流程图数据'" json:"jsplumb" form:"jsplumb"`
+	TypeID        int          `gorm:"column:type_id;not null;default:0" json:"type_id" form:"type_id"`
+	IsPublish     bool         `gorm:"column:is_publish;not null;default:0" json:"is_publish" form:"is_publish"`
+	IsShow        bool         `gorm:"column:is_show;not null;default:1" json:"is_show" form:"is_show"`
+	VersionID     uint         `gorm:"column:version_id;not null;default:0;comment:'当前发布版本id'" json:"version_id" form:"version_id"`
+	RecallPolicy  string       `gorm:"column:recall_policy;not null;default:'unhandled';comment:'撤回策略 unhandled当前步骤无人处理时可撤回 running审批中均可撤回 never不可撤回'" json:"recall_policy" form:"recall_policy"`
+	NoAuditor     string       `gorm:"column:no_auditor;not null;default:'error';comment:'步骤未找到审批人时的处理 error报错 skip自动跳过 admin转交流程管理员 superior沿上级部门查找主管 initiator退回发起人'" json:"no_auditor" form:"no_auditor"`
+	AutoApprove   string       `gorm:"column:auto_approve;not null;default:'';comment:'自动通过规则，逗号分隔 initiator审批人为发起人 approved本轮已审批通过 consecutive与上一步骤审批人相同'" json:"auto_approve" form:"auto_approve"`
+	AdminID       int          `gorm:"column:admin_id;not null;default:0;comment:'流程管理员员工id'" json:"admin_id" form:"admin_id"`
+	TitleTemplate string       `gorm:"column:title_template;not null;default:'';comment:'标题模板，{}内为表达式，如 报销{sum(items.amount)}元，发起时未填写标题则按模板生成'" json:"title_template" form:"title_template"`
+	Processes     []Process    `gorm:"foreignKey:FlowID"`     // HasMany Process
+	ProcessVars   []ProcessVar `gorm:"foreignKey:FlowID"`     // HasMany ProcessVar
+	Template      Template     `gorm:"foreignKey:TemplateID"` // BelongsTo Template
+	Flowtype      Flowtype     `gorm:"foreignKey:TypeID"`     // BelongsTo FlowType
 }
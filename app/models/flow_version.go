@@ -0,0 +1,10 @@
+package models
+
+// FlowVersion 流程发布版本，保存发布时的流程图、表单模板及插件配置快照，发布后不可修改
+type FlowVersion struct {
+	Model
+	FlowID   uint   `gorm:"column:flow_id;not null;default:0;uniqueIndex:idx_flow_version;comment:'流程id'" json:"flow_id"`
+	Version  int    `gorm:"column:version;not null;default:1;uniqueIndex:idx_flow_version;comment:'版本号'" json:"version"`
+	Remark   string `gorm:"column:remark;not null;default:'';comment:'发布说明'" json:"remark"`
+	Snapshot string `gorm:"column:snapshot;comment:'流程定义快照json'" json:"snapshot,omitempty"`
+}
@@ -1,14 +1,13 @@
 package models
 
 import (
-	"github.com/dromara/carbon/v2"
 	"gorm.io/gorm"
 )
 
 type Model struct {
-	ID        uint             `gorm:"primary_key" json:"id"`
-	CreatedAt carbon.Timestamp `json:"created_at" gorm:"type:bigint;column:created_at;autoCreateTime"`
-	UpdatedAt carbon.Timestamp `json:"updated_at" gorm:"type:bigint;column:updated_at;autoUpdateTime"`
+	ID        uint  `gorm:"primary_key" json:"id"`
+	CreatedAt int64 `json:"created_at" gorm:"type:bigint;column:created_at;autoCreateTime"`
+	UpdatedAt int64 `json:"updated_at" gorm:"type:bigint;column:updated_at;autoUpdateTime"`
 }
 
 type SoftDelete struct {
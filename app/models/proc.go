@@ -4,26 +4,35 @@ import "github.com/dromara/carbon/v2"
 
 type Proc struct {
 	Model
-	EntryID     uint              `gorm:"column:entry_id;not null" json:"entry_id" form:"entry_id"`
-	FlowID      int               `gorm:"column:flow_id;not null;comment:'流程id'" json:"flow_id" form:"flow_id"`
-	ProcessID   int               `gorm:"column:process_id;not null;comment:'当前步骤'" json:"process_id" form:"process_id"`
-	ProcessName string            `gorm:"column:process_name;not null;default:'';comment:'当前步骤名称'" json:"process_name" form:"process_name"`
-	EmpID       int               `gorm:"column:emp_id;not null;comment:'审核人'" json:"emp_id" form:"emp_id"`
-	EmpName     string            `gorm:"column:emp_name;default:null;comment:'审核人名称'" json:"emp_name" form:"emp_name"`
-	DeptName    string            `gorm:"column:dept_name;default:null;comment:'审核人部门名称'" json:"dept_name" form:"dept_name"`
-	AuditorID   int               `gorm:"column:auditor_id;not null;default:0;comment:'具体操作人'" json:"auditor_id" form:"auditor_id"`
-	AuditorName string            `gorm:"column:auditor_name;not null;default:'';comment:'操作人名称'" json:"auditor_name" form:"auditor_name"`
-	AuditorDept string            `gorm:"column:auditor_dept;not null;default:'';comment:'操作人部门'" json:"auditor_dept" form:"auditor_dept"`
-	Status      int               `gorm:"column:status;not null;comment:'当前处理状态 0待处理 9通过 -1驳回\n0：处理中\n-1：驳回\n9：会签'" json:"status" form:"status"`
-	Content     string            `gorm:"column:content;default:null;comment:'批复内容'" json:"content" form:"content"`
-	IsRead      int               `gorm:"column:is_read;not null;default:0;comment:'是否查看'" json:"is_read" form:"is_read"`
-	IsReal      bool              `gorm:"column:is_real;not null;default:1;comment:'审核人和操作人是否同一人'" json:"is_real" form:"is_real"`
-	Circle      int               `gorm:"column:circle;not null;default:1" json:"circle" form:"circle"`
-	Beizhu      string            `gorm:"column:beizhu;type:text;comment:'备注'" json:"beizhu" form:"beizhu"`
-	Concurrence *carbon.Timestamp `gorm:"column:concurrence;comment:'并行查找解决字段， 部门 角色 指定 分组用'" json:"concurrence" form:"concurrence"`
-	Emp         Emp               `gorm:"foreignKey:EmpID"`                                                  // 关联的Emp
-	Entry       Entry             `gorm:"foreignKey:EntryID"`                                                // 关联的Entry
-	Process     Process           `gorm:"foreignKey:ProcessID"`                                              // 关联的Process
-	Flow        Flow              `gorm:"foreignKey:FlowID"`                                                 // 关联的Flow
-	SubProcs    []Proc            `gorm:"foreignkey:EntryID;constraint:OnUpdate:CASCADE,OnDelete:NO ACTION"` // HasMany Proc
+	EntryID       uint              `gorm:"column:entry_id;not null" json:"entry_id" form:"entry_id"`
+	FlowID        int               `gorm:"column:flow_id;not null;comment:'流程id'" json:"flow_id" form:"flow_id"`
+	ProcessID     int               `gorm:"column:process_id;not null;comment:'当前步骤'" json:"process_id" form:"process_id"`
+	ProcessName   string            `gorm:"column:process_name;not null;default:'';comment:'当前步骤名称'" json:"process_name" form:"process_name"`
+	EmpID         int               `gorm:"column:emp_id;not null;comment:'审核人'" json:"emp_id" form:"emp_id"`
+	EmpName       string            `gorm:"column:emp_name;default:null;comment:'审核人名称'" json:"emp_name" form:"emp_name"`
+	DeptName      string            `gorm:"column:dept_name;default:null;comment:'审核人部门名称'" json:"dept_name" form:"dept_name"`
+	AuditorID     int               `gorm:"column:auditor_id;not null;default:0;comment:'具体操作人'" json:"auditor_id" form:"auditor_id"`
+	AuditorName   string            `gorm:"column:auditor_name;not null;default:'';comment:'操作人名称'" json:"auditor_name" form:"auditor_name"`
+	AuditorDept   string            `gorm:"column:auditor_dept;not null;default:'';comment:'操作人部门'" json:"auditor_dept" form:"auditor_dept"`
+	Status        ProcStatus        `gorm:"column:status;not null;comment:'当前处理状态 0待处理 1等待加签人处理 9通过 -1驳回 -2取消\n0：处理中\n-1：驳回\n9：会签'" json:"status" form:"status"`
+	Content       string            `gorm:"column:content;default:null;comment:'批复内容'" json:"content" form:"content"`
+	IsRead        int               `gorm:"column:is_read;not null;default:0;comment:'是否查看'" json:"is_read" form:"is_read"`
+	IsReal        bool              `gorm:"column:is_real;not null;default:1;comment:'审核人和操作人是否同一人'" json:"is_real" form:"is_real"`
+	Circle        int               `gorm:"column:circle;not null;default:1" json:"circle" form:"circle"`
+	Beizhu        string            `gorm:"column:beizhu;type:text;comment:'备注'" json:"beizhu" form:"beizhu"`
+	Concurrence   *carbon.Timestamp `gorm:"column:concurrence;comment:'并行查找解决字段， 部门 角色 指定 分组用'" json:"concurrence" form:"concurrence"`
+	Deadline      int64             `gorm:"column:deadline;not null;default:0;index;comment:'处理期限时间戳，0为不限'" json:"deadline" form:"deadline"`
+	OriginEmpID   int               `gorm:"column:origin_emp_id;not null;default:0;comment:'原审核人，超时转交时记录'" json:"origin_emp_id" form:"origin_emp_id"`
+	OriginEmpName string            `gorm:"column:origin_emp_name;not null;default:'';comment:'原审核人名称'" json:"origin_emp_name" form:"origin_emp_name"`
+	DelegateID    int               `gorm:"column:delegate_id;not null;default:0;index;comment:'受托人，审核人委托他人代为处理时记录'" json:"delegate_id" form:"delegate_id"`
+	SignType      string            `gorm:"column:sign_type;not null;default:'';comment:'加签方式：before前加签 after后加签 transfer转办'" json:"sign_type" form:"sign_type"`
+	SignFromID    int               `gorm:"column:sign_from_id;not null;default:0;index;comment:'发起加签或转办的待办id'" json:"sign_from_id" form:"sign_from_id"`
+	SignReason    string            `gorm:"column:sign_reason;not null;default:'';comment:'加签或转办原因'" json:"sign_reason" form:"sign_reason"`
+	Fallback      string            `gorm:"column:fallback;not null;default:'';comment:'未找到审批人时按兜底策略处理的说明'" json:"fallback" form:"fallback"`
+	BranchID      int               `gorm:"column:branch_id;not null;default:0;index;comment:'所在并行分支id，0为主干'" json:"branch_id" form:"branch_id"`
+	Emp           Emp               `gorm:"foreignKey:EmpID"`                                                  // 关联的Emp
+	Entry         Entry             `gorm:"foreignKey:EntryID"`                                                // 关联的Entry
+	Process       Process           `gorm:"foreignKey:ProcessID"`                                              // 关联的Process
+	Flow          Flow              `gorm:"foreignKey:FlowID"`                                                 // 关联的Flow
+	SubProcs      []Proc            `gorm:"foreignkey:EntryID;constraint:OnUpdate:CASCADE,OnDelete:NO ACTION"` // HasMany Proc
 }
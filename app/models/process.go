@@ -1,24 +1,42 @@
 package models
 
+import (
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/types"
+)
+
 type Process struct {
 	Model
-	FlowID           int       `gorm:"column:flow_id;not null;default:0;comment:'流程id'" json:"flow_id"`
-	ProcessName      string    `gorm:"column:process_name;not null;default:'';comment:'步骤名称'" json:"process_name"`
-	LimitTime        int       `gorm:"column:limit_time;not null;default:0;comment:'限定时间,单位秒'" json:"limit_time"`
-	Type             string    `gorm:"column:type;not null;default:'operation';comment:'流程图显示操作框类型'" json:"type"`
-	Icon             string    `gorm:"column:icon;default:'';comment:'流程图显示图标'" json:"icon,omitempty"`
-	ProcessTo        string    `gorm:"column:process_to;not null;default:''" json:"process_to"`
-	Style            string    `gorm:"column:style;type:text;" json:"style,omitempty"`
-	StyleColor       string    `gorm:"column:style_color;not null;default:'#78a300'" json:"style_color"`
-	StyleHeight      int       `gorm:"column:style_height;not null;default:30" json:"style_height"`
-	StyleWidth       int       `gorm:"column:style_width;not null;default:30" json:"style_width"`
-	PositionLeft     string    `gorm:"column:position_left;not null;default:'100px'" json:"position_left"`
-	PositionTop      string    `gorm:"column:position_top;not null;default:'200px'" json:"position_top"`
-	Position         int       `gorm:"column:position;not null;default:1;comment:'步骤位置：1正常步骤2：转入子流程0：第一步 当为2时 child_flow_id child_after child_back_process 可设置'" json:"position"`
-	ChildFlowID      int       `gorm:"column:child_flow_id;not null;default:0;comment:'子流程id'" json:"child_flow_id"`
-	ChildAfter       int       `gorm:"column:child_after;not null;default:2;comment:'子流程结束后 1.同时结束父流程 2.返回父流程'" json:"child_after"`
-	ChildBackProcess int       `gorm:"column:child_back_process;not null;default:0;comment:'子流程结束后返回父流程进程'" json:"child_back_process"`
-	Description      string    `gorm:"column:description;not null;default:'';comment:'步骤描述'" json:"description"`
-	ProcessVars      []Process `gorm:"many2many:process_vars;" json:"process_vars"`
+	FlowID           int              `gorm:"column:flow_id;not null;default:0;comment:'流程id'" json:"flow_id"`
+	ProcessName      string           `gorm:"column:process_name;not null;default:'';comment:'步骤名称'" json:"process_name"`
+	LimitTime        int              `gorm:"column:limit_time;not null;default:0;comment:'限定时间,单位秒'" json:"limit_time"`
+	Type             string           `gorm:"column:type;not null;default:'operation';comment:'流程图显示操作框类型'" json:"type"`
+	Icon             string           `gorm:"column:icon;default:'';comment:'流程图显示图标'" json:"icon,omitempty"`
+	ProcessTo        string           `gorm:"column:process_to;not null;default:''" json:"process_to"`
+	Style            string           `gorm:"column:style;type:text;" json:"style,omitempty"`
+	StyleColor       string           `gorm:"column:style_color;not null;default:'#78a300'" json:"style_color"`
+	StyleHeight      int              `gorm:"column:style_height;not null;default:30" json:"style_height"`
+	StyleWidth       int              `gorm:"column:style_width;not null;default:30" json:"style_width"`
+	PositionLeft     string           `gorm:"column:position_left;not null;default:'100px'" json:"position_left"`
+	PositionTop      string           `gorm:"column:position_top;not null;default:'200px'" json:"position_top"`
+	Position         int              `gorm:"column:position;not null;default:1;comment:'步骤位置：1正常步骤2：转入子流程0：第一步 当为2时 child_flow_id child_after child_back_process 可设置'" json:"position"`
+	ChildFlowID      int              `gorm:"column:child_flow_id;not null;default:0;comment:'子流程id'" json:"child_flow_id"`
+	ChildAfter       int              `gorm:"column:child_after;not null;default:2;comment:'子流程结束后 1.同时结束父流程 2.返回父流程'" json:"child_after"`
+	ChildBackProcess int              `gorm:"column:child_back_process;not null;default:0;comment:'子流程结束后返回父流程进程'" json:"child_back_process"`
+	ChildListField   string           `gorm:"column:child_list_field;not null;default:'';comment:'多实例子流程：按该列表字段的每一行发起一个子流程，为空时只发起一个'" json:"child_list_field"`
+	ChildMode        string           `gorm:"column:child_mode;not null;default:'parallel';comment:'多实例子流程执行方式：parallel同时发起 sequential逐个发起'" json:"child_mode"`
+	ChildQuorum      int              `gorm:"column:child_quorum;not null;default:0;comment:'多实例子流程需通过的数量，0为全部'" json:"child_quorum"`
+	ChildResultField string           `gorm:"column:child_result_field;not null;default:'';comment:'多实例子流程结果汇总到父流程的字段，为空时为列表字段加_result'" json:"child_result_field"`
+	Description      string           `gorm:"column:description;not null;default:'';comment:'步骤描述'" json:"description"`
+	ApproveMode      string           `gorm:"column:approve_mode;not null;default:'any';comment:'多人审批方式：any或签 all会签 percent按比例 count按人数 sequential依次审批'" json:"approve_mode"`
+	ApproveThreshold int              `gorm:"column:approve_threshold;not null;default:0;comment:'通过条件：percent时为通过比例1-100，count时为通过人数'" json:"approve_threshold"`
+	RejectPolicy     string           `gorm:"column:reject_policy;not null;default:'any';comment:'驳回策略：any任一人驳回即驳回 unreachable剩余人数无法满足通过条件时驳回'" json:"reject_policy"`
+	TimeoutAction    string           `gorm:"column:timeout_action;not null;default:'';comment:'超时处理：remind提醒 escalate_director转交部门主管 escalate_manager转交部门经理 approve自动通过 reject自动驳回 jump跳转至指定步骤'" json:"timeout_action"`
+	TimeoutProcess   int              `gorm:"column:timeout_process;not null;default:0;comment:'超时跳转的步骤id'" json:"timeout_process"`
+	RejectReturn     string           `gorm:"column:reject_return;not null;default:'resume';comment:'退回后的流转方式：resume从退回的步骤重新流转 direct退回的步骤处理后直接回到本步骤'" json:"reject_return"`
+	GatewayType      string           `gorm:"column:gateway_type;not null;default:'';comment:'网关类型：空为审批步骤 fork并行拆分 join并行合并'" json:"gateway_type"`
+	JoinThreshold    int              `gorm:"column:join_threshold;not null;default:0;comment:'并行合并所需完成的分支数，0为全部分支'" json:"join_threshold"`
+	NoAuditor        string           `gorm:"column:no_auditor;not null;default:'';comment:'未找到审批人时的处理，为空时按流程设置：error报错 skip自动跳过 admin转交流程管理员 superior沿上级部门查找主管 initiator退回发起人'" json:"no_auditor"`
+	FieldPerms       types.FieldPerms `gorm:"column:field_perms;type:text;comment:'表单字段权限：字段 => hidden隐藏 readonly只读 editable可编辑 required本步骤必填，未配置时第一步可编辑、其余步骤只读'" json:"field_perms"`
+	ProcessVars      []Process        `gorm:"many2many:process_vars;" json:"process_vars"`
 	Flow             Flow
 }
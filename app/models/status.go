@@ -0,0 +1,67 @@
+package models
+
+// EntryStatus 流程实例状态
+type EntryStatus int
+
+const (
+	EntryStatusRunning    EntryStatus = 0  // 审批中
+	EntryStatusSuspended  EntryStatus = 1  // 已挂起，待办暂停处理
+	EntryStatusCompleted  EntryStatus = 9  // 已通过
+	EntryStatusRejected   EntryStatus = -1 // 已驳回
+	EntryStatusDraft      EntryStatus = -2 // 草稿：退回发起人修改后重新提交
+	EntryStatusWithdrawn  EntryStatus = -3 // 发起人已撤回，可重新提交
+	EntryStatusTerminated EntryStatus = -4 // 已终止
+)
+
+var entryStatusNames = map[EntryStatus]string{
+	EntryStatusRunning:    "审批中",
+	EntryStatusSuspended:  "已挂起",
+	EntryStatusCompleted:  "已通过",
+	EntryStatusRejected:   "已驳回",
+	EntryStatusDraft:      "草稿",
+	EntryStatusWithdrawn:  "已撤回",
+	EntryStatusTerminated: "已终止",
+}
+
+func (s EntryStatus) String() string {
+	if name, ok := entryStatusNames[s]; ok {
+		return name
+	}
+	return "未知状态"
+}
+
+// ProcStatus 待办状态
+type ProcStatus int
+
+const (
+	ProcStatusPending   ProcStatus = 0  // 待处理
+	ProcStatusSuspended ProcStatus = 1  // 已加签，等待加签人处理
+	ProcStatusPassed    ProcStatus = 9  // 已通过
+	ProcStatusRejected  ProcStatus = -1 // 已驳回
+	ProcStatusCancelled ProcStatus = -2 // 已取消，无需处理
+)
+
+var procStatusNames = map[ProcStatus]string{
+	ProcStatusPending:   "待处理",
+	ProcStatusSuspended: "等待加签",
+	ProcStatusPassed:    "已通过",
+	ProcStatusRejected:  "已驳回",
+	ProcStatusCancelled: "已取消",
+}
+
+func (s ProcStatus) String() string {
+	if name, ok := procStatusNames[s]; ok {
+		return name
+	}
+	return "未知状态"
+}
+
+// StateTransition 状态变更记录：流程或待办的每次状态变更追加一条，只增不改
+type StateTransition struct {
+	Model
+	EntryID    uint   `gorm:"column:entry_id;not null;default:0;index" json:"entry_id" form:"entry_id"`
+	ProcID     uint   `gorm:"column:proc_id;not null;default:0;index;comment:'待办状态变更时为待办id，流程状态变更时为0'" json:"proc_id" form:"proc_id"`
+	FromStatus int    `gorm:"column:from_status;not null;default:0" json:"from_status" form:"from_status"`
+	ToStatus   int    `gorm:"column:to_status;not null;default:0" json:"to_status" form:"to_status"`
+	Reason     string `gorm:"column:reason;not null;default:'';comment:'变更原因'" json:"reason" form:"reason"`
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/hulutech-web/workflow-engine/core/logging"
 	"github.com/hulutech-web/workflow-engine/core/orm"
 	"github.com/hulutech-web/workflow-engine/core/queue"
+	"github.com/hulutech-web/workflow-engine/core/workflow"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -28,6 +29,8 @@ var Module = fx.Options(
 	queue.Module,
 	// 数据库模块
 	orm.Module,
+	// 工作流模块
+	workflow.Module,
 	// http模块
 	http.Module,
 
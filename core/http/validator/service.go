@@ -1,6 +1,7 @@
 package validator
 
 import (
+	"fmt"
 	"github.com/go-playground/locales/zh_Hans_CN"
 	unTrans "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
@@ -8,6 +9,8 @@ import (
 	valiRules "github.com/hulutech-web/workflow-engine/pkg/validate"
 	"go.uber.org/zap"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
 type Service struct {
@@ -70,3 +73,61 @@ func (s *Service) Validate(obj interface{}) (msg []string) {
 	}
 	return msg
 }
+
+// HasTag 是否为已注册的单条校验规则名，如 email、phone
+func (s *Service) HasTag(tag string) (ok bool) {
+	if tag == "" || strings.ContainsAny(tag, ",|=") {
+		return false
+	}
+	// 未注册的规则在解析时 panic
+	defer func() {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	_ = s.instance.Var(nil, tag)
+	return true
+}
+
+// FieldRule 动态字段的校验规则，Tag 为 validator 规则串，如 required,max=10
+type FieldRule struct {
+	Name  string
+	Label string
+	Tag   string
+}
+
+// ValidateFields 按规则校验动态字段，返回字段名到错误提示的映射，每个字段只返回第一条错误
+func (s *Service) ValidateFields(values map[string]interface{}, rules []FieldRule) map[string]string {
+	errs := make(map[string]string)
+	for _, rule := range rules {
+		if rule.Tag == "" {
+			continue
+		}
+		if msg := s.validateField(values[rule.Name], rule); msg != "" {
+			errs[rule.Name] = msg
+		}
+	}
+	return errs
+}
+
+// validateField 以单字段结构体承载值，借助 label 标签复用已注册的翻译
+func (s *Service) validateField(value interface{}, rule FieldRule) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg = fmt.Sprintf("%s的校验规则[%s]无法识别", rule.Label, rule.Tag)
+		}
+	}()
+	typ := reflect.StructOf([]reflect.StructField{{
+		Name: "Value",
+		Type: reflect.TypeOf((*interface{})(nil)).Elem(),
+		Tag:  reflect.StructTag(fmt.Sprintf("label:%s validate:%s", strconv.Quote(rule.Label), strconv.Quote(rule.Tag))),
+	}})
+	obj := reflect.New(typ)
+	if value != nil {
+		obj.Elem().Field(0).Set(reflect.ValueOf(value))
+	}
+	if msgs := s.Validate(obj.Interface()); len(msgs) > 0 {
+		return msgs[0]
+	}
+	return ""
+}
@@ -2,6 +2,7 @@ package orm
 
 import (
 	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow/official_plugin"
 	"gorm.io/gorm"
 )
 
@@ -21,17 +22,28 @@ func dst() []interface{} {
 		&models.AuthMenu{},
 		&models.AuthRole{},
 		&models.AuthPerm{},
+		models.CarbonCopy{},
+		models.CarbonCopyRecord{},
 		models.Dept{},
+		models.Delegation{},
 		models.Emp{},
 		models.Entry{},
+		models.EntryBranch{},
+		models.EntryEvent{},
 		models.EntryData{},
+		models.EntryDataCell{},
 		models.Flow{},
 		models.Flowlink{},
+		models.FlowVersion{},
 		models.Flowtype{},
 		models.Template{},
 		models.Proc{},
 		models.Process{},
 		models.ProcessVar{},
+		models.StateTransition{},
 		models.TemplateForm{},
+		official_plugin.Plugin{},
+		official_plugin.FlowPlugin{},
+		official_plugin.PluginConfig{},
 	}
 }
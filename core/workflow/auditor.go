@@ -0,0 +1,103 @@
+package workflow
+
+import (
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// processAuditors 查找步骤的审批人员工
+func (s *Service) processAuditors(t *flowTx, entry *models.Entry, g *flowGraph, processID int) ([]models.Emp, error) {
+	return s.linkAuditors(t, &AuditorContext{DB: t.DB, Entry: entry}, g.auditorLinks(uint(processID)))
+}
+
+// linkAuditors 按审批人设置查找审批人员工
+func (s *Service) linkAuditors(t *flowTx, ctx *AuditorContext, links []models.Flowlink) ([]models.Emp, error) {
+	auditorIds, err := s.getProcessAuditorIds(ctx, links)
+	if err != nil {
+		return nil, err
+	}
+	return orderedEmps(t, auditorIds)
+}
+
+// orderedEmps 查找员工（含所在部门），按 ids 中的顺序排列
+func orderedEmps(t *flowTx, auditorIds []int) ([]models.Emp, error) {
+	var auditors []models.Emp
+	if len(auditorIds) == 0 {
+		return auditors, nil
+	}
+	if err := t.Preload("Dept").Where("id IN (?)", auditorIds).Find(&auditors).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	// 按审批人设置中的顺序排列，依次审批时即为审批顺序
+	order := make(map[int]int, len(auditorIds))
+	for i, id := range auditorIds {
+		order[id] = i
+	}
+	sort.SliceStable(auditors, func(i, j int) bool {
+		return order[int(auditors[i].ID)] < order[int(auditors[j].ID)]
+	})
+	return auditors, nil
+}
+
+// getProcessAuditorIds 按步骤的审批人设置解析审批人员工ID：设置了旧版系统自动（Sys）时只按该设置，
+// 否则合并全部审批人设置
+func (s *Service) getProcessAuditorIds(ctx *AuditorContext, links []models.Flowlink) ([]int, error) {
+	for _, l := range links {
+		if l.Type == AuditorSys {
+			links = []models.Flowlink{l}
+			break
+		}
+	}
+	var auditorIds []int
+	for _, l := range links {
+		ids, err := resolveEmpIds(ctx, l.Type, l.Auditor)
+		if err != nil {
+			return nil, err
+		}
+		auditorIds = append(auditorIds, ids...)
+	}
+	//	对auditor_ids去重
+	return uniqueSlice(auditorIds), nil
+}
+
+// resolveEmpIds 按人员类型查找已注册的解析器解析员工ID
+func resolveEmpIds(ctx *AuditorContext, linkType string, value string) ([]int, error) {
+	r, ok := lookupAuditorResolver(linkType)
+	if !ok {
+		return nil, fmt.Errorf("人员类型[%s]未注册", linkType)
+	}
+	return r.Resolve(ctx, value)
+}
+
+// splitIds 拆分逗号分隔的ID列表
+func splitIds(str string) []int {
+	var ids []int
+	for _, s := range strings.Split(str, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || id <= 0 {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// 辅助函数，从slice中去重
+func uniqueSlice(slice []int) []int {
+	seen := make(map[int]bool)
+	result := []int{}
+
+	for _, value := range slice {
+		if value <= 0 {
+			continue
+		}
+		if _, ok := seen[value]; !ok {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
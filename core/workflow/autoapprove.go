@@ -0,0 +1,123 @@
+package workflow
+
+import (
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"strings"
+)
+
+// 自动通过规则，流程可同时设置多条，以逗号分隔
+const (
+	AutoApproveInitiator   = "initiator"   // 审批人为发起人
+	AutoApproveApproved    = "approved"    // 审批人本轮已审批通过过其他步骤
+	AutoApproveConsecutive = "consecutive" // 审批人与上一步骤的审批人相同
+)
+
+// autoApproveRules 流程设置的自动通过规则
+func autoApproveRules(flow models.Flow) map[string]bool {
+	rules := make(map[string]bool)
+	for _, rule := range strings.Split(flow.AutoApprove, ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules[rule] = true
+		}
+	}
+	return rules
+}
+
+// autoApprove 按流程的自动通过规则，由系统代审批人通过同一批次中符合规则的待办，处理记录与人工审批相同
+func (s *Service) autoApprove(t *flowTx, entry *models.Entry, g *flowGraph, process models.Process, branchID int) error {
+	rules := autoApproveRules(g.Flow)
+	if len(rules) == 0 {
+		return nil
+	}
+	var procs []models.Proc
+	err := t.Where("entry_id=?", entry.ID).
+		Where("process_id=?", process.ID).
+		Where("circle=?", entry.Circle).
+		Where("branch_id=?", branchID).
+		Where("sign_from_id=?", 0).
+		Where("status=?", models.ProcStatusPending).
+		Order("id asc").Find(&procs).Error
+	if err != nil {
+		return fmt.Errorf("数据库查询错误: %v", err)
+	}
+	for _, proc := range procs {
+		content, err := s.autoApproveReason(t, entry, g, rules, proc)
+		if err != nil {
+			return err
+		}
+		if content == "" {
+			continue
+		}
+		// 前一个待办自动通过后步骤可能已经通过，同批次待办已一并处理
+		var current models.Proc
+		if err = t.First(&current, proc.ID).Error; err != nil {
+			return fmt.Errorf("数据库查询错误: %v", err)
+		}
+		if current.Status != models.ProcStatusPending {
+			continue
+		}
+		var emp models.Emp
+		t.Preload("Dept").First(&emp, proc.EmpID)
+		current.Entry = *entry
+		event := procEvent(EventAutoApprove, current, content)
+		event.ActorID, event.ActorName = emp.ID, emp.Name
+		if err = s.logEvent(t, entry, event); err != nil {
+			return err
+		}
+		if err = s.approve(t, g, current, emp, content, false); err != nil {
+			return err
+		}
+		t.afterCommit(func() {
+			_ = s.execPlugin(current, emp)
+		})
+	}
+	return nil
+}
+
+// autoApproveReason 待办符合的自动通过规则，返回系统生成的审批意见，不符合时为空
+func (s *Service) autoApproveReason(t *flowTx, entry *models.Entry, g *flowGraph, rules map[string]bool, proc models.Proc) (string, error) {
+	if rules[AutoApproveInitiator] && uint(proc.EmpID) == entry.EmpID {
+		return "审批人为发起人，系统自动通过", nil
+	}
+	if !rules[AutoApproveApproved] && !rules[AutoApproveConsecutive] {
+		return "", nil
+	}
+	// 本轮审批人本人的审批通过记录，第一步骤为发起人提交，系统代为处理的记录（超时、自动通过等）不计入
+	var history []models.Proc
+	err := t.Where("entry_id=?", entry.ID).
+		Where("circle=?", entry.Circle).
+		Where("status=?", models.ProcStatusPassed).
+		Where("is_real=?", true).
+		Where("id < ?", proc.ID).
+		Order("id desc").Find(&history).Error
+	if err != nil {
+		return "", fmt.Errorf("数据库查询错误: %v", err)
+	}
+	starts := make(map[int]bool)
+	for _, p := range g.starts() {
+		starts[int(p.ID)] = true
+	}
+	previous := 0
+	for _, h := range history {
+		if starts[h.ProcessID] || h.ProcessID == proc.ProcessID {
+			continue
+		}
+		if h.BranchID != proc.BranchID && h.BranchID != 0 {
+			continue
+		}
+		if previous == 0 {
+			previous = h.ProcessID
+		}
+		if h.AuditorID != proc.EmpID {
+			continue
+		}
+		if rules[AutoApproveConsecutive] && h.ProcessID == previous {
+			return "审批人与上一步骤审批人相同，系统自动通过", nil
+		}
+		if rules[AutoApproveApproved] {
+			return "审批人本轮已审批通过，系统自动通过", nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,73 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// autoApproveFlow 按 auditors 依次设置审批步骤，rules 为流程的自动通过规则
+func autoApproveFlow(e *testEnv, rules string, auditors ...string) (*models.Entry, []uint) {
+	flowID := e.flow("autoapprove")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("auto_approve", rules).Error)
+	prev := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	steps := make([]uint, 0, len(auditors))
+	for _, auditor := range auditors {
+		id := e.step(flowID, models.Process{ProcessName: "审批" + auditor}, auditor, false)
+		e.link(flowID, prev, int(id), "")
+		steps = append(steps, id)
+		prev = id
+	}
+	e.link(flowID, prev, -1, "")
+	return e.start(flowID, nil), steps
+}
+
+// 与上一步骤审批人相同时系统代为通过，记录为非本人处理并写入事件日志
+func TestAutoApproveConsecutive(t *testing.T) {
+	e := newTestEnv(t)
+	entry, steps := autoApproveFlow(e, AutoApproveConsecutive, "2", "2", "3")
+	e.pass(entry.ID, empBob)
+	if e.pending(entry.ID, empCarol) == 0 {
+		t.Fatalf("第二步应自动通过；待办 %s", e.procs(entry.ID))
+	}
+	var proc models.Proc
+	e.must(e.db.Where("entry_id=?", entry.ID).Where("process_id=?", steps[1]).First(&proc).Error)
+	if proc.Status != models.ProcStatusPassed || proc.IsReal || proc.Content == "" {
+		t.Fatalf("自动通过的待办应为系统处理并记录原因，实际 %+v", proc)
+	}
+	var count int64
+	e.db.Model(&models.EntryEvent{}).Where("entry_id=?", entry.ID).Where("action=?", EventAutoApprove).Where("proc_id=?", proc.ID).Count(&count)
+	if count != 1 {
+		t.Fatal("事件日志应记录自动通过")
+	}
+}
+
+// 本轮已通过过其他步骤的审批人自动通过；仅要求连续时不自动通过
+func TestAutoApproveApproved(t *testing.T) {
+	for _, c := range []struct {
+		rules string
+		auto  bool
+	}{
+		{AutoApproveApproved, true},
+		{AutoApproveConsecutive, false},
+	} {
+		t.Run(c.rules, func(t *testing.T) {
+			e := newTestEnv(t)
+			entry, _ := autoApproveFlow(e, c.rules, "2", "3", "2")
+			e.pass(entry.ID, empBob)
+			e.pass(entry.ID, empCarol)
+			if c.auto {
+				e.expectStatus(entry.ID, models.EntryStatusCompleted)
+			} else if e.pending(entry.ID, empBob) == 0 {
+				t.Fatalf("不应自动通过；待办 %s", e.procs(entry.ID))
+			}
+		})
+	}
+}
+
+// 审批人为发起人时自动通过
+func TestAutoApproveInitiator(t *testing.T) {
+	e := newTestEnv(t)
+	entry, _ := autoApproveFlow(e, AutoApproveInitiator, "1")
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
@@ -0,0 +1,968 @@
+package workflow
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow/expression"
+	"github.com/hulutech-web/workflow-engine/core/workflow/official_plugin"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
+	"strings"
+)
+
+// BPMN 2.0 命名空间，wf 为本引擎的扩展属性
+const (
+	bpmnModelNS = "http://www.omg.org/spec/BPMN/20100524/MODEL"
+	bpmnXsiNS   = "http://www.w3.org/2001/XMLSchema-instance"
+	bpmnWfNS    = "https://github.com/hulutech-web/workflow-engine/bpmn"
+)
+
+// BPMN 元素名称
+const (
+	bpmnStartEvent       = "startEvent"
+	bpmnEndEvent         = "endEvent"
+	bpmnUserTask         = "userTask"
+	bpmnCallActivity     = "callActivity"
+	bpmnExclusiveGateway = "exclusiveGateway"
+	bpmnInclusiveGateway = "inclusiveGateway"
+	bpmnParallelGateway  = "parallelGateway"
+)
+
+// bpmnDefinitions BPMN 文档根元素，只支持包含一个流程
+type bpmnDefinitions struct {
+	XMLName         xml.Name      `xml:"definitions"`
+	Xmlns           string        `xml:"xmlns,attr,omitempty"`
+	XmlnsXsi        string        `xml:"xmlns:xsi,attr,omitempty"`
+	XmlnsWf         string        `xml:"xmlns:wf,attr,omitempty"`
+	ID              string        `xml:"id,attr,omitempty"`
+	TargetNamespace string        `xml:"targetNamespace,attr,omitempty"`
+	Processes       []bpmnProcess `xml:"process"`
+	Diagrams        []bpmnDiagram `xml:"http://www.omg.org/spec/BPMN/20100524/DI BPMNDiagram"`
+}
+
+// bpmnProcess BPMN 流程，流程级设置以 wf 扩展属性保存
+type bpmnProcess struct {
+	ID                string        `xml:"id,attr"`
+	Name              string        `xml:"name,attr,omitempty"`
+	IsExecutable      bool          `xml:"isExecutable,attr"`
+	Attrs             []xml.Attr    `xml:",any,attr"`
+	Documentation     string        `xml:"documentation,omitempty"`
+	Extensions        *bpmnInner    `xml:"extensionElements"`
+	StartEvents       []bpmnNode    `xml:"startEvent"`
+	UserTasks         []bpmnNode    `xml:"userTask"`
+	CallActivities    []bpmnNode    `xml:"callActivity"`
+	ExclusiveGateways []bpmnNode    `xml:"exclusiveGateway"`
+	InclusiveGateways []bpmnNode    `xml:"inclusiveGateway"`
+	ParallelGateways  []bpmnNode    `xml:"parallelGateway"`
+	EndEvents         []bpmnNode    `xml:"endEvent"`
+	SequenceFlows     []bpmnFlow    `xml:"sequenceFlow"`
+	LaneSets          []bpmnInner   `xml:"laneSet"`
+	TextAnnotations   []bpmnInner   `xml:"textAnnotation"`
+	Associations      []bpmnInner   `xml:"association"`
+	Unsupported       []bpmnElement `xml:",any"`
+}
+
+// bpmnNode 事件、任务、调用活动及网关，步骤设置以 wf 扩展属性保存
+type bpmnNode struct {
+	ID               string        `xml:"id,attr"`
+	Name             string        `xml:"name,attr,omitempty"`
+	CalledElement    string        `xml:"calledElement,attr,omitempty"`
+	GatewayDirection string        `xml:"gatewayDirection,attr,omitempty"`
+	Default          string        `xml:"default,attr,omitempty"`
+	Attrs            []xml.Attr    `xml:",any,attr"`
+	Documentation    string        `xml:"documentation,omitempty"`
+	Extensions       *bpmnInner    `xml:"extensionElements"`
+	Incoming         []string      `xml:"incoming"`
+	Outgoing         []string      `xml:"outgoing"`
+	Loop             *bpmnLoop     `xml:"multiInstanceLoopCharacteristics"`
+	Owners           []bpmnOwner   `xml:"potentialOwner"`
+	Performers       []bpmnOwner   `xml:"humanPerformer"`
+	Unsupported      []bpmnElement `xml:",any"`
+}
+
+// bpmnOwner 任务的审批人，表达式为 人员类型:设置值，如 Emp:3,4
+type bpmnOwner struct {
+	Expression string `xml:"resourceAssignmentExpression>formalExpression"`
+}
+
+// bpmnLoop 多实例子流程，列表字段等设置以 wf 扩展属性保存
+type bpmnLoop struct {
+	IsSequential bool `xml:"isSequential,attr"`
+}
+
+// bpmnFlow 顺序流，条件表达式使用本引擎的表达式语法
+type bpmnFlow struct {
+	ID        string          `xml:"id,attr"`
+	Name      string          `xml:"name,attr,omitempty"`
+	SourceRef string          `xml:"sourceRef,attr"`
+	TargetRef string          `xml:"targetRef,attr"`
+	Condition *bpmnExpression `xml:"conditionExpression"`
+}
+
+// bpmnExpression 条件表达式
+type bpmnExpression struct {
+	Type string `xml:"xsi:type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+// bpmnInner 导入时忽略内容的元素
+type bpmnInner struct {
+	Inner string `xml:",innerxml"`
+}
+
+// bpmnElement 不支持的元素，导入时报错
+type bpmnElement struct {
+	XMLName xml.Name
+	ID      string `xml:"id,attr"`
+}
+
+// bpmnDiagram 流程图布局，步骤位置与尺寸在导入导出时保留
+type bpmnDiagram struct {
+	ID    string    `xml:"id,attr"`
+	Plane bpmnPlane `xml:"http://www.omg.org/spec/BPMN/20100524/DI BPMNPlane"`
+}
+
+type bpmnPlane struct {
+	ID          string      `xml:"id,attr"`
+	BPMNElement string      `xml:"bpmnElement,attr"`
+	Shapes      []bpmnShape `xml:"http://www.omg.org/spec/BPMN/20100524/DI BPMNShape"`
+	Edges       []bpmnEdge  `xml:"http://www.omg.org/spec/BPMN/20100524/DI BPMNEdge"`
+}
+
+type bpmnShape struct {
+	ID          string     `xml:"id,attr"`
+	BPMNElement string     `xml:"bpmnElement,attr"`
+	Bounds      bpmnBounds `xml:"http://www.omg.org/spec/DD/20100524/DC Bounds"`
+}
+
+type bpmnBounds struct {
+	X      int `xml:"x,attr"`
+	Y      int `xml:"y,attr"`
+	Width  int `xml:"width,attr"`
+	Height int `xml:"height,attr"`
+}
+
+type bpmnEdge struct {
+	ID          string      `xml:"id,attr"`
+	BPMNElement string      `xml:"bpmnElement,attr"`
+	Waypoints   []bpmnPoint `xml:"http://www.omg.org/spec/DD/20100524/DI waypoint"`
+}
+
+type bpmnPoint struct {
+	X int `xml:"x,attr"`
+	Y int `xml:"y,attr"`
+}
+
+// center 元素中心点，流转连线连接两端元素的中心
+func (b bpmnBounds) center() bpmnPoint {
+	return bpmnPoint{X: b.X + b.Width/2, Y: b.Y + b.Height/2}
+}
+
+// bpmnField 以 wf 扩展属性保存的步骤设置，与默认值相同时不导出
+type bpmnField struct {
+	name  string
+	def   string
+	field func(p *models.Process) interface{}
+}
+
+// bpmnProcessFields BPMN 无对应概念的步骤设置，超时跳转步骤单独按元素ID保存
+var bpmnProcessFields = []bpmnField{
+	{"limitTime", "", func(p *models.Process) interface{} { return &p.LimitTime }},
+	{"approveMode", ApproveModeAny, func(p *models.Process) interface{} { return &p.ApproveMode }},
+	{"approveThreshold", "", func(p *models.Process) interface{} { return &p.ApproveThreshold }},
+	{"rejectPolicy", RejectPolicyAny, func(p *models.Process) interface{} { return &p.RejectPolicy }},
+	{"rejectReturn", RejectReturnResume, func(p *models.Process) interface{} { return &p.RejectReturn }},
+	{"timeoutAction", "", func(p *models.Process) interface{} { return &p.TimeoutAction }},
+	{"noAuditor", "", func(p *models.Process) interface{} { return &p.NoAuditor }},
+	{"joinThreshold", "", func(p *models.Process) interface{} { return &p.JoinThreshold }},
+	{"childListField", "", func(p *models.Process) interface{} { return &p.ChildListField }},
+	{"childQuorum", "", func(p *models.Process) interface{} { return &p.ChildQuorum }},
+	{"childResultField", "", func(p *models.Process) interface{} { return &p.ChildResultField }},
+	{"fieldPerms", "", func(p *models.Process) interface{} { return &p.FieldPerms }},
+}
+
+// wfAttr 本引擎扩展属性，导出时带 wf 前缀
+func wfAttr(name string, value string) xml.Attr {
+	return xml.Attr{Name: xml.Name{Local: "wf:" + name}, Value: value}
+}
+
+// lookupWfAttr 读取扩展属性，未声明 wf 命名空间时按前缀匹配
+func lookupWfAttr(attrs []xml.Attr, name string) (string, bool) {
+	for _, a := range attrs {
+		if a.Name.Local == name && (a.Name.Space == bpmnWfNS || a.Name.Space == "wf") {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// bpmnExpressionText 导出的条件表达式，旧版 JSON 条件转换为表达式，恒为真时为空
+func bpmnExpressionText(src string) (string, error) {
+	src = strings.TrimSpace(src)
+	if src == "1" || src == "" {
+		return "", nil
+	}
+	if strings.HasPrefix(src, "[") {
+		return legacyExpression(src)
+	}
+	return src, nil
+}
+
+// ExportBPMN 导出流程当前（编辑中）的定义为 BPMN 2.0 XML：审批步骤为用户任务，子流程步骤为调用活动，
+// 多条流转以排他网关表示，并行拆分与合并为并行网关，带条件的拆分为包容网关
+func (s *Service) ExportBPMN(flowID uint) ([]byte, error) {
+	g, err := loadGraph(s.db, flowID)
+	if err != nil {
+		return nil, err
+	}
+	e := &bpmnExporter{db: s.db, g: g, bounds: make(map[string]bpmnBounds)}
+	defs, err := e.export()
+	if err != nil {
+		return nil, err
+	}
+	out, err := xml.MarshalIndent(defs, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("BPMN生成错误: %v", err)
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// bpmnExporter 导出过程中的流程元素及布局
+type bpmnExporter struct {
+	db     *gorm.DB
+	g      *flowGraph
+	proc   bpmnProcess
+	bounds map[string]bpmnBounds
+	shapes []bpmnShape
+	end    bool
+}
+
+// bpmnStepID 步骤对应的元素ID
+func bpmnStepID(p models.Process) string {
+	if p.GatewayType != "" {
+		return fmt.Sprintf("Gateway_%d", p.ID)
+	}
+	return fmt.Sprintf("Activity_%d", p.ID)
+}
+
+// bpmnTarget 步骤的一条出口：下一步骤（-1 为结束）及条件
+type bpmnTarget struct {
+	id         string
+	next       int
+	expression string
+}
+
+func (e *bpmnExporter) export() (*bpmnDefinitions, error) {
+	flow := e.g.Flow
+	e.proc = bpmnProcess{
+		ID:           fmt.Sprintf("Process_%d", flow.ID),
+		Name:         flow.FlowName,
+		IsExecutable: true,
+	}
+	for _, attr := range []xml.Attr{
+		wfAttr("flowNo", flow.FlowNo),
+		wfAttr("recallPolicy", flow.RecallPolicy),
+		wfAttr("noAuditor", flow.NoAuditor),
+		wfAttr("autoApprove", flow.AutoApprove),
+		wfAttr("titleTemplate", flow.TitleTemplate),
+	} {
+		if attr.Value != "" {
+			e.proc.Attrs = append(e.proc.Attrs, attr)
+		}
+	}
+	starts := e.g.starts()
+	if len(starts) == 0 {
+		return nil, errors.New("流程未设置第一步骤")
+	}
+	for _, p := range e.g.Processes {
+		if err := e.step(p); err != nil {
+			return nil, err
+		}
+	}
+	first := e.bounds[bpmnStepID(starts[0])]
+	start := bpmnNode{ID: "StartEvent_1", Name: "开始"}
+	e.proc.StartEvents = append(e.proc.StartEvents, start)
+	e.shape(start.ID, bpmnBounds{X: first.X - 100, Y: first.center().Y - 18, Width: 36, Height: 36})
+	e.flow(bpmnFlow{ID: "Flow_start", SourceRef: start.ID, TargetRef: bpmnStepID(starts[0])})
+	for _, p := range e.g.Processes {
+		if err := e.outgoing(p); err != nil {
+			return nil, err
+		}
+	}
+	if e.end {
+		right := 0
+		for _, b := range e.bounds {
+			if b.X+b.Width > right {
+				right = b.X + b.Width
+			}
+		}
+		end := bpmnNode{ID: "EndEvent_1", Name: "结束"}
+		e.proc.EndEvents = append(e.proc.EndEvents, end)
+		e.shape(end.ID, bpmnBounds{X: right + 100, Y: first.center().Y - 18, Width: 36, Height: 36})
+	}
+	defs := &bpmnDefinitions{
+		Xmlns:           bpmnModelNS,
+		XmlnsXsi:        bpmnXsiNS,
+		XmlnsWf:         bpmnWfNS,
+		ID:              fmt.Sprintf("Definitions_%d", flow.ID),
+		TargetNamespace: bpmnWfNS,
+		Processes:       []bpmnProcess{e.proc},
+	}
+	diagram := bpmnDiagram{
+		ID:    "BPMNDiagram_1",
+		Plane: bpmnPlane{ID: "BPMNPlane_1", BPMNElement: e.proc.ID, Shapes: e.shapes},
+	}
+	for _, f := range e.proc.SequenceFlows {
+		source, target := e.bounds[f.SourceRef], e.bounds[f.TargetRef]
+		diagram.Plane.Edges = append(diagram.Plane.Edges, bpmnEdge{
+			ID:          f.ID + "_di",
+			BPMNElement: f.ID,
+			Waypoints:   []bpmnPoint{source.center(), target.center()},
+		})
+	}
+	defs.Diagrams = []bpmnDiagram{diagram}
+	return defs, nil
+}
+
+// step 导出步骤元素及其布局
+func (e *bpmnExporter) step(p models.Process) error {
+	node := bpmnNode{ID: bpmnStepID(p), Name: p.ProcessName, Documentation: p.Description}
+	for _, f := range bpmnProcessFields {
+		switch v := f.field(&p).(type) {
+		case *string:
+			if *v != "" && *v != f.def {
+				node.Attrs = append(node.Attrs, wfAttr(f.name, *v))
+			}
+		case *int:
+			if *v != 0 {
+				node.Attrs = append(node.Attrs, wfAttr(f.name, strconv.Itoa(*v)))
+			}
+		case *types.FieldPerms:
+			if len(*v) > 0 {
+				b, _ := json.Marshal(*v)
+				node.Attrs = append(node.Attrs, wfAttr(f.name, string(b)))
+			}
+		}
+	}
+	if p.TimeoutProcess > 0 {
+		target, ok := e.g.process(p.TimeoutProcess)
+		if !ok {
+			return fmt.Errorf("步骤[%s]的超时跳转步骤%d不存在", p.ProcessName, p.TimeoutProcess)
+		}
+		node.Attrs = append(node.Attrs, wfAttr("timeoutProcess", bpmnStepID(target)))
+	}
+	bounds := bpmnBounds{
+		X:      cssPixels(p.PositionLeft),
+		Y:      cssPixels(p.PositionTop),
+		Width:  p.StyleWidth,
+		Height: p.StyleHeight,
+	}
+	switch {
+	case p.GatewayType == GatewayFork:
+		node.GatewayDirection = "Diverging"
+		if e.conditional(p) {
+			e.proc.InclusiveGateways = append(e.proc.InclusiveGateways, node)
+		} else {
+			e.proc.ParallelGateways = append(e.proc.ParallelGateways, node)
+		}
+	case p.GatewayType == GatewayJoin:
+		node.GatewayDirection = "Converging"
+		e.proc.ParallelGateways = append(e.proc.ParallelGateways, node)
+	case p.ChildFlowID > 0:
+		var child models.Flow
+		if err := e.db.First(&child, p.ChildFlowID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("步骤[%s]的子流程不存在", p.ProcessName)
+			}
+			return fmt.Errorf("数据库查询错误: %v", err)
+		}
+		if child.FlowNo == "" {
+			return fmt.Errorf("步骤[%s]的子流程[%s]未设置流程编号，无法导出", p.ProcessName, child.FlowName)
+		}
+		node.CalledElement = child.FlowNo
+		if p.ChildListField != "" {
+			node.Loop = &bpmnLoop{IsSequential: p.ChildMode == ChildModeSequential}
+		}
+		node.Owners = e.owners(p)
+		e.proc.CallActivities = append(e.proc.CallActivities, node)
+	default:
+		node.Owners = e.owners(p)
+		e.proc.UserTasks = append(e.proc.UserTasks, node)
+	}
+	e.shape(node.ID, bounds)
+	return nil
+}
+
+// owners 步骤的审批人设置
+func (e *bpmnExporter) owners(p models.Process) []bpmnOwner {
+	var owners []bpmnOwner
+	for _, l := range e.g.auditorLinks(p.ID) {
+		owners = append(owners, bpmnOwner{Expression: l.Type + ":" + l.Auditor})
+	}
+	return owners
+}
+
+// conditional 拆分网关的分支是否带条件
+func (e *bpmnExporter) conditional(p models.Process) bool {
+	for _, l := range e.g.conditions(p.ID) {
+		if text, _ := bpmnExpressionText(l.Expression); text != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// targets 步骤的出口：子流程步骤按返回设置，其他步骤按流转
+func (e *bpmnExporter) targets(p models.Process) []bpmnTarget {
+	if p.ChildFlowID > 0 {
+		if p.ChildAfter == 1 {
+			return []bpmnTarget{{id: fmt.Sprintf("Flow_%d_back", p.ID), next: -1}}
+		}
+		if p.ChildBackProcess > 0 {
+			return []bpmnTarget{{id: fmt.Sprintf("Flow_%d_back", p.ID), next: p.ChildBackProcess}}
+		}
+	}
+	var targets []bpmnTarget
+	for _, l := range e.g.conditions(p.ID) {
+		targets = append(targets, bpmnTarget{id: fmt.Sprintf("Flow_%d", l.ID), next: l.NextProcessID, expression: l.Expression})
+	}
+	return targets
+}
+
+// outgoing 导出步骤的流转，非拆分网关的步骤有多条流转时经排他网关分支，恒为真的最后一条作为默认流转
+func (e *bpmnExporter) outgoing(p models.Process) error {
+	source := bpmnStepID(p)
+	targets := e.targets(p)
+	if len(targets) > 1 && p.GatewayType != GatewayFork {
+		gateway := bpmnNode{ID: source + "_split", GatewayDirection: "Diverging"}
+		b := e.bounds[source]
+		e.shape(gateway.ID, bpmnBounds{X: b.X + b.Width + 50, Y: b.center().Y - 25, Width: 50, Height: 50})
+		e.flow(bpmnFlow{ID: fmt.Sprintf("Flow_%d_split", p.ID), SourceRef: source, TargetRef: gateway.ID})
+		if last := targets[len(targets)-1]; strings.TrimSpace(last.expression) == "1" {
+			gateway.Default = last.id
+		}
+		e.proc.ExclusiveGateways = append(e.proc.ExclusiveGateways, gateway)
+		source = gateway.ID
+	}
+	for _, target := range targets {
+		f := bpmnFlow{ID: target.id, SourceRef: source}
+		if target.next == -1 {
+			f.TargetRef = "EndEvent_1"
+			e.end = true
+		} else {
+			next, ok := e.g.process(target.next)
+			if !ok {
+				return fmt.Errorf("步骤[%s]的流转指向不存在的步骤%d", p.ProcessName, target.next)
+			}
+			f.TargetRef = bpmnStepID(next)
+		}
+		text, err := bpmnExpressionText(target.expression)
+		if err != nil {
+			return fmt.Errorf("步骤[%s]的流转条件有误: %v", p.ProcessName, err)
+		}
+		if text != "" {
+			f.Condition = &bpmnExpression{Type: "tFormalExpression", Body: text}
+		}
+		e.flow(f)
+	}
+	return nil
+}
+
+func (e *bpmnExporter) flow(f bpmnFlow) {
+	e.proc.SequenceFlows = append(e.proc.SequenceFlows, f)
+}
+
+func (e *bpmnExporter) shape(id string, b bpmnBounds) {
+	e.bounds[id] = b
+	e.shapes = append(e.shapes, bpmnShape{ID: id + "_di", BPMNElement: id, Bounds: b})
+}
+
+// cssPixels 解析流程图中的像素位置，如 100px
+func cssPixels(v string) int {
+	n, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "px"), 64)
+	return int(n)
+}
+
+// ImportBPMN 从 BPMN 2.0 XML 导入流程定义：flowID 为0时新建流程，否则覆盖该流程当前（编辑中）的定义，
+// 已发布的版本不受影响。支持开始/结束事件、用户任务、调用活动、排他网关及并行、包容网关，
+// 遇到不支持的元素或无法对应的结构时返回错误
+func (s *Service) ImportBPMN(flowID uint, data []byte) (*models.Flow, error) {
+	var defs bpmnDefinitions
+	if err := xml.Unmarshal(data, &defs); err != nil {
+		return nil, fmt.Errorf("BPMN解析错误: %v", err)
+	}
+	if len(defs.Processes) != 1 {
+		return nil, errors.New("BPMN须包含且只能包含一个流程")
+	}
+	im := &bpmnImporter{
+		proc:   &defs.Processes[0],
+		nodes:  make(map[string]*bpmnStep),
+		out:    make(map[string][]*bpmnFlow),
+		in:     make(map[string]int),
+		bounds: make(map[string]bpmnBounds),
+	}
+	for _, d := range defs.Diagrams {
+		for _, shape := range d.Plane.Shapes {
+			im.bounds[shape.BPMNElement] = shape.Bounds
+		}
+	}
+	if err := im.parse(); err != nil {
+		return nil, err
+	}
+	var flow models.Flow
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := im.resolveChildFlows(tx); err != nil {
+			return err
+		}
+		var err error
+		flow, err = im.save(tx, flowID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &flow, nil
+}
+
+// bpmnStep 导入的元素，开始事件、结束事件和排他网关外的元素对应一个步骤
+type bpmnStep struct {
+	kind     string
+	node     *bpmnNode
+	process  models.Process
+	auditors []models.Flowlink
+	links    []models.Flowlink
+	targets  []string // 与 links 对应的下一元素ID，结束时为空
+}
+
+// bpmnImporter 导入过程中的元素及流转
+type bpmnImporter struct {
+	proc   *bpmnProcess
+	nodes  map[string]*bpmnStep
+	out    map[string][]*bpmnFlow
+	in     map[string]int
+	bounds map[string]bpmnBounds
+	steps  []*bpmnStep
+	start  *bpmnStep
+}
+
+// parse 校验元素并转换为步骤及流转
+func (im *bpmnImporter) parse() error {
+	p := im.proc
+	if err := unsupportedBPMN(p.Unsupported); err != nil {
+		return err
+	}
+	for _, group := range []struct {
+		kind  string
+		nodes []bpmnNode
+	}{
+		{bpmnStartEvent, p.StartEvents},
+		{bpmnEndEvent, p.EndEvents},
+		{bpmnUserTask, p.UserTasks},
+		{bpmnCallActivity, p.CallActivities},
+		{bpmnExclusiveGateway, p.ExclusiveGateways},
+		{bpmnInclusiveGateway, p.InclusiveGateways},
+		{bpmnParallelGateway, p.ParallelGateways},
+	} {
+		for i := range group.nodes {
+			node := &group.nodes[i]
+			if node.ID == "" {
+				return fmt.Errorf("BPMN元素%s缺少id", group.kind)
+			}
+			if _, ok := im.nodes[node.ID]; ok {
+				return fmt.Errorf("BPMN元素id[%s]重复", node.ID)
+			}
+			if err := unsupportedBPMN(node.Unsupported); err != nil {
+				return fmt.Errorf("%s[%s]中%v", group.kind, bpmnName(node), err)
+			}
+			im.nodes[node.ID] = &bpmnStep{kind: group.kind, node: node}
+		}
+	}
+	if len(p.StartEvents) != 1 {
+		return errors.New("BPMN流程须有且只有一个开始事件")
+	}
+	if len(p.EndEvents) == 0 {
+		return errors.New("BPMN流程缺少结束事件")
+	}
+	for i := range p.SequenceFlows {
+		f := &p.SequenceFlows[i]
+		if _, ok := im.nodes[f.SourceRef]; !ok {
+			return fmt.Errorf("流转[%s]的来源元素[%s]不存在", f.ID, f.SourceRef)
+		}
+		if _, ok := im.nodes[f.TargetRef]; !ok {
+			return fmt.Errorf("流转[%s]的目标元素[%s]不存在", f.ID, f.TargetRef)
+		}
+		im.out[f.SourceRef] = append(im.out[f.SourceRef], f)
+		im.in[f.TargetRef]++
+	}
+
+	// 开始事件之后为无审批人的用户任务时作为第一步骤，否则以开始事件作为第一步骤
+	startEvent := &p.StartEvents[0]
+	flows := im.out[startEvent.ID]
+	if len(flows) != 1 || flows[0].Condition != nil {
+		return errors.New("开始事件须有且只有一条无条件的流转")
+	}
+	first := im.nodes[flows[0].TargetRef]
+	if first.kind == bpmnUserTask && len(first.node.Owners)+len(first.node.Performers) == 0 {
+		im.start = first
+	} else {
+		im.start = im.nodes[startEvent.ID]
+		im.start.node.Name = "发起"
+		im.steps = append(im.steps, im.start)
+	}
+	for _, nodes := range [][]bpmnNode{p.UserTasks, p.CallActivities, p.ParallelGateways, p.InclusiveGateways} {
+		for _, node := range nodes {
+			im.steps = append(im.steps, im.nodes[node.ID])
+		}
+	}
+	for _, step := range im.steps {
+		if err := im.convert(step); err != nil {
+			return err
+		}
+		if err := im.resolveLinks(step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsupportedBPMN 不支持的元素，扩展元素及说明类元素除外
+func unsupportedBPMN(elements []bpmnElement) error {
+	var names []string
+	for _, el := range elements {
+		if el.ID != "" {
+			names = append(names, fmt.Sprintf("%s[%s]", el.XMLName.Local, el.ID))
+		} else {
+			names = append(names, el.XMLName.Local)
+		}
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("包含不支持的BPMN元素: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// bpmnName 元素名称，未设置时为ID
+func bpmnName(node *bpmnNode) string {
+	if node.Name != "" {
+		return node.Name
+	}
+	return node.ID
+}
+
+// convert 将元素转换为步骤设置
+func (im *bpmnImporter) convert(step *bpmnStep) error {
+	node := step.node
+	p := models.Process{
+		ProcessName: bpmnName(node),
+		Description: strings.TrimSpace(node.Documentation),
+		Position:    1,
+	}
+	for _, f := range bpmnProcessFields {
+		value, ok := lookupWfAttr(node.Attrs, f.name)
+		if !ok {
+			continue
+		}
+		switch v := f.field(&p).(type) {
+		case *string:
+			*v = value
+		case *int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("元素[%s]的扩展属性%s须为整数", bpmnName(node), f.name)
+			}
+			*v = n
+		case *types.FieldPerms:
+			if err := json.Unmarshal([]byte(value), v); err != nil {
+				return fmt.Errorf("元素[%s]的扩展属性%s须为JSON对象", bpmnName(node), f.name)
+			}
+		}
+	}
+	if b, ok := im.bounds[node.ID]; ok {
+		p.PositionLeft = fmt.Sprintf("%dpx", b.X)
+		p.PositionTop = fmt.Sprintf("%dpx", b.Y)
+		p.StyleWidth = b.Width
+		p.StyleHeight = b.Height
+	}
+	switch step.kind {
+	case bpmnParallelGateway, bpmnInclusiveGateway:
+		out, in := len(im.out[node.ID]), im.in[node.ID]
+		switch {
+		case node.GatewayDirection == "Diverging" || (node.GatewayDirection != "Converging" && out > 1 && in <= 1):
+			p.GatewayType = GatewayFork
+		case node.GatewayDirection == "Converging" || (out == 1 && in > 1):
+			p.GatewayType = GatewayJoin
+		default:
+			return fmt.Errorf("网关[%s]须为拆分（多条出口）或合并（多条入口）网关，不支持同时拆分与合并", bpmnName(node))
+		}
+	case bpmnCallActivity:
+		if node.CalledElement == "" {
+			return fmt.Errorf("调用活动[%s]未设置子流程(calledElement)", bpmnName(node))
+		}
+		p.Position = 2
+		if node.Loop != nil {
+			if p.ChildListField == "" {
+				return fmt.Errorf("调用活动[%s]为多实例，须设置列表字段(wf:childListField)", bpmnName(node))
+			}
+			p.ChildMode = ChildModeParallel
+			if node.Loop.IsSequential {
+				p.ChildMode = ChildModeSequential
+			}
+		} else if p.ChildListField != "" {
+			return fmt.Errorf("调用活动[%s]设置了列表字段，但不是多实例", bpmnName(node))
+		}
+		fallthrough
+	case bpmnUserTask:
+		for _, owner := range append(node.Owners, node.Performers...) {
+			expr := strings.TrimSpace(owner.Expression)
+			i := strings.Index(expr, ":")
+			if i <= 0 {
+				return fmt.Errorf("元素[%s]的审批人[%s]格式有误，须为 人员类型:设置值", bpmnName(node), expr)
+			}
+			linkType := strings.TrimSpace(expr[:i])
+			if _, ok := lookupAuditorResolver(linkType); !ok {
+				return fmt.Errorf("元素[%s]的审批人类型[%s]不支持", bpmnName(node), linkType)
+			}
+			step.auditors = append(step.auditors, models.Flowlink{Type: linkType, Auditor: strings.TrimSpace(expr[i+1:])})
+		}
+	}
+	if step == im.start {
+		p.Position = 0
+	}
+	step.process = p
+	return nil
+}
+
+// resolveLinks 将元素的出口转换为流转：经排他网关的出口展开为网关的各分支，默认流转排在最后
+func (im *bpmnImporter) resolveLinks(step *bpmnStep) error {
+	node := step.node
+	flows := im.out[node.ID]
+	if len(flows) == 0 {
+		return fmt.Errorf("元素[%s]缺少出口流转", bpmnName(node))
+	}
+	source, defaultFlow := node, node.Default
+	if len(flows) == 1 && im.nodes[flows[0].TargetRef].kind == bpmnExclusiveGateway {
+		if flows[0].Condition != nil {
+			return fmt.Errorf("进入排他网关的流转[%s]不能设置条件", flows[0].ID)
+		}
+		source = im.nodes[flows[0].TargetRef].node
+		defaultFlow = source.Default
+		flows = im.out[source.ID]
+		if len(flows) == 0 {
+			return fmt.Errorf("排他网关[%s]缺少出口流转", bpmnName(source))
+		}
+	}
+	var fallback *bpmnFlow
+	for _, f := range flows {
+		if f.ID != "" && f.ID == defaultFlow {
+			fallback = f
+			continue
+		}
+		expr := ""
+		if f.Condition != nil {
+			expr = bpmnConditionText(f.Condition.Body)
+		}
+		if step.kind == bpmnParallelGateway {
+			expr = ""
+		}
+		if expr == "" {
+			if len(flows) > 1 && step.kind != bpmnParallelGateway {
+				return fmt.Errorf("元素[%s]有多条出口，流转[%s]须设置条件或设为默认流转", bpmnName(source), f.ID)
+			}
+			expr = "1"
+		}
+		if err := im.addLink(step, f, expr); err != nil {
+			return err
+		}
+	}
+	if fallback != nil {
+		return im.addLink(step, fallback, "1")
+	}
+	return nil
+}
+
+// bpmnConditionText 条件表达式原文，去除其他引擎常用的 ${...} 包裹
+func bpmnConditionText(body string) string {
+	expr := strings.TrimSpace(body)
+	if (strings.HasPrefix(expr, "${") || strings.HasPrefix(expr, "#{")) && strings.HasSuffix(expr, "}") {
+		expr = strings.TrimSpace(expr[2 : len(expr)-1])
+	}
+	return expr
+}
+
+// addLink 添加一条流转，目标为排他网关、开始事件时不支持
+func (im *bpmnImporter) addLink(step *bpmnStep, f *bpmnFlow, expr string) error {
+	target := im.nodes[f.TargetRef]
+	switch target.kind {
+	case bpmnStartEvent:
+		return fmt.Errorf("流转[%s]不能指向开始事件", f.ID)
+	case bpmnExclusiveGateway:
+		return fmt.Errorf("流转[%s]指向的排他网关[%s]须是来源元素唯一的出口，不支持网关直接相连", f.ID, bpmnName(target.node))
+	}
+	if expr != "1" {
+		if _, err := expression.Compile(expr); err != nil {
+			return fmt.Errorf("流转[%s]的条件表达式有误: %v", f.ID, err)
+		}
+	}
+	link := models.Flowlink{Type: "Condition", Auditor: "0", Expression: expr, Sort: len(step.links) + 1}
+	targetID := ""
+	if target.kind == bpmnEndEvent {
+		link.NextProcessID = -1
+	} else {
+		targetID = target.node.ID
+	}
+	step.links = append(step.links, link)
+	step.targets = append(step.targets, targetID)
+	return nil
+}
+
+// resolveChildFlows 调用活动按 calledElement 查找流程编号相同的子流程
+func (im *bpmnImporter) resolveChildFlows(tx *gorm.DB) error {
+	for _, step := range im.steps {
+		if step.kind != bpmnCallActivity {
+			continue
+		}
+		var child models.Flow
+		err := tx.Where("flow_no=?", step.node.CalledElement).Order("is_publish desc, id asc").First(&child).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("调用活动[%s]的子流程[%s]不存在", bpmnName(step.node), step.node.CalledElement)
+			}
+			return fmt.Errorf("数据库查询错误: %v", err)
+		}
+		step.process.ChildFlowID = int(child.ID)
+	}
+	return nil
+}
+
+// save 保存流程及步骤、流转、条件变量；覆盖已有流程时删除其步骤及步骤的插件配置、抄送设置
+func (im *bpmnImporter) save(tx *gorm.DB, flowID uint) (models.Flow, error) {
+	var flow models.Flow
+	attrs := map[string]string{}
+	for _, name := range []string{"flowNo", "recallPolicy", "noAuditor", "autoApprove", "titleTemplate"} {
+		attrs[name], _ = lookupWfAttr(im.proc.Attrs, name)
+	}
+	name := im.proc.Name
+	if name == "" {
+		name = im.proc.ID
+	}
+	if attrs["recallPolicy"] == "" {
+		attrs["recallPolicy"] = RecallPolicyUnhandled
+	}
+	if attrs["noAuditor"] == "" {
+		attrs["noAuditor"] = NoAuditorError
+	}
+	if flowID == 0 {
+		flow = models.Flow{
+			FlowNo:        attrs["flowNo"],
+			FlowName:      name,
+			RecallPolicy:  attrs["recallPolicy"],
+			NoAuditor:     attrs["noAuditor"],
+			AutoApprove:   attrs["autoApprove"],
+			TitleTemplate: attrs["titleTemplate"],
+		}
+		if flow.FlowNo == "" {
+			flow.FlowNo = im.proc.ID
+		}
+		if err := tx.Omit(clause.Associations).Create(&flow).Error; err != nil {
+			return flow, fmt.Errorf("数据库插入错误: %v", err)
+		}
+	} else {
+		if err := tx.First(&flow, flowID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return flow, errors.New("流程不存在")
+			}
+			return flow, fmt.Errorf("数据库查询错误: %v", err)
+		}
+		flow.FlowName, flow.RecallPolicy, flow.NoAuditor, flow.AutoApprove = name, attrs["recallPolicy"], attrs["noAuditor"], attrs["autoApprove"]
+		flow.TitleTemplate = attrs["titleTemplate"]
+		err := tx.Model(&models.Flow{}).Where("id=?", flow.ID).Updates(map[string]interface{}{
+			"flow_name":      flow.FlowName,
+			"recall_policy":  flow.RecallPolicy,
+			"no_auditor":     flow.NoAuditor,
+			"auto_approve":   flow.AutoApprove,
+			"title_template": flow.TitleTemplate,
+		}).Error
+		if err != nil {
+			return flow, fmt.Errorf("数据库更新错误: %v", err)
+		}
+		for _, model := range []interface{}{&models.Flowlink{}, &models.Process{}, &models.ProcessVar{}, &official_plugin.PluginConfig{}} {
+			if err = tx.Where("flow_id=?", flow.ID).Delete(model).Error; err != nil {
+				return flow, fmt.Errorf("数据库删除错误: %v", err)
+			}
+		}
+		// 流程结束时的抄送设置与步骤无关，予以保留
+		if err = tx.Where("flow_id=?", flow.ID).Where("process_id<>0").Delete(&models.CarbonCopy{}).Error; err != nil {
+			return flow, fmt.Errorf("数据库删除错误: %v", err)
+		}
+	}
+
+	ids := make(map[string]int, len(im.steps))
+	for _, step := range im.steps {
+		step.process.FlowID = int(flow.ID)
+		if err := tx.Omit(clause.Associations).Create(&step.process).Error; err != nil {
+			return flow, fmt.Errorf("数据库插入错误: %v", err)
+		}
+		ids[step.node.ID] = int(step.process.ID)
+	}
+	for _, step := range im.steps {
+		updates := map[string]interface{}{}
+		// 零值在插入时会被默认值覆盖，第一步骤单独更新
+		if step == im.start {
+			updates["position"] = 0
+		}
+		if ref, ok := lookupWfAttr(step.node.Attrs, "timeoutProcess"); ok && ref != "" {
+			id, ok := ids[ref]
+			if !ok {
+				return flow, fmt.Errorf("元素[%s]的超时跳转步骤[%s]不存在", bpmnName(step.node), ref)
+			}
+			updates["timeout_process"] = id
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&models.Process{}).Where("id=?", step.process.ID).Updates(updates).Error; err != nil {
+				return flow, fmt.Errorf("数据库更新错误: %v", err)
+			}
+		}
+		links := append([]models.Flowlink(nil), step.auditors...)
+		var vars []string
+		for i, link := range step.links {
+			if step.targets[i] != "" {
+				link.NextProcessID = ids[step.targets[i]]
+			}
+			links = append(links, link)
+			if link.Expression == "1" {
+				continue
+			}
+			compiled, _ := expression.Compile(link.Expression)
+			vars = append(vars, compiled.Vars()...)
+		}
+		for i := range links {
+			links[i].FlowID = flow.ID
+			links[i].ProcessID = step.process.ID
+		}
+		if len(links) > 0 {
+			if err := tx.Omit(clause.Associations).Create(&links).Error; err != nil {
+				return flow, fmt.Errorf("数据库插入错误: %v", err)
+			}
+		}
+		seen := make(map[string]bool)
+		for _, name := range conditionBuiltinVars {
+			seen[name] = true
+		}
+		for _, field := range vars {
+			if seen[field] {
+				continue
+			}
+			seen[field] = true
+			pv := models.ProcessVar{ProcessID: int(step.process.ID), FlowID: int(flow.ID), ExpressionField: field}
+			if err := tx.Omit(clause.Associations).Create(&pv).Error; err != nil {
+				return flow, fmt.Errorf("数据库插入错误: %v", err)
+			}
+		}
+	}
+	return flow, nil
+}
@@ -0,0 +1,106 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// flowSignature 流程定义摘要：步骤名/审批方式/网关/审批人设置/流转，按步骤名排序，与步骤id无关
+func flowSignature(e *testEnv, flowID uint) string {
+	e.t.Helper()
+	g, err := loadGraph(e.db, flowID)
+	e.must(err)
+	names := map[int]string{-1: "结束"}
+	for _, p := range g.Processes {
+		names[int(p.ID)] = p.ProcessName
+	}
+	items := make([]string, 0, len(g.Processes))
+	for _, p := range g.Processes {
+		var auditors, links []string
+		for _, l := range g.auditorLinks(p.ID) {
+			auditors = append(auditors, l.Type+":"+l.Auditor)
+		}
+		for _, l := range g.conditions(p.ID) {
+			links = append(links, fmt.Sprintf("%s(%s)", names[l.NextProcessID], l.Expression))
+		}
+		first := ""
+		if p.Position == 0 {
+			first = "*"
+		}
+		items = append(items, fmt.Sprintf("%s%s/%s/%s/%s/%s", first, p.ProcessName, approveMode(p), p.GatewayType,
+			strings.Join(auditors, ","), strings.Join(links, ",")))
+	}
+	sort.Strings(items)
+	return strings.Join(items, "\n")
+}
+
+// 导出的 BPMN 导入后得到相同的流程定义，且可正常流转
+func TestBPMNRoundTrip(t *testing.T) {
+	e := newTestEnv(t)
+	flowID := e.flow("bpmn")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A", ApproveMode: ApproveModeAll}, "2,3", false)
+	fork := e.step(flowID, models.Process{ProcessName: "拆分", GatewayType: GatewayFork}, "", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "3", false)
+	c := e.step(flowID, models.Process{ProcessName: "C"}, "", false)
+	e.must(e.db.Create(&models.Flowlink{FlowID: flowID, Type: AuditorDirector, ProcessID: c}).Error)
+	join := e.step(flowID, models.Process{ProcessName: "合并", GatewayType: GatewayJoin}, "", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, int(fork), "amount > 1000")
+	e.link(flowID, a, -1, "")
+	e.link(flowID, fork, int(b), "")
+	e.link(flowID, fork, int(c), "")
+	e.link(flowID, b, int(join), "")
+	e.link(flowID, c, int(join), "")
+	e.link(flowID, join, -1, "")
+	want := flowSignature(e, flowID)
+
+	data, err := e.s.ExportBPMN(flowID)
+	e.must(err)
+	imported, err := e.s.ImportBPMN(0, data)
+	e.must(err)
+	if got := flowSignature(e, imported.ID); got != want {
+		t.Fatalf("导入后的流程定义为\n%s\n期望\n%s", got, want)
+	}
+	errs, err := e.s.ValidateFlow(imported.ID)
+	e.must(err)
+	if errs.HasError() {
+		t.Fatalf("导入的流程应通过校验，实际 %v", errs)
+	}
+
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", imported.ID).Update("is_publish", true).Error)
+	entry, err := e.s.Start(imported.ID, empAlice, "测试", map[string]string{"amount": "5000"})
+	e.must(err)
+	e.pass(entry.ID, empBob)
+	e.pass(entry.ID, empCarol)
+	e.pass(entry.ID, empCarol)
+	e.pass(entry.ID, empBob)
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
+
+// 不支持的元素导入时报错
+func TestImportBPMNUnsupported(t *testing.T) {
+	e := newTestEnv(t)
+	src := `<?xml version="1.0" encoding="UTF-8"?>
+<definitions xmlns="http://www.omg.org/spec/BPMN/20100524/MODEL">
+  <process id="p1">
+    <startEvent id="s"/>
+    <scriptTask id="x"/>
+    <endEvent id="end"/>
+    <sequenceFlow id="f1" sourceRef="s" targetRef="x"/>
+    <sequenceFlow id="f2" sourceRef="x" targetRef="end"/>
+  </process>
+</definitions>`
+	if _, err := e.s.ImportBPMN(0, []byte(src)); err == nil || !strings.Contains(err.Error(), "scriptTask") {
+		t.Fatalf("应报告不支持的元素 scriptTask，实际 %v", err)
+	}
+	var count int64
+	e.db.Model(&models.Flow{}).Where("flow_no=?", "p1").Count(&count)
+	if count != 0 {
+		t.Fatal("导入失败时不应创建流程")
+	}
+}
@@ -0,0 +1,159 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dromara/carbon/v2"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// carbonCopy 步骤通过（processID>0）或流程结束（processID=0）时，按抄送设置生成抄送记录并通知抄送人；
+// 同一轮次内同一步骤对同一人只抄送一次
+func (s *Service) carbonCopy(t *flowTx, entry *models.Entry, g *flowGraph, processID uint) error {
+	ccs := g.carbonCopies(processID)
+	if len(ccs) == 0 {
+		return nil
+	}
+	processName := "流程结束"
+	if processID > 0 {
+		if p, ok := g.process(int(processID)); ok {
+			processName = p.ProcessName
+		}
+	}
+	env := conditionEnv(t.DB, entry)
+	ctx := &AuditorContext{DB: t.DB, Entry: entry}
+	var empIds []int
+	for _, c := range ccs {
+		if src := strings.TrimSpace(c.Expression); src != "" {
+			ok, err := matchExpression(src, env)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+		}
+		ids, err := resolveEmpIds(ctx, c.Type, c.Recipient)
+		if err != nil {
+			return err
+		}
+		empIds = append(empIds, ids...)
+	}
+	empIds = uniqueSlice(empIds)
+	if len(empIds) == 0 {
+		return nil
+	}
+	var existing []int
+	t.Model(&models.CarbonCopyRecord{}).
+		Where("entry_id=?", entry.ID).
+		Where("process_id=?", processID).
+		Where("circle=?", entry.Circle).
+		Pluck("emp_id", &existing)
+	sent := make(map[int]bool, len(existing))
+	for _, id := range existing {
+		sent[id] = true
+	}
+	for _, id := range empIds {
+		if sent[id] {
+			continue
+		}
+		record := models.CarbonCopyRecord{
+			EntryID:     entry.ID,
+			FlowID:      entry.FlowID,
+			ProcessID:   processID,
+			ProcessName: processName,
+			Circle:      entry.Circle,
+			EmpID:       uint(id),
+		}
+		if err := t.Create(&record).Error; err != nil {
+			return fmt.Errorf("数据库插入错误: %v", err)
+		}
+		empID := uint(id)
+		t.afterCommit(func() {
+			_ = s.wf.NotifyCarbonCopy(empID)
+		})
+	}
+	return nil
+}
+
+// CarbonCopies 抄送给员工的记录，isRead 为-1时查询全部，按抄送时间倒序
+func (s *Service) CarbonCopies(empID uint, isRead int, limit int, offset int) ([]models.CarbonCopyRecord, int64, error) {
+	query := s.db.Model(&models.CarbonCopyRecord{}).Where("emp_id=?", empID)
+	if isRead >= 0 {
+		query = query.Where("is_read=?", isRead)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	var records []models.CarbonCopyRecord
+	if err := query.Preload("Entry").Order("id desc").Limit(limit).Offset(offset).Find(&records).Error; err != nil {
+		return nil, 0, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return records, count, nil
+}
+
+// ReadCarbonCopy 抄送人查看抄送的流程，标记为已读，返回流程及处理记录、表单数据
+func (s *Service) ReadCarbonCopy(id uint, empID uint) (*models.Entry, error) {
+	var record models.CarbonCopyRecord
+	if err := s.db.First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("抄送记录不存在")
+		}
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	if record.EmpID != empID {
+		return nil, errors.New("无权查看该抄送")
+	}
+	if record.IsRead == 0 {
+		err := s.db.Model(&models.CarbonCopyRecord{}).Where("id=?", record.ID).Updates(map[string]interface{}{
+			"is_read": 1,
+			"read_at": carbon.Now().Timestamp(),
+		}).Error
+		if err != nil {
+			return nil, fmt.Errorf("数据库更新错误: %v", err)
+		}
+	}
+	var entry models.Entry
+	err := s.db.Preload("Procs", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	}).Preload("EntryDatas").First(&entry, record.EntryID).Error
+	if err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	perms, err := s.viewerFieldPerms(&entry, empID)
+	if err != nil {
+		return nil, err
+	}
+	filterEntryData(&entry, perms)
+	return &entry, nil
+}
+
+// CanView 员工是否可以查看流程：发起人、审批人及其受托人、抄送人
+func (s *Service) CanView(entryID uint, empID uint) bool {
+	var entry models.Entry
+	if err := s.db.First(&entry, entryID).Error; err != nil {
+		return false
+	}
+	if entry.EmpID == empID {
+		return true
+	}
+	var count int64
+	s.db.Model(&models.Proc{}).Where("entry_id=?", entryID).
+		Where("emp_id=? OR auditor_id=?", empID, empID).Count(&count)
+	if count > 0 {
+		return true
+	}
+	// 受托人按当前生效的委托规则判断是否可代为处理待办
+	var pending []models.Proc
+	s.db.Where("entry_id=?", entryID).Where("status=?", models.ProcStatusPending).Find(&pending)
+	for _, proc := range pending {
+		if canActFor(s.db, proc, empID) {
+			return true
+		}
+	}
+	s.db.Model(&models.CarbonCopyRecord{}).Where("entry_id=?", entryID).Where("emp_id=?", empID).Count(&count)
+	return count > 0
+}
@@ -0,0 +1,57 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// 步骤通过及流程结束时按抄送设置抄送，抄送人可在收件箱查看并标记已读
+func TestCarbonCopyInbox(t *testing.T) {
+	e := newTestEnv(t)
+	tmpl := models.Template{TemplateName: "报销"}
+	e.must(e.db.Create(&tmpl).Error)
+	e.must(e.db.Create(&models.TemplateForm{TemplateID: tmpl.ID, Field: "amount", FieldName: "金额", FieldType: "number"}).Error)
+	flowID := e.flow("cc")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("template_id", tmpl.ID).Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, -1, "")
+	e.must(e.db.Create(&models.CarbonCopy{FlowID: flowID, ProcessID: a, Type: "Emp", Recipient: "4"}).Error)
+	// 金额超过100时流程结束抄送发起人部门主管
+	e.must(e.db.Create(&models.CarbonCopy{FlowID: flowID, Type: "Sys", Recipient: "-1001", Expression: "amount > 100"}).Error)
+
+	small := e.start(flowID, map[string]string{"amount": "50"})
+	if e.s.CanView(small.ID, empDave) {
+		t.Fatal("抄送前抄送人不应可查看流程")
+	}
+	e.pass(small.ID, empBob)
+	large := e.start(flowID, map[string]string{"amount": "500"})
+	e.pass(large.ID, empBob)
+
+	records, count, err := e.s.CarbonCopies(empDave, 0, 10, 0)
+	e.must(err)
+	if count != 2 || records[0].EntryID != large.ID || records[0].ProcessID != a {
+		t.Fatalf("dave应收到两条步骤A的抄送，实际 %+v", records)
+	}
+	_, count, err = e.s.CarbonCopies(empBob, -1, 10, 0)
+	e.must(err)
+	if count != 1 {
+		t.Fatalf("仅金额超过100的流程结束时抄送主管，实际%d条", count)
+	}
+	if !e.s.CanView(small.ID, empDave) {
+		t.Fatal("抄送人应可查看流程")
+	}
+
+	if _, err = e.s.ReadCarbonCopy(records[0].ID, empCarol); err == nil {
+		t.Fatal("非抄送人不应可查看抄送")
+	}
+	_, err = e.s.ReadCarbonCopy(records[0].ID, empDave)
+	e.must(err)
+	_, count, err = e.s.CarbonCopies(empDave, 0, 10, 0)
+	e.must(err)
+	if count != 1 {
+		t.Fatalf("查看后应剩一条未读抄送，实际%d条", count)
+	}
+}
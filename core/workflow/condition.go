@@ -0,0 +1,242 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow/expression"
+	"gorm.io/gorm"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// legacyField 旧版条件的字段名：字母、数字、下划线组成，可用.访问明细表格的列
+var legacyField = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// legacyOperators 旧版条件允许的比较运算符，兼容 SQL 写法的 = 与 <>
+var legacyOperators = map[string]string{
+	"==": "==", "=": "==",
+	"!=": "!=", "<>": "!=",
+	">": ">", ">=": ">=",
+	"<": "<", "<=": "<=",
+}
+
+// ProcessCondition 旧版流转条件，Extra 为与下一条件的连接符 AND/OR
+type ProcessCondition struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+	Extra    string `json:"extra"`
+}
+
+// matchExpression 判断流转条件是否满足，表达式为 1 时直接通过
+func matchExpression(src string, env *expression.Env) (bool, error) {
+	src = strings.TrimSpace(src)
+	if src == "1" {
+		return true, nil
+	}
+	if strings.HasPrefix(src, "[") {
+		legacy, err := legacyExpression(src)
+		if err != nil {
+			return false, err
+		}
+		src = legacy
+	}
+	ok, err := expression.Evaluate(src, env)
+	if err != nil {
+		return false, fmt.Errorf("条件语法错误，请检查: %v", err)
+	}
+	return ok, nil
+}
+
+// legacyExpression 将旧版 JSON 条件数组转换为表达式，连接符按从左到右结合
+func legacyExpression(src string) (string, error) {
+	var conditions []ProcessCondition
+	if err := json.Unmarshal([]byte(src), &conditions); err != nil {
+		return "", errors.New("条件语法错误，请检查")
+	}
+	if len(conditions) == 0 {
+		return "false", nil
+	}
+	result := ""
+	connector := ""
+	for _, condition := range conditions {
+		field := strings.TrimSpace(condition.Field)
+		if !legacyField.MatchString(field) {
+			return "", fmt.Errorf("条件语法错误，请检查: 字段[%s]不合法", condition.Field)
+		}
+		operator, ok := legacyOperators[strings.TrimSpace(condition.Operator)]
+		if !ok {
+			return "", fmt.Errorf("条件语法错误，请检查: 不支持的运算符[%s]", condition.Operator)
+		}
+		value := strings.Trim(strings.TrimSpace(condition.Value), `'"`)
+		if f, err := strconv.ParseFloat(value, 64); err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			value = strconv.Quote(value)
+		}
+		item := fmt.Sprintf("%s %s %s", field, operator, value)
+		if result == "" {
+			result = item
+		} else {
+			result = fmt.Sprintf("(%s) %s %s", result, connector, item)
+		}
+		connector = "and"
+		if strings.EqualFold(strings.TrimSpace(condition.Extra), "or") {
+			connector = "or"
+		}
+	}
+	return result, nil
+}
+
+// conditionEnv 构建流转条件的求值环境：表单字段、发起人信息、流程状态及组织架构函数
+func conditionEnv(db *gorm.DB, entry *models.Entry) *expression.Env {
+	var emp models.Emp
+	db.First(&emp, entry.EmpID)
+	return newConditionEnv(db, entry, emp, entryDataValues(db, entry.ID))
+}
+
+// newConditionEnv 按给定的发起人及类型化的表单数据构建流转条件的求值环境；
+// 内置变量优先于同名的表单字段，表单数据不能改变发起人、流程状态等流转依据
+func newConditionEnv(db *gorm.DB, entry *models.Entry, initiator models.Emp, data map[string]interface{}) *expression.Env {
+	vars := make(map[string]interface{}, len(data)+len(conditionBuiltinVars))
+	for field, value := range data {
+		vars[field] = value
+	}
+	for column, values := range tableColumns(data) {
+		if _, ok := vars[column]; !ok {
+			vars[column] = values
+		}
+	}
+	vars["initiator"] = entry.EmpID
+	vars["initiator_dept"] = initiator.DeptID
+	vars["circle"] = entry.Circle
+	vars["title"] = entry.Title
+	vars["status"] = int(entry.Status)
+	return &expression.Env{
+		Vars:  vars,
+		Funcs: orgFuncs(db),
+	}
+}
+
+// tableColumns 明细表格各列的值，只出现在一个明细表格中的列可直接以列名引用，如 sum(amount)
+func tableColumns(data map[string]interface{}) map[string][]interface{} {
+	columns := make(map[string][]interface{})
+	tables := make(map[string]int)
+	for _, value := range data {
+		rows, ok := value.([]interface{})
+		if !ok {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, row := range rows {
+			if obj, ok := row.(map[string]interface{}); ok {
+				for column := range obj {
+					seen[column] = true
+				}
+			}
+		}
+		for column := range seen {
+			tables[column]++
+			columns[column] = expression.Pluck(rows, column)
+		}
+	}
+	for column, n := range tables {
+		if n > 1 {
+			delete(columns, column)
+		}
+	}
+	return columns
+}
+
+// orgFuncs 组织架构查询函数
+func orgFuncs(db *gorm.DB) map[string]expression.Func {
+	deptOf := func(v interface{}) (models.Dept, error) {
+		var dept models.Dept
+		empID, err := expression.ToNumber(v)
+		if err != nil {
+			return dept, err
+		}
+		var emp models.Emp
+		if db.First(&emp, uint(empID)).Error != nil {
+			return dept, nil
+		}
+		db.First(&dept, emp.DeptID)
+		return dept, nil
+	}
+	return map[string]expression.Func{
+		// dept_of(员工ID) 员工所在部门ID
+		"dept_of": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, errors.New("参数个数错误")
+			}
+			dept, err := deptOf(args[0])
+			return dept.ID, err
+		},
+		// director_of(员工ID) 员工所在部门主管ID
+		"director_of": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, errors.New("参数个数错误")
+			}
+			dept, err := deptOf(args[0])
+			return dept.DirectorID, err
+		},
+		// manager_of(员工ID) 员工所在部门经理ID
+		"manager_of": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, errors.New("参数个数错误")
+			}
+			dept, err := deptOf(args[0])
+			return dept.ManagerID, err
+		},
+		// in_dept(员工ID, 部门ID) 员工是否属于该部门或其下级部门
+		"in_dept": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 2 {
+				return nil, errors.New("参数个数错误")
+			}
+			dept, err := deptOf(args[0])
+			if err != nil {
+				return nil, err
+			}
+			target, err := expression.ToNumber(args[1])
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range deptChain(db, dept.ID) {
+				if id == uint(target) {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+		// is_director(员工ID) 员工是否为所在部门主管
+		"is_director": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, errors.New("参数个数错误")
+			}
+			dept, err := deptOf(args[0])
+			if err != nil {
+				return nil, err
+			}
+			empID, _ := expression.ToNumber(args[0])
+			return dept.ID > 0 && dept.DirectorID == int(empID), nil
+		},
+	}
+}
+
+// deptChain 从指定部门沿 Pid 向上的部门ID链，包含自身
+func deptChain(db *gorm.DB, deptID uint) []uint {
+	var chain []uint
+	seen := make(map[uint]bool)
+	for deptID > 0 && !seen[deptID] {
+		seen[deptID] = true
+		chain = append(chain, deptID)
+		var dept models.Dept
+		if db.First(&dept, deptID).Error != nil {
+			break
+		}
+		deptID = dept.Pid
+	}
+	return chain
+}
@@ -0,0 +1,68 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+func TestLegacyExpression(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{`[]`, "false"},
+		{`[{"field":"amount","operator":">=","value":"100"}]`, `amount >= 100`},
+		{`[{"field":"dept","operator":"=","value":"'研发'","extra":"OR"},{"field":"amount","operator":"<>","value":"0"}]`, `(dept == "研发") or amount != 0`},
+		{`[{"field":"items.amount","operator":"<","value":"NaN"}]`, `items.amount < "NaN"`},
+	}
+	for _, c := range cases {
+		got, err := legacyExpression(c.src)
+		if err != nil {
+			t.Fatalf("%s: %v", c.src, err)
+		}
+		if got != c.want {
+			t.Fatalf("%s 转换为 %q，期望 %q", c.src, got, c.want)
+		}
+	}
+}
+
+// 字段名与运算符不合法的旧版条件不拼入表达式
+func TestLegacyExpressionRejectsInjection(t *testing.T) {
+	for _, src := range []string{
+		`[{"field":"1 or amount","operator":">","value":"0"}]`,
+		`[{"field":"amount","operator":"> 0 or amount >","value":"0"}]`,
+		`[{"field":"amount","operator":"like","value":"0"}]`,
+		`[{"field":"","operator":"==","value":"0"}]`,
+	} {
+		if got, err := legacyExpression(src); err == nil {
+			t.Fatalf("%s 应报错，实际转换为 %q", src, got)
+		}
+	}
+}
+
+// 表单中与内置变量同名的字段不能冒充发起人等流转依据
+func TestConditionEnvBuiltinsOverrideFormData(t *testing.T) {
+	e := newTestEnv(t)
+	entry := &models.Entry{EmpID: empAlice, Title: "报销", Status: models.EntryStatusRunning}
+	env := newConditionEnv(e.db, entry, models.Emp{DeptID: 1}, map[string]interface{}{
+		"initiator":      float64(empBob),
+		"initiator_dept": 2.0,
+		"title":          "伪造",
+		"status":         float64(models.EntryStatusCompleted),
+		"amount":         100.0,
+	})
+	for expr, want := range map[string]bool{
+		"initiator == 1":      true,
+		"initiator_dept == 1": true,
+		`title == "报销"`:       true,
+		"status == 0":         true,
+		"amount == 100":       true,
+	} {
+		got, err := matchExpression(expr, env)
+		e.must(err)
+		if got != want {
+			t.Fatalf("%s 求值为 %v，期望 %v", expr, got, want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package workflow
+
+import (
+	"fmt"
+	"github.com/dromara/carbon/v2"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"gorm.io/gorm"
+	"math"
+)
+
+// 多人审批方式
+const (
+	ApproveModeAny        = "any"        // 或签：任一人通过即通过
+	ApproveModeAll        = "all"        // 会签：全部通过才通过
+	ApproveModePercent    = "percent"    // 按比例：通过人数达到比例即通过
+	ApproveModeCount      = "count"      // 按人数：通过人数达到设定人数即通过
+	ApproveModeSequential = "sequential" // 依次审批：按审批人顺序逐个审批，全部通过才通过
+)
+
+// 驳回策略
+const (
+	RejectPolicyAny         = "any"         // 任一人驳回即驳回
+	RejectPolicyUnreachable = "unreachable" // 剩余人数无法满足通过条件时驳回
+)
+
+// approveMode 步骤的审批方式，未设置时为或签
+func approveMode(process models.Process) string {
+	if process.ApproveMode == "" {
+		return ApproveModeAny
+	}
+	return process.ApproveMode
+}
+
+// requiredApprovals 步骤通过所需的通过人数
+func requiredApprovals(process models.Process, total int) int {
+	required := 1
+	switch approveMode(process) {
+	case ApproveModeAll, ApproveModeSequential:
+		required = total
+	case ApproveModePercent:
+		required = int(math.Ceil(float64(total) * float64(process.ApproveThreshold) / 100))
+	case ApproveModeCount:
+		required = process.ApproveThreshold
+	}
+	if required > total {
+		required = total
+	}
+	if required < 1 {
+		required = 1
+	}
+	return required
+}
+
+// stepBatch 与待办同一批次的全部待办（同一步骤、同一轮次、同一 Concurrence）
+func (s *Service) stepBatch(t *flowTx, proc models.Proc) ([]models.Proc, error) {
+	var procs []models.Proc
+	err := t.Scopes(forUpdate).Where("entry_id=?", proc.EntryID).
+		Where("process_id=?", proc.ProcessID).
+		Where("circle=?", proc.Circle).
+		Order("id asc").Find(&procs).Error
+	if err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	batch := make([]models.Proc, 0, len(procs))
+	for _, p := range procs {
+		if sameConcurrence(p.Concurrence, proc.Concurrence) {
+			batch = append(batch, p)
+		}
+	}
+	return batch, nil
+}
+
+func sameConcurrence(a, b *carbon.Timestamp) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Int64() == b.Int64()
+}
+
+// batchConcurrence 新批次的 Concurrence，同一步骤同一轮次内保证与之前的批次不同
+func (s *Service) batchConcurrence(t *flowTx, entry *models.Entry, processID int) *carbon.Timestamp {
+	now := carbon.Now().Timestamp()
+	var last int64
+	t.Model(&models.Proc{}).
+		Where("entry_id=?", entry.ID).
+		Where("process_id=?", processID).
+		Where("circle=?", entry.Circle).
+		Select("COALESCE(MAX(concurrence), 0)").Scan(&last)
+	if last >= now {
+		now = last + 1
+	}
+	return carbon.NewTimestamp(carbon.CreateFromTimestamp(now))
+}
+
+// batchTotal 批次应参与审批的人数，依次审批时为全部审批人
+func (s *Service) batchTotal(t *flowTx, entry *models.Entry, g *flowGraph, process models.Process, batch []models.Proc) (int, []models.Emp, error) {
+	if approveMode(process) != ApproveModeSequential {
+		return len(tallyMembers(batch)), nil, nil
+	}
+	auditors, err := s.processAuditors(t, entry, g, int(process.ID))
+	if err != nil {
+		return 0, nil, err
+	}
+	total := len(auditors)
+	if members := len(tallyMembers(batch)); total < members {
+		total = members
+	}
+	return total, auditors, nil
+}
+
+// settleApproval 审批通过后按审批方式汇总批次结果，返回步骤是否已通过
+func (s *Service) settleApproval(t *flowTx, entry *models.Entry, g *flowGraph, process models.Process, proc models.Proc) (bool, error) {
+	batch, err := s.stepBatch(t, proc)
+	if err != nil {
+		return false, err
+	}
+	total, auditors, err := s.batchTotal(t, entry, g, process, batch)
+	if err != nil {
+		return false, err
+	}
+	passed := 0
+	handled := make(map[int]bool, len(batch))
+	for _, p := range batch {
+		if !isSigner(p) {
+			handled[p.EmpID] = true
+		}
+	}
+	for _, p := range tallyMembers(batch) {
+		if p.Status == models.ProcStatusPassed {
+			passed++
+		}
+	}
+	if passed < requiredApprovals(process, total) {
+		s.cancelDeadlines(t, proc)
+		if approveMode(process) == ApproveModeSequential {
+			// 依次审批：为下一位审批人生成待办
+			for _, auditor := range auditors {
+				if handled[int(auditor.ID)] {
+					continue
+				}
+				if err = s.createProc(t, entry, proc.BranchID, process, auditor, proc.Concurrence); err != nil {
+					return false, err
+				}
+				return false, s.autoApprove(t, entry, g, process, proc.BranchID)
+			}
+			return true, nil
+		}
+		return false, nil
+	}
+	// 已满足通过条件，同批次其他待办及加签待办无需再处理，一并取消
+	_, err = s.transitProcs(t, func(db *gorm.DB) *gorm.DB {
+		return db.Where("entry_id=?", proc.EntryID).
+			Where("process_id=?", proc.ProcessID).
+			Where("circle=?", proc.Circle).
+			Where("concurrence=?", proc.Concurrence).
+			Where("status IN (?)", openProcStatuses)
+	}, models.ProcStatusCancelled, "已满足通过条件", map[string]interface{}{
+		"is_real": false,
+		"content": "已满足通过条件",
+	})
+	if err != nil {
+		return false, err
+	}
+	s.cancelDeadlines(t, batch...)
+	return true, nil
+}
+
+// rejectionHolds 驳回后按驳回策略判断步骤是否仍可能通过，可能通过时流程继续等待其他审批人
+func (s *Service) rejectionHolds(t *flowTx, entry *models.Entry, g *flowGraph, process models.Process, proc models.Proc) (bool, error) {
+	if process.RejectPolicy != RejectPolicyUnreachable {
+		return false, nil
+	}
+	batch, err := s.stepBatch(t, proc)
+	if err != nil {
+		return false, err
+	}
+	total, _, err := s.batchTotal(t, entry, g, process, batch)
+	if err != nil {
+		return false, err
+	}
+	rejected := 0
+	for _, p := range tallyMembers(batch) {
+		if p.Status == models.ProcStatusRejected {
+			rejected++
+		}
+	}
+	return total-rejected >= requiredApprovals(process, total), nil
+}
@@ -0,0 +1,87 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// countersignFlow 发起 → 审批(bob、carol、dave) → 结束
+func countersignFlow(e *testEnv, process models.Process) *models.Entry {
+	flowID := e.flow("countersign")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	process.ProcessName = "审批"
+	a := e.step(flowID, process, "2,3,4", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, -1, "")
+	return e.start(flowID, nil)
+}
+
+func TestCountersignModes(t *testing.T) {
+	cases := []struct {
+		name    string
+		process models.Process
+		passes  int // 流程结束所需的通过人数
+	}{
+		{"或签", models.Process{ApproveMode: ApproveModeAny}, 1},
+		{"会签", models.Process{ApproveMode: ApproveModeAll}, 3},
+		{"按比例", models.Process{ApproveMode: ApproveModePercent, ApproveThreshold: 50}, 2},
+		{"按人数", models.Process{ApproveMode: ApproveModeCount, ApproveThreshold: 2}, 2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := newTestEnv(t)
+			entry := countersignFlow(e, c.process)
+			auditors := []uint{empBob, empCarol, empDave}
+			for i := 0; i < c.passes; i++ {
+				e.expectStatus(entry.ID, models.EntryStatusRunning)
+				e.pass(entry.ID, auditors[i])
+			}
+			e.expectStatus(entry.ID, models.EntryStatusCompleted)
+			// 满足通过条件后其余审批人的待办取消，不记为其通过
+			var rest []models.Proc
+			e.must(e.db.Where("entry_id=?", entry.ID).Where("emp_id IN (?)", auditors[c.passes:]).Find(&rest).Error)
+			for _, p := range rest {
+				if p.Status != models.ProcStatusCancelled || p.IsReal || p.AuditorID != 0 {
+					t.Fatalf("员工%d的待办应取消：状态%s is_real=%v auditor_id=%d", p.EmpID, p.Status, p.IsReal, p.AuditorID)
+				}
+			}
+		})
+	}
+}
+
+// 依次审批按审批人顺序逐个生成待办
+func TestCountersignSequential(t *testing.T) {
+	e := newTestEnv(t)
+	entry := countersignFlow(e, models.Process{ApproveMode: ApproveModeSequential})
+	for _, empID := range []uint{empBob, empCarol, empDave} {
+		e.expectStatus(entry.ID, models.EntryStatusRunning)
+		var open int64
+		e.db.Model(&models.Proc{}).Where("entry_id=?", entry.ID).Where("status=?", models.ProcStatusPending).Count(&open)
+		if open != 1 {
+			t.Fatalf("依次审批同时只应有一个待办；待办 %s", e.procs(entry.ID))
+		}
+		e.pass(entry.ID, empID)
+	}
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
+
+// 剩余人数仍可满足通过条件时驳回不终止流程
+func TestCountersignRejectUnreachable(t *testing.T) {
+	e := newTestEnv(t)
+	entry := countersignFlow(e, models.Process{ApproveMode: ApproveModeCount, ApproveThreshold: 2, RejectPolicy: RejectPolicyUnreachable})
+	e.must(e.s.Reject(e.pending(entry.ID, empBob), empBob, "no"))
+	e.expectStatus(entry.ID, models.EntryStatusRunning)
+	e.must(e.s.Reject(e.pending(entry.ID, empCarol), empCarol, "no"))
+	e.expectStatus(entry.ID, models.EntryStatusRejected)
+}
+
+// 会签的最后两位审批人同时通过时步骤仍能通过
+func TestCountersignConcurrentApprovals(t *testing.T) {
+	e := newTestEnv(t)
+	entry := countersignFlow(e, models.Process{ApproveMode: ApproveModeAll})
+	e.pass(entry.ID, empDave)
+	procs := map[uint]uint{empBob: e.pending(entry.ID, empBob), empCarol: e.pending(entry.ID, empCarol)}
+	e.passConcurrently(procs)
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
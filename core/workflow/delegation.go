@@ -0,0 +1,292 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dromara/carbon/v2"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+)
+
+// maxDelegationDepth 委托链最多向下查找的层数
+const maxDelegationDepth = 10
+
+// delegationCovers 委托规则在指定时间是否对流程生效
+func delegationCovers(d models.Delegation, flowID uint, at int64) bool {
+	if d.StartTime > at || d.EndTime < at {
+		return false
+	}
+	if strings.TrimSpace(d.FlowIds) == "" {
+		return true
+	}
+	for _, id := range splitIds(d.FlowIds) {
+		if uint(id) == flowID {
+			return true
+		}
+	}
+	return false
+}
+
+// activeDelegation 员工当前对该流程生效的委托规则
+func activeDelegation(db *gorm.DB, empID uint, flowID uint, at int64) (models.Delegation, bool) {
+	var delegations []models.Delegation
+	db.Where("emp_id=?", empID).
+		Where("start_time <= ?", at).
+		Where("end_time >= ?", at).
+		Order("id desc").Find(&delegations)
+	for _, d := range delegations {
+		if delegationCovers(d, flowID, at) {
+			return d, true
+		}
+	}
+	return models.Delegation{}, false
+}
+
+// resolveDelegate 按委托链查找审核人当前的最终受托人：A 委托 B、B 又委托 C 时为 C；
+// 无委托时返回0；委托链形成循环时委托不生效，同样返回0，待办仍由审核人本人处理
+func resolveDelegate(db *gorm.DB, empID uint, flowID uint) uint {
+	now := carbon.Now().Timestamp()
+	seen := map[uint]bool{empID: true}
+	current := empID
+	for i := 0; i < maxDelegationDepth; i++ {
+		d, ok := activeDelegation(db, current, flowID, now)
+		if !ok {
+			break
+		}
+		if seen[d.DelegateID] {
+			return 0
+		}
+		seen[d.DelegateID] = true
+		current = d.DelegateID
+	}
+	if current == empID {
+		return 0
+	}
+	return current
+}
+
+// canActFor 操作人是否可以处理该待办：本人，或当前有效的受托人
+func canActFor(db *gorm.DB, proc models.Proc, empID uint) bool {
+	if uint(proc.EmpID) == empID {
+		return true
+	}
+	return resolveDelegate(db, uint(proc.EmpID), uint(proc.FlowID)) == empID
+}
+
+// applyDelegation 生成待办时按委托规则记录受托人并通知受托人；记录的受托人仅为生成时的快照，
+// 委托到期或尚未生效时不会随之更新，处理权限、通知及查询结果均按当前生效的委托规则重新计算
+func (s *Service) applyDelegation(t *flowTx, proc *models.Proc) {
+	delegateID := resolveDelegate(t.DB, uint(proc.EmpID), uint(proc.FlowID))
+	if delegateID == 0 {
+		return
+	}
+	proc.DelegateID = int(delegateID)
+	t.afterCommit(func() {
+		_ = s.wf.NotifyNextAuditor(delegateID)
+	})
+}
+
+// Delegations 员工设置的委托规则，empID 为0时查询全部
+func (s *Service) Delegations(empID uint) ([]models.Delegation, error) {
+	var delegations []models.Delegation
+	query := s.db.Preload("Emp").Preload("Delegate").Order("id desc")
+	if empID > 0 {
+		query = query.Where("emp_id=?", empID)
+	}
+	if err := query.Find(&delegations).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return delegations, nil
+}
+
+// AddDelegation 新增委托规则，不允许形成委托循环；生效中的规则会应用到委托人已有的待办
+func (s *Service) AddDelegation(d *models.Delegation) error {
+	if d.EmpID == 0 || d.DelegateID == 0 {
+		return errors.New("请选择委托人和受托人")
+	}
+	if d.EmpID == d.DelegateID {
+		return errors.New("不能委托给自己")
+	}
+	if d.EndTime <= d.StartTime {
+		return errors.New("委托结束时间必须晚于开始时间")
+	}
+	var count int64
+	s.db.Model(&models.Emp{}).Where("id IN (?)", []uint{d.EmpID, d.DelegateID}).Count(&count)
+	if count != 2 {
+		return errors.New("委托人或受托人不存在")
+	}
+	d.FlowIds = joinIds(splitIds(d.FlowIds))
+	if s.delegationLoop(d) {
+		return errors.New("受托人在委托期间已将审批委托回委托人，不能形成委托循环")
+	}
+	return s.transaction(func(t *flowTx) error {
+		if err := t.Create(d).Error; err != nil {
+			return fmt.Errorf("数据库插入错误: %v", err)
+		}
+		return s.refreshDelegates(t, d.EmpID)
+	})
+}
+
+// DeleteDelegation 删除委托规则，委托人已有待办的受托人随之更新
+func (s *Service) DeleteDelegation(id uint) error {
+	var d models.Delegation
+	if err := s.db.First(&d, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("委托规则不存在")
+		}
+		return fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return s.transaction(func(t *flowTx) error {
+		if err := t.Delete(&d).Error; err != nil {
+			return fmt.Errorf("数据库删除错误: %v", err)
+		}
+		return s.refreshDelegates(t, d.EmpID)
+	})
+}
+
+// refreshDelegates 委托规则变化后，重新计算待办的受托人；委托人本身是他人受托人时，上游委托人的待办一并更新
+func (s *Service) refreshDelegates(t *flowTx, empID uint) error {
+	affected := map[uint]bool{empID: true}
+	queue := []uint{empID}
+	for len(queue) > 0 {
+		var upstream []uint
+		t.Model(&models.Delegation{}).Where("delegate_id=?", queue[0]).Pluck("emp_id", &upstream)
+		queue = queue[1:]
+		for _, id := range upstream {
+			if !affected[id] {
+				affected[id] = true
+				queue = append(queue, id)
+			}
+		}
+	}
+	empIds := make([]uint, 0, len(affected))
+	for id := range affected {
+		empIds = append(empIds, id)
+	}
+	var procs []models.Proc
+	if err := t.Where("emp_id IN (?)", empIds).Where("status=?", models.ProcStatusPending).Find(&procs).Error; err != nil {
+		return fmt.Errorf("数据库查询错误: %v", err)
+	}
+	for _, proc := range procs {
+		delegateID := int(resolveDelegate(t.DB, uint(proc.EmpID), uint(proc.FlowID)))
+		if delegateID == proc.DelegateID {
+			continue
+		}
+		if err := t.Model(&models.Proc{}).Where("id=?", proc.ID).Update("delegate_id", delegateID).Error; err != nil {
+			return fmt.Errorf("数据库更新错误: %v", err)
+		}
+		if delegateID > 0 {
+			t.afterCommit(func() {
+				_ = s.wf.NotifyNextAuditor(uint(delegateID))
+			})
+		}
+	}
+	return nil
+}
+
+// delegationLoop 新规则是否会与时间、流程范围重叠的已有规则形成委托循环
+func (s *Service) delegationLoop(d *models.Delegation) bool {
+	var rules []models.Delegation
+	s.db.Where("start_time <= ?", d.EndTime).Where("end_time >= ?", d.StartTime).Find(&rules)
+	seen := map[uint]bool{}
+	queue := []uint{d.DelegateID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == d.EmpID {
+			return true
+		}
+		if seen[current] {
+			continue
+		}
+		seen[current] = true
+		for _, r := range rules {
+			if r.EmpID == current && flowScopesOverlap(r.FlowIds, d.FlowIds) {
+				queue = append(queue, r.DelegateID)
+			}
+		}
+	}
+	return false
+}
+
+// flowScopesOverlap 两个委托范围是否有共同的流程，为空表示全部流程
+func flowScopesOverlap(a string, b string) bool {
+	if a == "" || b == "" {
+		return true
+	}
+	ids := make(map[int]bool)
+	for _, id := range splitIds(a) {
+		ids[id] = true
+	}
+	for _, id := range splitIds(b) {
+		if ids[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// DelegatedProcs 受托人当前可代为处理的待办
+func (s *Service) DelegatedProcs(empID uint) ([]models.Proc, error) {
+	now := carbon.Now().Timestamp()
+	// 直接或经委托链委托给该员工的委托人
+	var rules []models.Delegation
+	s.db.Where("start_time <= ?", now).Where("end_time >= ?", now).Find(&rules)
+	candidates := map[uint]bool{}
+	queue := []uint{empID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, r := range rules {
+			if r.DelegateID == current && r.EmpID != empID && !candidates[r.EmpID] {
+				candidates[r.EmpID] = true
+				queue = append(queue, r.EmpID)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return []models.Proc{}, nil
+	}
+	empIds := make([]uint, 0, len(candidates))
+	for id := range candidates {
+		empIds = append(empIds, id)
+	}
+	var procs []models.Proc
+	err := s.db.Preload("Entry").
+		Joins("JOIN entries ON entries.id = procs.entry_id").
+		Where("procs.emp_id IN (?)", empIds).
+		Where("procs.status=?", models.ProcStatusPending).
+		Where("entries.status=?", models.EntryStatusRunning).
+		Order("procs.id asc").Find(&procs).Error
+	if err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	result := make([]models.Proc, 0, len(procs))
+	for _, proc := range procs {
+		if canActFor(s.db, proc, empID) {
+			proc.DelegateID = int(empID)
+			result = append(result, proc)
+		}
+	}
+	return result, nil
+}
+
+// currentDelegates 按当前生效的委托规则填充待办的受托人，替换生成待办时记录的受托人
+func currentDelegates(db *gorm.DB, procs []models.Proc) {
+	for i := range procs {
+		if procs[i].Status == models.ProcStatusPending {
+			procs[i].DelegateID = int(resolveDelegate(db, uint(procs[i].EmpID), uint(procs[i].FlowID)))
+		}
+	}
+}
+
+// joinIds 将ID列表拼接为逗号分隔的字符串
+func joinIds(ids []int) string {
+	strs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strs = append(strs, strconv.Itoa(id))
+	}
+	return strings.Join(strs, ",")
+}
@@ -0,0 +1,116 @@
+package workflow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// delegationFlow 发起 → A(bob) → 结束
+func delegationFlow(e *testEnv) *models.Entry {
+	flowID := e.flow("delegation")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, -1, "")
+	return e.start(flowID, nil)
+}
+
+// delegate 新增当前生效的委托规则
+func (e *testEnv) delegate(empID uint, delegateID uint) *models.Delegation {
+	e.t.Helper()
+	now := time.Now().Unix()
+	d := &models.Delegation{EmpID: empID, DelegateID: delegateID, StartTime: now - 60, EndTime: now + 3600}
+	e.must(e.s.AddDelegation(d))
+	return d
+}
+
+// 委托链的最终受托人代为处理，中间的受托人不能处理
+func TestDelegationChain(t *testing.T) {
+	e := newTestEnv(t)
+	entry := delegationFlow(e)
+	e.delegate(empBob, empCarol)
+	e.delegate(empCarol, empDave)
+	procID := e.pending(entry.ID, empBob)
+	var proc models.Proc
+	e.must(e.db.First(&proc, procID).Error)
+	if proc.DelegateID != int(empDave) {
+		t.Fatalf("受托人应更新为委托链末端的员工%d，实际为%d", empDave, proc.DelegateID)
+	}
+	procs, err := e.s.DelegatedProcs(empDave)
+	e.must(err)
+	if len(procs) != 1 || procs[0].ID != procID {
+		t.Fatalf("受托人可代为处理的待办应为%d，实际 %+v", procID, procs)
+	}
+	if err = e.s.Pass(procID, empCarol, "ok"); err == nil {
+		t.Fatal("委托链中间的受托人不应可以处理")
+	}
+	e.must(e.s.Pass(procID, empDave, "ok"))
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
+
+// 不允许新增形成循环的委托；已存在的循环委托不生效，待办仍由本人处理
+func TestDelegationLoop(t *testing.T) {
+	e := newTestEnv(t)
+	entry := delegationFlow(e)
+	d := e.delegate(empBob, empCarol)
+	if err := e.s.AddDelegation(&models.Delegation{EmpID: empCarol, DelegateID: empBob, StartTime: d.StartTime, EndTime: d.EndTime}); err == nil {
+		t.Fatal("不应可以新增形成循环的委托")
+	}
+	e.must(e.db.Create(&models.Delegation{EmpID: empCarol, DelegateID: empBob, StartTime: d.StartTime, EndTime: d.EndTime}).Error)
+	procID := e.pending(entry.ID, empBob)
+	if err := e.s.Pass(procID, empCarol, "ok"); err == nil {
+		t.Fatal("循环委托不应生效")
+	}
+	e.must(e.s.Pass(procID, empBob, "ok"))
+}
+
+// 委托到期后受托人不再能处理、查看，查询结果中的受托人按当前规则计算
+func TestDelegationExpired(t *testing.T) {
+	e := newTestEnv(t)
+	entry := delegationFlow(e)
+	d := e.delegate(empBob, empDave)
+	procID := e.pending(entry.ID, empBob)
+	if !e.s.CanView(entry.ID, empDave) {
+		t.Fatal("受托人应可查看流程")
+	}
+	e.must(e.db.Model(&models.Delegation{}).Where("id=?", d.ID).Update("end_time", time.Now().Unix()-1).Error)
+	e.must(e.db.Model(&models.Proc{}).Where("id=?", procID).Update("deadline", time.Now().Unix()-1).Error)
+
+	if e.s.CanView(entry.ID, empDave) {
+		t.Fatal("委托到期后受托人不应可查看流程")
+	}
+	procs, err := e.s.DelegatedProcs(empDave)
+	e.must(err)
+	if len(procs) != 0 {
+		t.Fatalf("委托到期后不应有可代为处理的待办，实际 %+v", procs)
+	}
+	overdue, _, err := e.s.OverdueProcs(empBob, 10, 0)
+	e.must(err)
+	if len(overdue) != 1 || overdue[0].DelegateID != 0 {
+		t.Fatalf("超期待办的受托人应按当前规则为空，实际 %+v", overdue)
+	}
+	if err = e.s.Pass(procID, empDave, "ok"); err == nil {
+		t.Fatal("委托到期后受托人不应可以处理")
+	}
+	e.must(e.s.Pass(procID, empBob, "ok"))
+}
+
+// 生成待办后才生效的委托，受托人同样可以代为处理，处理记录计入撤回判断
+func TestDelegationStartsLater(t *testing.T) {
+	e := newTestEnv(t)
+	now := time.Now().Unix()
+	d := &models.Delegation{EmpID: empBob, DelegateID: empDave, StartTime: now + 3600, EndTime: now + 7200}
+	e.must(e.s.AddDelegation(d))
+	entry := recallFlow(e, RecallPolicyUnhandled)
+	procID := e.pending(entry.ID, empBob)
+	if err := e.s.Pass(procID, empDave, "ok"); err == nil {
+		t.Fatal("委托生效前受托人不应可以处理")
+	}
+	e.must(e.db.Model(&models.Delegation{}).Where("id=?", d.ID).Update("start_time", now-60).Error)
+	e.must(e.s.Pass(procID, empDave, "ok"))
+	if err := e.s.Recall(entry.ID, empAlice, ""); err == nil {
+		t.Fatal("受托人已处理时不应可以撤回")
+	}
+}
@@ -0,0 +1,541 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"gorm.io/gorm"
+	"html"
+	"math"
+	"strings"
+)
+
+// 流程图格式
+const (
+	DiagramDOT     = "dot"     // Graphviz DOT
+	DiagramMermaid = "mermaid" // Mermaid flowchart
+	DiagramSVG     = "svg"     // 独立的 SVG 图片
+)
+
+// 步骤在流程进度中的状态，未经过的步骤为空
+const (
+	StepStatePassed   = "passed"   // 已通过
+	StepStateCurrent  = "current"  // 当前步骤
+	StepStateRejected = "rejected" // 被驳回
+)
+
+// 流程图中的元素类型
+const (
+	nodeApprove = "approve"
+	nodeChild   = "child"
+	nodeFork    = "fork"
+	nodeJoin    = "join"
+	nodeEnd     = "end"
+)
+
+// defaultStyleColor 步骤未设置颜色时的默认颜色，与步骤颜色字段的默认值一致
+const defaultStyleColor = "#78a300"
+
+// stateColors 流程进度中各状态的填充色与边框色，未经过的步骤为灰色
+var stateColors = map[string][2]string{
+	StepStatePassed:   {"#f6ffed", "#52c41a"},
+	StepStateCurrent:  {"#e6f7ff", "#1890ff"},
+	StepStateRejected: {"#fff1f0", "#f5222d"},
+	"":                {"#fafafa", "#bfbfbf"},
+}
+
+// stateNames 状态说明，用于 SVG 的提示文字
+var stateNames = map[string]string{
+	StepStatePassed:   "已通过",
+	StepStateCurrent:  "当前步骤",
+	StepStateRejected: "被驳回",
+	"":                "未经过",
+}
+
+// diagram 流程图：步骤、流转及布局，progress 为 true 时按流程进度着色
+type diagram struct {
+	name     string
+	start    int
+	nodes    []*diagramNode
+	edges    []*diagramEdge
+	progress bool
+}
+
+// diagramNode 流程图中的步骤，结束的 id 为 -1
+type diagramNode struct {
+	id    int
+	label string
+	kind  string
+	color string
+	x, y  int
+	w, h  int
+	state string
+}
+
+// diagramEdge 流程图中的流转，taken 为流程实际经过的流转
+type diagramEdge struct {
+	from, to int
+	label    string
+	taken    bool
+}
+
+// RenderFlow 按流程当前（编辑中）的定义生成流程图，format 为 dot、mermaid 或 svg
+func (s *Service) RenderFlow(flowID uint, format string) (string, error) {
+	g, err := loadGraph(s.db, flowID)
+	if err != nil {
+		return "", err
+	}
+	return newDiagram(g).render(format)
+}
+
+// RenderEntry 按流程实例发起时的版本生成流程图，标出本轮已通过、当前及被驳回的步骤和实际经过的流转
+func (s *Service) RenderEntry(entryID uint, format string) (string, error) {
+	var entry models.Entry
+	if err := s.db.First(&entry, entryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errors.New("流程不存在")
+		}
+		return "", fmt.Errorf("数据库查询错误: %v", err)
+	}
+	g, err := s.entryGraph(&flowTx{DB: s.db}, &entry)
+	if err != nil {
+		return "", err
+	}
+	d := newDiagram(g)
+	if err = d.overlay(s.db, entry); err != nil {
+		return "", err
+	}
+	return d.render(format)
+}
+
+// newDiagram 由流程定义生成流程图，步骤位置互不重叠时按设计器中的位置布局，否则按流转层级自动布局
+func newDiagram(g *flowGraph) *diagram {
+	d := &diagram{name: g.Flow.FlowName}
+	end := false
+	for _, p := range g.Processes {
+		node := &diagramNode{id: int(p.ID), label: p.ProcessName, kind: nodeApprove, color: p.StyleColor, w: 140, h: 48}
+		switch {
+		case p.GatewayType == GatewayFork:
+			node.kind, node.w, node.h = nodeFork, 56, 56
+		case p.GatewayType == GatewayJoin:
+			node.kind, node.w, node.h = nodeJoin, 56, 56
+		case p.ChildFlowID > 0:
+			node.kind = nodeChild
+		}
+		if node.color == "" {
+			node.color = defaultStyleColor
+		}
+		d.nodes = append(d.nodes, node)
+
+		for _, l := range g.conditions(p.ID) {
+			label, _ := bpmnExpressionText(l.Expression)
+			d.edges = append(d.edges, &diagramEdge{from: int(p.ID), to: l.NextProcessID, label: label})
+			end = end || l.NextProcessID == -1
+		}
+		if p.ChildFlowID > 0 && p.ChildAfter == 1 {
+			d.edges = append(d.edges, &diagramEdge{from: int(p.ID), to: -1, label: "子流程结束"})
+			end = true
+		} else if p.ChildFlowID > 0 && p.ChildBackProcess > 0 {
+			d.edges = append(d.edges, &diagramEdge{from: int(p.ID), to: p.ChildBackProcess, label: "子流程结束"})
+		}
+	}
+	if end {
+		d.nodes = append(d.nodes, &diagramNode{id: -1, label: "结束", kind: nodeEnd, color: defaultStyleColor, w: 48, h: 48})
+	}
+	if starts := g.starts(); len(starts) > 0 {
+		d.start = int(starts[0].ID)
+	}
+	d.layout(g)
+	return d
+}
+
+func (d *diagram) node(id int) *diagramNode {
+	for _, n := range d.nodes {
+		if n.id == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// layout 布局：设计器中的位置互不重叠时采用，结束放在最右侧；否则从第一步骤按流转层级从左到右排列
+func (d *diagram) layout(g *flowGraph) {
+	seen := make(map[[2]int]bool)
+	stored := len(g.Processes) > 0
+	right := 0
+	for _, p := range g.Processes {
+		pos := [2]int{cssPixels(p.PositionLeft), cssPixels(p.PositionTop)}
+		if seen[pos] {
+			stored = false
+			break
+		}
+		seen[pos] = true
+		n := d.node(int(p.ID))
+		n.x, n.y = pos[0], pos[1]
+		if n.x+n.w > right {
+			right = n.x + n.w
+		}
+	}
+	if stored {
+		if end := d.node(-1); end != nil {
+			end.x = right + 80
+			if starts := g.starts(); len(starts) > 0 {
+				first := d.node(int(starts[0].ID))
+				end.y = first.y + first.h/2 - end.h/2
+			}
+		}
+		return
+	}
+
+	depth := make(map[int]int)
+	var queue []int
+	for _, p := range g.starts() {
+		depth[int(p.ID)] = 0
+		queue = append(queue, int(p.ID))
+	}
+	maxDepth := 0
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, e := range d.edges {
+			if e.from != id {
+				continue
+			}
+			if _, ok := depth[e.to]; ok || d.node(e.to) == nil {
+				continue
+			}
+			depth[e.to] = depth[id] + 1
+			if depth[e.to] > maxDepth {
+				maxDepth = depth[e.to]
+			}
+			queue = append(queue, e.to)
+		}
+	}
+	// 结束放在最后一层，无法到达的步骤放在其后
+	if end := d.node(-1); end != nil {
+		depth[-1] = maxDepth + 1
+		maxDepth++
+	}
+	rows := make(map[int]int)
+	for _, n := range d.nodes {
+		level, ok := depth[n.id]
+		if !ok {
+			level = maxDepth + 1
+		}
+		n.x = 40 + level*200 + (140-n.w)/2
+		n.y = 40 + rows[level]*100 + (48-n.h)/2
+		rows[level]++
+	}
+}
+
+// overlay 按本轮的待办及事件日志标出步骤状态和实际经过的流转
+func (d *diagram) overlay(db *gorm.DB, entry models.Entry) error {
+	d.progress = true
+	var procs []models.Proc
+	if err := db.Where("entry_id=?", entry.ID).Where("circle=?", entry.Circle).Find(&procs).Error; err != nil {
+		return fmt.Errorf("数据库查询错误: %v", err)
+	}
+	var moves []models.EntryEvent
+	err := db.Where("entry_id=?", entry.ID).Where("circle=?", entry.Circle).Where("action=?", EventMove).
+		Order("id asc").Find(&moves).Error
+	if err != nil {
+		return fmt.Errorf("数据库查询错误: %v", err)
+	}
+	var branches []models.EntryBranch
+	if err = db.Where("entry_id=?", entry.ID).Where("circle=?", entry.Circle).Find(&branches).Error; err != nil {
+		return fmt.Errorf("数据库查询错误: %v", err)
+	}
+
+	rank := map[string]int{"": 0, StepStatePassed: 1, StepStateRejected: 2, StepStateCurrent: 3}
+	mark := func(id int, state string) {
+		if n := d.node(id); n != nil && rank[state] > rank[n.state] {
+			n.state = state
+		}
+	}
+	for _, p := range procs {
+		switch p.Status {
+		case models.ProcStatusPassed:
+			mark(p.ProcessID, StepStatePassed)
+		case models.ProcStatusRejected:
+			mark(p.ProcessID, StepStateRejected)
+		case models.ProcStatusPending, models.ProcStatusSuspended:
+			mark(p.ProcessID, StepStateCurrent)
+		}
+	}
+	// 网关、子流程及跳过的步骤没有待办，按进入记录视为已经过
+	for _, m := range moves {
+		mark(m.ToProcessID, StepStatePassed)
+	}
+	forked := false
+	for _, b := range branches {
+		forked = forked || b.Status == BranchStatusActive
+	}
+	open := entry.Status == models.EntryStatusRunning || entry.Status == models.EntryStatusSuspended
+	if open && entry.ProcessID > 0 && !forked {
+		mark(int(entry.ProcessID), StepStateCurrent)
+	}
+	if entry.Status == models.EntryStatusCompleted {
+		mark(-1, StepStatePassed)
+		d.take(int(entry.ProcessID), -1)
+	}
+
+	// 主干按进入记录的来源与目标，发起人提交时来源为空，即第一步骤；
+	// 分支的第一条进入记录即为从拆分网关进入的步骤，到达合并网关时不记录进入
+	paths := make(map[int][]int)
+	for _, m := range moves {
+		if m.BranchID == 0 {
+			from := m.FromProcessID
+			if from == 0 {
+				from = d.start
+			}
+			d.take(from, m.ToProcessID)
+			continue
+		}
+		path := paths[m.BranchID]
+		if len(path) == 0 || path[len(path)-1] != m.ToProcessID {
+			paths[m.BranchID] = append(path, m.ToProcessID)
+		}
+	}
+	for _, b := range branches {
+		path := append([]int{b.ForkProcessID}, paths[int(b.ID)]...)
+		if b.Status == BranchStatusJoined {
+			path = append(path, b.JoinProcessID)
+		}
+		if b.Status == BranchStatusActive && len(path) > 1 {
+			mark(path[len(path)-1], StepStateCurrent)
+		}
+		for i := 1; i < len(path); i++ {
+			d.take(path[i-1], path[i])
+		}
+	}
+	return nil
+}
+
+// take 标记流转为实际经过，流程定义中不存在的跳转（退回、超时跳转等）不标记
+func (d *diagram) take(from, to int) {
+	for _, e := range d.edges {
+		if e.from == from && e.to == to {
+			e.taken = true
+		}
+	}
+}
+
+// render 按格式输出流程图
+func (d *diagram) render(format string) (string, error) {
+	switch format {
+	case DiagramDOT:
+		return d.dot(), nil
+	case DiagramMermaid:
+		return d.mermaid(), nil
+	case DiagramSVG:
+		return d.svg(), nil
+	}
+	return "", fmt.Errorf("不支持的流程图格式[%s]", format)
+}
+
+// colors 元素的填充色与边框色：流程进度中按状态，否则按步骤颜色
+func (d *diagram) colors(n *diagramNode) (string, string) {
+	if d.progress {
+		c := stateColors[n.state]
+		return c[0], c[1]
+	}
+	return "#ffffff", n.color
+}
+
+// edgeColor 流转的颜色：流程进度中经过的流转为绿色，未经过的为灰色
+func (d *diagram) edgeColor(e *diagramEdge) string {
+	switch {
+	case !d.progress:
+		return "#595959"
+	case e.taken:
+		return stateColors[StepStatePassed][1]
+	}
+	return stateColors[""][1]
+}
+
+// nodeKey 元素在 DOT、Mermaid 中的标识
+func nodeKey(id int) string {
+	if id == -1 {
+		return "p_end"
+	}
+	return fmt.Sprintf("p%d", id)
+}
+
+// dot 输出 Graphviz DOT，位置以 pos 属性保存，使用 neato -n 时按设计器中的位置绘制
+func (d *diagram) dot() string {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph \"%s\" {\n", quote.Replace(d.name))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [fontname=\"sans-serif\", fontsize=12];\n")
+	b.WriteString("  edge [fontname=\"sans-serif\", fontsize=10];\n")
+	for _, n := range d.nodes {
+		fill, stroke := d.colors(n)
+		shape, style := "box", "rounded,filled"
+		switch n.kind {
+		case nodeChild:
+			style = "filled"
+		case nodeFork, nodeJoin:
+			shape, style = "diamond", "filled"
+		case nodeEnd:
+			shape, style = "doublecircle", "filled"
+		}
+		fmt.Fprintf(&b, "  %s [label=\"%s\", shape=%s, style=\"%s\", color=\"%s\", fillcolor=\"%s\"",
+			nodeKey(n.id), quote.Replace(n.label), shape, style, stroke, fill)
+		if n.kind == nodeChild {
+			b.WriteString(", peripheries=2")
+		}
+		fmt.Fprintf(&b, ", pos=\"%d,%d!\"];\n", n.x+n.w/2, -(n.y + n.h/2))
+	}
+	for _, e := range d.edges {
+		fmt.Fprintf(&b, "  %s -> %s [color=\"%s\"", nodeKey(e.from), nodeKey(e.to), d.edgeColor(e))
+		if e.label != "" {
+			fmt.Fprintf(&b, ", label=\"%s\"", quote.Replace(e.label))
+		}
+		if e.taken {
+			b.WriteString(", penwidth=2")
+		}
+		b.WriteString("];\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// mermaid 输出 Mermaid flowchart
+func (d *diagram) mermaid() string {
+	quote := strings.NewReplacer(`"`, "#quot;", "\n", " ")
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, n := range d.nodes {
+		label := quote.Replace(n.label)
+		switch n.kind {
+		case nodeChild:
+			fmt.Fprintf(&b, "    %s[[\"%s\"]]\n", nodeKey(n.id), label)
+		case nodeFork, nodeJoin:
+			fmt.Fprintf(&b, "    %s{\"%s\"}\n", nodeKey(n.id), label)
+		case nodeEnd:
+			fmt.Fprintf(&b, "    %s((\"%s\"))\n", nodeKey(n.id), label)
+		default:
+			fmt.Fprintf(&b, "    %s(\"%s\")\n", nodeKey(n.id), label)
+		}
+	}
+	for _, e := range d.edges {
+		if e.label != "" {
+			fmt.Fprintf(&b, "    %s -->|\"%s\"| %s\n", nodeKey(e.from), quote.Replace(e.label), nodeKey(e.to))
+		} else {
+			fmt.Fprintf(&b, "    %s --> %s\n", nodeKey(e.from), nodeKey(e.to))
+		}
+	}
+	for _, n := range d.nodes {
+		fill, stroke := d.colors(n)
+		fmt.Fprintf(&b, "    style %s fill:%s,stroke:%s\n", nodeKey(n.id), fill, stroke)
+	}
+	for i, e := range d.edges {
+		if d.progress {
+			width := 1
+			if e.taken {
+				width = 2
+			}
+			fmt.Fprintf(&b, "    linkStyle %d stroke:%s,stroke-width:%dpx\n", i, d.edgeColor(e), width)
+		}
+	}
+	return b.String()
+}
+
+// svg 输出独立的 SVG 图片，不依赖外部样式和字体文件
+func (d *diagram) svg() string {
+	minX, minY, maxX, maxY := 0, 0, 0, 0
+	for i, n := range d.nodes {
+		if i == 0 || n.x < minX {
+			minX = n.x
+		}
+		if i == 0 || n.y < minY {
+			minY = n.y
+		}
+		if i == 0 || n.x+n.w > maxX {
+			maxX = n.x + n.w
+		}
+		if i == 0 || n.y+n.h > maxY {
+			maxY = n.y + n.h
+		}
+	}
+	minX, minY, maxX, maxY = minX-40, minY-40, maxX+40, maxY+40
+	width, height := maxX-minX, maxY-minY
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="%d %d %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		width, height, minX, minY, width, height)
+	fmt.Fprintf(&b, "  <title>%s</title>\n", html.EscapeString(d.name))
+	b.WriteString("  <defs>\n")
+	for _, color := range []string{"#595959", stateColors[StepStatePassed][1], stateColors[""][1]} {
+		fmt.Fprintf(&b, `    <marker id="arrow-%s" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="%s"/></marker>`+"\n",
+			strings.TrimPrefix(color, "#"), color)
+	}
+	b.WriteString("  </defs>\n")
+	fmt.Fprintf(&b, `  <rect x="%d" y="%d" width="%d" height="%d" fill="#ffffff"/>`+"\n", minX, minY, width, height)
+
+	for _, e := range d.edges {
+		from, to := d.node(e.from), d.node(e.to)
+		if from == nil || to == nil {
+			continue
+		}
+		x1, y1, x2, y2 := edgeEnds(from, to)
+		color, strokeWidth := d.edgeColor(e), 1
+		if e.taken {
+			strokeWidth = 2
+		}
+		fmt.Fprintf(&b, `  <line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%d" marker-end="url(#arrow-%s)"/>`+"\n",
+			x1, y1, x2, y2, color, strokeWidth, strings.TrimPrefix(color, "#"))
+		if e.label != "" {
+			fmt.Fprintf(&b, `  <text x="%.1f" y="%.1f" text-anchor="middle" font-size="10" fill="#595959" stroke="#ffffff" stroke-width="3" paint-order="stroke">%s</text>`+"\n",
+				(x1+x2)/2, (y1+y2)/2-4, html.EscapeString(e.label))
+		}
+	}
+	for _, n := range d.nodes {
+		fill, stroke := d.colors(n)
+		cx, cy := n.x+n.w/2, n.y+n.h/2
+		b.WriteString("  <g>\n")
+		if d.progress {
+			fmt.Fprintf(&b, "    <title>%s：%s</title>\n", html.EscapeString(n.label), stateNames[n.state])
+		}
+		switch n.kind {
+		case nodeFork, nodeJoin:
+			fmt.Fprintf(&b, `    <polygon points="%d,%d %d,%d %d,%d %d,%d" fill="%s" stroke="%s" stroke-width="2"/>`+"\n",
+				cx, n.y, n.x+n.w, cy, cx, n.y+n.h, n.x, cy, fill, stroke)
+		case nodeEnd:
+			fmt.Fprintf(&b, `    <circle cx="%d" cy="%d" r="%d" fill="%s" stroke="%s" stroke-width="3"/>`+"\n", cx, cy, n.w/2, fill, stroke)
+		default:
+			fmt.Fprintf(&b, `    <rect x="%d" y="%d" width="%d" height="%d" rx="8" fill="%s" stroke="%s" stroke-width="2"/>`+"\n",
+				n.x, n.y, n.w, n.h, fill, stroke)
+			if n.kind == nodeChild {
+				fmt.Fprintf(&b, `    <rect x="%d" y="%d" width="%d" height="%d" rx="5" fill="none" stroke="%s"/>`+"\n",
+					n.x+4, n.y+4, n.w-8, n.h-8, stroke)
+			}
+		}
+		if n.kind == nodeFork || n.kind == nodeJoin || n.kind == nodeEnd {
+			// 网关与结束图形较小，名称显示在图形下方
+			cy = n.y + n.h + 14
+		}
+		fmt.Fprintf(&b, `    <text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle" fill="#262626">%s</text>`+"\n",
+			cx, cy, html.EscapeString(n.label))
+		b.WriteString("  </g>\n")
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// edgeEnds 连线两端：从元素中心出发，截止在元素边框上
+func edgeEnds(from, to *diagramNode) (float64, float64, float64, float64) {
+	fx, fy := float64(from.x)+float64(from.w)/2, float64(from.y)+float64(from.h)/2
+	tx, ty := float64(to.x)+float64(to.w)/2, float64(to.y)+float64(to.h)/2
+	dx, dy := tx-fx, ty-fy
+	if dx == 0 && dy == 0 {
+		return fx, fy, tx, ty
+	}
+	clip := func(n *diagramNode) float64 {
+		hw, hh := float64(n.w)/2, float64(n.h)/2
+		return math.Min(hw/math.Max(math.Abs(dx), 1e-9), hh/math.Max(math.Abs(dy), 1e-9))
+	}
+	s, t := clip(from), clip(to)
+	return fx + dx*s, fy + dy*s, tx - dx*t, ty - dy*t
+}
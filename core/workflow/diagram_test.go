@@ -0,0 +1,104 @@
+package workflow
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// diagramFlow 发起 →(amount > 1) A(bob) → B(carol) → 结束，流程名含引号
+func diagramFlow(e *testEnv) (uint, []uint) {
+	flowID := e.flow(`报销"加急"`)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "3", false)
+	e.link(flowID, start, int(a), "amount > 1")
+	e.link(flowID, start, -1, "")
+	e.link(flowID, a, int(b), "")
+	e.link(flowID, b, -1, "")
+	return flowID, []uint{start, a, b}
+}
+
+func TestRenderFlow(t *testing.T) {
+	e := newTestEnv(t)
+	flowID, ids := diagramFlow(e)
+	start, a, b := ids[0], ids[1], ids[2]
+
+	dot, err := e.s.RenderFlow(flowID, DiagramDOT)
+	e.must(err)
+	for _, want := range []string{
+		`digraph "报销\"加急\""`,
+		fmt.Sprintf(`p%d [label="A", shape=box`, a),
+		fmt.Sprintf(`p%d -> p%d [color="#595959", label="amount > 1"]`, start, a),
+		fmt.Sprintf(`p%d -> p_end`, b),
+		`p_end [label="结束", shape=doublecircle`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Fatalf("DOT 应包含 %s，实际\n%s", want, dot)
+		}
+	}
+
+	mermaid, err := e.s.RenderFlow(flowID, DiagramMermaid)
+	e.must(err)
+	for _, want := range []string{
+		"flowchart LR\n",
+		fmt.Sprintf(`p%d("A")`, a),
+		fmt.Sprintf(`p%d -->|"amount > 1"| p%d`, start, a),
+		`p_end(("结束"))`,
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Fatalf("Mermaid 应包含 %s，实际\n%s", want, mermaid)
+		}
+	}
+
+	svg, err := e.s.RenderFlow(flowID, DiagramSVG)
+	e.must(err)
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	texts := 0
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("SVG 应为合法的 XML：%v\n%s", err, svg)
+		}
+		if el, ok := tok.(xml.StartElement); ok && el.Name.Local == "text" {
+			texts++
+		}
+	}
+	// 四个元素的名称及一条流转条件
+	if texts != 5 {
+		t.Fatalf("SVG 应有5处文字，实际%d处\n%s", texts, svg)
+	}
+
+	if _, err = e.s.RenderFlow(flowID, "png"); err == nil {
+		t.Fatal("不支持的格式应报错")
+	}
+}
+
+// 流程进度图按本轮处理情况标出已通过、当前步骤及经过的流转
+func TestRenderEntryProgress(t *testing.T) {
+	e := newTestEnv(t)
+	flowID, ids := diagramFlow(e)
+	start, a, b := ids[0], ids[1], ids[2]
+	entry := e.start(flowID, map[string]string{"amount": "5"})
+	e.pass(entry.ID, empBob)
+
+	dot, err := e.s.RenderEntry(entry.ID, DiagramDOT)
+	e.must(err)
+	for _, want := range []string{
+		fmt.Sprintf(`p%d [label="A", shape=box, style="rounded,filled", color="#52c41a", fillcolor="#f6ffed"`, a),
+		fmt.Sprintf(`p%d [label="B", shape=box, style="rounded,filled", color="#1890ff", fillcolor="#e6f7ff"`, b),
+		fmt.Sprintf(`p%d -> p%d [color="#52c41a", label="amount > 1", penwidth=2]`, start, a),
+		fmt.Sprintf(`p%d -> p_end [color="#bfbfbf"]`, start),
+	} {
+		if !strings.Contains(dot, want) {
+			t.Fatalf("进度图应包含 %s，实际\n%s", want, dot)
+		}
+	}
+}
@@ -0,0 +1,521 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow/expression"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 表单字段筛选的比较方式
+const (
+	FilterOpEq       = "="
+	FilterOpNe       = "!="
+	FilterOpGt       = ">"
+	FilterOpGte      = ">="
+	FilterOpLt       = "<"
+	FilterOpLte      = "<="
+	FilterOpContains = "contains" // 列表包含某项，文本包含子串
+)
+
+// entryDataMigrateBatch 迁移旧表单数据时每批处理的行数
+const entryDataMigrateBatch = 500
+
+// FieldFilter 按表单字段筛选流程，按字段类型比较类型化的值
+type FieldFilter struct {
+	Field string `json:"field"` // 字段英文名，明细表格的列写作 表格字段.列字段
+	Op    string `json:"op"`    // 比较方式
+	Value string `json:"value"` // 比较值
+}
+
+// EntryQuery 流程列表的筛选条件
+type EntryQuery struct {
+	FlowID  uint                // 流程，按表单字段筛选时必填
+	EmpID   uint                // 发起人，为0时不限
+	Status  *models.EntryStatus // 状态，为 nil 时不限
+	Filters []FieldFilter       // 表单字段筛选，全部满足
+}
+
+// valueType 表单字段类型对应的值类型
+func valueType(fieldType string) string {
+	switch fieldType {
+	case FieldTypeNumber:
+		return models.EntryValueNumber
+	case FieldTypeMoney:
+		return models.EntryValueMoney
+	case FieldTypeDate, FieldTypeDatetime:
+		return models.EntryValueDate
+	case FieldTypeBool:
+		return models.EntryValueBool
+	case FieldTypeCheckbox, FieldTypeList:
+		return models.EntryValueList
+	case FieldTypeEmp:
+		return models.EntryValueEmp
+	case FieldTypeDept:
+		return models.EntryValueDept
+	case FieldTypeTable:
+		return models.EntryValueTable
+	}
+	return models.EntryValueText
+}
+
+// fieldTypes 流程定义中表单字段的类型
+func (g *flowGraph) fieldTypes() map[string]string {
+	types := make(map[string]string, len(g.Template.TemplateForms))
+	for _, form := range g.Template.TemplateForms {
+		types[form.Field] = form.FieldType
+	}
+	return types
+}
+
+// tableForms 流程定义中的明细表格字段
+func (g *flowGraph) tableForms() map[string]models.TemplateForm {
+	tables := make(map[string]models.TemplateForm)
+	for _, form := range g.Template.TemplateForms {
+		if form.FieldType == FieldTypeTable {
+			tables[form.Field] = form
+		}
+	}
+	return tables
+}
+
+// typeEntryData 按字段类型填充类型化的值，无法转换的值按文本保存
+func typeEntryData(d *models.EntryData, fieldType string) {
+	d.ValueType, d.NumValue, d.TimeValue, d.RefID = models.EntryValueText, nil, nil, nil
+	raw := strings.TrimSpace(d.FieldValue)
+	if raw == "" {
+		return
+	}
+	vt := valueType(fieldType)
+	switch vt {
+	case models.EntryValueNumber, models.EntryValueMoney:
+		if f, err := parseNumber(raw); err == nil {
+			d.ValueType, d.NumValue = vt, &f
+		}
+	case models.EntryValueDate:
+		if tm, err := expression.ToTime(raw); err == nil {
+			ts := tm.Unix()
+			d.ValueType, d.TimeValue = vt, &ts
+		}
+	case models.EntryValueBool:
+		if b, err := expression.ToBool(raw); err == nil {
+			f := 0.0
+			if b {
+				f = 1
+			}
+			d.ValueType, d.NumValue = vt, &f
+		}
+	case models.EntryValueList:
+		d.ValueType, d.FieldValue = vt, listValue(raw)
+	case models.EntryValueTable:
+		if compact, ok := compactJSON(raw); ok {
+			d.ValueType, d.FieldValue = vt, compact
+		}
+	case models.EntryValueEmp, models.EntryValueDept:
+		if id, err := strconv.ParseInt(raw, 10, 64); err == nil && id > 0 {
+			d.ValueType, d.RefID = vt, &id
+		}
+	}
+}
+
+// parseNumber 解析数字，金额允许千分位逗号
+func parseNumber(raw string) (float64, error) {
+	return strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(raw), ",", ""), 64)
+}
+
+// listValue 列表字段统一保存为紧凑的 JSON 数组，逗号分隔的值转为字符串数组
+func listValue(raw string) string {
+	if strings.HasPrefix(raw, "[") {
+		if compact, ok := compactJSON(raw); ok {
+			return compact
+		}
+	}
+	items := checkboxItems(raw)
+	if items == nil {
+		items = []string{}
+	}
+	b, _ := json.Marshal(items)
+	return string(b)
+}
+
+// compactJSON 去掉 JSON 中的空白，不是有效的 JSON 时返回 false
+func compactJSON(raw string) (string, bool) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(raw)); err != nil {
+		return raw, false
+	}
+	return buf.String(), true
+}
+
+// normalizeEntryData 按字段类型规范化提交的表单数据，列表字段转为 JSON 数组，明细表格去掉 JSON 空白
+func normalizeEntryData(types map[string]string, data map[string]string) map[string]string {
+	normalized := make(map[string]string, len(data))
+	for field, value := range data {
+		raw := strings.TrimSpace(value)
+		switch valueType(types[field]) {
+		case models.EntryValueList:
+			if raw != "" {
+				value = listValue(raw)
+			}
+		case models.EntryValueTable:
+			if compact, ok := compactJSON(raw); ok {
+				value = compact
+			}
+		}
+		normalized[field] = value
+	}
+	return normalized
+}
+
+// tableCells 明细表格的单元格，按列类型填充类型化的值，定义以外的列按文本保存，不是 JSON 对象数组时没有单元格
+func tableCells(entry models.Entry, form models.TemplateForm, value string) []models.EntryDataCell {
+	rows, err := listRows(form.Field, value)
+	if err != nil {
+		return nil
+	}
+	columnTypes := make(map[string]string, len(form.FieldColumns))
+	for _, column := range form.FieldColumns {
+		columnTypes[column.Field] = column.FieldType
+	}
+	var cells []models.EntryDataCell
+	for i, row := range rows {
+		columns := make([]string, 0, len(row))
+		for column := range row {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		for _, column := range columns {
+			d := models.EntryData{FieldValue: row[column]}
+			typeEntryData(&d, columnTypes[column])
+			cells = append(cells, models.EntryDataCell{
+				EntryID:    int(entry.ID),
+				FlowID:     int(entry.FlowID),
+				FieldName:  form.Field,
+				ColumnName: column,
+				RowIndex:   i,
+				FieldValue: d.FieldValue,
+				ValueType:  d.ValueType,
+				NumValue:   d.NumValue,
+				TimeValue:  d.TimeValue,
+				RefID:      d.RefID,
+			})
+		}
+	}
+	return cells
+}
+
+// writeTableCells 重写变更的明细表格字段的单元格，fields 为变更的字段，值为 nil 表示字段已删除
+func writeTableCells(t *flowTx, g *flowGraph, entry models.Entry, fields map[string]*string) error {
+	tables := g.tableForms()
+	var changed []string
+	var cells []models.EntryDataCell
+	for field, value := range fields {
+		form, ok := tables[field]
+		if !ok {
+			continue
+		}
+		changed = append(changed, field)
+		if value != nil {
+			cells = append(cells, tableCells(entry, form, *value)...)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if err := t.Where("entry_id=?", entry.ID).Where("field_name IN (?)", changed).Delete(&models.EntryDataCell{}).Error; err != nil {
+		return fmt.Errorf("数据库删除错误: %v", err)
+	}
+	if len(cells) == 0 {
+		return nil
+	}
+	if err := t.CreateInBatches(&cells, 200).Error; err != nil {
+		return fmt.Errorf("数据库插入错误: %v", err)
+	}
+	return nil
+}
+
+// entryDataValue 表单数据的类型化值，用于条件求值：数字为 float64，日期为 time.Time，列表为 []interface{}
+func entryDataValue(d models.EntryData) interface{} {
+	switch d.ValueType {
+	case models.EntryValueNumber, models.EntryValueMoney:
+		if d.NumValue != nil {
+			return *d.NumValue
+		}
+	case models.EntryValueBool:
+		if d.NumValue != nil {
+			return *d.NumValue != 0
+		}
+	case models.EntryValueDate:
+		if d.TimeValue != nil {
+			return time.Unix(*d.TimeValue, 0)
+		}
+	case models.EntryValueList, models.EntryValueTable:
+		return expression.ToList(d.FieldValue)
+	case models.EntryValueEmp, models.EntryValueDept:
+		if d.RefID != nil {
+			return float64(*d.RefID)
+		}
+	}
+	return d.FieldValue
+}
+
+// tableValue 明细表格的类型化值，各行为列字段到值的映射，单元格按列类型转换，空单元格为 nil
+func tableValue(raw string, cells []models.EntryDataCell) []interface{} {
+	rows := expression.ToList(raw)
+	for _, c := range cells {
+		if c.RowIndex < 0 || c.RowIndex >= len(rows) {
+			continue
+		}
+		row, ok := rows[c.RowIndex].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if strings.TrimSpace(c.FieldValue) == "" {
+			row[c.ColumnName] = nil
+			continue
+		}
+		row[c.ColumnName] = entryDataValue(models.EntryData{
+			FieldValue: c.FieldValue,
+			ValueType:  c.ValueType,
+			NumValue:   c.NumValue,
+			TimeValue:  c.TimeValue,
+			RefID:      c.RefID,
+		})
+	}
+	return rows
+}
+
+// entryDataValues 流程的类型化表单数据
+func entryDataValues(db *gorm.DB, entryID uint) map[string]interface{} {
+	var entryDatas []models.EntryData
+	db.Where("entry_id=?", entryID).Find(&entryDatas)
+	var cells []models.EntryDataCell
+	db.Where("entry_id=?", entryID).Find(&cells)
+	fieldCells := make(map[string][]models.EntryDataCell)
+	for _, c := range cells {
+		fieldCells[c.FieldName] = append(fieldCells[c.FieldName], c)
+	}
+	values := make(map[string]interface{}, len(entryDatas))
+	for _, d := range entryDatas {
+		if d.ValueType == models.EntryValueTable {
+			values[d.FieldName] = tableValue(d.FieldValue, fieldCells[d.FieldName])
+			continue
+		}
+		values[d.FieldName] = entryDataValue(d)
+	}
+	return values
+}
+
+// formValues 按字段类型转换尚未保存的表单数据
+func formValues(g *flowGraph, data map[string]string) map[string]interface{} {
+	types, tables := g.fieldTypes(), g.tableForms()
+	values := make(map[string]interface{}, len(data))
+	for field, value := range data {
+		d := models.EntryData{FieldName: field, FieldValue: value}
+		typeEntryData(&d, types[field])
+		if form, ok := tables[field]; ok && d.ValueType == models.EntryValueTable {
+			values[field] = tableValue(d.FieldValue, tableCells(models.Entry{}, form, d.FieldValue))
+			continue
+		}
+		values[field] = entryDataValue(d)
+	}
+	return values
+}
+
+// publishedGraph 流程当前发布版本的定义，未发布过版本时读取当前定义
+func (s *Service) publishedGraph(flowID uint) (*flowGraph, error) {
+	var flow models.Flow
+	if err := s.db.First(&flow, flowID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("流程不存在")
+		}
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	if flow.VersionID > 0 {
+		return s.versionGraph(s.db, flow.VersionID)
+	}
+	return loadGraph(s.db, flowID)
+}
+
+// Entries 流程列表，表单字段按流程当前发布版本的字段类型比较
+func (s *Service) Entries(query EntryQuery, limit int, offset int) ([]models.Entry, int64, error) {
+	db := s.db.Model(&models.Entry{})
+	if query.FlowID > 0 {
+		db = db.Where("flow_id=?", query.FlowID)
+	}
+	if query.EmpID > 0 {
+		db = db.Where("emp_id=?", query.EmpID)
+	}
+	if query.Status != nil {
+		db = db.Where("status=?", *query.Status)
+	}
+	if len(query.Filters) > 0 {
+		if query.FlowID == 0 {
+			return nil, 0, errors.New("按表单字段筛选时需指定流程")
+		}
+		g, err := s.publishedGraph(query.FlowID)
+		if err != nil {
+			return nil, 0, err
+		}
+		types, tables := g.fieldTypes(), g.tableForms()
+		for _, f := range query.Filters {
+			var sub *gorm.DB
+			if field, column, ok := strings.Cut(f.Field, "."); ok && tables[field].Field != "" {
+				sub, err = s.cellFilter(tables[field], column, f)
+			} else {
+				sub, err = fieldFilter(s.db.Model(&models.EntryData{}).Select("entry_id").Where("field_name=?", f.Field), types[f.Field], f)
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+			db = db.Where("id IN (?)", sub)
+		}
+	}
+	var count int64
+	if err := db.Count(&count).Error; err != nil {
+		return nil, 0, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	var entries []models.Entry
+	if err := db.Preload("EntryDatas").Order("id desc").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return entries, count, nil
+}
+
+// cellFilter 明细表格按列筛选，任一行满足条件即可，字段写作 表格字段.列字段
+func (s *Service) cellFilter(form models.TemplateForm, column string, f FieldFilter) (*gorm.DB, error) {
+	for _, c := range form.FieldColumns {
+		if c.Field == column {
+			sub := s.db.Model(&models.EntryDataCell{}).Select("entry_id").Where("field_name=?", form.Field).Where("column_name=?", column)
+			return fieldFilter(sub, c.FieldType, f)
+		}
+	}
+	return nil, fmt.Errorf("明细表格[%s]没有列[%s]", formLabel(form), column)
+}
+
+// fieldFilter 在 sub 上追加字段筛选条件，得到满足条件的流程id子查询，按值类型比较对应的类型化列
+func fieldFilter(sub *gorm.DB, fieldType string, f FieldFilter) (*gorm.DB, error) {
+	vt := valueType(fieldType)
+	if f.Op == FilterOpContains {
+		pattern := f.Value
+		if vt == models.EntryValueList {
+			b, _ := json.Marshal(f.Value)
+			pattern = string(b)
+		} else if vt != models.EntryValueText {
+			return nil, fmt.Errorf("字段[%s]不支持包含筛选", f.Field)
+		}
+		return sub.Where("field_value LIKE ? ESCAPE '!'", "%"+escapeLike(pattern)+"%"), nil
+	}
+	switch f.Op {
+	case FilterOpEq, FilterOpNe, FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte:
+	default:
+		return nil, fmt.Errorf("不支持的筛选方式: %s", f.Op)
+	}
+	var column string
+	var value interface{}
+	switch vt {
+	case models.EntryValueNumber, models.EntryValueMoney:
+		n, err := parseNumber(f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("字段[%s]的筛选值%q不是有效的数字", f.Field, f.Value)
+		}
+		column, value = "num_value", n
+	case models.EntryValueBool:
+		b, err := expression.ToBool(f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("字段[%s]的筛选值%q不是有效的布尔值", f.Field, f.Value)
+		}
+		column, value = "num_value", 0
+		if b {
+			value = 1
+		}
+	case models.EntryValueDate:
+		tm, err := expression.ToTime(f.Value)
+		if err != nil {
+			return nil, fmt.Errorf("字段[%s]的筛选值%q不是有效的日期", f.Field, f.Value)
+		}
+		column, value = "time_value", tm.Unix()
+	case models.EntryValueEmp, models.EntryValueDept:
+		id, err := strconv.ParseInt(strings.TrimSpace(f.Value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("字段[%s]的筛选值%q不是有效的ID", f.Field, f.Value)
+		}
+		column, value = "ref_id", id
+	case models.EntryValueList:
+		return nil, fmt.Errorf("列表字段[%s]只支持包含筛选", f.Field)
+	case models.EntryValueTable:
+		return nil, fmt.Errorf("明细表格[%s]须按列筛选，字段写作 %s.列字段", f.Field, f.Field)
+	default:
+		column, value = "field_value", f.Value
+	}
+	op := f.Op
+	if op == FilterOpNe {
+		op = "<>"
+	}
+	return sub.Where(fmt.Sprintf("%s %s ?", column, op), value), nil
+}
+
+// escapeLike 转义 LIKE 通配符，配合 ESCAPE '!' 使用
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// MigrateEntryData 为类型化之前写入的表单数据按流程定义的字段类型补写类型化的值，返回处理的行数
+func (s *Service) MigrateEntryData() (int, error) {
+	total := 0
+	for {
+		var rows []models.EntryData
+		if err := s.db.Where("value_type=?", "").Order("id asc").Limit(entryDataMigrateBatch).Find(&rows).Error; err != nil {
+			return total, fmt.Errorf("数据库查询错误: %v", err)
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+		t := &flowTx{DB: s.db}
+		types := make(map[int]map[string]string)
+		for i := range rows {
+			fieldTypes, ok := types[rows[i].EntryID]
+			if !ok {
+				var entry models.Entry
+				if err := s.db.First(&entry, rows[i].EntryID).Error; err == nil {
+					if g, err := s.entryGraph(t, &entry); err == nil {
+						fieldTypes = g.fieldTypes()
+					}
+				}
+				types[rows[i].EntryID] = fieldTypes
+			}
+			typeEntryData(&rows[i], fieldTypes[rows[i].FieldName])
+			err := s.db.Model(&rows[i]).Select("field_value", "value_type", "num_value", "time_value", "ref_id").Updates(&rows[i]).Error
+			if err != nil {
+				return total, fmt.Errorf("数据库更新错误: %v", err)
+			}
+			total++
+		}
+	}
+}
+
+// runEntryDataMigration 启动时在后台迁移旧表单数据
+func runEntryDataMigration(lifecycle fx.Lifecycle, s *Service) {
+	lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				if n, err := s.MigrateEntryData(); err != nil {
+					zap.S().Warnf("表单数据类型迁移失败: %v", err)
+				} else if n > 0 {
+					zap.S().Infof("表单数据类型迁移完成，共%d条", n)
+				}
+			}()
+			return nil
+		},
+	})
+}
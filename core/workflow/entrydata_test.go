@@ -0,0 +1,97 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/types"
+)
+
+// entryDataFlow 带数字、日期、多选、员工及明细表格字段的流程
+func entryDataFlow(e *testEnv) uint {
+	tmpl := models.Template{TemplateName: "采购"}
+	e.must(e.db.Create(&tmpl).Error)
+	forms := []models.TemplateForm{
+		{Field: "amount", FieldType: FieldTypeNumber},
+		{Field: "day", FieldType: FieldTypeDate},
+		{Field: "tags", FieldType: FieldTypeCheckbox},
+		{Field: "owner", FieldType: FieldTypeEmp},
+		{Field: "items", FieldType: FieldTypeTable, FieldColumns: types.FieldColumns{{Field: "price", FieldType: FieldTypeNumber}}},
+	}
+	for i := range forms {
+		forms[i].TemplateID = tmpl.ID
+		forms[i].FieldName = forms[i].Field
+		e.must(e.db.Create(&forms[i]).Error)
+	}
+	flowID := e.flow("entrydata")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("template_id", tmpl.ID).Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, -1, "")
+	return flowID
+}
+
+// 按字段类型比较类型化的值，明细表格任一行满足即可
+func TestEntriesFieldFilters(t *testing.T) {
+	e := newTestEnv(t)
+	flowID := entryDataFlow(e)
+	small := e.start(flowID, map[string]string{
+		"amount": "9", "day": "2024-01-15", "tags": "日常", "owner": "3",
+		"items": `[{"price":"5"},{"price":"8"}]`,
+	})
+	large := e.start(flowID, map[string]string{
+		"amount": "1,200", "day": "2024-06-01", "tags": "紧急,日常", "owner": "4",
+		"items": `[{"price":"20"},{"price":"60"}]`,
+	})
+
+	cases := []struct {
+		name   string
+		filter FieldFilter
+		want   []uint
+	}{
+		{"数字按数值比较", FieldFilter{Field: "amount", Op: FilterOpGt, Value: "100"}, []uint{large.ID}},
+		{"数字不等", FieldFilter{Field: "amount", Op: FilterOpNe, Value: "9"}, []uint{large.ID}},
+		{"日期", FieldFilter{Field: "day", Op: FilterOpGte, Value: "2024-03-01"}, []uint{large.ID}},
+		{"多选包含", FieldFilter{Field: "tags", Op: FilterOpContains, Value: "日常"}, []uint{large.ID, small.ID}},
+		{"多选包含单项", FieldFilter{Field: "tags", Op: FilterOpContains, Value: "紧急"}, []uint{large.ID}},
+		{"员工", FieldFilter{Field: "owner", Op: FilterOpEq, Value: "3"}, []uint{small.ID}},
+		{"明细表格列", FieldFilter{Field: "items.price", Op: FilterOpGt, Value: "50"}, []uint{large.ID}},
+		{"明细表格列全部", FieldFilter{Field: "items.price", Op: FilterOpLte, Value: "20"}, []uint{large.ID, small.ID}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entries, count, err := e.s.Entries(EntryQuery{FlowID: flowID, Filters: []FieldFilter{c.filter}}, 10, 0)
+			e.must(err)
+			if int(count) != len(c.want) || len(entries) != len(c.want) {
+				t.Fatalf("应筛选出 %d 条，实际 %d 条", len(c.want), count)
+			}
+			for i, entry := range entries {
+				if entry.ID != c.want[i] {
+					t.Fatalf("第 %d 条应为 %d，实际 %d", i, c.want[i], entry.ID)
+				}
+			}
+		})
+	}
+
+	errCases := []struct {
+		name  string
+		query EntryQuery
+	}{
+		{"未指定流程", EntryQuery{Filters: []FieldFilter{{Field: "amount", Op: FilterOpEq, Value: "9"}}}},
+		{"无效的数字", EntryQuery{FlowID: flowID, Filters: []FieldFilter{{Field: "amount", Op: FilterOpGt, Value: "abc"}}}},
+		{"无效的日期", EntryQuery{FlowID: flowID, Filters: []FieldFilter{{Field: "day", Op: FilterOpLt, Value: "明天"}}}},
+		{"列表字段只支持包含", EntryQuery{FlowID: flowID, Filters: []FieldFilter{{Field: "tags", Op: FilterOpEq, Value: "日常"}}}},
+		{"数字不支持包含", EntryQuery{FlowID: flowID, Filters: []FieldFilter{{Field: "amount", Op: FilterOpContains, Value: "9"}}}},
+		{"明细表格须按列", EntryQuery{FlowID: flowID, Filters: []FieldFilter{{Field: "items", Op: FilterOpEq, Value: "5"}}}},
+		{"明细表格没有该列", EntryQuery{FlowID: flowID, Filters: []FieldFilter{{Field: "items.qty", Op: FilterOpEq, Value: "5"}}}},
+		{"不支持的筛选方式", EntryQuery{FlowID: flowID, Filters: []FieldFilter{{Field: "amount", Op: "~", Value: "5"}}}},
+	}
+	for _, c := range errCases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, _, err := e.s.Entries(c.query, 10, 0); err == nil {
+				t.Fatal("应返回错误")
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"sort"
+)
+
+// 流程事件类型
+const (
+	EventStart       = "start"        // 发起流程或子流程
+	EventPass        = "pass"         // 审批通过
+	EventAutoApprove = "auto_approve" // 系统自动通过
+	EventReject      = "reject"       // 驳回
+	EventRejectBack  = "reject_back"  // 驳回并退回
+	EventSign        = "sign"         // 前加签或后加签
+	EventReassign    = "reassign"     // 转办
+	EventRecall      = "recall"       // 发起人撤回
+	EventResubmit    = "resubmit"     // 重新提交
+	EventSuspend     = "suspend"      // 挂起
+	EventResume      = "resume"       // 恢复
+	EventTerminate   = "terminate"    // 终止
+	EventTimeout     = "timeout"      // 待办超时
+	EventJump        = "jump"         // 跳转至指定步骤
+	EventSkip        = "skip"         // 未找到审批人自动跳过
+	EventPlugin      = "plugin"       // 执行插件
+
+	// 以下事件由引擎在状态变化时记录，用于重建流程状态
+	EventMove   = "move"   // 进入步骤
+	EventStatus = "status" // 流程状态变更
+	EventData   = "data"   // 表单数据变更
+)
+
+// systemActor 系统自动处理时的操作人
+var systemActor = models.Emp{Name: "系统"}
+
+// actAs 登记本次操作的操作人，事件日志按此记录；未登记时为系统操作
+func (t *flowTx) actAs(emp models.Emp) {
+	t.actorID, t.actorName = emp.ID, emp.Name
+}
+
+// logEvent 追加一条流程事件，未指定操作人时记录本次操作的操作人
+func (s *Service) logEvent(t *flowTx, entry *models.Entry, event models.EntryEvent) error {
+	event.EntryID = entry.ID
+	event.Circle = entry.Circle
+	if event.ActorName == "" {
+		event.ActorID, event.ActorName = t.actorID, t.actorName
+	}
+	if event.ActorName == "" {
+		event.ActorName = "系统"
+	}
+	if err := t.Create(&event).Error; err != nil {
+		return fmt.Errorf("数据库插入错误: %v", err)
+	}
+	return nil
+}
+
+// procEvent 与待办相关的流程事件
+func procEvent(action string, proc models.Proc, content string) models.EntryEvent {
+	return models.EntryEvent{
+		ProcID:        proc.ID,
+		Action:        action,
+		FromProcessID: proc.ProcessID,
+		BranchID:      proc.BranchID,
+		Content:       content,
+	}
+}
+
+// dataDiff 表单数据的变更，字段 => [原值, 新值]，新增时原值为 nil，删除时新值为 nil
+func dataDiff(old map[string]string, data map[string]string, replace bool) map[string][2]*string {
+	diff := make(map[string][2]*string)
+	for field, value := range data {
+		value := value
+		if before, ok := old[field]; !ok {
+			diff[field] = [2]*string{nil, &value}
+		} else if before != value {
+			diff[field] = [2]*string{&before, &value}
+		}
+	}
+	if replace {
+		for field, before := range old {
+			before := before
+			if _, ok := data[field]; !ok {
+				diff[field] = [2]*string{&before, nil}
+			}
+		}
+	}
+	return diff
+}
+
+// Events 员工查看流程的事件日志，按发生先后排列，数据变更只含查看人可查看的字段
+func (s *Service) Events(entryID uint, empID uint) ([]models.EntryEvent, error) {
+	_, perms, err := s.viewEntry(entryID, empID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.entryEvents(entryID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		filterDataDiff(&events[i], perms)
+	}
+	return events, nil
+}
+
+// entryEvents 流程的完整事件日志，按发生先后排列
+func (s *Service) entryEvents(entryID uint) ([]models.EntryEvent, error) {
+	var events []models.EntryEvent
+	if err := s.db.Where("entry_id=?", entryID).Order("id asc").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return events, nil
+}
+
+// EntryState 流程的状态快照
+type EntryState struct {
+	Status    models.EntryStatus `json:"status"`
+	ProcessID int                `json:"process_id"`
+	Circle    int                `json:"circle"`
+	Data      map[string]string  `json:"data"`
+}
+
+// StateCheck 按事件日志重建的流程状态与当前状态的比对结果
+type StateCheck struct {
+	Rebuilt    EntryState `json:"rebuilt"`
+	Current    EntryState `json:"current"`
+	Consistent bool       `json:"consistent"`
+	Mismatches []string   `json:"mismatches"`
+}
+
+// RebuildState 按事件日志依次重放步骤、状态及表单数据的变更，重建流程状态并与当前状态比对，用于一致性检查
+func (s *Service) RebuildState(entryID uint) (*StateCheck, error) {
+	var entry models.Entry
+	if err := s.db.First(&entry, entryID).Error; err != nil {
+		return nil, errors.New("流程不存在")
+	}
+	events, err := s.entryEvents(entryID)
+	if err != nil {
+		return nil, err
+	}
+	check := &StateCheck{
+		Rebuilt: EntryState{Data: map[string]string{}},
+		Current: EntryState{
+			Status:    entry.Status,
+			ProcessID: int(entry.ProcessID),
+			Circle:    entry.Circle,
+			Data:      entryDataMap(&flowTx{DB: s.db}, entry.ID),
+		},
+		Mismatches: []string{},
+	}
+	rebuilt := &check.Rebuilt
+	for _, event := range events {
+		rebuilt.Circle = event.Circle
+		switch event.Action {
+		case EventMove:
+			if event.BranchID == 0 {
+				rebuilt.ProcessID = event.ToProcessID
+			}
+		case EventStatus:
+			if from := models.EntryStatus(event.FromStatus); from != rebuilt.Status {
+				check.Mismatches = append(check.Mismatches, fmt.Sprintf("事件%d：变更前状态为[%s]，重建的状态为[%s]", event.ID, from, rebuilt.Status))
+			}
+			rebuilt.Status = models.EntryStatus(event.ToStatus)
+		case EventData:
+			var diff map[string][2]*string
+			if err = json.Unmarshal([]byte(event.DataDiff), &diff); err != nil {
+				check.Mismatches = append(check.Mismatches, fmt.Sprintf("事件%d：表单数据变更无法解析", event.ID))
+				continue
+			}
+			for field, change := range diff {
+				if change[1] == nil {
+					delete(rebuilt.Data, field)
+				} else {
+					rebuilt.Data[field] = *change[1]
+				}
+			}
+		}
+	}
+	current := check.Current
+	if rebuilt.Status != current.Status {
+		check.Mismatches = append(check.Mismatches, fmt.Sprintf("状态：重建为[%s]，当前为[%s]", rebuilt.Status, current.Status))
+	}
+	if rebuilt.ProcessID != current.ProcessID {
+		check.Mismatches = append(check.Mismatches, fmt.Sprintf("当前步骤：重建为%d，当前为%d", rebuilt.ProcessID, current.ProcessID))
+	}
+	if rebuilt.Circle != current.Circle {
+		check.Mismatches = append(check.Mismatches, fmt.Sprintf("轮次：重建为%d，当前为%d", rebuilt.Circle, current.Circle))
+	}
+	fields := make(map[string]bool)
+	for field := range rebuilt.Data {
+		fields[field] = true
+	}
+	for field := range current.Data {
+		fields[field] = true
+	}
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+	for _, field := range names {
+		rebuiltValue, rebuiltOk := rebuilt.Data[field]
+		currentValue, currentOk := current.Data[field]
+		if rebuiltOk != currentOk || rebuiltValue != currentValue {
+			check.Mismatches = append(check.Mismatches, fmt.Sprintf("表单字段[%s]：重建为%q，当前为%q", field, rebuiltValue, currentValue))
+		}
+	}
+	check.Consistent = len(check.Mismatches) == 0
+	return check, nil
+}
@@ -0,0 +1,55 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/types"
+)
+
+// 事件日志按发生先后记录处理过程，重放可重建流程状态，直接修改数据库会被检查出来
+func TestEventLogRebuildState(t *testing.T) {
+	e := newTestEnv(t)
+	tmpl := models.Template{TemplateName: "报销"}
+	e.must(e.db.Create(&tmpl).Error)
+	e.must(e.db.Create(&models.TemplateForm{TemplateID: tmpl.ID, Field: "amount", FieldName: "金额", FieldType: "number"}).Error)
+	flowID := e.flow("events")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("template_id", tmpl.ID).Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A", FieldPerms: types.FieldPerms{"amount": FieldPermEditable}}, "2", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "3", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, int(b), "")
+	e.link(flowID, b, -1, "")
+	entry := e.start(flowID, map[string]string{"amount": "100"})
+	e.must(e.s.PassWithData(e.pending(entry.ID, empBob), empBob, "ok", map[string]string{"amount": "200"}))
+	e.must(e.s.Reject(e.pending(entry.ID, empCarol), empCarol, "no"))
+
+	events, err := e.s.Events(entry.ID, empAlice)
+	e.must(err)
+	var actions []string
+	for _, event := range events {
+		switch event.Action {
+		case EventStart, EventPass, EventReject:
+			actions = append(actions, event.Action+"/"+event.ActorName)
+		}
+	}
+	if got, want := strings.Join(actions, " "), "start/alice pass/bob reject/carol"; got != want {
+		t.Fatalf("事件日志为 %s，期望 %s", got, want)
+	}
+
+	check, err := e.s.RebuildState(entry.ID)
+	e.must(err)
+	if !check.Consistent || check.Rebuilt.Status != models.EntryStatusRejected || check.Rebuilt.ProcessID != int(b) || check.Rebuilt.Data["amount"] != "200" {
+		t.Fatalf("重建的状态应与当前一致，实际 %+v", check)
+	}
+
+	e.must(e.db.Model(&models.Entry{}).Where("id=?", entry.ID).Update("status", models.EntryStatusCompleted).Error)
+	e.must(e.db.Model(&models.EntryData{}).Where("entry_id=?", entry.ID).Where("field_name=?", "amount").Update("field_value", "300").Error)
+	check, err = e.s.RebuildState(entry.ID)
+	e.must(err)
+	if check.Consistent || len(check.Mismatches) != 2 {
+		t.Fatalf("应检查出状态及金额两处不一致，实际 %q", check.Mismatches)
+	}
+}
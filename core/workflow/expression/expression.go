@@ -0,0 +1,590 @@
+package expression
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dromara/carbon/v2"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Func 表达式函数，参数与返回值为 nil、bool、float64、string、time.Time 或 []interface{}
+type Func func(args ...interface{}) (interface{}, error)
+
+// Env 表达式求值环境：变量取值与环境相关函数（如组织架构查询）
+type Env struct {
+	Vars  map[string]interface{}
+	Funcs map[string]Func
+}
+
+// Expression 编译后的表达式
+type Expression struct {
+	src   string
+	root  node
+	vars  []string
+	funcs []string
+}
+
+// cacheSize 解析结果缓存的表达式数量上限，超出时清空缓存重新累积
+const cacheSize = 1024
+
+var (
+	cacheMu sync.RWMutex
+	cache   = make(map[string]*Expression, cacheSize)
+)
+
+// Compile 解析表达式，相同表达式只解析一次
+func Compile(src string) (*Expression, error) {
+	cacheMu.RLock()
+	e, ok := cache[src]
+	cacheMu.RUnlock()
+	if ok {
+		return e, nil
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tokens[p.pos].kind != tokenEOF {
+		return nil, p.errorf("出现多余的 %s", p.tokens[p.pos].text)
+	}
+	e = &Expression{src: src, root: root, vars: p.vars, funcs: p.funcs}
+	cacheMu.Lock()
+	if len(cache) >= cacheSize {
+		cache = make(map[string]*Expression, cacheSize)
+	}
+	cache[src] = e
+	cacheMu.Unlock()
+	return e, nil
+}
+
+// Evaluate 解析并以布尔结果求值
+func Evaluate(src string, env *Env) (bool, error) {
+	e, err := Compile(src)
+	if err != nil {
+		return false, err
+	}
+	return e.Bool(env)
+}
+
+// String 表达式原文
+func (e *Expression) String() string {
+	return e.src
+}
+
+// Vars 表达式引用的变量名
+func (e *Expression) Vars() []string {
+	return e.vars
+}
+
+// Funcs 表达式调用的函数名
+func (e *Expression) Funcs() []string {
+	return e.funcs
+}
+
+// Eval 求值
+func (e *Expression) Eval(env *Env) (interface{}, error) {
+	if env == nil {
+		env = &Env{}
+	}
+	return e.root.eval(env)
+}
+
+// Bool 求值并转为布尔结果
+func (e *Expression) Bool(env *Env) (bool, error) {
+	v, err := e.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	return Truthy(v), nil
+}
+
+// Truthy 值的真假判断
+func Truthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case float64:
+		return val != 0
+	case string:
+		s := strings.TrimSpace(strings.ToLower(val))
+		return s != "" && s != "0" && s != "false"
+	case time.Time:
+		return !val.IsZero()
+	case []interface{}:
+		return len(val) > 0
+	}
+	return true
+}
+
+// Normalize 将 Go 值统一为表达式内部类型
+func Normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil, bool, float64, string, time.Time:
+		return val
+	case int:
+		return float64(val)
+	case int8:
+		return float64(val)
+	case int16:
+		return float64(val)
+	case int32:
+		return float64(val)
+	case int64:
+		return float64(val)
+	case uint:
+		return float64(val)
+	case uint8:
+		return float64(val)
+	case uint16:
+		return float64(val)
+	case uint32:
+		return float64(val)
+	case uint64:
+		return float64(val)
+	case float32:
+		return float64(val)
+	case json.Number:
+		f, err := val.Float64()
+		if err != nil {
+			return val.String()
+		}
+		return f
+	case []string:
+		list := make([]interface{}, 0, len(val))
+		for _, item := range val {
+			list = append(list, item)
+		}
+		return list
+	case []interface{}:
+		list := make([]interface{}, 0, len(val))
+		for _, item := range val {
+			list = append(list, Normalize(item))
+		}
+		return list
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			m[k] = Normalize(item)
+		}
+		return m
+	}
+	return fmt.Sprint(v)
+}
+
+func (n *literalNode) eval(env *Env) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *varNode) eval(env *Env) (interface{}, error) {
+	if v, ok := env.Vars[n.name]; ok {
+		return Normalize(v), nil
+	}
+	// 支持 a.b 形式访问对象字段，a 为对象列表时取出各项的 b 组成列表
+	if idx := strings.Index(n.name, "."); idx > 0 {
+		switch obj := Normalize(env.Vars[n.name[:idx]]).(type) {
+		case map[string]interface{}:
+			return obj[n.name[idx+1:]], nil
+		case []interface{}:
+			return Pluck(obj, n.name[idx+1:]), nil
+		}
+	}
+	return nil, nil
+}
+
+func (n *listNode) eval(env *Env) (interface{}, error) {
+	list := make([]interface{}, 0, len(n.items))
+	for _, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+func (n *callNode) eval(env *Env) (interface{}, error) {
+	fn, ok := env.Funcs[n.name]
+	if !ok {
+		fn, ok = lookupFunc(n.name)
+	}
+	if !ok {
+		return nil, fmt.Errorf("未定义的函数 %s", n.name)
+	}
+	args := make([]interface{}, 0, len(n.args))
+	for _, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	v, err := fn(args...)
+	if err != nil {
+		return nil, fmt.Errorf("函数 %s 执行错误: %v", n.name, err)
+	}
+	return Normalize(v), nil
+}
+
+func (n *unaryNode) eval(env *Env) (interface{}, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "not" {
+		return !Truthy(v), nil
+	}
+	f, err := ToNumber(v)
+	if err != nil {
+		return nil, err
+	}
+	return -f, nil
+}
+
+func (n *betweenNode) eval(env *Env) (interface{}, error) {
+	v, err := n.value.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	low, err := n.low.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	high, err := n.high.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return false, nil
+	}
+	c1, err := Compare(v, low)
+	if err != nil {
+		return nil, err
+	}
+	c2, err := Compare(v, high)
+	if err != nil {
+		return nil, err
+	}
+	return (c1 >= 0 && c2 <= 0) != n.not, nil
+}
+
+func (n *binaryNode) eval(env *Env) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	// 逻辑运算短路求值
+	switch n.op {
+	case "and":
+		if !Truthy(left) {
+			return false, nil
+		}
+		right, err := n.right.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		return Truthy(right), nil
+	case "or":
+		if Truthy(left) {
+			return true, nil
+		}
+		right, err := n.right.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		return Truthy(right), nil
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return Equal(left, right), nil
+	case "!=":
+		return !Equal(left, right), nil
+	case ">", ">=", "<", "<=":
+		if left == nil || right == nil {
+			return false, nil
+		}
+		c, err := Compare(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case ">":
+			return c > 0, nil
+		case ">=":
+			return c >= 0, nil
+		case "<":
+			return c < 0, nil
+		}
+		return c <= 0, nil
+	case "in":
+		return contains(right, left), nil
+	case "contains":
+		return contains(left, right), nil
+	case "+":
+		if ls, ok := left.(string); ok {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(ls), 64); err != nil {
+				return ls + toString(right), nil
+			}
+		}
+		return arithmetic(n.op, left, right)
+	}
+	return arithmetic(n.op, left, right)
+}
+
+func arithmetic(op string, left, right interface{}) (interface{}, error) {
+	l, err := ToNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := ToNumber(right)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, errors.New("除数不能为0")
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, errors.New("除数不能为0")
+		}
+		return math.Mod(l, r), nil
+	}
+	return nil, fmt.Errorf("不支持的运算符 %s", op)
+}
+
+// contains 列表包含元素或字符串包含子串
+func contains(container, item interface{}) bool {
+	switch c := container.(type) {
+	case []interface{}:
+		for _, v := range c {
+			if Equal(v, item) {
+				return true
+			}
+		}
+		return false
+	case string:
+		if list, ok := parseList(c); ok {
+			return contains(list, item)
+		}
+		return item != nil && strings.Contains(c, toString(item))
+	case nil:
+		return false
+	}
+	return Equal(container, item)
+}
+
+// Equal 按类型判断相等
+func Equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	c, err := Compare(a, b)
+	if err != nil {
+		return toString(a) == toString(b)
+	}
+	return c == 0
+}
+
+// Compare 按类型比较大小：数字按数值，日期按时间，其余按字符串
+func Compare(a, b interface{}) (int, error) {
+	switch av := a.(type) {
+	case float64:
+		bv, err := ToNumber(b)
+		if err != nil {
+			return 0, fmt.Errorf("无法将 %v 与数字 %v 比较", b, av)
+		}
+		return compareFloat(av, bv), nil
+	case time.Time:
+		bv, err := ToTime(b)
+		if err != nil {
+			return 0, fmt.Errorf("无法将 %v 与日期比较", b)
+		}
+		return compareTime(av, bv), nil
+	case bool:
+		bv, err := ToBool(b)
+		if err != nil {
+			return 0, err
+		}
+		if av == bv {
+			return 0, nil
+		}
+		if !av {
+			return -1, nil
+		}
+		return 1, nil
+	case string:
+		switch b.(type) {
+		case float64, time.Time, bool:
+			c, err := Compare(b, a)
+			return -c, err
+		case string:
+			// 两边均为数字字符串时按数值比较
+			if af, err := strconv.ParseFloat(strings.TrimSpace(av), 64); err == nil {
+				if bf, err := strconv.ParseFloat(strings.TrimSpace(b.(string)), 64); err == nil {
+					return compareFloat(af, bf), nil
+				}
+			}
+			return strings.Compare(av, b.(string)), nil
+		}
+	}
+	return 0, fmt.Errorf("无法比较 %v 与 %v", a, b)
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+// ToNumber 转为数字
+func ToNumber(v interface{}) (float64, error) {
+	switch val := v.(type) {
+	case float64:
+		return val, nil
+	case bool:
+		if val {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		s := strings.ReplaceAll(strings.TrimSpace(val), ",", "")
+		if s == "" {
+			return 0, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q 不是有效的数字", val)
+		}
+		return f, nil
+	case nil:
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%v 不是有效的数字", v)
+}
+
+// ToTime 转为日期时间
+func ToTime(v interface{}) (time.Time, error) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, nil
+	case float64:
+		return time.Unix(int64(val), 0), nil
+	case string:
+		s := strings.TrimSpace(val)
+		if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(ts, 0), nil
+		}
+		c := carbon.Parse(s)
+		if c.HasError() || c.IsZero() {
+			return time.Time{}, fmt.Errorf("%q 不是有效的日期", val)
+		}
+		return c.StdTime(), nil
+	}
+	return time.Time{}, fmt.Errorf("%v 不是有效的日期", v)
+}
+
+// ToBool 转为布尔值
+func ToBool(v interface{}) (bool, error) {
+	switch val := v.(type) {
+	case bool:
+		return val, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(val))
+		if err != nil {
+			return false, fmt.Errorf("%q 不是有效的布尔值", val)
+		}
+		return b, nil
+	}
+	return Truthy(v), nil
+}
+
+// Pluck 取出对象列表中各项的字段值，不是对象的项跳过
+func Pluck(list []interface{}, key string) []interface{} {
+	values := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		if obj, ok := item.(map[string]interface{}); ok {
+			values = append(values, Normalize(obj[key]))
+		}
+	}
+	return values
+}
+
+// ToList 转为列表，JSON 数组字符串会被解析
+func ToList(v interface{}) []interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return val
+	case string:
+		if list, ok := parseList(val); ok {
+			return list
+		}
+	}
+	return []interface{}{v}
+}
+
+func parseList(s string) ([]interface{}, bool) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") {
+		return nil, false
+	}
+	var list []interface{}
+	if err := json.Unmarshal([]byte(s), &list); err != nil {
+		return nil, false
+	}
+	return Normalize(list).([]interface{}), true
+}
+
+func toString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case time.Time:
+		return val.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,179 @@
+package expression
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func eval(t *testing.T, src string, vars map[string]interface{}) (interface{}, error) {
+	t.Helper()
+	e, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return e.Eval(&Env{Vars: vars})
+}
+
+func TestEval(t *testing.T) {
+	vars := map[string]interface{}{
+		"amount": "1,200",
+		"count":  3,
+		"dept":   "研发",
+		"depts":  `["研发","财务"]`,
+		"tags":   []string{"urgent", "travel"},
+		"items":  []interface{}{map[string]interface{}{"price": 10}, map[string]interface{}{"price": 2.5}},
+		"empty":  "",
+	}
+	cases := []struct {
+		src  string
+		want interface{}
+	}{
+		// 优先级与结合性
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"10 - 4 - 3", 3.0},
+		{"-2 * 3", -6.0},
+		{"7 % 4 + 1", 4.0},
+		{"not 1 == 2", true},
+		{"true or false and false", true},
+		{"(true or false) and false", false},
+		{"1 < 2 and 3 > 4 or 5 == 5", true},
+		{"1 = 1 && 2 <> 3 || false", true},
+		// 字符串与数字
+		{`"10" > 9`, true},
+		{`"10" == 10`, true},
+		{`"10" > "9"`, true},
+		{`"b" > "a"`, true},
+		{`"2" + 3`, 5.0},
+		{`"abc" + 1`, "abc1"},
+		{"amount > 1000", true},
+		{"count >= 3", true},
+		{"empty == null", false},
+		{"missing == null", true},
+		{"missing > 1", false},
+		// 列表
+		{`"研发" in ["研发", "财务"]`, true},
+		{"3 in (1, 2, 3)", true},
+		{`"人事" not in ["研发"]`, true},
+		{"dept in depts", true},
+		{`tags contains "travel"`, true},
+		{`dept not contains "财"`, true},
+		{"sum(items.price)", 12.5},
+		{"count(tags)", 2.0},
+		// 区间
+		{"5 between 1 and 10", true},
+		{"count not between 4 and 10", true},
+		// 函数
+		{"round(1.25, 1)", 1.3},
+		{"max(1, [5, 3], 2)", 5.0},
+		{`days_between("2024-01-01", "2024-01-31")`, 30.0},
+		{`date("2024-01-02") > date("2024-01-01")`, true},
+		{`UPPER("a")`, "A"},
+	}
+	for _, c := range cases {
+		got, err := eval(t, c.src, vars)
+		if err != nil {
+			t.Fatalf("%s: %v", c.src, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%s = %#v，期望 %#v", c.src, got, c.want)
+		}
+	}
+}
+
+// and、or 短路求值，右侧不会出错
+func TestShortCircuit(t *testing.T) {
+	for src, want := range map[string]bool{
+		"false and 1 / 0 > 0":  false,
+		"true or 1 / 0":        true,
+		"false && undefined()": false,
+		"true || undefined()":  true,
+	} {
+		got, err := eval(t, src, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if got != want {
+			t.Fatalf("%s = %v，期望 %v", src, got, want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{"1 / 0", "除数不能为0"},
+		{"5 % 0", "除数不能为0"},
+		{"true and 1 / 0", "除数不能为0"},
+		{"abs()", "参数个数错误"},
+		{"abs(1, 2)", "参数个数错误"},
+		{"round(1, 2, 3)", "参数个数错误"},
+		{"days_between(today())", "参数个数错误"},
+		{"undefined(1)", "未定义的函数"},
+		{`"abc" * 2`, "不是有效的数字"},
+		{`date("abc")`, "不是有效的日期"},
+		{`1 > date("2024-01-01")`, "无法将"},
+	}
+	for _, c := range cases {
+		_, err := eval(t, c.src, nil)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s 应报错[%s]，实际 %v", c.src, c.want, err)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, src := range []string{"1 +", "(1", "'abc", "1 2", "a not b", "5 between 1", "#", "[1, 2"} {
+		if _, err := Compile(src); err == nil {
+			t.Fatalf("%s 应解析失败", src)
+		}
+	}
+}
+
+func TestVarsAndFuncs(t *testing.T) {
+	e, err := Compile("sum(items.price) > limit and Lower(dept) == dept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"items.price", "limit", "dept"}; !reflect.DeepEqual(e.Vars(), want) {
+		t.Fatalf("变量为 %v，期望 %v", e.Vars(), want)
+	}
+	if want := []string{"sum", "lower"}; !reflect.DeepEqual(e.Funcs(), want) {
+		t.Fatalf("函数为 %v，期望 %v", e.Funcs(), want)
+	}
+}
+
+// 缓存达到上限时清空，之后重新累积
+func TestCompileCacheEviction(t *testing.T) {
+	cacheMu.Lock()
+	cache = make(map[string]*Expression, cacheSize)
+	cacheMu.Unlock()
+	first, err := Compile("0 + 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Compile("0 + 0"); again != first {
+		t.Fatal("相同表达式应使用缓存的解析结果")
+	}
+	for i := 1; i < cacheSize; i++ {
+		if _, err = Compile(fmt.Sprintf("%d + 0", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(cache); n != cacheSize {
+		t.Fatalf("缓存应有 %d 个表达式，实际 %d", cacheSize, n)
+	}
+	if _, err = Compile("overflow + 0"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(cache); n != 1 {
+		t.Fatalf("超出上限后缓存应清空重新累积，实际 %d 个", n)
+	}
+	if again, _ := Compile("0 + 0"); again == first {
+		t.Fatal("清空后应重新解析")
+	}
+}
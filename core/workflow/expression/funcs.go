@@ -0,0 +1,312 @@
+package expression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	funcs   = map[string]Func{}
+	funcsMu sync.RWMutex
+)
+
+// RegisterFunc 注册全局表达式函数，函数名不区分大小写
+func RegisterFunc(name string, fn Func) {
+	funcsMu.Lock()
+	defer funcsMu.Unlock()
+	funcs[strings.ToLower(name)] = fn
+}
+
+func lookupFunc(name string) (Func, bool) {
+	funcsMu.RLock()
+	defer funcsMu.RUnlock()
+	fn, ok := funcs[name]
+	return fn, ok
+}
+
+func argCount(args []interface{}, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return fmt.Errorf("参数个数错误")
+	}
+	return nil
+}
+
+// numbers 将参数展开为数字列表，列表参数会被展开
+func numbers(args []interface{}) ([]float64, error) {
+	var result []float64
+	for _, arg := range args {
+		for _, item := range ToList(arg) {
+			if item == nil {
+				continue
+			}
+			f, err := ToNumber(item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, f)
+		}
+	}
+	return result, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func init() {
+	// 日期
+	RegisterFunc("now", func(args ...interface{}) (interface{}, error) {
+		return time.Now(), nil
+	})
+	RegisterFunc("today", func(args ...interface{}) (interface{}, error) {
+		return startOfDay(time.Now()), nil
+	})
+	RegisterFunc("date", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		return ToTime(args[0])
+	})
+	RegisterFunc("days_between", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 2, 2); err != nil {
+			return nil, err
+		}
+		start, err := ToTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := ToTime(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return math.Round(startOfDay(end).Sub(startOfDay(start)).Hours() / 24), nil
+	})
+	RegisterFunc("hours_between", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 2, 2); err != nil {
+			return nil, err
+		}
+		start, err := ToTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := ToTime(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return end.Sub(start).Hours(), nil
+	})
+	RegisterFunc("add_days", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 2, 2); err != nil {
+			return nil, err
+		}
+		t, err := ToTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		days, err := ToNumber(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return t.AddDate(0, 0, int(days)), nil
+	})
+	datePart := func(part func(t time.Time) int) Func {
+		return func(args ...interface{}) (interface{}, error) {
+			if err := argCount(args, 1, 1); err != nil {
+				return nil, err
+			}
+			t, err := ToTime(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return float64(part(t)), nil
+		}
+	}
+	RegisterFunc("year", datePart(func(t time.Time) int { return t.Year() }))
+	RegisterFunc("month", datePart(func(t time.Time) int { return int(t.Month()) }))
+	RegisterFunc("day", datePart(func(t time.Time) int { return t.Day() }))
+	RegisterFunc("hour", datePart(func(t time.Time) int { return t.Hour() }))
+	// 星期一至星期日为 1-7
+	RegisterFunc("weekday", datePart(func(t time.Time) int {
+		if t.Weekday() == time.Sunday {
+			return 7
+		}
+		return int(t.Weekday())
+	}))
+
+	// 数字
+	RegisterFunc("number", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		return ToNumber(args[0])
+	})
+	RegisterFunc("abs", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		f, err := ToNumber(args[0])
+		return math.Abs(f), err
+	})
+	RegisterFunc("round", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 1, 2); err != nil {
+			return nil, err
+		}
+		f, err := ToNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		n := 0.0
+		if len(args) == 2 {
+			if n, err = ToNumber(args[1]); err != nil {
+				return nil, err
+			}
+		}
+		base := math.Pow(10, n)
+		return math.Round(f*base) / base, nil
+	})
+	RegisterFunc("floor", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		f, err := ToNumber(args[0])
+		return math.Floor(f), err
+	})
+	RegisterFunc("ceil", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		f, err := ToNumber(args[0])
+		return math.Ceil(f), err
+	})
+	RegisterFunc("sum", func(args ...interface{}) (interface{}, error) {
+		nums, err := numbers(args)
+		if err != nil {
+			return nil, err
+		}
+		total := 0.0
+		for _, f := range nums {
+			total += f
+		}
+		return total, nil
+	})
+	RegisterFunc("avg", func(args ...interface{}) (interface{}, error) {
+		nums, err := numbers(args)
+		if err != nil {
+			return nil, err
+		}
+		if len(nums) == 0 {
+			return 0.0, nil
+		}
+		total := 0.0
+		for _, f := range nums {
+			total += f
+		}
+		return total / float64(len(nums)), nil
+	})
+	RegisterFunc("min", func(args ...interface{}) (interface{}, error) {
+		nums, err := numbers(args)
+		if err != nil {
+			return nil, err
+		}
+		if len(nums) == 0 {
+			return nil, errors.New("没有可比较的数字")
+		}
+		result := nums[0]
+		for _, f := range nums[1:] {
+			result = math.Min(result, f)
+		}
+		return result, nil
+	})
+	RegisterFunc("max", func(args ...interface{}) (interface{}, error) {
+		nums, err := numbers(args)
+		if err != nil {
+			return nil, err
+		}
+		if len(nums) == 0 {
+			return nil, errors.New("没有可比较的数字")
+		}
+		result := nums[0]
+		for _, f := range nums[1:] {
+			result = math.Max(result, f)
+		}
+		return result, nil
+	})
+
+	// 字符串与列表
+	RegisterFunc("len", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case nil:
+			return 0.0, nil
+		case string:
+			if list, ok := parseList(v); ok {
+				return float64(len(list)), nil
+			}
+			return float64(len([]rune(v))), nil
+		}
+		return float64(len(ToList(args[0]))), nil
+	})
+	RegisterFunc("count", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		return float64(len(ToList(args[0]))), nil
+	})
+	RegisterFunc("lower", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		return strings.ToLower(toString(args[0])), nil
+	})
+	RegisterFunc("upper", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		return strings.ToUpper(toString(args[0])), nil
+	})
+	RegisterFunc("trim", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		return strings.TrimSpace(toString(args[0])), nil
+	})
+	RegisterFunc("contains", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 2, 2); err != nil {
+			return nil, err
+		}
+		return contains(args[0], args[1]), nil
+	})
+	RegisterFunc("starts_with", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 2, 2); err != nil {
+			return nil, err
+		}
+		return strings.HasPrefix(toString(args[0]), toString(args[1])), nil
+	})
+	RegisterFunc("ends_with", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 2, 2); err != nil {
+			return nil, err
+		}
+		return strings.HasSuffix(toString(args[0]), toString(args[1])), nil
+	})
+	RegisterFunc("empty", func(args ...interface{}) (interface{}, error) {
+		if err := argCount(args, 1, 1); err != nil {
+			return nil, err
+		}
+		switch v := args[0].(type) {
+		case nil:
+			return true, nil
+		case string:
+			s := strings.TrimSpace(v)
+			return s == "" || s == "[]", nil
+		case []interface{}:
+			return len(v) == 0, nil
+		}
+		return false, nil
+	})
+}
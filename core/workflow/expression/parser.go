@@ -0,0 +1,344 @@
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// node 语法树节点
+type node interface {
+	eval(env *Env) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+type varNode struct {
+	name string
+}
+
+type listNode struct {
+	items []node
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+type betweenNode struct {
+	value, low, high node
+	not              bool
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	vars   []string
+	funcs  []string
+}
+
+// 关键字不区分大小写
+func (p *parser) peekKeyword(words ...string) bool {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenIdent {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(tok.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) peekOperator(ops ...string) bool {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenOperator {
+		return false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("第%d个字符处%s", p.tokens[p.pos].pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	if p.tokens[p.pos].kind != kind {
+		return p.errorf("缺少 %s", text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") || p.peekOperator("||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") || p.peekOperator("&&") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.peekKeyword("not") || p.peekOperator("!") {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "not", operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if p.peekOperator("==", "=", "!=", "<>", ">", ">=", "<", "<=") {
+		op := p.next().text
+		switch op {
+		case "=":
+			op = "=="
+		case "<>":
+			op = "!="
+		}
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{op: op, left: left, right: right}, nil
+	}
+	not := false
+	if p.peekKeyword("not") {
+		p.next()
+		not = true
+		if !p.peekKeyword("in", "between", "contains") {
+			return nil, p.errorf("not 之后应为 in、between 或 contains")
+		}
+	}
+	var result node
+	switch {
+	case p.peekKeyword("in"):
+		p.next()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		result = &binaryNode{op: "in", left: left, right: right}
+	case p.peekKeyword("contains"):
+		p.next()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		result = &binaryNode{op: "contains", left: left, right: right}
+	case p.peekKeyword("between"):
+		p.next()
+		low, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekKeyword("and") {
+			return nil, p.errorf("between 缺少 and")
+		}
+		p.next()
+		high, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &betweenNode{value: left, low: low, high: high, not: not}, nil
+	default:
+		return left, nil
+	}
+	if not {
+		return &unaryNode{op: "not", operand: result}, nil
+	}
+	return result, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOperator("+", "-") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOperator("*", "/", "%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peekOperator("-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "-", operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.tokens[p.pos]
+	switch tok.kind {
+	case tokenNumber:
+		p.next()
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("第%d个字符处数字 %s 格式错误", tok.pos+1, tok.text)
+		}
+		return &literalNode{value: f}, nil
+	case tokenString:
+		p.next()
+		return &literalNode{value: tok.value}, nil
+	case tokenIdent:
+		p.next()
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		}
+		if p.tokens[p.pos].kind == tokenLParen {
+			p.next()
+			args, err := p.parseItems(tokenRParen, ")")
+			if err != nil {
+				return nil, err
+			}
+			name := strings.ToLower(tok.text)
+			p.funcs = appendUnique(p.funcs, name)
+			return &callNode{name: name, args: args}, nil
+		}
+		p.vars = appendUnique(p.vars, tok.text)
+		return &varNode{name: tok.text}, nil
+	case tokenLParen:
+		p.next()
+		items, err := p.parseItems(tokenRParen, ")")
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 1 {
+			return items[0], nil
+		}
+		return &listNode{items: items}, nil
+	case tokenLBracket:
+		p.next()
+		items, err := p.parseItems(tokenRBracket, "]")
+		if err != nil {
+			return nil, err
+		}
+		return &listNode{items: items}, nil
+	case tokenEOF:
+		return nil, p.errorf("表达式不完整")
+	}
+	return nil, p.errorf("出现意外的 %s", tok.text)
+}
+
+// parseItems 解析逗号分隔的表达式列表，直到结束符
+func (p *parser) parseItems(end tokenKind, endText string) ([]node, error) {
+	var items []node
+	if p.tokens[p.pos].kind == end {
+		p.next()
+		return items, nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.tokens[p.pos].kind == tokenComma {
+			p.next()
+			continue
+		}
+		if err = p.expect(end, endText); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,162 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// 未找到审批人时的兜底策略
+const (
+	NoAuditorError     = "error"     // 报错，流程停留在上一步骤
+	NoAuditorSkip      = "skip"      // 自动跳过该步骤
+	NoAuditorAdmin     = "admin"     // 转交流程管理员
+	NoAuditorSuperior  = "superior"  // 沿发起人部门向上查找主管
+	NoAuditorInitiator = "initiator" // 退回发起人
+)
+
+// noAuditorPolicy 步骤的兜底策略，步骤未设置时按流程设置，均未设置时报错
+func noAuditorPolicy(flow models.Flow, process models.Process) string {
+	if process.NoAuditor != "" {
+		return process.NoAuditor
+	}
+	if flow.NoAuditor != "" {
+		return flow.NoAuditor
+	}
+	return NoAuditorError
+}
+
+// fallbackAuditors 步骤未找到审批人时按兜底策略处理：转交时返回替代的审批人及处理说明；
+// 跳过或退回发起人时步骤已处理完毕，返回的审批人为 nil
+func (s *Service) fallbackAuditors(t *flowTx, entry *models.Entry, g *flowGraph, branchID int, process models.Process) ([]models.Emp, string, error) {
+	switch noAuditorPolicy(g.Flow, process) {
+	case NoAuditorSkip:
+		return nil, "", s.skipProcess(t, entry, g, branchID, process)
+	case NoAuditorInitiator:
+		return nil, "", s.fallbackToInitiator(t, entry, branchID, process)
+	}
+	return fallbackTargets(t, &AuditorContext{DB: t.DB, Entry: entry}, g, process)
+}
+
+// fallbackTargets 按转交类的兜底策略查找替代的审批人，返回处理说明；其他策略时报错
+func fallbackTargets(t *flowTx, ctx *AuditorContext, g *flowGraph, process models.Process) ([]models.Emp, string, error) {
+	var ids []int
+	var action string
+	switch noAuditorPolicy(g.Flow, process) {
+	case NoAuditorAdmin:
+		if g.Flow.AdminID <= 0 {
+			return nil, "", fmt.Errorf("步骤[%s]未找到审批人，且流程未设置管理员", process.ProcessName)
+		}
+		ids = []int{g.Flow.AdminID}
+		action = "转交流程管理员"
+	case NoAuditorSuperior:
+		var err error
+		if ids, err = resolveSuperior(ctx, ""); err != nil {
+			return nil, "", err
+		}
+		action = "转交发起人上级主管"
+	default:
+		return nil, "", errors.New("未找到下一步骤审批人")
+	}
+	auditors, err := orderedEmps(t, ids)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(auditors) == 0 {
+		return nil, "", fmt.Errorf("步骤[%s]未找到审批人，%s失败", process.ProcessName, action)
+	}
+	return auditors, "未找到审批人，" + action, nil
+}
+
+// skipProcess 自动跳过未找到审批人的步骤：记录一条由系统通过的处理记录后按流转继续
+func (s *Service) skipProcess(t *flowTx, entry *models.Entry, g *flowGraph, branchID int, process models.Process) error {
+	// 流转形成回路且回路中的步骤都无审批人时避免无限跳过
+	if t.skips++; t.skips > len(g.Processes) {
+		return errors.New("连续跳过的步骤过多，请检查流程的审批人设置")
+	}
+	reason := "未找到审批人，自动跳过"
+	proc := models.Proc{
+		EntryID:     entry.ID,
+		FlowID:      int(entry.FlowID),
+		ProcessID:   int(process.ID),
+		ProcessName: process.ProcessName,
+		AuditorName: "系统",
+		Status:      models.ProcStatusPassed,
+		IsRead:      1,
+		Content:     reason,
+		Circle:      entry.Circle,
+		Concurrence: s.batchConcurrence(t, entry, int(process.ID)),
+		Fallback:    reason,
+		BranchID:    branchID,
+	}
+	if err := t.Create(&proc).Error; err != nil {
+		return fmt.Errorf("数据库插入错误: %v", err)
+	}
+	// IsReal 默认值为 true，系统处理的记录单独更新
+	if err := t.Model(&models.Proc{}).Where("id=?", proc.ID).Update("is_real", false).Error; err != nil {
+		return fmt.Errorf("数据库更新错误: %v", err)
+	}
+	if err := s.logEvent(t, entry, models.EntryEvent{Action: EventSkip, ProcID: proc.ID, ActorName: "系统", ToProcessID: int(process.ID), BranchID: branchID, Content: reason}); err != nil {
+		return err
+	}
+	if err := s.moveTo(t, entry, branchID, int(process.ID)); err != nil {
+		return err
+	}
+	return s.transfer(t, entry, proc)
+}
+
+// fallbackToInitiator 未找到审批人时退回发起人：取消未处理的待办及并行分支，流程回到草稿由发起人修改后重新提交
+func (s *Service) fallbackToInitiator(t *flowTx, entry *models.Entry, branchID int, process models.Process) error {
+	if entry.Pid > 0 {
+		return fmt.Errorf("步骤[%s]未找到审批人，子流程不能退回发起人", process.ProcessName)
+	}
+	reason := "未找到审批人，退回发起人"
+	var emp models.Emp
+	t.Preload("Dept").First(&emp, entry.EmpID)
+	proc := models.Proc{
+		EntryID:     entry.ID,
+		FlowID:      int(entry.FlowID),
+		ProcessID:   int(process.ID),
+		ProcessName: process.ProcessName,
+		EmpID:       int(emp.ID),
+		EmpName:     emp.Name,
+		DeptName:    emp.Dept.DeptName,
+		AuditorName: "系统",
+		Status:      models.ProcStatusRejected,
+		IsRead:      1,
+		Content:     reason,
+		Circle:      entry.Circle,
+		Concurrence: s.batchConcurrence(t, entry, int(process.ID)),
+		Fallback:    reason,
+		BranchID:    branchID,
+	}
+	if err := t.Create(&proc).Error; err != nil {
+		return fmt.Errorf("数据库插入错误: %v", err)
+	}
+	if err := t.Model(&models.Proc{}).Where("id=?", proc.ID).Update("is_real", false).Error; err != nil {
+		return fmt.Errorf("数据库更新错误: %v", err)
+	}
+	pending, err := s.transitProcs(t, openProcs([]uint{entry.ID}), models.ProcStatusCancelled, reason, map[string]interface{}{
+		"is_real": false,
+		"content": reason,
+	})
+	if err != nil {
+		return err
+	}
+	s.cancelDeadlines(t, pending...)
+	if err = s.cancelBranches(t, entry.ID, nil, reason); err != nil {
+		return err
+	}
+	entry.ReturnProcessID = 0
+	err = s.transitEntry(t, entry, models.EntryStatusDraft, reason, map[string]interface{}{
+		"return_process_id": 0,
+	})
+	if err != nil {
+		return err
+	}
+	initiator := entry.EmpID
+	t.afterCommit(func() {
+		_ = s.wf.NotifySendOne(initiator)
+	})
+	return nil
+}
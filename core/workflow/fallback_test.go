@@ -0,0 +1,89 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// fallbackFlow 发起 → A(审批人取自空的表单字段，按 policy 兜底) → B(carol) → 结束
+func fallbackFlow(e *testEnv, policy string) (uint, uint) {
+	flowID := e.flow("fallback")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("admin_id", empDave).Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A", NoAuditor: policy}, "", false)
+	e.must(e.db.Create(&models.Flowlink{FlowID: flowID, Type: AuditorField, ProcessID: a, Auditor: "approver"}).Error)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "3", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, int(b), "")
+	e.link(flowID, b, -1, "")
+	return flowID, a
+}
+
+// fallbackProc 流程中该步骤的第一条处理记录
+func (e *testEnv) fallbackProc(entryID uint, processID uint) models.Proc {
+	e.t.Helper()
+	var proc models.Proc
+	e.must(e.db.Where("entry_id=?", entryID).Where("process_id=?", processID).First(&proc).Error)
+	return proc
+}
+
+func TestNoAuditorFallback(t *testing.T) {
+	t.Run("报错", func(t *testing.T) {
+		e := newTestEnv(t)
+		flowID, _ := fallbackFlow(e, NoAuditorError)
+		if _, err := e.s.Start(flowID, empAlice, "测试", nil); err == nil {
+			t.Fatal("未找到审批人时应报错")
+		}
+	})
+	t.Run("转交管理员", func(t *testing.T) {
+		e := newTestEnv(t)
+		flowID, a := fallbackFlow(e, NoAuditorAdmin)
+		entry := e.start(flowID, nil)
+		proc := e.fallbackProc(entry.ID, a)
+		if proc.EmpID != int(empDave) || proc.Status != models.ProcStatusPending || !strings.Contains(proc.Fallback, "管理员") {
+			t.Fatalf("应转交管理员dave处理并记录兜底说明，实际 %+v", proc)
+		}
+	})
+	t.Run("转交上级主管", func(t *testing.T) {
+		e := newTestEnv(t)
+		flowID, a := fallbackFlow(e, NoAuditorSuperior)
+		entry := e.start(flowID, nil)
+		proc := e.fallbackProc(entry.ID, a)
+		if proc.EmpID != int(empBob) || !strings.Contains(proc.Fallback, "上级主管") {
+			t.Fatalf("应转交发起人上级主管bob处理，实际 %+v", proc)
+		}
+	})
+	t.Run("跳过", func(t *testing.T) {
+		e := newTestEnv(t)
+		flowID, a := fallbackFlow(e, NoAuditorSkip)
+		entry := e.start(flowID, nil)
+		proc := e.fallbackProc(entry.ID, a)
+		if proc.Status != models.ProcStatusPassed || proc.IsReal || proc.Fallback == "" {
+			t.Fatalf("步骤A应由系统通过，实际 %+v", proc)
+		}
+		if e.pending(entry.ID, empCarol) == 0 {
+			t.Fatalf("跳过后应进入步骤B；待办 %s", e.procs(entry.ID))
+		}
+		events, err := e.s.Events(entry.ID, empAlice)
+		e.must(err)
+		skipped := false
+		for _, event := range events {
+			skipped = skipped || (event.Action == EventSkip && event.ProcID == proc.ID)
+		}
+		if !skipped {
+			t.Fatal("事件日志应记录自动跳过")
+		}
+	})
+	t.Run("退回发起人", func(t *testing.T) {
+		e := newTestEnv(t)
+		flowID, a := fallbackFlow(e, NoAuditorInitiator)
+		entry := e.start(flowID, nil)
+		e.expectStatus(entry.ID, models.EntryStatusDraft)
+		proc := e.fallbackProc(entry.ID, a)
+		if proc.Status != models.ProcStatusRejected || proc.EmpID != int(empAlice) || proc.Fallback == "" {
+			t.Fatalf("步骤A应由系统退回发起人，实际 %+v", proc)
+		}
+	})
+}
@@ -0,0 +1,269 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// 步骤的表单字段权限
+const (
+	FieldPermHidden   = "hidden"   // 隐藏，不可查看
+	FieldPermReadonly = "readonly" // 只读
+	FieldPermEditable = "editable" // 可编辑
+	FieldPermRequired = "required" // 可编辑且本步骤必填
+)
+
+// fieldPermRank 权限从低到高，合并多个步骤的权限时取最高者
+var fieldPermRank = map[string]int{
+	FieldPermHidden:   0,
+	FieldPermReadonly: 1,
+	FieldPermEditable: 2,
+	FieldPermRequired: 3,
+}
+
+// EntryView 按查看人权限过滤后的流程详情
+type EntryView struct {
+	Entry      *models.Entry     `json:"entry"`       // 流程及处理记录，表单数据只含可查看的字段
+	FieldPerms map[string]string `json:"field_perms"` // 查看人对各表单字段的权限
+}
+
+// editable 权限是否允许修改字段
+func editable(perm string) bool {
+	return perm == FieldPermEditable || perm == FieldPermRequired
+}
+
+// stepFieldPerms 步骤对各表单字段的权限，未配置的字段第一步为可编辑，其余步骤为只读
+func stepFieldPerms(g *flowGraph, process models.Process) map[string]string {
+	def := FieldPermReadonly
+	if process.Position == 0 {
+		def = FieldPermEditable
+	}
+	perms := make(map[string]string, len(g.Template.TemplateForms))
+	for _, form := range g.Template.TemplateForms {
+		perm := process.FieldPerms[form.Field]
+		if _, ok := fieldPermRank[perm]; !ok {
+			perm = def
+		}
+		perms[form.Field] = perm
+	}
+	return perms
+}
+
+// startFieldPerms 发起人在第一步的字段权限，流程未设置第一步时均可编辑
+func startFieldPerms(g *flowGraph) map[string]string {
+	starts := g.starts()
+	if len(starts) == 0 {
+		return stepFieldPerms(g, models.Process{})
+	}
+	return stepFieldPerms(g, starts[0])
+}
+
+// stepData 按步骤权限检查提交的表单数据：不可编辑的字段不得修改，本步骤必填及可编辑字段按表单规则校验；
+// replace 时 data 中没有的不可编辑字段保留原值，返回实际写入的数据，表单以外的字段不受限制
+func stepData(g *flowGraph, perms map[string]string, current map[string]string, data map[string]string, replace bool) (map[string]string, error) {
+	types := g.fieldTypes()
+	data = normalizeEntryData(types, data)
+	var errs FormErrors
+	for _, form := range g.Template.TemplateForms {
+		value, ok := data[form.Field]
+		if !ok || editable(perms[form.Field]) || value == current[form.Field] {
+			continue
+		}
+		errs = append(errs, FormError{Field: form.Field, FieldName: form.FieldName, Message: fmt.Sprintf("%s在当前步骤不可修改", formLabel(form))})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	merged := make(map[string]string, len(current)+len(data))
+	if !replace {
+		for field, value := range current {
+			merged[field] = value
+		}
+	} else {
+		for field, value := range current {
+			if _, ok := types[field]; ok && !editable(perms[field]) {
+				merged[field] = value
+			}
+		}
+	}
+	for field, value := range data {
+		merged[field] = value
+	}
+	if errs = validateStepData(g, perms, merged); len(errs) > 0 {
+		return nil, errs
+	}
+	if replace {
+		return merged, nil
+	}
+	return data, nil
+}
+
+// validateStepData 按表单规则校验本步骤可编辑的字段，本步骤必填的字段不能为空
+func validateStepData(g *flowGraph, perms map[string]string, data map[string]string) FormErrors {
+	var errs FormErrors
+	for _, err := range validateEntryData(g, data) {
+		if editable(perms[err.Field]) {
+			errs = append(errs, err)
+		}
+	}
+	reported := make(map[string]bool, len(errs))
+	for _, err := range errs {
+		reported[err.Field] = true
+	}
+	for _, form := range g.Template.TemplateForms {
+		if perms[form.Field] != FieldPermRequired || reported[form.Field] {
+			continue
+		}
+		value := strings.TrimSpace(data[form.Field])
+		if value == "" || (valueType(form.FieldType) == models.EntryValueList && len(checkboxItems(value)) == 0) {
+			errs = append(errs, FormError{Field: form.Field, FieldName: form.FieldName, Message: fmt.Sprintf("%s为本步骤必填字段", formLabel(form))})
+		}
+	}
+	return errs
+}
+
+// EntryDetail 员工查看流程详情：表单数据按其所在步骤的字段权限过滤，
+// 有待办时为待办步骤的权限，否则为发起、处理过或抄送步骤中可查看的字段，均为只读
+func (s *Service) EntryDetail(entryID uint, empID uint) (*EntryView, error) {
+	entry, perms, err := s.viewEntry(entryID, empID)
+	if err != nil {
+		return nil, err
+	}
+	filterEntryData(entry, perms)
+	return &EntryView{Entry: entry, FieldPerms: perms}, nil
+}
+
+// viewEntry 员工可查看的流程及其对各表单字段的权限
+func (s *Service) viewEntry(entryID uint, empID uint) (*models.Entry, map[string]string, error) {
+	var entry models.Entry
+	err := s.db.Preload("Procs", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	}).Preload("EntryDatas").First(&entry, entryID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("流程不存在")
+		}
+		return nil, nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	if !s.CanView(entryID, empID) {
+		return nil, nil, errors.New("无权查看该流程")
+	}
+	perms, err := s.viewerFieldPerms(&entry, empID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &entry, perms, nil
+}
+
+// viewerFieldPerms 员工对流程各表单字段的权限
+func (s *Service) viewerFieldPerms(entry *models.Entry, empID uint) (map[string]string, error) {
+	g, err := s.entryGraph(&flowTx{DB: s.db}, entry)
+	if err != nil {
+		return nil, err
+	}
+	var pending, viewed []uint
+	for _, proc := range entry.Procs {
+		open := proc.Status == models.ProcStatusPending && proc.Circle == entry.Circle && entry.Status == models.EntryStatusRunning
+		// 受托人按当前生效的委托规则判断，已过期的委托不再授予权限
+		if uint(proc.EmpID) != empID && uint(proc.AuditorID) != empID && !(open && canActFor(s.db, *proc, empID)) {
+			continue
+		}
+		if open {
+			pending = append(pending, uint(proc.ProcessID))
+		} else {
+			viewed = append(viewed, uint(proc.ProcessID))
+		}
+	}
+	if len(pending) > 0 {
+		return mergeFieldPerms(g, pending), nil
+	}
+	if entry.EmpID == empID {
+		for _, p := range g.starts() {
+			viewed = append(viewed, p.ID)
+		}
+	}
+	var records []models.CarbonCopyRecord
+	if err = s.db.Where("entry_id=?", entry.ID).Where("emp_id=?", empID).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	perms := mergeFieldPerms(g, viewed)
+	for _, record := range records {
+		// 流程结束时的抄送可查看全部字段
+		if record.ProcessID == 0 {
+			for field := range perms {
+				perms[field] = FieldPermReadonly
+			}
+			break
+		}
+		for field, perm := range mergeFieldPerms(g, []uint{record.ProcessID}) {
+			if fieldPermRank[perm] > fieldPermRank[perms[field]] {
+				perms[field] = perm
+			}
+		}
+	}
+	for field, perm := range perms {
+		if editable(perm) {
+			perms[field] = FieldPermReadonly
+		}
+	}
+	return perms, nil
+}
+
+// mergeFieldPerms 多个步骤的字段权限取最高者，没有步骤时全部隐藏
+func mergeFieldPerms(g *flowGraph, processIDs []uint) map[string]string {
+	perms := make(map[string]string, len(g.Template.TemplateForms))
+	for _, form := range g.Template.TemplateForms {
+		perms[form.Field] = FieldPermHidden
+	}
+	for _, id := range processIDs {
+		process, ok := g.process(int(id))
+		if !ok {
+			continue
+		}
+		for field, perm := range stepFieldPerms(g, process) {
+			if fieldPermRank[perm] > fieldPermRank[perms[field]] {
+				perms[field] = perm
+			}
+		}
+	}
+	return perms
+}
+
+// filterDataDiff 去掉数据变更事件中查看人无权查看的字段，全部字段均不可查看时为空
+func filterDataDiff(event *models.EntryEvent, perms map[string]string) {
+	if event.DataDiff == "" {
+		return
+	}
+	var diff map[string][2]*string
+	if err := json.Unmarshal([]byte(event.DataDiff), &diff); err != nil {
+		event.DataDiff = ""
+		return
+	}
+	for field := range diff {
+		if perm, ok := perms[field]; ok && perm == FieldPermHidden {
+			delete(diff, field)
+		}
+	}
+	if len(diff) == 0 {
+		event.DataDiff = ""
+		return
+	}
+	b, _ := json.Marshal(diff)
+	event.DataDiff = string(b)
+}
+
+// filterEntryData 去掉查看人无权查看的表单字段，表单以外的字段保留
+func filterEntryData(entry *models.Entry, perms map[string]string) {
+	datas := entry.EntryDatas[:0]
+	for _, d := range entry.EntryDatas {
+		if perm, ok := perms[d.FieldName]; ok && perm == FieldPermHidden {
+			continue
+		}
+		datas = append(datas, d)
+	}
+	entry.EntryDatas = datas
+}
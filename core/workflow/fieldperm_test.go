@@ -0,0 +1,70 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/types"
+)
+
+// 事件日志中的数据变更只含查看人可查看的字段
+func TestEventsHideFieldsFromViewer(t *testing.T) {
+	e := newTestEnv(t)
+	tmpl := models.Template{TemplateName: "报销"}
+	e.must(e.db.Create(&tmpl).Error)
+	for _, field := range []string{"amount", "secret"} {
+		e.must(e.db.Create(&models.TemplateForm{Field: field, FieldName: field, FieldType: "text", TemplateID: tmpl.ID}).Error)
+	}
+	flowID := e.flow("perm")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("template_id", tmpl.ID).Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A", FieldPerms: types.FieldPerms{"secret": FieldPermHidden}}, "2", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, -1, "")
+	entry := e.start(flowID, map[string]string{"amount": "100", "secret": "x"})
+
+	diff := func(empID uint) string {
+		events, err := e.s.Events(entry.ID, empID)
+		e.must(err)
+		for _, event := range events {
+			if event.Action == EventData {
+				return event.DataDiff
+			}
+		}
+		t.Fatalf("缺少数据变更事件")
+		return ""
+	}
+	if got := diff(empAlice); !strings.Contains(got, "secret") || !strings.Contains(got, "amount") {
+		t.Fatalf("发起人应可查看全部字段的变更，实际 %s", got)
+	}
+	if got := diff(empBob); strings.Contains(got, "secret") || !strings.Contains(got, "amount") {
+		t.Fatalf("审批人不应看到隐藏字段的变更，实际 %s", got)
+	}
+	if _, err := e.s.Events(entry.ID, empDave); err == nil {
+		t.Fatal("无关员工不应可查看事件日志")
+	}
+}
+
+// 流转与通过一样检查本步骤的必填字段
+func TestTransferChecksRequiredFields(t *testing.T) {
+	e := newTestEnv(t)
+	tmpl := models.Template{TemplateName: "报销"}
+	e.must(e.db.Create(&tmpl).Error)
+	e.must(e.db.Create(&models.TemplateForm{Field: "opinion", FieldName: "意见", FieldType: "text", TemplateID: tmpl.ID}).Error)
+	flowID := e.flow("transfer")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("template_id", tmpl.ID).Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A", FieldPerms: types.FieldPerms{"opinion": FieldPermRequired}}, "2", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, -1, "")
+	entry := e.start(flowID, nil)
+
+	procID := e.pending(entry.ID, empBob)
+	if err := e.s.Transfer(procID, empBob, "ok"); err == nil || !strings.Contains(err.Error(), "必填") {
+		t.Fatalf("必填字段未填写时流转应报错，实际 %v", err)
+	}
+	e.expectStatus(entry.ID, models.EntryStatusRunning)
+	e.must(e.s.PassWithData(procID, empBob, "ok", map[string]string{"opinion": "同意"}))
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
@@ -0,0 +1,308 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/http/validator"
+	"github.com/hulutech-web/workflow-engine/core/workflow/expression"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/types"
+	"strconv"
+	"strings"
+)
+
+// 表单字段类型，未列出的类型按文本处理
+const (
+	FieldTypeText     = "text"
+	FieldTypeNumber   = "number"
+	FieldTypeDate     = "date"
+	FieldTypeDatetime = "datetime"
+	FieldTypeSelect   = "select"
+	FieldTypeRadio    = "radio"
+	FieldTypeCheckbox = "checkbox"
+	FieldTypeMoney    = "money"
+	FieldTypeBool     = "bool"
+	FieldTypeList     = "list"
+	FieldTypeEmp      = "emp"
+	FieldTypeDept     = "dept"
+	FieldTypeTable    = "table" // 明细表格，值为 JSON 对象数组，列由 FieldColumns 定义
+)
+
+// 表单字段的特殊校验规则，其余规则名直接作为 validator 规则使用
+const (
+	FieldRuleRequired = "required" // 必填
+	FieldRuleRange    = "range"    // 取值范围，规则值为 最小值,最大值
+	FieldRuleRegex    = "regex"    // 正则，规则值为正则表达式
+)
+
+// formValidator 表单数据校验，复用接口参数校验的翻译与验证插件
+var formValidator = validator.NewService()
+
+// FormError 表单字段校验问题
+type FormError struct {
+	Field     string `json:"field"`            // 字段英文名
+	FieldName string `json:"field_name"`       // 字段中文名
+	Row       int    `json:"row,omitempty"`    // 明细表格的行号，从1开始
+	Column    string `json:"column,omitempty"` // 明细表格的列字段
+	Message   string `json:"message"`          // 问题说明
+}
+
+// FormErrors 表单数据校验结果
+type FormErrors []FormError
+
+func (e FormErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, item := range e {
+		msgs = append(msgs, item.Message)
+	}
+	return "表单校验未通过: " + strings.Join(msgs, "; ")
+}
+
+// ValidateFormData 按流程当前发布版本的表单及第一步的字段权限校验发起的数据，流程未关联表单时不做检查
+func (s *Service) ValidateFormData(flowID uint, data map[string]string) (FormErrors, error) {
+	g, err := s.publishedGraph(flowID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = stepData(g, startFieldPerms(g), nil, data, false)
+	var errs FormErrors
+	if errors.As(err, &errs) {
+		return errs, nil
+	}
+	return nil, err
+}
+
+// checkEntryData 按第一步的字段权限校验发起或重新提交的表单数据，未通过时返回 FormErrors；返回实际写入的数据
+func (s *Service) checkEntryData(t *flowTx, entry *models.Entry, current map[string]string, data map[string]string, replace bool) (map[string]string, error) {
+	g, err := s.entryGraph(t, entry)
+	if err != nil {
+		return nil, err
+	}
+	return stepData(g, startFieldPerms(g), current, data, replace)
+}
+
+// validateEntryData 逐个表单字段检查必填、类型、可选项和自定义规则，data 中表单以外的字段不做检查
+func validateEntryData(g *flowGraph, data map[string]string) FormErrors {
+	forms := g.Template.TemplateForms
+	messages := validateForms(forms, data)
+	var errs FormErrors
+	for _, form := range forms {
+		if msg, ok := messages[form.Field]; ok {
+			errs = append(errs, FormError{Field: form.Field, FieldName: form.FieldName, Message: msg})
+		} else if form.FieldType == FieldTypeTable {
+			errs = append(errs, validateTableRows(form, data[form.Field])...)
+		}
+	}
+	return errs
+}
+
+// validateTableRows 按列定义逐行校验明细表格
+func validateTableRows(form models.TemplateForm, raw string) FormErrors {
+	rows, err := listRows(form.Field, raw)
+	if err != nil {
+		return nil
+	}
+	var errs FormErrors
+	for i, row := range rows {
+		columns := make([]models.TemplateForm, 0, len(form.FieldColumns))
+		for _, column := range form.FieldColumns {
+			f := columnForm(column)
+			f.FieldName = fmt.Sprintf("%s第%d行%s", formLabel(form), i+1, formLabel(f))
+			columns = append(columns, f)
+		}
+		messages := validateForms(columns, row)
+		for _, column := range form.FieldColumns {
+			if msg, ok := messages[column.Field]; ok {
+				errs = append(errs, FormError{Field: form.Field, FieldName: form.FieldName, Row: i + 1, Column: column.Field, Message: msg})
+			}
+		}
+	}
+	return errs
+}
+
+// columnForm 明细表格的列按表单字段校验
+func columnForm(column types.FieldColumn) models.TemplateForm {
+	return models.TemplateForm{
+		Field:      column.Field,
+		FieldName:  column.FieldName,
+		FieldType:  column.FieldType,
+		FieldValue: column.FieldValue,
+		FieldRules: column.FieldRules,
+	}
+}
+
+// validateForms 检查字段的必填、类型、可选项和自定义规则，返回字段名到错误提示的映射
+func validateForms(forms []models.TemplateForm, data map[string]string) map[string]string {
+	messages := make(map[string]string)
+	values := make(map[string]interface{})
+	var rules []validator.FieldRule
+	for _, form := range forms {
+		label := formLabel(form)
+		raw, required := strings.TrimSpace(data[form.Field]), fieldRequired(form)
+		if emptyValue(form, raw) {
+			if required {
+				rules = append(rules, validator.FieldRule{Name: form.Field, Label: label, Tag: FieldRuleRequired})
+			}
+			continue
+		}
+		value, msg := fieldValue(form, raw)
+		if msg != "" {
+			messages[form.Field] = msg
+			continue
+		}
+		values[form.Field] = value
+		rules = append(rules, validator.FieldRule{Name: form.Field, Label: label, Tag: fieldRuleTag(form)})
+	}
+	for field, msg := range formValidator.ValidateFields(values, rules) {
+		messages[field] = msg
+	}
+	return messages
+}
+
+// emptyValue 字段值是否为空，列表及明细表格没有任何项时为空
+func emptyValue(form models.TemplateForm, raw string) bool {
+	if raw == "" {
+		return true
+	}
+	switch valueType(form.FieldType) {
+	case models.EntryValueList:
+		return len(checkboxItems(raw)) == 0
+	case models.EntryValueTable:
+		return raw == "[]"
+	}
+	return false
+}
+
+// fieldValue 按字段类型转换表单值，数字转为 float64 以便比较大小，多选转为选项列表
+func fieldValue(form models.TemplateForm, raw string) (interface{}, string) {
+	label := formLabel(form)
+	switch form.FieldType {
+	case FieldTypeNumber, FieldTypeMoney:
+		f, err := parseNumber(raw)
+		if err != nil {
+			return nil, fmt.Sprintf("%s必须是数字", label)
+		}
+		return f, ""
+	case FieldTypeDate, FieldTypeDatetime:
+		if _, err := expression.ToTime(raw); err != nil {
+			return nil, fmt.Sprintf("%s不是有效的日期", label)
+		}
+	case FieldTypeBool:
+		if _, err := expression.ToBool(raw); err != nil {
+			return nil, fmt.Sprintf("%s必须是布尔值", label)
+		}
+	case FieldTypeEmp, FieldTypeDept:
+		if id, err := strconv.ParseInt(raw, 10, 64); err != nil || id <= 0 {
+			return nil, fmt.Sprintf("%s必须是有效的ID", label)
+		}
+	case FieldTypeList:
+		if !json.Valid([]byte(raw)) || !strings.HasPrefix(raw, "[") {
+			return nil, fmt.Sprintf("%s必须是JSON数组", label)
+		}
+	case FieldTypeTable:
+		var rows []map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &rows); err != nil {
+			return nil, fmt.Sprintf("%s必须是JSON对象数组", label)
+		}
+		// 按行数校验 min、max 等规则
+		items := make([]interface{}, len(rows))
+		for i, row := range rows {
+			items[i] = row
+		}
+		return items, ""
+	case FieldTypeSelect, FieldTypeRadio:
+		if !fieldOption(form, raw) {
+			return nil, fmt.Sprintf("%s的选项[%s]不在可选范围内", label, raw)
+		}
+	case FieldTypeCheckbox:
+		items := checkboxItems(raw)
+		for _, item := range items {
+			if !fieldOption(form, item) {
+				return nil, fmt.Sprintf("%s的选项[%s]不在可选范围内", label, item)
+			}
+		}
+		return items, ""
+	}
+	return raw, ""
+}
+
+// fieldOption 值是否为字段的可选项，未设置可选项时不限制
+func fieldOption(form models.TemplateForm, value string) bool {
+	if len(form.FieldValue) == 0 {
+		return true
+	}
+	for _, option := range form.FieldValue {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkboxItems 多选值，支持 JSON 数组或逗号分隔
+func checkboxItems(raw string) []string {
+	var items []string
+	if strings.HasPrefix(raw, "[") {
+		for _, item := range expression.ToList(raw) {
+			if s := strings.TrimSpace(fmt.Sprint(item)); s != "" {
+				items = append(items, s)
+			}
+		}
+		return items
+	}
+	for _, item := range strings.Split(raw, ",") {
+		if s := strings.TrimSpace(item); s != "" {
+			items = append(items, s)
+		}
+	}
+	return items
+}
+
+// fieldRequired 字段是否必填
+func fieldRequired(form models.TemplateForm) bool {
+	for _, rule := range form.FieldRules {
+		if strings.TrimSpace(rule.RuleName) == FieldRuleRequired {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldRuleTag 将字段的自定义规则转为 validator 规则串，必填已单独处理
+func fieldRuleTag(form models.TemplateForm) string {
+	var tags []string
+	for _, rule := range form.FieldRules {
+		name, value := strings.TrimSpace(rule.RuleName), strings.TrimSpace(rule.RuleValue)
+		switch {
+		case name == "" || name == FieldRuleRequired:
+			continue
+		case name == FieldRuleRange:
+			bounds := strings.SplitN(value, ",", 2)
+			if lower := strings.TrimSpace(bounds[0]); lower != "" {
+				tags = append(tags, "gte="+lower)
+			}
+			if len(bounds) == 2 && strings.TrimSpace(bounds[1]) != "" {
+				tags = append(tags, "lte="+strings.TrimSpace(bounds[1]))
+			}
+		case value == "":
+			tags = append(tags, name)
+		default:
+			tags = append(tags, name+"="+escapeRuleParam(value))
+		}
+	}
+	return strings.Join(tags, ",")
+}
+
+// escapeRuleParam validator 以逗号、竖线分隔规则，参数中出现时需转义
+func escapeRuleParam(param string) string {
+	return strings.NewReplacer(",", "0x2C", "|", "0x7C").Replace(param)
+}
+
+// formLabel 字段在提示中的名称
+func formLabel(form models.TemplateForm) string {
+	if form.FieldName != "" {
+		return form.FieldName
+	}
+	return form.Field
+}
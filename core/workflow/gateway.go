@@ -0,0 +1,213 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"strings"
+)
+
+// 网关类型
+const (
+	GatewayFork = "fork" // 并行拆分：同时进入全部满足条件的分支
+	GatewayJoin = "join" // 并行合并：等待分支完成后继续流转
+)
+
+// 并行分支状态
+const (
+	BranchStatusActive    = 0  // 进行中
+	BranchStatusJoined    = 9  // 已到达合并网关
+	BranchStatusCancelled = -2 // 已取消
+)
+
+// fork 并行拆分：为每条满足条件的流转创建分支，各分支同时进入下一步骤；流转条件为空或为1时总是进入
+func (s *Service) fork(t *flowTx, entry *models.Entry, g *flowGraph, branchID int, process models.Process) error {
+	env := conditionEnv(t.DB, entry)
+	var targets []int
+	for _, l := range g.conditions(process.ID) {
+		if src := strings.TrimSpace(l.Expression); src != "" && src != "1" {
+			ok, err := matchExpression(src, env)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+		}
+		targets = append(targets, l.NextProcessID)
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("并行网关[%s]没有满足条件的分支，无法流转", process.ProcessName)
+	}
+	if err := s.moveTo(t, entry, branchID, int(process.ID)); err != nil {
+		return err
+	}
+	var seq int
+	t.Model(&models.EntryBranch{}).Where("entry_id=?", entry.ID).Select("COALESCE(MAX(fork_seq), 0)").Scan(&seq)
+	branches := make([]models.EntryBranch, 0, len(targets))
+	for _, target := range targets {
+		branches = append(branches, models.EntryBranch{
+			EntryID:       entry.ID,
+			Circle:        entry.Circle,
+			ForkProcessID: int(process.ID),
+			ForkSeq:       seq + 1,
+			ParentID:      branchID,
+			ProcessID:     target,
+			Status:        BranchStatusActive,
+		})
+	}
+	if err := t.Create(&branches).Error; err != nil {
+		return fmt.Errorf("数据库插入错误: %v", err)
+	}
+	// 先创建全部分支再逐个进入，先进入的分支到达合并网关并满足合并条件时其余分支已被取消
+	for _, branch := range branches {
+		var status int
+		t.Model(&models.EntryBranch{}).Where("id=?", branch.ID).Select("status").Scan(&status)
+		if status != BranchStatusActive {
+			continue
+		}
+		if err := s.goToProcess(t, entry, int(branch.ID), branch.ProcessID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// join 并行合并：分支到达后，同一次拆分的分支完成数达到要求时取消其余分支，回到上级分支（或主干）继续流转
+func (s *Service) join(t *flowTx, entry *models.Entry, branchID int, process models.Process) error {
+	if branchID == 0 {
+		// 未经并行拆分到达合并网关，直接通过
+		return s.passJoin(t, entry, 0, process)
+	}
+	// 锁定流程后再统计到达的分支，同时到达的分支依次处理，只有最后满足条件的分支继续流转
+	if _, err := lockEntry(t, entry.ID); err != nil {
+		return err
+	}
+	var branch models.EntryBranch
+	if err := t.Scopes(forUpdate).First(&branch, branchID).Error; err != nil {
+		return errors.New("并行分支不存在")
+	}
+	err := t.Model(&models.EntryBranch{}).Where("id=?", branch.ID).Updates(map[string]interface{}{
+		"status":          BranchStatusJoined,
+		"process_id":      process.ID,
+		"join_process_id": process.ID,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("数据库更新错误: %v", err)
+	}
+	var siblings []models.EntryBranch
+	if err = t.Scopes(forUpdate).Where("entry_id=?", entry.ID).Where("fork_seq=?", branch.ForkSeq).Find(&siblings).Error; err != nil {
+		return fmt.Errorf("数据库查询错误: %v", err)
+	}
+	required := len(siblings)
+	if process.JoinThreshold > 0 && process.JoinThreshold < required {
+		required = process.JoinThreshold
+	}
+	arrived := 0
+	var open []uint
+	for _, b := range siblings {
+		if b.Status == BranchStatusJoined && b.JoinProcessID == int(process.ID) {
+			arrived++
+		}
+		if b.Status == BranchStatusActive {
+			open = append(open, b.ID)
+		}
+	}
+	if arrived < required {
+		return nil
+	}
+	// 已满足合并条件，未完成的分支不再需要处理
+	if len(open) > 0 {
+		if err = s.cancelBranches(t, entry.ID, open, "并行分支已合并，无需处理"); err != nil {
+			return err
+		}
+	}
+	return s.passJoin(t, entry, branch.ParentID, process)
+}
+
+// passJoin 通过合并网关，按流转进入下一步骤
+func (s *Service) passJoin(t *flowTx, entry *models.Entry, branchID int, process models.Process) error {
+	if err := s.moveTo(t, entry, branchID, int(process.ID)); err != nil {
+		return err
+	}
+	flowlink, err := s.nextFlowlink(t, entry, int(process.ID))
+	if err != nil {
+		return err
+	}
+	return s.goToProcess(t, entry, branchID, flowlink.NextProcessID)
+}
+
+// cancelBranches 取消并行分支（含嵌套的下级分支）、分支上未处理的待办及分支上发起的子流程，ids 为空时取消流程全部进行中的分支；content 为待办的取消说明
+func (s *Service) cancelBranches(t *flowTx, entryID uint, ids []uint, content string) error {
+	var active []models.EntryBranch
+	if err := t.Where("entry_id=?", entryID).Where("status=?", BranchStatusActive).Find(&active).Error; err != nil {
+		return fmt.Errorf("数据库查询错误: %v", err)
+	}
+	cancel := make(map[uint]bool)
+	if ids == nil {
+		for _, b := range active {
+			cancel[b.ID] = true
+		}
+	} else {
+		for _, id := range ids {
+			cancel[id] = true
+		}
+		for changed := true; changed; {
+			changed = false
+			for _, b := range active {
+				if !cancel[b.ID] && cancel[uint(b.ParentID)] {
+					cancel[b.ID] = true
+					changed = true
+				}
+			}
+		}
+	}
+	if len(cancel) == 0 {
+		return nil
+	}
+	branchIds := make([]uint, 0, len(cancel))
+	for id := range cancel {
+		branchIds = append(branchIds, id)
+	}
+	err := t.Model(&models.EntryBranch{}).Where("id IN (?)", branchIds).Where("status=?", BranchStatusActive).
+		Update("status", BranchStatusCancelled).Error
+	if err != nil {
+		return fmt.Errorf("数据库更新错误: %v", err)
+	}
+	pending, err := s.transitProcs(t, openProcs([]uint{entryID}, branchIds...), models.ProcStatusCancelled, content, map[string]interface{}{
+		"is_real": false,
+		"content": content,
+	})
+	if err != nil {
+		return err
+	}
+	s.cancelDeadlines(t, pending...)
+	var procIds []uint
+	if err = t.Model(&models.Proc{}).Where("entry_id=?", entryID).Where("branch_id IN (?)", branchIds).Pluck("id", &procIds).Error; err != nil {
+		return fmt.Errorf("数据库查询错误: %v", err)
+	}
+	if len(procIds) == 0 {
+		return nil
+	}
+	var children []models.Entry
+	err = t.Where("pid=?", entryID).Where("enter_proc_id IN (?)", procIds).
+		Where("status IN (?)", []models.EntryStatus{models.EntryStatusRunning, models.EntryStatusSuspended}).Find(&children).Error
+	if err != nil {
+		return fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return s.cancelChildren(t, children, content)
+}
+
+// Branches 流程当前轮次的并行分支，含已合并和已取消的分支
+func (s *Service) Branches(entryID uint) ([]models.EntryBranch, error) {
+	var entry models.Entry
+	if err := s.db.First(&entry, entryID).Error; err != nil {
+		return nil, errors.New("流程不存在")
+	}
+	var branches []models.EntryBranch
+	err := s.db.Where("entry_id=?", entryID).Where("circle=?", entry.Circle).Order("id asc").Find(&branches).Error
+	if err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return branches, nil
+}
@@ -0,0 +1,128 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// 拆分后先进入的分支直接满足合并条件时，其余分支已取消，不应再生成待办
+func TestForkSkipsBranchesCancelledByJoin(t *testing.T) {
+	e := newTestEnv(t)
+	flowID := e.flow("fork")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	fork := e.step(flowID, models.Process{ProcessName: "拆分", GatewayType: GatewayFork}, "", false)
+	join := e.step(flowID, models.Process{ProcessName: "合并", GatewayType: GatewayJoin, JoinThreshold: 1}, "", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "2", false)
+	z := e.step(flowID, models.Process{ProcessName: "Z"}, "3", false)
+	e.link(flowID, start, int(fork), "")
+	e.link(flowID, fork, int(join), "")
+	e.link(flowID, fork, int(b), "")
+	e.link(flowID, b, int(join), "")
+	e.link(flowID, join, int(z), "")
+	e.link(flowID, z, -1, "")
+
+	entry := e.start(flowID, nil)
+	if got := e.entry(entry.ID).ProcessID; got != z {
+		t.Fatalf("流程应流转到步骤Z，实际为%d；待办 %s", got, e.procs(entry.ID))
+	}
+	if id := e.pending(entry.ID, empBob); id != 0 {
+		t.Fatalf("已取消的分支不应生成待办；待办 %s", e.procs(entry.ID))
+	}
+	branches, err := e.s.Branches(entry.ID)
+	e.must(err)
+	for _, branch := range branches {
+		if branch.ProcessID == int(b) && branch.Status != BranchStatusCancelled {
+			t.Fatalf("分支B状态为%d，期望已取消", branch.Status)
+		}
+	}
+	e.must(e.s.Pass(e.pending(entry.ID, empCarol), empCarol, "ok"))
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
+
+// 合并条件满足后其余分支取消，分支中发起的子流程一并终止
+func TestJoinTerminatesChildEntriesOfCancelledBranches(t *testing.T) {
+	e := newTestEnv(t)
+	childFlowID := e.flow("child")
+	childStart := e.step(childFlowID, models.Process{ProcessName: "子流程发起"}, "", true)
+	childStep := e.step(childFlowID, models.Process{ProcessName: "子流程审批"}, "4", false)
+	e.link(childFlowID, childStart, int(childStep), "")
+	e.link(childFlowID, childStep, -1, "")
+
+	flowID := e.flow("fork")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	fork := e.step(flowID, models.Process{ProcessName: "拆分", GatewayType: GatewayFork}, "", false)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	c := e.step(flowID, models.Process{ProcessName: "C", Position: 2, ChildFlowID: int(childFlowID)}, "3", false)
+	join := e.step(flowID, models.Process{ProcessName: "合并", GatewayType: GatewayJoin, JoinThreshold: 1}, "", false)
+	e.link(flowID, start, int(fork), "")
+	e.link(flowID, fork, int(a), "")
+	e.link(flowID, fork, int(c), "")
+	e.link(flowID, a, int(join), "")
+	e.link(flowID, c, int(join), "")
+	e.link(flowID, join, -1, "")
+
+	entry := e.start(flowID, nil)
+	e.must(e.s.Pass(e.pending(entry.ID, empCarol), empCarol, "ok"))
+	var child models.Entry
+	e.must(e.db.Where("pid=?", entry.ID).First(&child).Error)
+	e.expectStatus(child.ID, models.EntryStatusRunning)
+
+	e.must(e.s.Pass(e.pending(entry.ID, empBob), empBob, "ok"))
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+	e.expectStatus(child.ID, models.EntryStatusTerminated)
+	if id := e.pending(child.ID, empDave); id != 0 {
+		t.Fatalf("已终止的子流程不应保留待办；待办 %s", e.procs(child.ID))
+	}
+}
+
+// 未设置合并数量时等待全部分支到达
+func TestJoinWaitsForAllBranches(t *testing.T) {
+	e := newTestEnv(t)
+	flowID := e.flow("fork")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	fork := e.step(flowID, models.Process{ProcessName: "拆分", GatewayType: GatewayFork}, "", false)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "3", false)
+	join := e.step(flowID, models.Process{ProcessName: "合并", GatewayType: GatewayJoin}, "", false)
+	e.link(flowID, start, int(fork), "")
+	e.link(flowID, fork, int(a), "")
+	e.link(flowID, fork, int(b), "")
+	e.link(flowID, a, int(join), "")
+	e.link(flowID, b, int(join), "")
+	e.link(flowID, join, -1, "")
+
+	entry := e.start(flowID, nil)
+	e.must(e.s.Pass(e.pending(entry.ID, empBob), empBob, "ok"))
+	e.expectStatus(entry.ID, models.EntryStatusRunning)
+	e.must(e.s.Pass(e.pending(entry.ID, empCarol), empCarol, "ok"))
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
+
+// 两个分支的审批人同时通过时合并网关只通过一次
+func TestJoinConcurrentArrivals(t *testing.T) {
+	e := newTestEnv(t)
+	flowID := e.flow("fork")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	fork := e.step(flowID, models.Process{ProcessName: "拆分", GatewayType: GatewayFork}, "", false)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "3", false)
+	join := e.step(flowID, models.Process{ProcessName: "合并", GatewayType: GatewayJoin}, "", false)
+	z := e.step(flowID, models.Process{ProcessName: "Z"}, "4", false)
+	e.link(flowID, start, int(fork), "")
+	e.link(flowID, fork, int(a), "")
+	e.link(flowID, fork, int(b), "")
+	e.link(flowID, a, int(join), "")
+	e.link(flowID, b, int(join), "")
+	e.link(flowID, join, int(z), "")
+	e.link(flowID, z, -1, "")
+
+	entry := e.start(flowID, nil)
+	procs := map[uint]uint{empBob: e.pending(entry.ID, empBob), empCarol: e.pending(entry.ID, empCarol)}
+	e.passConcurrently(procs)
+	var n int64
+	e.db.Model(&models.Proc{}).Where("entry_id=?", entry.ID).Where("process_id=?", z).Count(&n)
+	if n != 1 {
+		t.Fatalf("合并后应只生成一个步骤Z的待办，实际 %d 个；待办 %s", n, e.procs(entry.ID))
+	}
+}
@@ -0,0 +1,176 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow/official_plugin"
+	"gorm.io/gorm"
+	"sort"
+)
+
+// flowGraph 流程定义：步骤、流转、表单模板、插件配置及抄送设置，发布时整体保存为版本快照
+type flowGraph struct {
+	Flow          models.Flow                    `json:"flow"`
+	Processes     []models.Process               `json:"processes"`
+	Flowlinks     []models.Flowlink              `json:"flowlinks"`
+	Template      models.Template                `json:"template"`
+	PluginConfigs []official_plugin.PluginConfig `json:"plugin_configs"`
+	CarbonCopies  []models.CarbonCopy            `json:"carbon_copies"`
+}
+
+// loadGraph 读取流程当前（编辑中）的定义
+func loadGraph(db *gorm.DB, flowID uint) (*flowGraph, error) {
+	var g flowGraph
+	if err := db.First(&g.Flow, flowID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("流程不存在")
+		}
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	if err := db.Where("flow_id=?", flowID).Order("id asc").Find(&g.Processes).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	if err := db.Where("flow_id=?", flowID).Order("sort asc, id asc").Find(&g.Flowlinks).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	if g.Flow.TemplateID > 0 {
+		err := db.Preload("TemplateForms", func(db *gorm.DB) *gorm.DB {
+			return db.Order("sort asc, id asc")
+		}).Limit(1).Find(&g.Template, g.Flow.TemplateID).Error
+		if err != nil {
+			return nil, fmt.Errorf("数据库查询错误: %v", err)
+		}
+	}
+	if err := db.Where("flow_id=?", flowID).Order("id asc").Find(&g.PluginConfigs).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	if err := db.Where("flow_id=?", flowID).Order("id asc").Find(&g.CarbonCopies).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return &g, nil
+}
+
+// versionGraph 读取发布版本的定义快照，版本不可修改，读取后缓存
+func (s *Service) versionGraph(db *gorm.DB, versionID uint) (*flowGraph, error) {
+	if g, ok := s.versions.Load(versionID); ok {
+		return g.(*flowGraph), nil
+	}
+	var version models.FlowVersion
+	if err := db.First(&version, versionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("流程版本不存在")
+		}
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	var g flowGraph
+	if err := json.Unmarshal([]byte(version.Snapshot), &g); err != nil {
+		return nil, fmt.Errorf("流程版本快照解析错误: %v", err)
+	}
+	s.versions.Store(versionID, &g)
+	return &g, nil
+}
+
+// entryGraph 流程实例的定义：按发起时的版本流转，版本功能上线前发起的实例读取当前定义
+func (s *Service) entryGraph(t *flowTx, entry *models.Entry) (*flowGraph, error) {
+	if entry.FlowVersionID > 0 {
+		return s.versionGraph(t.DB, entry.FlowVersionID)
+	}
+	if g, ok := t.graphs[entry.FlowID]; ok {
+		return g, nil
+	}
+	g, err := loadGraph(t.DB, entry.FlowID)
+	if err != nil {
+		return nil, err
+	}
+	if t.graphs == nil {
+		t.graphs = make(map[uint]*flowGraph)
+	}
+	t.graphs[entry.FlowID] = g
+	return g, nil
+}
+
+// process 按ID查找步骤
+func (g *flowGraph) process(id int) (models.Process, bool) {
+	for _, p := range g.Processes {
+		if int(p.ID) == id {
+			return p, true
+		}
+	}
+	return models.Process{}, false
+}
+
+// starts 第一步（Position=0）步骤
+func (g *flowGraph) starts() []models.Process {
+	var starts []models.Process
+	for _, p := range g.Processes {
+		if p.Position == 0 {
+			starts = append(starts, p)
+		}
+	}
+	return starts
+}
+
+// conditions 步骤的流转，按判断顺序排列
+func (g *flowGraph) conditions(processID uint) []models.Flowlink {
+	var links []models.Flowlink
+	for _, l := range g.Flowlinks {
+		if l.ProcessID == processID && l.Type == "Condition" {
+			links = append(links, l)
+		}
+	}
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].Sort < links[j].Sort
+	})
+	return links
+}
+
+// auditorLinks 步骤的审批人设置
+func (g *flowGraph) auditorLinks(processID uint) []models.Flowlink {
+	var links []models.Flowlink
+	for _, l := range g.Flowlinks {
+		if l.ProcessID == processID && l.Type != "Condition" {
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// pluginConfigs 步骤的插件配置
+func (g *flowGraph) pluginConfigs(processID uint) []official_plugin.PluginConfig {
+	configs := []official_plugin.PluginConfig{}
+	for _, c := range g.PluginConfigs {
+		if c.ProcessID == processID {
+			configs = append(configs, c)
+		}
+	}
+	return configs
+}
+
+// carbonCopies 步骤通过时的抄送设置，processID 为0时为流程结束时的抄送设置
+func (g *flowGraph) carbonCopies(processID uint) []models.CarbonCopy {
+	var ccs []models.CarbonCopy
+	for _, c := range g.CarbonCopies {
+		if c.ProcessID == processID {
+			ccs = append(ccs, c)
+		}
+	}
+	return ccs
+}
+
+// successors 步骤之后可能进入的步骤，-1 表示流程结束
+func (g *flowGraph) successors(p models.Process) []int {
+	var next []int
+	for _, l := range g.conditions(p.ID) {
+		next = append(next, l.NextProcessID)
+	}
+	if p.ChildFlowID > 0 {
+		if p.ChildAfter == 1 {
+			next = append(next, -1)
+		} else if p.ChildBackProcess > 0 {
+			next = append(next, p.ChildBackProcess)
+		}
+	}
+	return next
+}
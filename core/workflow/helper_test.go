@@ -0,0 +1,181 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow/official_plugin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 测试数据中的员工，均属于部门1（主管 bob，经理 carol）
+const (
+	empAlice uint = iota + 1
+	empBob
+	empCarol
+	empDave
+)
+
+// testEnv 基于内存 sqlite 的引擎测试环境
+type testEnv struct {
+	t  *testing.T
+	db *gorm.DB
+	s  *Service
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+		DisableForeignKeyConstraintWhenMigrating: true,
+		SkipDefaultTransaction:                   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内存库每个连接独立，限制为单连接
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	err = db.AutoMigrate(&models.CarbonCopy{}, &models.CarbonCopyRecord{}, &models.Dept{}, &models.Delegation{}, &models.Emp{},
+		&models.Entry{}, &models.EntryBranch{}, &models.EntryEvent{}, &models.EntryData{}, &models.EntryDataCell{},
+		&models.Flow{}, &models.Flowlink{}, &models.FlowVersion{}, &models.Flowtype{}, &models.Template{}, &models.Proc{},
+		&models.Process{}, &models.ProcessVar{}, &models.StateTransition{}, &models.TemplateForm{},
+		&official_plugin.Plugin{}, &official_plugin.FlowPlugin{}, &official_plugin.PluginConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &testEnv{t: t, db: db, s: NewService(db, nil)}
+	e.must(db.Create(&models.Dept{DeptName: "研发", DirectorID: int(empBob), ManagerID: int(empCarol)}).Error)
+	for i, name := range []string{"alice", "bob", "carol", "dave"} {
+		e.must(db.Create(&models.Emp{Name: name, Email: name, WorkNo: fmt.Sprint(i + 1), DeptID: 1}).Error)
+	}
+	return e
+}
+
+func (e *testEnv) must(err error) {
+	e.t.Helper()
+	if err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+// flow 创建已发布的流程，未关联表单
+func (e *testEnv) flow(name string) uint {
+	e.t.Helper()
+	flow := models.Flow{FlowNo: name, FlowName: name, IsPublish: true}
+	e.must(e.db.Create(&flow).Error)
+	return flow.ID
+}
+
+// step 创建步骤，auditors 为逗号分隔的员工id，为空时不设置审批人；first 为第一步
+func (e *testEnv) step(flowID uint, p models.Process, auditors string, first bool) uint {
+	e.t.Helper()
+	p.FlowID = int(flowID)
+	if p.ProcessName == "" {
+		p.ProcessName = fmt.Sprintf("步骤%d", p.ID)
+	}
+	e.must(e.db.Create(&p).Error)
+	if first {
+		e.must(e.db.Model(&models.Process{}).Where("id=?", p.ID).Update("position", 0).Error)
+	}
+	if auditors != "" {
+		e.must(e.db.Create(&models.Flowlink{FlowID: flowID, Type: "Emp", ProcessID: p.ID, Auditor: auditors}).Error)
+	}
+	return p.ID
+}
+
+// link 添加流转，to 为 -1 时流向结束，expression 为空时总是流转
+func (e *testEnv) link(flowID uint, from uint, to int, expression string) {
+	e.t.Helper()
+	if expression == "" {
+		expression = "1"
+	}
+	var n int64
+	e.db.Model(&models.Flowlink{}).Where("process_id=?", from).Where("type=?", "Condition").Count(&n)
+	e.must(e.db.Create(&models.Flowlink{FlowID: flowID, Type: "Condition", ProcessID: from, NextProcessID: to, Expression: expression, Sort: int(n) + 1}).Error)
+}
+
+func (e *testEnv) start(flowID uint, data map[string]string) *models.Entry {
+	e.t.Helper()
+	entry, err := e.s.Start(flowID, empAlice, "测试", data)
+	e.must(err)
+	return entry
+}
+
+func (e *testEnv) entry(id uint) models.Entry {
+	e.t.Helper()
+	var entry models.Entry
+	e.must(e.db.First(&entry, id).Error)
+	return entry
+}
+
+// pending 员工在流程中的待办，没有时返回 0
+func (e *testEnv) pending(entryID uint, empID uint) uint {
+	var proc models.Proc
+	e.db.Where("entry_id=?", entryID).Where("emp_id=?", empID).Where("status=?", models.ProcStatusPending).Order("id desc").Limit(1).Find(&proc)
+	return proc.ID
+}
+
+// pass 员工通过其在流程中的待办
+func (e *testEnv) pass(entryID uint, empID uint) {
+	e.t.Helper()
+	procID := e.pending(entryID, empID)
+	if procID == 0 {
+		e.t.Fatalf("员工%d没有待办；待办 %s", empID, e.procs(entryID))
+	}
+	e.must(e.s.Pass(procID, empID, "ok"))
+}
+
+// passConcurrently 多名员工同时通过各自的待办，procs 为员工id到待办id
+func (e *testEnv) passConcurrently(procs map[uint]uint) {
+	e.t.Helper()
+	var wg sync.WaitGroup
+	errs := make(chan error, len(procs))
+	for empID, procID := range procs {
+		wg.Add(1)
+		go func(empID, procID uint) {
+			defer wg.Done()
+			errs <- e.s.Pass(procID, empID, "ok")
+		}(empID, procID)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		e.must(err)
+	}
+}
+
+// procStatus 待办当前的状态
+func (e *testEnv) procStatus(procID uint) models.ProcStatus {
+	e.t.Helper()
+	var proc models.Proc
+	e.must(e.db.First(&proc, procID).Error)
+	return proc.Status
+}
+
+// procs 流程全部待办的摘要：步骤id/员工id/状态，按创建顺序排列
+func (e *testEnv) procs(entryID uint) string {
+	var procs []models.Proc
+	e.db.Where("entry_id=?", entryID).Order("id asc").Find(&procs)
+	items := make([]string, 0, len(procs))
+	for _, p := range procs {
+		items = append(items, fmt.Sprintf("%d/%d/%d", p.ProcessID, p.EmpID, p.Status))
+	}
+	return strings.Join(items, " ")
+}
+
+func (e *testEnv) expectStatus(entryID uint, want models.EntryStatus) {
+	e.t.Helper()
+	if got := e.entry(entryID).Status; got != want {
+		e.t.Fatalf("流程%d状态为%s，期望%s；待办 %s", entryID, got, want, e.procs(entryID))
+	}
+}
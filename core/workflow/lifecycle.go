@@ -0,0 +1,150 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"gorm.io/gorm"
+)
+
+// rootEntry 查找可整体管理的流程，子流程随父流程挂起、恢复或终止
+func rootEntry(t *flowTx, entryID uint) (models.Entry, error) {
+	var entry models.Entry
+	if err := t.First(&entry, entryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entry, errors.New("流程不存在")
+		}
+		return entry, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	if entry.Pid > 0 {
+		return entry, errors.New("子流程不能单独操作，请操作父流程")
+	}
+	return entry, nil
+}
+
+// Suspend 挂起审批中的流程（含进行中的子流程）：待办暂停处理，超时任务随之取消
+func (s *Service) Suspend(entryID uint, reason string) error {
+	return s.transaction(func(t *flowTx) error {
+		entry, err := rootEntry(t, entryID)
+		if err != nil {
+			return err
+		}
+		entries, err := runningEntries(t, entry)
+		if err != nil {
+			return err
+		}
+		if reason == "" {
+			reason = "流程挂起"
+		}
+		if err = s.logEvent(t, &entry, models.EntryEvent{Action: EventSuspend, Content: reason}); err != nil {
+			return err
+		}
+		for i := range entries {
+			if err = s.transitEntry(t, &entries[i], models.EntryStatusSuspended, reason, nil); err != nil {
+				return err
+			}
+		}
+		var procs []models.Proc
+		if err = t.Scopes(openProcs(entryIds(entries))).Find(&procs).Error; err != nil {
+			return fmt.Errorf("数据库查询错误: %v", err)
+		}
+		s.cancelDeadlines(t, procs...)
+		return nil
+	})
+}
+
+// Resume 恢复已挂起的流程，未处理待办的处理期限自恢复时起重新计算
+func (s *Service) Resume(entryID uint) error {
+	return s.transaction(func(t *flowTx) error {
+		entry, err := rootEntry(t, entryID)
+		if err != nil {
+			return err
+		}
+		entries, err := runningEntries(t, entry)
+		if err != nil {
+			return err
+		}
+		if err = s.logEvent(t, &entry, models.EntryEvent{Action: EventResume}); err != nil {
+			return err
+		}
+		for i := range entries {
+			if err = s.transitEntry(t, &entries[i], models.EntryStatusRunning, "流程恢复", nil); err != nil {
+				return err
+			}
+		}
+		for _, e := range entries {
+			g, err := s.entryGraph(t, &e)
+			if err != nil {
+				return err
+			}
+			var procs []models.Proc
+			err = t.Where("entry_id=?", e.ID).Where("status=?", models.ProcStatusPending).Where("deadline > ?", 0).Find(&procs).Error
+			if err != nil {
+				return fmt.Errorf("数据库查询错误: %v", err)
+			}
+			for _, proc := range procs {
+				process, ok := g.process(proc.ProcessID)
+				if !ok {
+					continue
+				}
+				err = t.Model(&models.Proc{}).Where("id=?", proc.ID).Update("deadline", procDeadline(process.LimitTime)).Error
+				if err != nil {
+					return fmt.Errorf("数据库更新错误: %v", err)
+				}
+				s.enqueueDeadline(t, proc.ID, process.LimitTime)
+			}
+		}
+		return nil
+	})
+}
+
+// Terminate 终止流程：取消全部待办及并行分支，进行中的子流程随之终止，通知发起人
+func (s *Service) Terminate(entryID uint, reason string) error {
+	return s.transaction(func(t *flowTx) error {
+		entry, err := rootEntry(t, entryID)
+		if err != nil {
+			return err
+		}
+		entries, err := runningEntries(t, entry)
+		if err != nil {
+			return err
+		}
+		if reason == "" {
+			reason = "流程已终止"
+		}
+		if err = s.logEvent(t, &entry, models.EntryEvent{Action: EventTerminate, Content: reason}); err != nil {
+			return err
+		}
+		ids := entryIds(entries)
+		procs, err := s.transitProcs(t, openProcs(ids), models.ProcStatusCancelled, reason, map[string]interface{}{
+			"is_real": false,
+			"content": reason,
+		})
+		if err != nil {
+			return err
+		}
+		s.cancelDeadlines(t, procs...)
+		err = t.Model(&models.EntryBranch{}).Where("entry_id IN (?)", ids).Where("status=?", BranchStatusActive).
+			Update("status", BranchStatusCancelled).Error
+		if err != nil {
+			return fmt.Errorf("数据库更新错误: %v", err)
+		}
+		if err = s.transitEntries(t, ids, models.EntryStatusTerminated, reason); err != nil {
+			return err
+		}
+		initiator := entry.EmpID
+		t.afterCommit(func() {
+			_ = s.wf.NotifySendOne(initiator)
+		})
+		return nil
+	})
+}
+
+// entryIds 流程id列表
+func entryIds(entries []models.Entry) []uint {
+	ids := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
@@ -0,0 +1,299 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"strings"
+)
+
+// 多实例子流程执行方式
+const (
+	ChildModeParallel   = "parallel"   // 同时发起全部子流程
+	ChildModeSequential = "sequential" // 上一个子流程结束后再发起下一个
+)
+
+// ChildResult 多实例子流程汇总到父流程的单行结果
+type ChildResult struct {
+	Index   int                `json:"index"`    // 列表行序号
+	EntryID uint               `json:"entry_id"` // 子流程id，未发起时为0
+	Status  models.EntryStatus `json:"status"`   // 9通过 -1驳回 -4已终止或未发起
+	Data    map[string]string  `json:"data"`     // 子流程中与父流程不同的表单数据
+}
+
+// childMode 多实例子流程的执行方式，未设置时为同时发起
+func childMode(process models.Process) string {
+	if process.ChildMode == "" {
+		return ChildModeParallel
+	}
+	return process.ChildMode
+}
+
+// childResultField 多实例子流程结果汇总到父流程的字段
+func childResultField(process models.Process) string {
+	if process.ChildResultField != "" {
+		return process.ChildResultField
+	}
+	return process.ChildListField + "_result"
+}
+
+// childQuorum 多实例子流程需通过的数量
+func childQuorum(process models.Process, total int) int {
+	if process.ChildQuorum > 0 && process.ChildQuorum < total {
+		return process.ChildQuorum
+	}
+	return total
+}
+
+// entryDataMap 流程的表单数据
+func entryDataMap(t *flowTx, entryID uint) map[string]string {
+	var entryDatas []models.EntryData
+	t.Where("entry_id=?", entryID).Find(&entryDatas)
+	data := make(map[string]string, len(entryDatas))
+	for _, entryData := range entryDatas {
+		data[entryData.FieldName] = entryData.FieldValue
+	}
+	return data
+}
+
+// listRows 解析列表字段，字段值为JSON数组；对象行的各属性作为子流程的表单字段，其他类型的行保存在 item 字段
+func listRows(field string, value string) ([]map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(value))
+	dec.UseNumber()
+	var items []interface{}
+	if err := dec.Decode(&items); err != nil {
+		return nil, fmt.Errorf("列表字段[%s]不是有效的JSON数组", field)
+	}
+	rows := make([]map[string]string, 0, len(items))
+	for _, item := range items {
+		row := make(map[string]string)
+		if obj, ok := item.(map[string]interface{}); ok {
+			for k, v := range obj {
+				row[k] = jsonString(v)
+			}
+		} else {
+			row["item"] = jsonString(item)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// jsonString JSON值转为表单字段的字符串值
+func jsonString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// startChildren 按列表字段的每一行发起多实例子流程，列表为空时直接按子流程设置继续父流程
+func (s *Service) startChildren(t *flowTx, entry *models.Entry, proc models.Proc, process models.Process, childFlow models.Flow) error {
+	rows, err := listRows(process.ChildListField, entryDataMap(t, entry.ID)[process.ChildListField])
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		if err = s.saveChildResults(t, entry, process, nil, rows); err != nil {
+			return err
+		}
+		return s.leaveChildStep(t, entry, process, int(proc.ID))
+	}
+	if childMode(process) == ChildModeSequential {
+		rows = rows[:1]
+	}
+	// 先创建全部子流程再逐个进入第一步，子流程进入后即满足通过数量时其余子流程已被取消
+	children := make([]models.Entry, 0, len(rows))
+	for i, row := range rows {
+		child, err := s.createChild(t, entry, proc, process, childFlow, i, row)
+		if err != nil {
+			return err
+		}
+		children = append(children, child)
+	}
+	for _, child := range children {
+		var status models.EntryStatus
+		t.Model(&models.Entry{}).Where("id=?", child.ID).Select("status").Scan(&status)
+		if status != models.EntryStatusRunning {
+			continue
+		}
+		if err = s.startEntry(t, &child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// childBatch 同一次进入子流程步骤发起的全部子流程，按行序号排列
+func (s *Service) childBatch(t *flowTx, parent *models.Entry, enterProcID int) ([]models.Entry, error) {
+	var children []models.Entry
+	err := t.Scopes(forUpdate).Where("pid=?", parent.ID).Where("enter_proc_id=?", enterProcID).Order("child_seq asc").Find(&children).Error
+	if err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return children, nil
+}
+
+// settleChildren 多实例子流程通过后汇总：通过数量满足要求时取消其余子流程、汇总结果，返回父流程是否可以继续；
+// 逐个发起时为下一行发起子流程
+func (s *Service) settleChildren(t *flowTx, parent *models.Entry, process models.Process, enterProcID int) (bool, error) {
+	children, rows, err := s.childState(t, parent, process, enterProcID)
+	if err != nil {
+		return false, err
+	}
+	passed := 0
+	for _, c := range children {
+		if c.Status == models.EntryStatusCompleted {
+			passed++
+		}
+	}
+	if passed < childQuorum(process, len(rows)) {
+		return false, s.startNextChild(t, parent, process, enterProcID, children, rows)
+	}
+	return true, s.closeChildren(t, parent, process, children, rows, "子流程已满足通过数量，无需处理")
+}
+
+// childrenHold 多实例子流程驳回后，剩余子流程仍能满足通过数量时父流程继续等待；否则取消其余子流程并汇总结果
+func (s *Service) childrenHold(t *flowTx, parent *models.Entry, process models.Process, enterProcID int) (bool, error) {
+	children, rows, err := s.childState(t, parent, process, enterProcID)
+	if err != nil {
+		return false, err
+	}
+	rejected := 0
+	for _, c := range children {
+		if c.Status == models.EntryStatusRejected {
+			rejected++
+		}
+	}
+	if len(rows)-rejected >= childQuorum(process, len(rows)) {
+		return true, s.startNextChild(t, parent, process, enterProcID, children, rows)
+	}
+	return false, s.closeChildren(t, parent, process, children, rows, "子流程已无法满足通过数量，无需处理")
+}
+
+// childState 多实例子流程及列表字段的行；先锁定父流程，同时结束的子流程依次汇总
+func (s *Service) childState(t *flowTx, parent *models.Entry, process models.Process, enterProcID int) ([]models.Entry, []map[string]string, error) {
+	if _, err := lockEntry(t, parent.ID); err != nil {
+		return nil, nil, err
+	}
+	children, err := s.childBatch(t, parent, enterProcID)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := listRows(process.ChildListField, entryDataMap(t, parent.ID)[process.ChildListField])
+	if err != nil {
+		return nil, nil, err
+	}
+	return children, rows, nil
+}
+
+// startNextChild 逐个发起时，没有进行中的子流程则为下一行发起子流程
+func (s *Service) startNextChild(t *flowTx, parent *models.Entry, process models.Process, enterProcID int, children []models.Entry, rows []map[string]string) error {
+	if childMode(process) != ChildModeSequential || len(children) >= len(rows) {
+		return nil
+	}
+	for _, c := range children {
+		if c.Status == models.EntryStatusRunning {
+			return nil
+		}
+	}
+	var childFlow models.Flow
+	if err := t.First(&childFlow, process.ChildFlowID).Error; err != nil {
+		return errors.New("子流程不存在")
+	}
+	var enterProc models.Proc
+	if err := t.First(&enterProc, enterProcID).Error; err != nil {
+		return errors.New("子流程进入待办不存在")
+	}
+	seq := len(children)
+	child, err := s.createChild(t, parent, enterProc, process, childFlow, seq, rows[seq])
+	if err != nil {
+		return err
+	}
+	return s.startEntry(t, &child)
+}
+
+// closeChildren 汇总多实例子流程结果到父流程，并取消仍在进行中的子流程
+func (s *Service) closeChildren(t *flowTx, parent *models.Entry, process models.Process, children []models.Entry, rows []map[string]string, content string) error {
+	if err := s.saveChildResults(t, parent, process, children, rows); err != nil {
+		return err
+	}
+	var running []models.Entry
+	for _, c := range children {
+		if c.Status == models.EntryStatusRunning {
+			running = append(running, c)
+		}
+	}
+	return s.cancelChildren(t, running, content)
+}
+
+// saveChildResults 将各行子流程的结果以JSON数组保存到父流程的汇总字段，进行中及未发起的行记为已终止
+func (s *Service) saveChildResults(t *flowTx, parent *models.Entry, process models.Process, children []models.Entry, rows []map[string]string) error {
+	parentData := entryDataMap(t, parent.ID)
+	bySeq := make(map[int]models.Entry, len(children))
+	for _, c := range children {
+		bySeq[c.ChildSeq] = c
+	}
+	results := make([]ChildResult, 0, len(rows))
+	for i := range rows {
+		result := ChildResult{Index: i, Status: models.EntryStatusTerminated, Data: map[string]string{}}
+		if c, ok := bySeq[i]; ok {
+			result.EntryID = c.ID
+			if c.Status != models.EntryStatusRunning {
+				result.Status = c.Status
+			}
+			for field, value := range entryDataMap(t, c.ID) {
+				if old, ok := parentData[field]; !ok || old != value {
+					result.Data[field] = value
+				}
+			}
+		}
+		results = append(results, result)
+	}
+	value, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	return s.saveEntryData(t, *parent, map[string]string{childResultField(process): string(value)})
+}
+
+// cancelChildren 终止子流程（含其下级子流程），取消未处理的待办
+func (s *Service) cancelChildren(t *flowTx, children []models.Entry, content string) error {
+	var ids []uint
+	for _, c := range children {
+		entries, err := runningEntries(t, c)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	procs, err := s.transitProcs(t, openProcs(ids), models.ProcStatusCancelled, content, map[string]interface{}{
+		"is_real": false,
+		"content": content,
+	})
+	if err != nil {
+		return err
+	}
+	s.cancelDeadlines(t, procs...)
+	err = t.Model(&models.EntryBranch{}).Where("entry_id IN (?)", ids).Where("status=?", BranchStatusActive).
+		Update("status", BranchStatusCancelled).Error
+	if err != nil {
+		return fmt.Errorf("数据库更新错误: %v", err)
+	}
+	return s.transitEntries(t, ids, models.EntryStatusTerminated, content)
+}
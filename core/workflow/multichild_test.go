@@ -0,0 +1,137 @@
+package workflow
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// multiChildFlow 发起 → M(bob，按 items 每行发起子流程) → Z(carol) → 结束；子流程为 发起 → X(dave) → 结束
+func multiChildFlow(e *testEnv, process models.Process) (*models.Entry, uint) {
+	childFlowID := e.flow("child")
+	childStart := e.step(childFlowID, models.Process{ProcessName: "子流程发起"}, "", true)
+	x := e.step(childFlowID, models.Process{ProcessName: "X"}, "4", false)
+	e.link(childFlowID, childStart, int(x), "")
+	e.link(childFlowID, x, -1, "")
+
+	flowID := e.flow("multichild")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	process.ProcessName = "M"
+	process.ChildFlowID = int(childFlowID)
+	process.ChildListField = "items"
+	m := e.step(flowID, process, "2", false)
+	z := e.step(flowID, models.Process{ProcessName: "Z"}, "3", false)
+	e.link(flowID, start, int(m), "")
+	e.link(flowID, m, int(z), "")
+	e.link(flowID, z, -1, "")
+	entry := e.start(flowID, map[string]string{"items": `[{"name":"a"},{"name":"b"},{"name":"c"}]`})
+	e.pass(entry.ID, empBob)
+	return entry, z
+}
+
+func (e *testEnv) children(parentID uint) []models.Entry {
+	e.t.Helper()
+	var children []models.Entry
+	e.must(e.db.Where("pid=?", parentID).Order("child_seq asc").Find(&children).Error)
+	return children
+}
+
+// childResults 父流程汇总字段中各行子流程的状态
+func (e *testEnv) childResults(parentID uint) []models.EntryStatus {
+	e.t.Helper()
+	var results []ChildResult
+	e.must(json.Unmarshal([]byte(entryDataMap(&flowTx{DB: e.db}, parentID)["items_result"]), &results))
+	statuses := make([]models.EntryStatus, 0, len(results))
+	for _, r := range results {
+		statuses = append(statuses, r.Status)
+	}
+	return statuses
+}
+
+func expectStatuses(t *testing.T, got []models.EntryStatus, want ...models.EntryStatus) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("状态为 %v，期望 %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("状态为 %v，期望 %v", got, want)
+		}
+	}
+}
+
+// 通过数量满足要求后其余子流程终止，父流程继续
+func TestMultiChildQuorumPassed(t *testing.T) {
+	e := newTestEnv(t)
+	entry, z := multiChildFlow(e, models.Process{ChildQuorum: 2})
+	children := e.children(entry.ID)
+	if len(children) != 3 {
+		t.Fatalf("应按列表的每一行发起子流程，实际 %d 个", len(children))
+	}
+	e.pass(children[0].ID, empDave)
+	if got := e.entry(entry.ID).ProcessID; got == z {
+		t.Fatal("通过数量不足时父流程应继续等待")
+	}
+	e.pass(children[1].ID, empDave)
+	e.expectStatus(children[2].ID, models.EntryStatusTerminated)
+	if got := e.entry(entry.ID).ProcessID; got != z {
+		t.Fatalf("父流程应进入步骤Z(%d)，实际为%d", z, got)
+	}
+	expectStatuses(t, e.childResults(entry.ID), models.EntryStatusCompleted, models.EntryStatusCompleted, models.EntryStatusTerminated)
+	e.pass(entry.ID, empCarol)
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
+
+// 剩余子流程无法满足通过数量时父流程驳回
+func TestMultiChildQuorumUnreachable(t *testing.T) {
+	e := newTestEnv(t)
+	entry, _ := multiChildFlow(e, models.Process{ChildQuorum: 2})
+	children := e.children(entry.ID)
+	e.must(e.s.Reject(e.pending(children[0].ID, empDave), empDave, "no"))
+	e.expectStatus(entry.ID, models.EntryStatusRunning)
+	e.must(e.s.Reject(e.pending(children[1].ID, empDave), empDave, "no"))
+	e.expectStatus(entry.ID, models.EntryStatusRejected)
+	e.expectStatus(children[2].ID, models.EntryStatusTerminated)
+	expectStatuses(t, e.childResults(entry.ID), models.EntryStatusRejected, models.EntryStatusRejected, models.EntryStatusTerminated)
+}
+
+// 逐个发起时上一个子流程结束后再发起下一个
+func TestMultiChildSequential(t *testing.T) {
+	e := newTestEnv(t)
+	entry, z := multiChildFlow(e, models.Process{ChildMode: ChildModeSequential})
+	for i := 1; i <= 3; i++ {
+		children := e.children(entry.ID)
+		if len(children) != i {
+			t.Fatalf("第%d个子流程处理前应只发起%d个子流程，实际 %d 个", i, i, len(children))
+		}
+		e.pass(children[i-1].ID, empDave)
+	}
+	if got := e.entry(entry.ID).ProcessID; got != z {
+		t.Fatalf("父流程应进入步骤Z(%d)，实际为%d", z, got)
+	}
+	expectStatuses(t, e.childResults(entry.ID), models.EntryStatusCompleted, models.EntryStatusCompleted, models.EntryStatusCompleted)
+}
+
+// 多个子流程同时通过时父流程只继续一次
+func TestMultiChildConcurrentCompletion(t *testing.T) {
+	e := newTestEnv(t)
+	entry, z := multiChildFlow(e, models.Process{})
+	children := e.children(entry.ID)
+	e.pass(children[0].ID, empDave)
+	// 其余两个子流程的待办同时提交
+	errs := make(chan error, 2)
+	for _, c := range children[1:] {
+		go func(procID uint) {
+			errs <- e.s.Pass(procID, empDave, "ok")
+		}(e.pending(c.ID, empDave))
+	}
+	for i := 0; i < 2; i++ {
+		e.must(<-errs)
+	}
+	var n int64
+	e.db.Model(&models.Proc{}).Where("entry_id=?", entry.ID).Where("process_id=?", z).Count(&n)
+	if n != 1 {
+		t.Fatalf("父流程应只生成一个步骤Z的待办，实际 %d 个；待办 %s", n, e.procs(entry.ID))
+	}
+}
@@ -0,0 +1,251 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"gorm.io/gorm"
+)
+
+// 撤回策略
+const (
+	RecallPolicyUnhandled = "unhandled" // 当前步骤尚无人处理时可撤回
+	RecallPolicyRunning   = "running"   // 审批中均可撤回
+	RecallPolicyNever     = "never"     // 不可撤回
+)
+
+// recallPolicy 流程的撤回策略，未设置时为当前步骤无人处理时可撤回
+func recallPolicy(flow models.Flow) string {
+	if flow.RecallPolicy == "" {
+		return RecallPolicyUnhandled
+	}
+	return flow.RecallPolicy
+}
+
+// Recall 发起人撤回审批中的流程：取消全部待办，进行中的子流程随之终止，流程变为已撤回并通知相关审批人
+func (s *Service) Recall(entryID uint, empID uint, reason string) error {
+	return s.transaction(func(t *flowTx) error {
+		var entry models.Entry
+		if err := t.First(&entry, entryID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("流程不存在")
+			}
+			return fmt.Errorf("数据库查询错误: %v", err)
+		}
+		if entry.EmpID != empID {
+			return errors.New("只有发起人可以撤回")
+		}
+		var emp models.Emp
+		if err := t.First(&emp, empID).Error; err != nil {
+			return errors.New("未找到发起人员工信息")
+		}
+		t.actAs(emp)
+		if entry.Pid > 0 {
+			return errors.New("子流程不能单独撤回，请撤回父流程")
+		}
+		if entry.Status != models.EntryStatusRunning {
+			return errors.New("流程不在审批中，无法撤回")
+		}
+		g, err := s.entryGraph(t, &entry)
+		if err != nil {
+			return err
+		}
+		entries, err := runningEntries(t, entry)
+		if err != nil {
+			return err
+		}
+		switch recallPolicy(g.Flow) {
+		case RecallPolicyNever:
+			return errors.New("该流程不允许撤回")
+		case RecallPolicyUnhandled:
+			// 转入子流程的步骤以子流程的当前步骤为准
+			parents := make(map[uint]bool)
+			for _, e := range entries {
+				parents[uint(e.Pid)] = true
+			}
+			for _, e := range entries {
+				if parents[e.ID] {
+					continue
+				}
+				if handled, err := currentStepHandled(t, e); err != nil {
+					return err
+				} else if handled {
+					return errors.New("当前步骤已有审批人处理，无法撤回")
+				}
+			}
+		}
+		ids := entryIds(entries)
+		if reason == "" {
+			reason = "发起人撤回"
+		}
+		if err = s.logEvent(t, &entry, models.EntryEvent{Action: EventRecall, Content: reason}); err != nil {
+			return err
+		}
+		procs, err := s.transitProcs(t, openProcs(ids), models.ProcStatusCancelled, reason, map[string]interface{}{
+			"is_real": false,
+			"content": reason,
+		})
+		if err != nil {
+			return err
+		}
+		s.cancelDeadlines(t, procs...)
+		err = t.Model(&models.EntryBranch{}).Where("entry_id IN (?)", ids).Where("status=?", BranchStatusActive).
+			Update("status", BranchStatusCancelled).Error
+		if err != nil {
+			return fmt.Errorf("数据库更新错误: %v", err)
+		}
+		// 子流程随父流程终止，父流程变为已撤回
+		if err = s.transitEntries(t, ids[1:], models.EntryStatusTerminated, reason); err != nil {
+			return err
+		}
+		err = s.transitEntry(t, &entry, models.EntryStatusWithdrawn, reason, map[string]interface{}{
+			"child":             0,
+			"return_process_id": 0,
+		})
+		if err != nil {
+			return err
+		}
+		notified := make(map[uint]bool)
+		for _, proc := range procs {
+			for _, id := range []uint{uint(proc.EmpID), resolveDelegate(t.DB, uint(proc.EmpID), uint(proc.FlowID))} {
+				if id > 0 && !notified[id] {
+					notified[id] = true
+					empID := id
+					t.afterCommit(func() {
+						_ = s.wf.NotifyRecall(empID)
+					})
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Resubmit 重新提交已撤回或退回发起人的流程：进入新一轮审批，按流程当前发布的版本从第一步重新开始；data 不为空时替换表单数据
+func (s *Service) Resubmit(entryID uint, empID uint, data map[string]string) (*models.Entry, error) {
+	var entry models.Entry
+	err := s.transaction(func(t *flowTx) error {
+		if err := t.First(&entry, entryID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("流程不存在")
+			}
+			return fmt.Errorf("数据库查询错误: %v", err)
+		}
+		if entry.EmpID != empID {
+			return errors.New("只有发起人可以重新提交")
+		}
+		var emp models.Emp
+		if err := t.First(&emp, empID).Error; err != nil {
+			return errors.New("未找到发起人员工信息")
+		}
+		t.actAs(emp)
+		if entry.Status != models.EntryStatusDraft && entry.Status != models.EntryStatusWithdrawn {
+			return errors.New("只有已撤回或退回发起人的流程可以重新提交")
+		}
+		var flow models.Flow
+		if err := t.First(&flow, entry.FlowID).Error; err != nil {
+			return errors.New("流程不存在")
+		}
+		if !flow.IsPublish {
+			return errors.New("流程未发布，无法发起")
+		}
+		entry.Circle++
+		entry.FlowVersionID = flow.VersionID
+		// 未提交新数据时按新版本的表单校验原有数据
+		current := entryDataMap(t, entry.ID)
+		formData := data
+		if len(formData) == 0 {
+			formData = current
+		}
+		formData, err := s.checkEntryData(t, &entry, current, formData, true)
+		if err != nil {
+			return err
+		}
+		if err := s.logEvent(t, &entry, models.EntryEvent{Action: EventResubmit}); err != nil {
+			return err
+		}
+		err = s.transitEntry(t, &entry, models.EntryStatusRunning, "重新提交", map[string]interface{}{
+			"circle":          entry.Circle,
+			"flow_version_id": entry.FlowVersionID,
+		})
+		if err != nil {
+			return err
+		}
+		if len(data) > 0 {
+			if err = s.replaceEntryData(t, entry, formData); err != nil {
+				return err
+			}
+		}
+		if entry.ReturnProcessID == 0 {
+			return s.startEntry(t, &entry)
+		}
+		// 退回发起人且驳回步骤设置为直接返回时，跳过中间步骤直接回到驳回步骤
+		g, err := s.entryGraph(t, &entry)
+		if err != nil {
+			return err
+		}
+		starts := g.starts()
+		if len(starts) == 0 {
+			return errors.New("流程未设置第一步骤")
+		}
+		if _, err = s.initiatorProc(t, &entry, starts[0]); err != nil {
+			return err
+		}
+		_, err = s.returnToRejecter(t, &entry, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// runningEntries 流程及其进行中的子流程（含多层子流程），流程本身在第一位
+func runningEntries(t *flowTx, entry models.Entry) ([]models.Entry, error) {
+	entries := []models.Entry{entry}
+	for i := 0; i < len(entries); i++ {
+		var children []models.Entry
+		if err := t.Where("pid=?", entries[i].ID).Where("status IN (?)", []models.EntryStatus{models.EntryStatusRunning, models.EntryStatusSuspended}).Find(&children).Error; err != nil {
+			return nil, fmt.Errorf("数据库查询错误: %v", err)
+		}
+		entries = append(entries, children...)
+	}
+	return entries, nil
+}
+
+// currentStepHandled 当前步骤本轮是否已有审批人处理，存在进行中的并行分支时检查各分支的当前步骤
+func currentStepHandled(t *flowTx, entry models.Entry) (bool, error) {
+	var branches []models.EntryBranch
+	if err := t.Where("entry_id=?", entry.ID).Where("status=?", BranchStatusActive).Find(&branches).Error; err != nil {
+		return false, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	if len(branches) == 0 {
+		return stepHandled(t, entry, 0, int(entry.ProcessID))
+	}
+	for _, b := range branches {
+		if handled, err := stepHandled(t, entry, int(b.ID), b.ProcessID); err != nil || handled {
+			return handled, err
+		}
+	}
+	return false, nil
+}
+
+// stepHandled 步骤本轮在主干或指定分支上是否已有审批人处理：只计审批人本人或其受托人通过、驳回的待办，
+// 取消的待办及超时、自动通过等系统代为处理的记录不计入
+func stepHandled(t *flowTx, entry models.Entry, branchID int, processID int) (bool, error) {
+	if processID == 0 {
+		return false, nil
+	}
+	var count int64
+	err := t.Model(&models.Proc{}).
+		Where("entry_id=?", entry.ID).
+		Where("branch_id=?", branchID).
+		Where("process_id=?", processID).
+		Where("circle=?", entry.Circle).
+		Where("status IN (?)", []models.ProcStatus{models.ProcStatusPassed, models.ProcStatusRejected}).
+		Where("is_real=? OR (auditor_id > 0 AND auditor_id <> emp_id)", true).Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return count > 0, nil
+}
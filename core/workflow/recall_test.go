@@ -0,0 +1,119 @@
+package workflow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// recallFlow 发起 → A(bob、carol 会签) → B(dave) → 结束
+func recallFlow(e *testEnv, policy string) *models.Entry {
+	flowID := e.flow("recall")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("recall_policy", policy).Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A", ApproveMode: ApproveModeAll}, "2,3", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "4", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, int(b), "")
+	e.link(flowID, b, -1, "")
+	return e.start(flowID, nil)
+}
+
+// 撤回后待办取消，重新提交进入新一轮并从第一步重新审批
+func TestRecallAndResubmit(t *testing.T) {
+	e := newTestEnv(t)
+	entry := recallFlow(e, RecallPolicyUnhandled)
+	bobProc := e.pending(entry.ID, empBob)
+	if err := e.s.Recall(entry.ID, empBob, ""); err == nil {
+		t.Fatal("非发起人不应可以撤回")
+	}
+	e.must(e.s.Recall(entry.ID, empAlice, ""))
+	e.expectStatus(entry.ID, models.EntryStatusWithdrawn)
+	if e.pending(entry.ID, empBob) != 0 || e.pending(entry.ID, empCarol) != 0 {
+		t.Fatalf("撤回后不应保留待办；待办 %s", e.procs(entry.ID))
+	}
+
+	resubmitted, err := e.s.Resubmit(entry.ID, empAlice, nil)
+	e.must(err)
+	if resubmitted.Circle != entry.Circle+1 {
+		t.Fatalf("重新提交后轮次为%d，期望%d", resubmitted.Circle, entry.Circle+1)
+	}
+	e.expectStatus(entry.ID, models.EntryStatusRunning)
+	if id := e.pending(entry.ID, empBob); id == 0 || id == bobProc {
+		t.Fatalf("重新提交后应为第一步审批人生成新待办；待办 %s", e.procs(entry.ID))
+	}
+	e.pass(entry.ID, empBob)
+	e.pass(entry.ID, empCarol)
+	e.pass(entry.ID, empDave)
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+	if _, err = e.s.Resubmit(entry.ID, empAlice, nil); err == nil {
+		t.Fatal("已完成的流程不应可以重新提交")
+	}
+}
+
+// 按撤回策略限制撤回
+func TestRecallPolicy(t *testing.T) {
+	t.Run("当前步骤已有人处理", func(t *testing.T) {
+		e := newTestEnv(t)
+		entry := recallFlow(e, RecallPolicyUnhandled)
+		e.pass(entry.ID, empBob)
+		if err := e.s.Recall(entry.ID, empAlice, ""); err == nil {
+			t.Fatal("当前步骤已有审批人处理时不应可以撤回")
+		}
+		e.expectStatus(entry.ID, models.EntryStatusRunning)
+	})
+	t.Run("审批中均可撤回", func(t *testing.T) {
+		e := newTestEnv(t)
+		entry := recallFlow(e, RecallPolicyRunning)
+		e.pass(entry.ID, empBob)
+		e.must(e.s.Recall(entry.ID, empAlice, "修改金额"))
+		e.expectStatus(entry.ID, models.EntryStatusWithdrawn)
+	})
+	t.Run("不可撤回", func(t *testing.T) {
+		e := newTestEnv(t)
+		entry := recallFlow(e, RecallPolicyNever)
+		if err := e.s.Recall(entry.ID, empAlice, ""); err == nil {
+			t.Fatal("不可撤回的流程不应可以撤回")
+		}
+	})
+}
+
+// 转办取消的待办、系统自动通过的待办不算有人处理，受托人代为处理的算作处理
+func TestRecallHandledBy(t *testing.T) {
+	t.Run("转办", func(t *testing.T) {
+		e := newTestEnv(t)
+		entry := recallFlow(e, RecallPolicyUnhandled)
+		e.must(e.s.Reassign(e.pending(entry.ID, empBob), empBob, empDave, "出差"))
+		e.must(e.s.Recall(entry.ID, empAlice, ""))
+		e.expectStatus(entry.ID, models.EntryStatusWithdrawn)
+	})
+	t.Run("自动通过", func(t *testing.T) {
+		e := newTestEnv(t)
+		flowID := e.flow("recall-auto")
+		e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Updates(map[string]interface{}{
+			"recall_policy": RecallPolicyUnhandled,
+			"auto_approve":  AutoApproveInitiator,
+		}).Error)
+		start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+		a := e.step(flowID, models.Process{ProcessName: "A", ApproveMode: ApproveModeAll}, "1,2", false)
+		e.link(flowID, start, int(a), "")
+		e.link(flowID, a, -1, "")
+		entry := e.start(flowID, nil)
+		if e.pending(entry.ID, empAlice) != 0 {
+			t.Fatalf("发起人的待办应自动通过；待办 %s", e.procs(entry.ID))
+		}
+		e.must(e.s.Recall(entry.ID, empAlice, ""))
+		e.expectStatus(entry.ID, models.EntryStatusWithdrawn)
+	})
+	t.Run("受托人", func(t *testing.T) {
+		e := newTestEnv(t)
+		entry := recallFlow(e, RecallPolicyUnhandled)
+		now := time.Now().Unix()
+		e.must(e.s.AddDelegation(&models.Delegation{EmpID: empBob, DelegateID: empDave, StartTime: now - 60, EndTime: now + 3600}))
+		e.must(e.s.Pass(e.pending(entry.ID, empBob), empDave, "ok"))
+		if err := e.s.Recall(entry.ID, empAlice, ""); err == nil {
+			t.Fatal("受托人已处理时不应可以撤回")
+		}
+	})
+}
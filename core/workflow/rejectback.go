@@ -0,0 +1,227 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// 退回后的流转方式
+const (
+	RejectReturnResume = "resume" // 从退回的步骤按流程重新流转
+	RejectReturnDirect = "direct" // 退回的步骤处理后直接回到驳回步骤
+)
+
+// 退回目标类型
+const (
+	RejectTargetInitiator = "initiator" // 退回发起人
+	RejectTargetPrevious  = "previous"  // 退回上一步
+	RejectTargetVisited   = "visited"   // 退回本轮已经过的步骤
+)
+
+// RejectTarget 驳回时可退回的目标
+type RejectTarget struct {
+	Kind        string `json:"kind"`
+	ProcessID   int    `json:"process_id"` // 退回发起人时为0
+	ProcessName string `json:"process_name"`
+	BranchID    int    `json:"branch_id"` // 目标步骤所在并行分支，0为主干
+}
+
+// RejectTargets 待办驳回时可退回的目标，依据本轮的处理记录计算
+func (s *Service) RejectTargets(procID uint, empID uint) ([]RejectTarget, error) {
+	t := &flowTx{DB: s.db}
+	proc, err := s.pendingProc(t, procID, empID)
+	if err != nil {
+		return nil, err
+	}
+	return s.rejectTargets(t, proc)
+}
+
+// rejectTargets 本轮已处理过的步骤，最近处理的在前；子流程及无审批人的步骤不能作为退回目标，
+// 并行分支内只能退回本分支或主干上的步骤
+func (s *Service) rejectTargets(t *flowTx, proc models.Proc) ([]RejectTarget, error) {
+	g, err := s.entryGraph(t, &proc.Entry)
+	if err != nil {
+		return nil, err
+	}
+	var history []models.Proc
+	err = t.Where("entry_id=?", proc.EntryID).
+		Where("circle=?", proc.Circle).
+		Where("status=?", models.ProcStatusPassed).
+		Order("id desc").Find(&history).Error
+	if err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	targets := []RejectTarget{}
+	// 子流程没有独立的发起人，不能退回发起人
+	if proc.Entry.Pid == 0 {
+		targets = append(targets, RejectTarget{Kind: RejectTargetInitiator, ProcessName: "发起人"})
+	}
+	kind := RejectTargetPrevious
+	seen := map[int]bool{proc.ProcessID: true}
+	for _, h := range history {
+		if seen[h.ProcessID] || (h.BranchID != 0 && h.BranchID != proc.BranchID) {
+			continue
+		}
+		seen[h.ProcessID] = true
+		process, ok := g.process(h.ProcessID)
+		if !ok || process.ChildFlowID > 0 || len(g.auditorLinks(process.ID)) == 0 {
+			continue
+		}
+		targets = append(targets, RejectTarget{Kind: kind, ProcessID: h.ProcessID, ProcessName: process.ProcessName, BranchID: h.BranchID})
+		kind = RejectTargetVisited
+	}
+	return targets, nil
+}
+
+// RejectBack 驳回并退回：processID 为0时退回发起人，流程回到草稿由发起人修改后重新提交；
+// 否则退回本轮已经过的步骤重新审批。驳回步骤设置为直接返回时，退回的步骤处理后直接回到驳回步骤。
+// 与驳回相同，加签人退回视为发起加签的审批人退回，按驳回策略步骤仍可能通过时不退回
+func (s *Service) RejectBack(procID uint, empID uint, content string, processID int) error {
+	return s.transaction(func(t *flowTx) error {
+		proc, err := s.pendingProc(t, procID, empID)
+		if err != nil {
+			return err
+		}
+		var emp models.Emp
+		if err = t.Preload("Dept").First(&emp, empID).Error; err != nil {
+			return errors.New("未找到审批人员工信息")
+		}
+		g, err := s.entryGraph(t, &proc.Entry)
+		if err != nil {
+			return err
+		}
+		process, ok := g.process(proc.ProcessID)
+		if !ok {
+			return errors.New("流程步骤不存在")
+		}
+		targets, err := s.rejectTargets(t, proc)
+		if err != nil {
+			return err
+		}
+		var target *RejectTarget
+		for i := range targets {
+			if targets[i].ProcessID == processID {
+				target = &targets[i]
+				break
+			}
+		}
+		if target == nil {
+			return errors.New("不能退回到该步骤")
+		}
+		t.actAs(emp)
+		event := procEvent(EventRejectBack, proc, content)
+		event.ToProcessID = target.ProcessID
+		if err = s.logEvent(t, &proc.Entry, event); err != nil {
+			return err
+		}
+
+		err = s.transitProc(t, proc.ID, models.ProcStatusRejected, content, map[string]interface{}{
+			"auditor_id":   emp.ID,
+			"auditor_name": emp.Name,
+			"auditor_dept": emp.Dept.DeptName,
+			"content":      content,
+			"is_read":      1,
+			"is_real":      emp.ID == uint(proc.EmpID),
+		})
+		if err != nil {
+			return err
+		}
+		// 加签人驳回视为发起加签的审批人驳回
+		if isSigner(proc) {
+			s.cancelDeadlines(t, proc)
+			origin, err := s.rejectSigned(t, proc)
+			if err != nil {
+				return err
+			}
+			origin.Entry = proc.Entry
+			proc = origin
+		}
+		// 按驳回策略，步骤仍可能通过时只记为驳回意见，等待其他审批人
+		holds, err := s.rejectionHolds(t, &proc.Entry, g, process, proc)
+		if err != nil {
+			return err
+		}
+		if holds {
+			s.cancelDeadlines(t, proc)
+			return nil
+		}
+		// 退回本分支内的步骤时只影响本分支，否则全部并行分支及未处理的待办随之取消
+		local := proc.BranchID > 0 && target.BranchID == proc.BranchID
+		scope := openProcs([]uint{proc.EntryID})
+		if local {
+			scope = openProcs([]uint{proc.EntryID}, uint(proc.BranchID))
+		}
+		returned := fmt.Sprintf("已退回至[%s]", target.ProcessName)
+		pending, err := s.transitProcs(t, scope, models.ProcStatusCancelled, returned, map[string]interface{}{
+			"is_real": false,
+			"content": returned,
+		})
+		if err != nil {
+			return err
+		}
+		s.cancelDeadlines(t, append(pending, proc)...)
+
+		returnProcessID := 0
+		if process.RejectReturn == RejectReturnDirect {
+			returnProcessID = proc.ProcessID
+		}
+		entry := proc.Entry
+		if local {
+			err = t.Model(&models.EntryBranch{}).Where("id=?", proc.BranchID).Update("return_process_id", returnProcessID).Error
+			if err != nil {
+				return fmt.Errorf("数据库更新错误: %v", err)
+			}
+			return s.goToProcess(t, &entry, proc.BranchID, target.ProcessID)
+		}
+		if err = s.cancelBranches(t, entry.ID, nil, returned); err != nil {
+			return err
+		}
+		// 并行分支已取消，无法直接回到分支内的驳回步骤
+		if proc.BranchID > 0 {
+			returnProcessID = 0
+		}
+		entry.ReturnProcessID = returnProcessID
+		updates := map[string]interface{}{"return_process_id": entry.ReturnProcessID}
+		if target.Kind == RejectTargetInitiator {
+			if err = s.transitEntry(t, &entry, models.EntryStatusDraft, returned, updates); err != nil {
+				return err
+			}
+			initiator := entry.EmpID
+			t.afterCommit(func() {
+				_ = s.wf.NotifySendOne(initiator)
+			})
+			return nil
+		}
+		if err = t.Model(&models.Entry{}).Where("id=?", entry.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("数据库更新错误: %v", err)
+		}
+		return s.goToProcess(t, &entry, 0, target.ProcessID)
+	})
+}
+
+// returnToRejecter 退回的步骤处理完成后，直接回到驳回步骤；并行分支内的退回记录在分支上。返回是否已回到驳回步骤
+func (s *Service) returnToRejecter(t *flowTx, entry *models.Entry, branchID int) (bool, error) {
+	if branchID > 0 {
+		var branch models.EntryBranch
+		if err := t.First(&branch, branchID).Error; err != nil {
+			return false, errors.New("并行分支不存在")
+		}
+		if branch.ReturnProcessID == 0 {
+			return false, nil
+		}
+		if err := t.Model(&models.EntryBranch{}).Where("id=?", branchID).Update("return_process_id", 0).Error; err != nil {
+			return false, fmt.Errorf("数据库更新错误: %v", err)
+		}
+		return true, s.goToProcess(t, entry, branchID, branch.ReturnProcessID)
+	}
+	if entry.ReturnProcessID == 0 {
+		return false, nil
+	}
+	processID := entry.ReturnProcessID
+	entry.ReturnProcessID = 0
+	if err := t.Model(&models.Entry{}).Where("id=?", entry.ID).Update("return_process_id", 0).Error; err != nil {
+		return false, fmt.Errorf("数据库更新错误: %v", err)
+	}
+	return true, s.goToProcess(t, entry, 0, processID)
+}
@@ -0,0 +1,142 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// rejectBackFlow 发起 → A(bob) → B(carol) → C(dave，按 ret 设置退回后的流转方式) → 结束
+func rejectBackFlow(e *testEnv, ret string) (*models.Entry, uint, uint, uint) {
+	flowID := e.flow("rejectback")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "3", false)
+	c := e.step(flowID, models.Process{ProcessName: "C", RejectReturn: ret}, "4", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, int(b), "")
+	e.link(flowID, b, int(c), "")
+	e.link(flowID, c, -1, "")
+	entry := e.start(flowID, nil)
+	e.pass(entry.ID, empBob)
+	e.pass(entry.ID, empCarol)
+	return entry, a, b, c
+}
+
+// 可退回的目标为发起人及本轮已经过的步骤，最近处理的在前
+func TestRejectTargets(t *testing.T) {
+	e := newTestEnv(t)
+	entry, a, b, _ := rejectBackFlow(e, RejectReturnResume)
+	targets, err := e.s.RejectTargets(e.pending(entry.ID, empDave), empDave)
+	e.must(err)
+	want := []RejectTarget{
+		{Kind: RejectTargetInitiator, ProcessName: "发起人"},
+		{Kind: RejectTargetPrevious, ProcessID: int(b), ProcessName: "B"},
+		{Kind: RejectTargetVisited, ProcessID: int(a), ProcessName: "A"},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("退回目标为 %+v，期望 %+v", targets, want)
+	}
+	for i := range want {
+		if targets[i] != want[i] {
+			t.Fatalf("第%d个退回目标为 %+v，期望 %+v", i+1, targets[i], want[i])
+		}
+	}
+}
+
+// 退回到已经过的步骤后按流程重新流转
+func TestRejectBackResume(t *testing.T) {
+	e := newTestEnv(t)
+	entry, a, _, c := rejectBackFlow(e, RejectReturnResume)
+	procID := e.pending(entry.ID, empDave)
+	if err := e.s.RejectBack(procID, empDave, "no", int(c)); err == nil {
+		t.Fatal("不应可以退回到当前步骤")
+	}
+	e.must(e.s.RejectBack(procID, empDave, "金额有误", int(a)))
+	e.expectStatus(entry.ID, models.EntryStatusRunning)
+	e.pass(entry.ID, empBob)
+	e.pass(entry.ID, empCarol)
+	e.pass(entry.ID, empDave)
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
+
+// 驳回步骤设置为直接返回时，退回的步骤处理后跳过中间步骤回到驳回步骤
+func TestRejectBackDirect(t *testing.T) {
+	e := newTestEnv(t)
+	entry, a, _, c := rejectBackFlow(e, RejectReturnDirect)
+	e.must(e.s.RejectBack(e.pending(entry.ID, empDave), empDave, "金额有误", int(a)))
+	e.pass(entry.ID, empBob)
+	if got := e.entry(entry.ID).ProcessID; got != c {
+		t.Fatalf("流程应直接回到步骤C(%d)，实际为%d；待办 %s", c, got, e.procs(entry.ID))
+	}
+	if e.pending(entry.ID, empCarol) != 0 {
+		t.Fatalf("直接返回时不应经过步骤B；待办 %s", e.procs(entry.ID))
+	}
+	e.pass(entry.ID, empDave)
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
+
+// 退回发起人后流程回到草稿，重新提交后按直接返回设置回到驳回步骤
+func TestRejectBackToInitiator(t *testing.T) {
+	e := newTestEnv(t)
+	entry, _, _, c := rejectBackFlow(e, RejectReturnDirect)
+	e.must(e.s.RejectBack(e.pending(entry.ID, empDave), empDave, "补充材料", 0))
+	e.expectStatus(entry.ID, models.EntryStatusDraft)
+	_, err := e.s.Resubmit(entry.ID, empAlice, nil)
+	e.must(err)
+	if got := e.entry(entry.ID).ProcessID; got != c {
+		t.Fatalf("重新提交后应直接回到步骤C(%d)，实际为%d；待办 %s", c, got, e.procs(entry.ID))
+	}
+	e.pass(entry.ID, empDave)
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
+
+// rejectBackBatchFlow 发起 → A(bob) → B(carol、dave，或签，剩余人数无法通过时才驳回) → 结束
+func rejectBackBatchFlow(e *testEnv) (*models.Entry, uint) {
+	flowID := e.flow("rejectback-batch")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	b := e.step(flowID, models.Process{ProcessName: "B", ApproveMode: ApproveModeAny, RejectPolicy: RejectPolicyUnreachable}, "3,4", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, int(b), "")
+	e.link(flowID, b, -1, "")
+	entry := e.start(flowID, nil)
+	e.pass(entry.ID, empBob)
+	return entry, a
+}
+
+// 按驳回策略步骤仍可能通过时，退回只记为驳回意见，等待其他审批人
+func TestRejectBackHoldsStep(t *testing.T) {
+	e := newTestEnv(t)
+	entry, a := rejectBackBatchFlow(e)
+	e.must(e.s.RejectBack(e.pending(entry.ID, empCarol), empCarol, "金额有误", int(a)))
+	if e.pending(entry.ID, empDave) == 0 || e.pending(entry.ID, empBob) != 0 {
+		t.Fatalf("其他审批人仍可通过，不应退回；待办 %s", e.procs(entry.ID))
+	}
+	e.must(e.s.RejectBack(e.pending(entry.ID, empDave), empDave, "金额有误", int(a)))
+	if e.pending(entry.ID, empBob) == 0 {
+		t.Fatalf("无人可通过时应退回步骤A；待办 %s", e.procs(entry.ID))
+	}
+	e.expectStatus(entry.ID, models.EntryStatusRunning)
+}
+
+// 加签人退回视为发起加签的审批人驳回，同样按驳回策略判断是否退回
+func TestRejectBackBySigner(t *testing.T) {
+	e := newTestEnv(t)
+	entry, a := rejectBackBatchFlow(e)
+	carolProc := e.pending(entry.ID, empCarol)
+	e.must(e.s.AddSignBefore(carolProc, empCarol, empAlice, "请确认"))
+	e.must(e.s.RejectBack(e.pending(entry.ID, empAlice), empAlice, "金额有误", int(a)))
+	var origin models.Proc
+	e.must(e.db.First(&origin, carolProc).Error)
+	if origin.Status != models.ProcStatusRejected {
+		t.Fatalf("发起加签的待办应随之驳回，实际为%s", origin.Status)
+	}
+	if e.pending(entry.ID, empDave) == 0 || e.pending(entry.ID, empBob) != 0 {
+		t.Fatalf("其他审批人仍可通过，不应退回；待办 %s", e.procs(entry.ID))
+	}
+	e.must(e.s.RejectBack(e.pending(entry.ID, empDave), empDave, "金额有误", int(a)))
+	if e.pending(entry.ID, empBob) == 0 {
+		t.Fatalf("无人可通过时应退回步骤A；待办 %s", e.procs(entry.ID))
+	}
+}
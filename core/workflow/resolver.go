@@ -0,0 +1,231 @@
+package workflow
+
+import (
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"gorm.io/gorm"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AuditorResolver 人员解析器：按流程定义中人员设置的类型（Flowlink.Type、CarbonCopy.Type）查找，
+// 将设置值（Flowlink.Auditor、CarbonCopy.Recipient）解析为员工ID，审批人与抄送人共用
+type AuditorResolver interface {
+	// Name 人员类型名称，流程定义中以该名称引用
+	Name() string
+	// Resolve 解析员工ID，按返回顺序作为依次审批的顺序
+	Resolve(ctx *AuditorContext, value string) ([]int, error)
+}
+
+// AuditorContext 解析人员时的流程上下文，发起人及表单数据在首次使用时查询
+type AuditorContext struct {
+	DB    *gorm.DB
+	Entry *models.Entry
+
+	initiator *models.Emp
+	data      map[string]string
+}
+
+// Initiator 发起人，含所在部门
+func (c *AuditorContext) Initiator() models.Emp {
+	if c.initiator == nil {
+		var emp models.Emp
+		c.DB.Preload("Dept").First(&emp, c.Entry.EmpID)
+		c.initiator = &emp
+	}
+	return *c.initiator
+}
+
+// Data 流程的表单数据
+func (c *AuditorContext) Data() map[string]string {
+	if c.data == nil {
+		var entryDatas []models.EntryData
+		c.DB.Where("entry_id=?", c.Entry.ID).Find(&entryDatas)
+		c.data = make(map[string]string, len(entryDatas))
+		for _, d := range entryDatas {
+			c.data[d.FieldName] = d.FieldValue
+		}
+	}
+	return c.data
+}
+
+var (
+	auditorResolvers   = map[string]AuditorResolver{}
+	auditorResolversMu sync.RWMutex
+)
+
+// RegisterAuditorResolver 注册人员解析器，同名时覆盖已注册的解析器
+func RegisterAuditorResolver(r AuditorResolver) {
+	auditorResolversMu.Lock()
+	defer auditorResolversMu.Unlock()
+	auditorResolvers[r.Name()] = r
+}
+
+// AuditorResolverNames 已注册的人员类型名称
+func AuditorResolverNames() []string {
+	auditorResolversMu.RLock()
+	defer auditorResolversMu.RUnlock()
+	names := make([]string, 0, len(auditorResolvers))
+	for name := range auditorResolvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupAuditorResolver(name string) (AuditorResolver, bool) {
+	auditorResolversMu.RLock()
+	defer auditorResolversMu.RUnlock()
+	r, ok := auditorResolvers[name]
+	return r, ok
+}
+
+// ResolverFunc 以函数实现的人员解析器
+type ResolverFunc func(ctx *AuditorContext, value string) ([]int, error)
+
+type funcResolver struct {
+	name string
+	fn   ResolverFunc
+}
+
+func (r funcResolver) Name() string { return r.name }
+
+func (r funcResolver) Resolve(ctx *AuditorContext, value string) ([]int, error) {
+	return r.fn(ctx, value)
+}
+
+// NewAuditorResolver 以函数创建人员解析器
+func NewAuditorResolver(name string, fn ResolverFunc) AuditorResolver {
+	return funcResolver{name: name, fn: fn}
+}
+
+// 内置人员类型
+const (
+	AuditorSys       = "Sys"       // 系统自动（兼容旧设置）：-1000发起人 -1001发起人部门主管 -1002发起人部门经理
+	AuditorEmp       = "Emp"       // 指定员工，值为员工ID列表
+	AuditorDept      = "Dept"      // 指定部门的主管，值为部门ID列表
+	AuditorInitiator = "Initiator" // 发起人
+	AuditorDirector  = "Director"  // 发起人部门主管
+	AuditorManager   = "Manager"   // 发起人部门经理
+	AuditorSuperior  = "Superior"  // 发起人第N级上级主管，值为级数，为空时为1
+	AuditorRole      = "Role"      // 角色成员，值为角色ID列表
+	AuditorField     = "Field"     // 表单字段中的员工ID，值为字段名
+)
+
+// resolveInitiator 发起人
+func resolveInitiator(ctx *AuditorContext, value string) ([]int, error) {
+	return []int{int(ctx.Entry.EmpID)}, nil
+}
+
+// resolveDirector 发起人部门主管
+func resolveDirector(ctx *AuditorContext, value string) ([]int, error) {
+	emp := ctx.Initiator()
+	if emp.Dept.ID == 0 {
+		return nil, nil
+	}
+	return []int{emp.Dept.DirectorID}, nil
+}
+
+// resolveManager 发起人部门经理
+func resolveManager(ctx *AuditorContext, value string) ([]int, error) {
+	emp := ctx.Initiator()
+	if emp.Dept.ID == 0 {
+		return nil, nil
+	}
+	return []int{emp.Dept.ManagerID}, nil
+}
+
+// resolveSuperior 发起人第N级上级主管：从发起人部门沿上级部门依次查找主管，
+// 跳过未设置主管或主管为发起人本人的部门，同一人只计一级
+func resolveSuperior(ctx *AuditorContext, value string) ([]int, error) {
+	level := 1
+	if value = strings.TrimSpace(value); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("上级主管级数[%s]无效", value)
+		}
+		level = n
+	}
+	emp := ctx.Initiator()
+	seen := map[int]bool{int(emp.ID): true}
+	for _, deptID := range deptChain(ctx.DB, uint(emp.DeptID)) {
+		var dept models.Dept
+		if ctx.DB.First(&dept, deptID).Error != nil {
+			break
+		}
+		if dept.DirectorID <= 0 || seen[dept.DirectorID] {
+			continue
+		}
+		seen[dept.DirectorID] = true
+		if level--; level == 0 {
+			return []int{dept.DirectorID}, nil
+		}
+	}
+	return nil, nil
+}
+
+// resolveRole 角色成员：员工关联的用户属于指定角色，不含已离职员工
+func resolveRole(ctx *AuditorContext, value string) ([]int, error) {
+	var empIds []int
+	err := ctx.DB.Model(&models.Emp{}).
+		Joins("JOIN users ON users.id = emps.user_id").
+		Where("users.role_id IN (?)", splitIds(value)).
+		Where("emps.leave=?", 0).
+		Order("emps.id asc").
+		Pluck("emps.id", &empIds).Error
+	if err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return empIds, nil
+}
+
+// resolveField 表单字段中的员工ID，多个以逗号分隔
+func resolveField(ctx *AuditorContext, value string) ([]int, error) {
+	return splitIds(ctx.Data()[strings.TrimSpace(value)]), nil
+}
+
+// resolveEmp 指定员工
+func resolveEmp(ctx *AuditorContext, value string) ([]int, error) {
+	return splitIds(value), nil
+}
+
+// resolveDept 指定部门的主管，可能指定多个部门，主管 director_id 对应员工的id
+func resolveDept(ctx *AuditorContext, value string) ([]int, error) {
+	var empIds []int
+	if err := ctx.DB.Model(&models.Dept{}).Where("id IN (?)", splitIds(value)).Pluck("director_id", &empIds).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return empIds, nil
+}
+
+// resolveSys 旧版系统自动设置
+func resolveSys(ctx *AuditorContext, value string) ([]int, error) {
+	switch strings.TrimSpace(value) {
+	case "-1000":
+		return resolveInitiator(ctx, "")
+	case "-1001":
+		return resolveDirector(ctx, "")
+	case "-1002":
+		return resolveManager(ctx, "")
+	}
+	return nil, fmt.Errorf("系统人员设置[%s]无法识别", value)
+}
+
+func init() {
+	builtin := map[string]ResolverFunc{
+		AuditorSys:       resolveSys,
+		AuditorEmp:       resolveEmp,
+		AuditorDept:      resolveDept,
+		AuditorInitiator: resolveInitiator,
+		AuditorDirector:  resolveDirector,
+		AuditorManager:   resolveManager,
+		AuditorSuperior:  resolveSuperior,
+		AuditorRole:      resolveRole,
+		AuditorField:     resolveField,
+	}
+	for name, fn := range builtin {
+		RegisterAuditorResolver(NewAuditorResolver(name, fn))
+	}
+}
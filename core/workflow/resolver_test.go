@@ -0,0 +1,80 @@
+package workflow
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// 内置人员类型按发起人(alice)及表单数据解析
+func TestBuiltinResolvers(t *testing.T) {
+	e := newTestEnv(t)
+	entry := models.Entry{EmpID: empAlice}
+	e.must(e.db.Create(&entry).Error)
+	e.must(e.db.Create(&models.EntryData{EntryID: int(entry.ID), FieldName: "reviewers", FieldValue: "4,3"}).Error)
+	ctx := &AuditorContext{DB: e.db, Entry: &entry}
+	cases := []struct {
+		linkType string
+		value    string
+		want     []int
+	}{
+		{AuditorInitiator, "", []int{int(empAlice)}},
+		{AuditorDirector, "", []int{int(empBob)}},
+		{AuditorManager, "", []int{int(empCarol)}},
+		{AuditorSuperior, "", []int{int(empBob)}},
+		{AuditorSuperior, "2", nil},
+		{AuditorDept, "1", []int{int(empBob)}},
+		{AuditorEmp, "3, 2", []int{int(empCarol), int(empBob)}},
+		{AuditorField, "reviewers", []int{int(empDave), int(empCarol)}},
+		{AuditorSys, "-1002", []int{int(empCarol)}},
+	}
+	for _, c := range cases {
+		got, err := resolveEmpIds(ctx, c.linkType, c.value)
+		e.must(err)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%s[%s] 解析为 %v，期望 %v", c.linkType, c.value, got, c.want)
+		}
+	}
+	for _, c := range []struct{ linkType, value string }{{AuditorSys, "-1"}, {AuditorSuperior, "0"}, {"Nope", ""}} {
+		if _, err := resolveEmpIds(ctx, c.linkType, c.value); err == nil {
+			t.Fatalf("%s[%s] 应解析失败", c.linkType, c.value)
+		}
+	}
+}
+
+// 注册的人员类型可用于审批人设置，未注册的类型不能发布
+func TestRegisterAuditorResolver(t *testing.T) {
+	const name = "Finance"
+	RegisterAuditorResolver(NewAuditorResolver(name, func(ctx *AuditorContext, value string) ([]int, error) {
+		return []int{int(empDave)}, nil
+	}))
+	t.Cleanup(func() {
+		auditorResolversMu.Lock()
+		delete(auditorResolvers, name)
+		auditorResolversMu.Unlock()
+	})
+	e := newTestEnv(t)
+	flowID := e.flow("resolver")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "", false)
+	e.must(e.db.Create(&models.Flowlink{FlowID: flowID, Type: name, ProcessID: a}).Error)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, -1, "")
+	errs, err := e.s.ValidateFlow(flowID)
+	e.must(err)
+	if errs.HasError() {
+		t.Fatalf("已注册的人员类型不应报错，实际 %v", errs)
+	}
+	entry := e.start(flowID, nil)
+	if e.pending(entry.ID, empDave) == 0 {
+		t.Fatalf("应按注册的解析器交由dave审批；待办 %s", e.procs(entry.ID))
+	}
+
+	e.must(e.db.Model(&models.Flowlink{}).Where("process_id=?", a).Where("type=?", name).Update("type", "Nope").Error)
+	errs, err = e.s.ValidateFlow(flowID)
+	e.must(err)
+	if !errs.HasError() {
+		t.Fatal("未注册的人员类型应报错")
+	}
+}
@@ -1,15 +1,24 @@
 package workflow
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/hulutech-web/workflow-engine/core/config"
+	"github.com/dromara/carbon/v2"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/queue"
 	"go.uber.org/fx"
+	"gorm.io/gorm"
 	"reflect"
+	"sort"
 	"sync"
 )
 
 type Service struct {
-	wf *Workflow
+	db       *gorm.DB
+	dq       *queue.DelayQueue // 待办超时队列
+	wf       *Workflow
+	versions sync.Map // 已读取的流程版本快照
 }
 
 // Singleton 是 Workflow 的单例实例
@@ -67,6 +76,71 @@ func (w *Workflow) NotifyNextAuditor(id uint) error {
 	return nil
 }
 
+// NotifyTimeout 调用 NotifyTimeout 钩子，提醒审批人待办已超时
+func (w *Workflow) NotifyTimeout(id uint) error {
+	if w == nil {
+		fmt.Println("Workflow instance is nil in NotifyTimeout!")
+		return fmt.Errorf("workflow instance is nil")
+	}
+	fmt.Printf("BaseWorkflow.NotifyTimeout:%d\n", id)
+
+	w.invokeHooks("NotifyTimeoutHook", id)
+
+	return nil
+}
+
+// NotifyRecall 调用 NotifyRecall 钩子，通知审批人待办已被发起人撤回
+func (w *Workflow) NotifyRecall(id uint) error {
+	if w == nil {
+		fmt.Println("Workflow instance is nil in NotifyRecall!")
+		return fmt.Errorf("workflow instance is nil")
+	}
+	fmt.Printf("BaseWorkflow.NotifyRecall:%d\n", id)
+
+	w.invokeHooks("NotifyRecallHook", id)
+
+	return nil
+}
+
+// NotifyCarbonCopy 调用 NotifyCarbonCopy 钩子，通知抄送人
+func (w *Workflow) NotifyCarbonCopy(id uint) error {
+	if w == nil {
+		fmt.Println("Workflow instance is nil in NotifyCarbonCopy!")
+		return fmt.Errorf("workflow instance is nil")
+	}
+	fmt.Printf("BaseWorkflow.NotifyCarbonCopy:%d\n", id)
+
+	w.invokeHooks("NotifyCarbonCopyHook", id)
+
+	return nil
+}
+
+// NotifyEntryStatus 调用 NotifyEntryStatus 钩子，发布流程状态变更事件，id 为流程id
+func (w *Workflow) NotifyEntryStatus(id uint) error {
+	if w == nil {
+		fmt.Println("Workflow instance is nil in NotifyEntryStatus!")
+		return fmt.Errorf("workflow instance is nil")
+	}
+	fmt.Printf("BaseWorkflow.NotifyEntryStatus:%d\n", id)
+
+	w.invokeHooks("NotifyEntryStatusHook", id)
+
+	return nil
+}
+
+// NotifyProcStatus 调用 NotifyProcStatus 钩子，发布待办状态变更事件，id 为待办id
+func (w *Workflow) NotifyProcStatus(id uint) error {
+	if w == nil {
+		fmt.Println("Workflow instance is nil in NotifyProcStatus!")
+		return fmt.Errorf("workflow instance is nil")
+	}
+	fmt.Printf("BaseWorkflow.NotifyProcStatus:%d\n", id)
+
+	w.invokeHooks("NotifyProcStatusHook", id)
+
+	return nil
+}
+
 // invokeHooks 用于依次调用所有注册的钩子方法
 func (w *Workflow) invokeHooks(hookName string, id uint) {
 	if hooks, ok := w.hooks[hookName]; ok {
@@ -88,547 +162,764 @@ func (w *Workflow) invokeHooks(hookName string, id uint) {
 	}
 }
 
-/**
-func (w *Workflow) SetFirstProcessAuditor(entry models.Entry, flowlink models.Flowlink) error {
-	return facades.Orm().Transaction(func(tx orm.Transaction) error {
-		var myFlowlink models.Flowlink
-
-		var auditor_ids []int
-		err := tx.Model(&models.Flowlink{}).Where("type != ?", "Condition").
-			Where("process_id=?", flowlink.ProcessID).Find(&myFlowlink)
-
-		var process_id int
-		var process_name string
-		if myFlowlink.ID == 0 {
-
-			//第一步未指定审核人 自动进入下一步操作
-			var proc models.Proc
-			proc.FlowID = cast.ToInt(entry.FlowID)
-			proc.ProcessID = cast.ToInt(flowlink.ProcessID)
-			proc.ProcessName = flowlink.Process.ProcessName
-			proc.EmpID = cast.ToInt(entry.EmpID)
-			proc.EmpName = entry.Emp.Name
-			proc.DeptName = entry.Emp.Dept.DeptName
-			proc.AuditorID = cast.ToInt(entry.EmpID)
-			proc.AuditorName = entry.Emp.Name
-			proc.AuditorDept = entry.Emp.Dept.DeptName
-			proc.Status = 9
-			proc.Circle = entry.Circle
-			proc.Concurrence = carbon.NewDateTime(carbon.Now())
-			proc.EntryID = entry.ID
-			err = facades.Orm().Transaction(func(tx2 orm.Transaction) error {
-				err = tx2.Model(&models.Proc{}).Create(&proc)
-				return err
-			})
-
-			auditor_ids = w.GetProcessAuditorIds(entry, flowlink.NextProcessID)
-			process_id = flowlink.NextProcessID
-			process_name = flowlink.NextProcess.ProcessName
-			entry.ProcessID = cast.ToUint(flowlink.NextProcessID)
-		} else {
-
-			auditor_ids = w.GetProcessAuditorIds(entry, cast.ToInt(flowlink.ProcessID))
-			process_id = cast.ToInt(flowlink.ProcessID)
-			process_name = flowlink.Process.ProcessName
-			entry.ProcessID = cast.ToUint(flowlink.ProcessID)
-
-		}
-		//步骤流转
-		//步骤审核人
-		var auditors_emps []models.Emp
-		err = facades.Orm().Transaction(func(tx4 orm.Transaction) error {
-			tx4.Model(&models.Emp{}).Where("id IN (?)", auditor_ids).With("Dept").Find(&auditors_emps)
-			if len(auditors_emps) < 1 {
-				return errors.New("下一步骤未找到审批人")
-			}
-			return nil
-		})
-		for _, emp := range auditors_emps {
-			var proc2 models.Proc
-			proc2.EntryID = entry.ID
-			proc2.FlowID = cast.ToInt(entry.FlowID)
-			proc2.ProcessID = process_id
-			proc2.ProcessName = process_name
-			proc2.EmpID = cast.ToInt(emp.ID)
-			proc2.EmpName = emp.Name
-			proc2.DeptName = emp.Dept.DeptName
-			proc2.Status = 0
-			proc2.Circle = entry.Circle
-			proc2.Concurrence = carbon.NewDateTime(carbon.Now())
-			facades.Orm().Query().Model(&models.Proc{}).Create(&proc2)
-		}
-
-		facades.Orm().Query().Model(models.Entry{}).Where("id=?", entry.ID).Save(&entry)
-		return nil
+// flowTx 单次流程操作的事务上下文，通知、插件等副作用在事务提交后统一执行
+type flowTx struct {
+	*gorm.DB
+	after  []func()
+	graphs map[uint]*flowGraph // 未关联版本的实例，按流程缓存本次操作读取的定义
+	skips  int                 // 本次操作中因未找到审批人自动跳过的步骤数
+
+	actorID   uint // 本次操作的操作人，系统操作为0
+	actorName string
+}
+
+// afterCommit 登记事务提交后执行的动作
+func (t *flowTx) afterCommit(fn func()) {
+	t.after = append(t.after, fn)
+}
+
+// transaction 在同一个事务中执行流程操作，提交成功后再触发钩子
+func (s *Service) transaction(fn func(t *flowTx) error) error {
+	t := &flowTx{}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		t.DB = tx
+		return fn(t)
 	})
+	if err != nil {
+		return err
+	}
+	for _, f := range t.after {
+		f()
+	}
+	return nil
 }
-**/
-
-/**
-func (w *Workflow) GetProcessAuditorIds(entry models.Entry, next_process_id int) []int {
-	var auditor_ids []int
-	var flowlink models.Flowlink
-	query := facades.Orm().Query()
-	query.Model(&models.Flowlink{}).Where("type = ?", "Sys").Where("process_id=?", next_process_id).Find(&flowlink)
-	if flowlink.ID > 0 {
-		if flowlink.Auditor == "-1000" {
-			//发起人
-			auditor_ids = append(auditor_ids, cast.ToInt(entry.EmpID))
-		}
-		if flowlink.Auditor == "-1001" {
-			//发起人部门主管
-			if entry.Emp.Dept.ID == 0 {
-				return auditor_ids
+
+// Start 发起流程：校验并创建 Entry 及表单数据，并进入第一个步骤；未填写标题时按流程的标题模板生成
+func (s *Service) Start(flowID uint, empID uint, title string, data map[string]string) (*models.Entry, error) {
+	var entry models.Entry
+	err := s.transaction(func(t *flowTx) error {
+		var flow models.Flow
+		if err := t.First(&flow, flowID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("流程不存在")
 			}
-			auditor_ids = append(auditor_ids, cast.ToInt(entry.Emp.Dept.DirectorID))
+			return fmt.Errorf("数据库查询错误: %v", err)
 		}
-		if flowlink.Auditor == "-1002" {
-			//发起人部门经理
-			if entry.Emp.Dept.ID == 0 {
-				return auditor_ids
-			}
-			auditor_ids = append(auditor_ids, cast.ToInt(entry.Emp.Dept.ManagerID))
+		if !flow.IsPublish {
+			return errors.New("流程未发布，无法发起")
 		}
-	} else {
-		//	concurrent 并行
-		//	1、指定员工
-		concurrent_emp_flowlink := models.Flowlink{}
-		query.Model(&models.Flowlink{}).Where("type = ?", "Emp").Where("process_id=?", next_process_id).Find(&concurrent_emp_flowlink)
-		if concurrent_emp_flowlink.ID > 0 {
-			Auditor_ids := []string{}
-			//按照,分割concurrent_flowlink.Auditor
-			Auditor_ids = strings.Split(concurrent_emp_flowlink.Auditor, ",")
-			for _, id := range Auditor_ids {
-				auditor_ids = append(auditor_ids, cast.ToInt(id))
-			}
+		var emp models.Emp
+		if err := t.First(&emp, empID).Error; err != nil {
+			return errors.New("未找到发起人员工信息")
 		}
-		//	2、指定部门（指定部门时，可能指定多个部门，分别找到部门的主管，并找到对应的emp_id）
-		concurrent_dept_flowlink := models.Flowlink{}
-		query.Model(&models.Flowlink{}).Where("type = ?", "Dept").Where("process_id=?", next_process_id).
-			Find(&concurrent_dept_flowlink)
-
-		if concurrent_dept_flowlink.ID > 0 {
-			dept_id_strs := []string{}
-			//按照,分割concurrent_flowlink.Auditor
-			dept_id_strs = strings.Split(concurrent_dept_flowlink.Auditor, ",")
-			dept_ids := []int{}
-			for _, id := range dept_id_strs {
-				dept_ids = append(dept_ids, cast.ToInt(id))
-			}
-			emp_ids := []int{}
-			//默认查找部门主管director_id，它对应着员工的id
-			query.Model(&models.Dept{}).Select("director_id").Where("id IN (?)", dept_ids).Pluck("director_id", &emp_ids)
-			for _, id := range emp_ids {
-				auditor_ids = append(auditor_ids, id)
+		t.actAs(emp)
+		entry = models.Entry{
+			Title:         title,
+			FlowID:        flow.ID,
+			FlowVersionID: flow.VersionID,
+			EmpID:         emp.ID,
+			Circle:        1,
+			Status:        models.EntryStatusRunning,
+		}
+		data, err := s.checkEntryData(t, &entry, nil, data, false)
+		if err != nil {
+			return err
+		}
+		if entry.Title == "" {
+			if entry.Title, err = s.entryTitle(t, &entry, emp, data); err != nil {
+				return err
 			}
+			title = entry.Title
+		}
+		if err := t.Create(&entry).Error; err != nil {
+			return fmt.Errorf("数据库插入错误: %v", err)
+		}
+		if err := s.logEvent(t, &entry, models.EntryEvent{Action: EventStart, Content: title}); err != nil {
+			return err
+		}
+		if err := s.saveEntryData(t, entry, data); err != nil {
+			return err
 		}
-		//	3、指定角色，暂时不需要
+		return s.startEntry(t, &entry)
+	})
+	if err != nil {
+		return nil, err
 	}
-	ret_auditor_ids := uniqueSlice(auditor_ids)
-	//	对auditor_ids去重
-	return ret_auditor_ids
+	return &entry, nil
+}
+
+// Pass 审批通过，本步骤必填的表单字段须已填写
+func (s *Service) Pass(procID uint, empID uint, content string) error {
+	return s.PassWithData(procID, empID, content, nil)
+}
 
+// PassWithData 审批人修改表单数据后审批通过，只能修改步骤字段权限中可编辑的字段
+func (s *Service) PassWithData(procID uint, empID uint, content string, data map[string]string) error {
+	return s.pass(procID, empID, content, data)
 }
-**/
 
-// 辅助函数，从slice中去重
-func uniqueSlice(slice []int) []int {
-	seen := make(map[int]bool)
-	result := []int{}
+// Transfer 流转：处理当前待办，并按流转条件进入下一步骤；与 Pass 相同，同样检查本步骤的必填字段
+func (s *Service) Transfer(procID uint, empID uint, content string) error {
+	return s.pass(procID, empID, content, nil)
+}
 
-	for _, value := range slice {
-		if _, ok := seen[value]; !ok {
-			seen[value] = true
-			result = append(result, value)
+// pass 处理待办并流转，按步骤的字段权限检查并保存审批人提交的表单数据
+func (s *Service) pass(procID uint, empID uint, content string, data map[string]string) error {
+	var proc models.Proc
+	var emp models.Emp
+	err := s.transaction(func(t *flowTx) error {
+		var err error
+		proc, err = s.pendingProc(t, procID, empID)
+		if err != nil {
+			return err
+		}
+		if err = t.Preload("Dept").First(&emp, empID).Error; err != nil {
+			return errors.New("未找到审批人员工信息")
 		}
+		t.actAs(emp)
+		if err = s.logEvent(t, &proc.Entry, procEvent(EventPass, proc, content)); err != nil {
+			return err
+		}
+
+		g, err := s.entryGraph(t, &proc.Entry)
+		if err != nil {
+			return err
+		}
+		process, _ := g.process(proc.ProcessID)
+		data, err = stepData(g, stepFieldPerms(g, process), entryDataMap(t, proc.Entry.ID), data, false)
+		if err != nil {
+			return err
+		}
+		if err = s.saveEntryData(t, proc.Entry, data); err != nil {
+			return err
+		}
+		return s.approve(t, g, proc, emp, content, emp.ID == uint(proc.EmpID))
+	})
+	if err != nil {
+		return err
 	}
-	return result
+	return s.execPlugin(proc, emp)
 }
 
-// 流转
-/*
-func (w *Workflow) Transfer(process_id int, user models.Emp, content string) error {
-	tx := facades.Orm().Query()
-	var emp models.Emp
-	facades.Orm().Query().Model(&models.Emp{}).With("Dept").Where("user_id=?", user.ID).Find(&emp)
-	var proc models.Proc
-	tx.Model(&models.Proc{}).With("Entry.Emp.Dept").With("Entry.ParentEntry").Where("process_id=?", process_id).
-		Where("emp_id=?", emp.ID).Where("status=?", 0).Find(&proc)
-	if proc.ID == 0 {
-		return errors.New("未绑定员工，请设置员工绑定")
-	}
-	var fkcount int64
-	tx.Model(&models.Flowlink{}).Where("process_id=?", proc.ProcessID).Where("type=?", "Condition").Count(&fkcount)
-
-	if fkcount > 1 {
-		//	情况一：有条件
-		pvar := models.ProcessVar{}
-		tx.Model(&models.ProcessVar{}).Where("process_id=?", process_id).Find(&pvar)
-		var field_value string
-		tx.Model(&models.EntryData{}).Select("field_value").
-			Where("entry_id=?", proc.EntryID).
-			Where("field_name=?", pvar.ExpressionField).Pluck("field_value", &field_value)
-
-		flowlinks := []models.Flowlink{}
-		tx.Model(&models.Flowlink{}).Where("process_id=?", proc.ProcessID).
-			Where("type=?", "Condition").Find(&flowlinks)
-		var flowlink models.Flowlink //满足条件的flowlink
-		field := pvar.ExpressionField
-		for _, m := range flowlinks {
-			if m.Expression == "" {
-				return errors.New("未设置流转条件，无法流转，请联系流程设置人员")
-			}
+// execPlugin 执行步骤的分发插件，并记录插件事件
+func (s *Service) execPlugin(proc models.Proc, actor models.Emp) error {
+	if GetCollectorIns() == nil {
+		return nil
+	}
+	err := s.wf.ExecPluginMethod("DistributePlugin", uint(proc.FlowID), uint(proc.ProcessID))
+	content := "DistributePlugin"
+	if err != nil {
+		content = fmt.Sprintf("DistributePlugin：%v", err)
+	}
+	t := &flowTx{DB: s.db}
+	t.actAs(actor)
+	_ = s.logEvent(t, &proc.Entry, procEvent(EventPlugin, proc, content))
+	return err
+}
 
-			if m.Expression == "1" {
-				flowlink = m
-				break
-			} else {
-				//m.Expression
-				type ResultCount struct {
-					Number int `json:"number"`
-				}
-				var resultCount ResultCount
-				processConditions := []common.ProcessCondition{}
-				json.Unmarshal([]byte(m.Expression), &processConditions)
-				if len(processConditions) > 0 {
-					//检查语法错误(使用mysql数条件表达式
-					conditionSql := ""
-					for _, condition := range processConditions {
-						if condition.Field != field {
-							return errors.New("没有该条件字段，请检查")
-						} else {
-							conditionSql += fmt.Sprintf(" `field_value` %s %s %s", condition.Operator, condition.Value, condition.Extra)
-						}
-					}
-					conditionSql = fmt.Sprintf("SELECT count(*) as number FROM entrydatas WHERE entry_id=%d and flow_id=%d and (%s) and (`field_name`='%s')",
-						proc.EntryID, proc.FlowID, conditionSql, field)
-					//还需要条件entry_id和flow_id
-					err := facades.Orm().Query().Raw(conditionSql).Scan(&resultCount)
-					if err != nil {
-						return errors.New("条件语法错误，请检查")
-					}
-					if resultCount.Number > 0 {
-						flowlink = m
-						break
-					}
-				}
-			}
+// approve 审批通过待办，按步骤的审批方式汇总，步骤通过后流转；isReal 为审核人本人处理
+func (s *Service) approve(t *flowTx, g *flowGraph, proc models.Proc, emp models.Emp, content string, isReal bool) error {
+	pluginConfigsStr, _ := json.Marshal(g.pluginConfigs(uint(proc.ProcessID)))
+	err := s.transitProc(t, proc.ID, models.ProcStatusPassed, content, map[string]interface{}{
+		"auditor_id":   emp.ID,
+		"auditor_name": emp.Name,
+		"auditor_dept": emp.Dept.DeptName,
+		"content":      content,
+		"beizhu":       string(pluginConfigsStr),
+		"is_read":      1,
+		"is_real":      isReal,
+	})
+	if err != nil {
+		return err
+	}
+	process, ok := g.process(proc.ProcessID)
+	if !ok {
+		return errors.New("流程步骤不存在")
+	}
+	// 加签人通过后回到发起加签的待办
+	if isSigner(proc) {
+		s.cancelDeadlines(t, proc)
+		origin, settle, err := s.resumeSigned(t, process, proc)
+		if err != nil || !settle {
+			return err
+		}
+		origin.Entry = proc.Entry
+		proc = origin
+	}
+	// 按步骤的审批方式汇总，未满足通过条件时等待其他审批人
+	passed, err := s.settleApproval(t, &proc.Entry, g, process, proc)
+	if err != nil || !passed {
+		return err
+	}
+	return s.transfer(t, &proc.Entry, proc)
+}
+
+// Reject 驳回，按步骤的驳回策略终止流程
+func (s *Service) Reject(procID uint, empID uint, content string) error {
+	return s.transaction(func(t *flowTx) error {
+		proc, err := s.pendingProc(t, procID, empID)
+		if err != nil {
+			return err
 		}
-		if flowlink.ID == 0 {
-			return errors.New("未找到符合条件的流转条件，无法流转")
-		}
-		var withFlowlink models.Flowlink
-		facades.Orm().Query().Model(&models.Flowlink{}).With("NextProcess").Where("id=?", flowlink.ID).Find(&withFlowlink)
-		auditor_ids := w.GetProcessAuditorIds(proc.Entry, withFlowlink.NextProcessID)
-		if len(auditor_ids) == 0 {
-			return errors.New("未找到下一步骤审批人")
-		}
-		auditors := []models.Emp{}
-		tx.Model(&models.Emp{}).Where("id IN (?)", auditor_ids).With("Dept").Find(&auditors)
-		if len(auditors) == 0 {
-			return errors.New("未找到下一步骤审批人")
-		}
-		curr_time := carbon.NewDateTime(carbon.Now())
-		for _, auditor := range auditors {
-			tx.Model(&models.Proc{}).Create(&models.Proc{
-				EntryID:     proc.EntryID,
-				FlowID:      cast.ToInt(proc.FlowID),
-				ProcessID:   withFlowlink.NextProcessID,
-				ProcessName: withFlowlink.NextProcess.ProcessName,
-				EmpID:       cast.ToInt(auditor.ID),
-				EmpName:     auditor.Name,
-				DeptName:    auditor.Dept.DeptName,
-				Circle:      proc.Entry.Circle,
-				Status:      0,
-				IsRead:      0,
-				Concurrence: curr_time,
-			})
-			//通知下一个审批人
-			//通知发起人，被驳回
-			ins := NewBaseWorkflow()
-			ins.NotifyNextAuditor(auditor.ID)
-		}
-		procEntry := models.Entry{}
-		tx.Model(&models.Entry{}).Where("id=?", proc.EntryID).Find(&procEntry)
-		procEntry.ProcessID = cast.ToUint(flowlink.NextProcessID)
-		tx.Model(&models.Entry{}).Where("id=?", procEntry.ID).Save(&procEntry)
-		//判断是否存在父进程
-		if proc.Entry.Pid > 0 {
-			proc2Entry := models.Entry{}
-			tx.Model(&models.Entry{}).Where("id=?", proc.EntryID).Find(&proc2Entry)
-			partentEntry := models.Entry{}
-			tx.Model(&models.Entry{}).Where("pid=?", proc.ID).Find(&partentEntry)
-			partentEntry.Child = flowlink.NextProcessID
-			tx.Model(&models.Entry{}).Where("id=?", partentEntry.ID).Save(&partentEntry)
+		var emp models.Emp
+		if err = t.Preload("Dept").First(&emp, empID).Error; err != nil {
+			return errors.New("未找到审批人员工信息")
 		}
-	} else {
-		fklink := models.Flowlink{}
-		tx.Model(&models.Flowlink{}).With("Process").With("NextProcess").Where("process_id=?", proc.ProcessID).
-			Where("type=?", "Condition").Find(&fklink)
-		if fklink.Process.ChildFlowID > 0 {
-			// 创建子流程
-			child_entry := models.Entry{}
-			tx.Model(&models.Entry{}).With("Flow").
-				With("Process").With("EnterProcess").
-				With("Emp.Dept").
-				Where("pid=?", proc.Entry.ID).
-				Where("circle=?", proc.Entry.Circle).Find(&child_entry)
-			if child_entry.ID == 0 {
-				new_child_entry := models.Entry{
-					Title:          proc.Entry.Title,
-					FlowID:         cast.ToUint(fklink.Process.ChildFlowID),
-					EmpID:          cast.ToUint(proc.Entry.EmpID),
-					Status:         0,
-					Pid:            cast.ToInt(proc.Entry.ID),
-					Circle:         proc.Entry.Circle,
-					EnterProcessID: cast.ToInt(fklink.ProcessID),
-					EnterProcID:    cast.ToInt(proc.ID),
-				}
-				tx.Model(&models.Entry{}).Create(&new_child_entry)
-
-				tx.Model(&models.Entry{}).Where("id=?", new_child_entry.ID).
-					With("Flow").
-					With("Process").With("EnterProcess").
-					With("Emp.Dept").
-					Find(&new_child_entry)
-				child_entry = new_child_entry
-			}
+		t.actAs(emp)
+		return s.reject(t, proc, emp, content, emp.ID == uint(proc.EmpID))
+	})
+}
 
-			child_flowlink := models.Flowlink{}
-			exec_sql := "SELECT * FROM flowlinks AS f " +
-				"WHERE f.flow_id = (SELECT child_flow_id FROM processes WHERE id = ? AND f.type = 'Condition' " +
-				"AND EXISTS (SELECT * FROM processes AS p WHERE p.id = f.process_id AND p.position = 0) " +
-				"ORDER BY f.sort ASC " +
-				"LIMIT 1);"
-			tx.Raw(exec_sql, fklink.ProcessID).Scan(&child_flowlink)
-			tx.Model(&models.Flowlink{}).Where("id=?", child_flowlink.ID).With("Process").
-				With("NextProcess").Find(&child_flowlink)
-			err := w.SetFirstProcessAuditor(child_entry, child_flowlink)
-			if err != nil {
-				return err
-			}
-			tx.Model(&models.Entry{}).Where("id=?", child_entry.Pid).Update("child", child_entry.ProcessID)
-		} else {
-			if fklink.NextProcessID == -1 {
-				//最后一步
-				tx.Model(&models.Entry{}).Where("id=?", proc.EntryID).Update(models.Entry{
-					Status:    9,
-					ProcessID: fklink.ProcessID,
-				})
-
-				if proc.Entry.Pid > 0 {
-					if proc.Entry.EnterProcess.ChildAfter == 1 {
-						//同时结束父流程
-						parentEntry := models.Entry{}
-						tx.Model(&models.Entry{}).Where("id=?", proc.Entry.Pid).Find(&parentEntry)
-						map_entry := make(map[string]interface{})
-						map_entry["status"] = 9
-						map_entry["child"] = 0
-						tx.Model(&models.Entry{}).Where("id=?", parentEntry.ID).Update(&map_entry)
-						//通知发起人，审批结束
-						ins := NewBaseWorkflow()
-						ins.NotifySendOne(proc.Entry.ID)
-					} else {
-						//	进入设置的父流程步骤
-						if proc.Entry.EnterProcess.ChildBackProcess > 0 {
-							w.goToProcess(*proc.Entry.ParentEntry, proc.Entry.EnterProcess.ChildBackProcess)
-							proc.Entry.ParentEntry.ProcessID = cast.ToUint(proc.Entry.EnterProcess.ChildBackProcess)
-							//	通知设置的父流程步骤中的审批人
-							//ins := NewBaseWorkflow()
-							//ins.NotifySendOne(cast.ToUint(proc.AuditorID))
-						} else {
-							//默认进入父流程步骤下一步
-							parentFlowlink := models.Flowlink{}
-							tx.Model(&models.Flowlink{}).Where("process_id=?", proc.Entry.EnterProcessID).
-								Where("type=?", "Condition").Find(&parentFlowlink)
-							if parentFlowlink.NextProcessID == -1 {
-								parentEntry := models.Entry{}
-								tx.Model(&models.Entry{}).Where("id=?", proc.Entry.Pid).Find(&parentEntry)
-								map_entry := make(map[string]interface{})
-								map_entry["process_id"] = cast.ToUint(proc.Entry.EnterProcess.ChildBackProcess)
-								map_entry["status"] = 9
-								map_entry["child"] = 0
-								tx.Model(&models.Entry{}).Where("id=?", parentEntry.ID).Update(&map_entry)
-
-								var notifyProc models.Proc
-								tx.Model(&models.Proc{}).Where("id=?", proc.ID).With("Emp").Find(&notifyProc)
-								//通知发起人，审批结束
-								ins := NewBaseWorkflow()
-								ins.NotifySendOne(proc.Entry.EmpID)
-							} else {
-								w.goToProcess(*proc.Entry.ParentEntry, parentFlowlink.NextProcessID)
-								proc.Entry.ParentEntry.ProcessID = cast.ToUint(parentFlowlink.NextProcessID)
-								parentEntry := models.Entry{}
-								tx.Model(&models.Entry{}).Where("id=?", proc.Entry.Pid).Find(&parentEntry)
-								map_entry := make(map[string]interface{})
-								map_entry["process_id"] = parentFlowlink.NextProcessID
-								map_entry["status"] = 0
-								tx.Model(&models.Entry{}).Where("id=?", parentEntry.ID).Update(&map_entry)
-								//通知到下一个审批人
-								ins := NewBaseWorkflow()
-								ins.NotifySendOne(cast.ToUint(proc.AuditorID))
-							}
-						}
-						pentry := models.Entry{}
-						tx.Model(&models.Entry{}).Where("id=?", proc.Entry.ParentEntry.ID).Find(&pentry)
-						map_entry := make(map[string]interface{})
-						map_entry["child"] = 0
-						tx.Model(&models.Entry{}).Where("id=?", pentry.ID).Save(&map_entry)
-
-					}
-				} else {
-					var notifyProc models.Proc
-					tx.Model(&models.Proc{}).Where("id=?", proc.ID).Find(&notifyProc)
-				}
-			} else {
-				auditor_ids := w.GetProcessAuditorIds(proc.Entry, fklink.NextProcessID)
-				auditors := []models.Emp{}
-				tx.Model(&models.Emp{}).Where("id in (?)", auditor_ids).With("Dept").Find(&auditors)
-				if len(auditors) < 1 {
-					return errors.New("未找到下一步步骤审批人")
-				}
-				for _, auditor := range auditors {
-					tx.Model(&models.Proc{}).Create(&models.Proc{
-						EntryID:     proc.Entry.ID,
-						FlowID:      cast.ToInt(proc.FlowID),
-						ProcessID:   cast.ToInt(fklink.NextProcessID),
-						ProcessName: fklink.NextProcess.ProcessName,
-						EmpID:       cast.ToInt(auditor.ID),
-						EmpName:     auditor.Name,
-						DeptName:    auditor.Dept.DeptName,
-						Circle:      proc.Entry.Circle,
-						Concurrence: carbon.NewDateTime(carbon.Now()),
-						Status:      0,
-						IsRead:      0,
-					})
-					//通知下一个审批人
-					ins := NewBaseWorkflow()
-					ins.NotifyNextAuditor(auditor.ID)
-				}
-				tx.Model(&models.Entry{}).Where("id=?", proc.Entry.ID).Update("process_id", cast.ToUint(fklink.NextProcessID))
-				//	判断是否存在父进程
-				var parentEntry models.Entry
-				tx.Model(&models.Entry{}).Where("id=?", proc.Entry.Pid).Find(&parentEntry)
-				if parentEntry.Pid > 0 {
-					parentEntry.Child = cast.ToInt(fklink.NextProcessID)
-					tx.Model(&models.Entry{}).Where("id=?", parentEntry.ID).Save(&parentEntry)
-				}
-			}
+// reject 驳回待办，按驳回策略汇总，步骤驳回后终止流程；isReal 为审核人本人处理
+func (s *Service) reject(t *flowTx, proc models.Proc, emp models.Emp, content string, isReal bool) error {
+	if err := s.logEvent(t, &proc.Entry, procEvent(EventReject, proc, content)); err != nil {
+		return err
+	}
+	err := s.transitProc(t, proc.ID, models.ProcStatusRejected, content, map[string]interface{}{
+		"auditor_id":   emp.ID,
+		"auditor_name": emp.Name,
+		"auditor_dept": emp.Dept.DeptName,
+		"content":      content,
+		"is_read":      1,
+		"is_real":      isReal,
+	})
+	if err != nil {
+		return err
+	}
+	g, err := s.entryGraph(t, &proc.Entry)
+	if err != nil {
+		return err
+	}
+	process, ok := g.process(proc.ProcessID)
+	if !ok {
+		return errors.New("流程步骤不存在")
+	}
+	// 加签人驳回视为发起加签的审批人驳回
+	if isSigner(proc) {
+		s.cancelDeadlines(t, proc)
+		origin, err := s.rejectSigned(t, proc)
+		if err != nil {
+			return err
+		}
+		origin.Entry = proc.Entry
+		proc = origin
+	}
+	// 按驳回策略，步骤仍可能通过时等待其他审批人
+	holds, err := s.rejectionHolds(t, &proc.Entry, g, process, proc)
+	if err != nil {
+		return err
+	}
+	if holds {
+		s.cancelDeadlines(t, proc)
+		return nil
+	}
+	if err = s.cancelBatchDeadlines(t, proc); err != nil {
+		return err
+	}
+	if err = s.transitEntry(t, &proc.Entry, models.EntryStatusRejected, content, nil); err != nil {
+		return err
+	}
+	if err = s.cancelBranches(t, proc.EntryID, nil, "流程已驳回"); err != nil {
+		return err
+	}
+	if err = s.carbonCopy(t, &proc.Entry, g, 0); err != nil {
+		return err
+	}
+	if proc.Entry.Pid > 0 {
+		if err = s.childRejected(t, &proc.Entry, proc.ProcessID); err != nil {
+			return err
 		}
 	}
+	initiator := proc.Entry.EmpID
+	t.afterCommit(func() {
+		_ = s.wf.NotifySendOne(initiator)
+	})
+	return nil
+}
 
-	var plugin_configs []official_plugin.PluginConfig
-	tx.Model(official_plugin.PluginConfig{}).Where("process_id=?", process_id).Find(&plugin_configs)
+// pendingProc 查找当前人员可处理的待办，审核人本人或其当前受托人均可处理
+func (s *Service) pendingProc(t *flowTx, procID uint, empID uint) (models.Proc, error) {
+	var proc models.Proc
+	if err := t.Select("id", "entry_id").First(&proc, procID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return proc, errors.New("待办不存在")
+		}
+		return proc, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	// 先锁定流程再读取待办，同一流程的审批依次处理，汇总时不会遗漏同时提交的审批
+	entry, err := lockEntry(t, proc.EntryID)
+	if err != nil {
+		return proc, err
+	}
+	if err = t.Scopes(forUpdate).First(&proc, procID).Error; err != nil {
+		return proc, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	proc.Entry = entry
+	if proc.Status == models.ProcStatusSuspended {
+		return proc, errors.New("已加签，请等待加签人处理")
+	}
+	if proc.Status != models.ProcStatusPending {
+		return proc, errors.New("该步骤已处理")
+	}
+	if proc.Entry.Status == models.EntryStatusSuspended {
+		return proc, errors.New("流程已挂起，暂停处理")
+	}
+	if proc.Entry.Status != models.EntryStatusRunning {
+		return proc, errors.New("流程已结束，无法审批")
+	}
+	if !canActFor(t.DB, proc, empID) {
+		return proc, errors.New("无权处理该步骤")
+	}
+	return proc, nil
+}
 
-	plugin_configs_str, _ := json.Marshal(plugin_configs)
+// saveEntryData 保存表单数据，覆盖同名字段
+func (s *Service) saveEntryData(t *flowTx, entry models.Entry, data map[string]string) error {
+	return s.writeEntryData(t, entry, data, false)
+}
 
-	tx.Model(&models.Proc{}).
-		Where("entry_id=?", proc.EntryID).
-		Where("process_id=?", proc.ProcessID).
-		Where("circle=?", proc.Entry.Circle).
-		Where("status=?", 0).Update(models.Proc{
-		Status:      1,
-		AuditorID:   cast.ToInt(emp.ID),
-		AuditorName: emp.Name,
+// replaceEntryData 以 data 替换流程的全部表单数据
+func (s *Service) replaceEntryData(t *flowTx, entry models.Entry, data map[string]string) error {
+	return s.writeEntryData(t, entry, data, true)
+}
+
+// writeEntryData 写入表单数据并记录数据变更事件，replace 时删除 data 中没有的字段
+func (s *Service) writeEntryData(t *flowTx, entry models.Entry, data map[string]string, replace bool) error {
+	g, err := s.entryGraph(t, &entry)
+	if err != nil {
+		return err
+	}
+	types := g.fieldTypes()
+	data = normalizeEntryData(types, data)
+	diff := dataDiff(entryDataMap(t, entry.ID), data, replace)
+	if len(diff) == 0 {
+		return nil
+	}
+	changed := make([]string, 0, len(diff))
+	for field := range diff {
+		changed = append(changed, field)
+	}
+	if err := t.Where("entry_id=?", entry.ID).Where("field_name IN (?)", changed).Delete(&models.EntryData{}).Error; err != nil {
+		return fmt.Errorf("数据库删除错误: %v", err)
+	}
+	values := make(map[string]*string, len(diff))
+	for field, change := range diff {
+		values[field] = change[1]
+	}
+	if err := writeTableCells(t, g, entry, values); err != nil {
+		return err
+	}
+	diffStr, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	if err = s.logEvent(t, &entry, models.EntryEvent{Action: EventData, DataDiff: string(diffStr)}); err != nil {
+		return err
+	}
+	fields := make([]string, 0, len(data))
+	for field := range data {
+		if change := diff[field]; change[1] != nil {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	sort.Strings(fields)
+	entryDatas := make([]models.EntryData, 0, len(fields))
+	for _, field := range fields {
+		entryData := models.EntryData{
+			EntryID:    int(entry.ID),
+			FlowID:     int(entry.FlowID),
+			FieldName:  field,
+			FieldValue: data[field],
+		}
+		typeEntryData(&entryData, types[field])
+		entryDatas = append(entryDatas, entryData)
+	}
+	if err := t.Create(&entryDatas).Error; err != nil {
+		return fmt.Errorf("数据库插入错误: %v", err)
+	}
+	return nil
+}
+
+// startEntry 进入流程第一步，第一步未指定审批人时由发起人自动通过
+func (s *Service) startEntry(t *flowTx, entry *models.Entry) error {
+	g, err := s.entryGraph(t, entry)
+	if err != nil {
+		return err
+	}
+	starts := g.starts()
+	if len(starts) == 0 {
+		return errors.New("流程未设置第一步骤")
+	}
+	first := starts[0]
+	if len(g.auditorLinks(first.ID)) > 0 {
+		return s.goToProcess(t, entry, 0, int(first.ID))
+	}
+
+	proc, err := s.initiatorProc(t, entry, first)
+	if err != nil {
+		return err
+	}
+	return s.transfer(t, entry, proc)
+}
+
+// initiatorProc 发起人处理第一步骤的记录
+func (s *Service) initiatorProc(t *flowTx, entry *models.Entry, first models.Process) (models.Proc, error) {
+	var emp models.Emp
+	t.Preload("Dept").First(&emp, entry.EmpID)
+	proc := models.Proc{
+		EntryID:     entry.ID,
+		FlowID:      int(entry.FlowID),
+		ProcessID:   int(first.ID),
+		ProcessName: first.ProcessName,
+		EmpID:       int(emp.ID),
+		EmpName:     emp.Name,
 		DeptName:    emp.Dept.DeptName,
-		Content:     content,
-		Beizhu:      string(plugin_configs_str),
-		Concurrence: carbon.NewDateTime(carbon.Now()),
-	})
-	FlowID := cast.ToUint(proc.FlowID)
-	ProcessID := cast.ToUint(proc.ProcessID)
+		AuditorID:   int(emp.ID),
+		AuditorName: emp.Name,
+		AuditorDept: emp.Dept.DeptName,
+		Status:      models.ProcStatusPassed,
+		IsRead:      1,
+		IsReal:      true,
+		Circle:      entry.Circle,
+		Concurrence: carbon.NewTimestamp(carbon.Now()),
+	}
+	if err := t.Create(&proc).Error; err != nil {
+		return proc, fmt.Errorf("数据库插入错误: %v", err)
+	}
+	return proc, nil
+}
 
-	w.ExecPluginMethod("DistributePlugin", FlowID, ProcessID)
+// transfer 当前步骤处理完成后的流转：转入子流程，或按条件进入下一步骤
+func (s *Service) transfer(t *flowTx, entry *models.Entry, proc models.Proc) error {
+	g, err := s.entryGraph(t, entry)
+	if err != nil {
+		return err
+	}
+	process, ok := g.process(proc.ProcessID)
+	if !ok {
+		return errors.New("流程步骤不存在")
+	}
+	if err = s.carbonCopy(t, entry, g, process.ID); err != nil {
+		return err
+	}
+	if process.ChildFlowID > 0 {
+		return s.startChild(t, entry, proc, process)
+	}
+	// 退回的步骤处理完成后直接回到驳回步骤
+	if returned, err := s.returnToRejecter(t, entry, proc.BranchID); err != nil || returned {
+		return err
+	}
+	flowlink, err := s.nextFlowlink(t, entry, int(process.ID))
+	if err != nil {
+		return err
+	}
+	return s.goToProcess(t, entry, proc.BranchID, flowlink.NextProcessID)
+}
 
-	return nil
+// nextFlowlink 按顺序判断步骤的流转条件，返回第一条满足条件的流转
+func (s *Service) nextFlowlink(t *flowTx, entry *models.Entry, processID int) (models.Flowlink, error) {
+	g, err := s.entryGraph(t, entry)
+	if err != nil {
+		return models.Flowlink{}, err
+	}
+	flowlinks := g.conditions(uint(processID))
+	if len(flowlinks) == 0 {
+		return models.Flowlink{}, errors.New("未设置流转条件，无法流转，请联系流程设置人员")
+	}
+	if len(flowlinks) == 1 {
+		return flowlinks[0], nil
+	}
+	env := conditionEnv(t.DB, entry)
+	for _, flowlink := range flowlinks {
+		if flowlink.Expression == "" {
+			return models.Flowlink{}, errors.New("未设置流转条件，无法流转，请联系流程设置人员")
+		}
+		ok, err := matchExpression(flowlink.Expression, env)
+		if err != nil {
+			return models.Flowlink{}, err
+		}
+		if ok {
+			return flowlink, nil
+		}
+	}
+	return models.Flowlink{}, errors.New("未找到符合条件的流转条件，无法流转")
 }
-*/
 
-/*
-func (w *Workflow) goToProcess(entry models.Entry, processID int) error {
-	auditor_ids := w.GetProcessAuditorIds(entry, processID)
-	auditors := []models.Emp{}
-	err := facades.Orm().Query().Model(&models.Emp{}).With("Dept").Where("id in (?)", auditor_ids).Find(&auditors)
+// goToProcess 进入指定步骤，为步骤审批人生成待办；branchID 为所在并行分支，0为主干；processID 为 -1 时流程结束
+func (s *Service) goToProcess(t *flowTx, entry *models.Entry, branchID int, processID int) error {
+	if processID == -1 {
+		if branchID > 0 {
+			return errors.New("并行分支未经过合并网关，无法结束流程")
+		}
+		return s.finish(t, entry)
+	}
+	g, err := s.entryGraph(t, entry)
 	if err != nil {
 		return err
 	}
-	if len(auditors) < 1 {
-		return errors.New("未找到下一步步骤审批人")
+	process, ok := g.process(processID)
+	if !ok {
+		return errors.New("流程步骤不存在")
+	}
+	switch process.GatewayType {
+	case GatewayFork:
+		return s.fork(t, entry, g, branchID, process)
+	case GatewayJoin:
+		return s.join(t, entry, branchID, process)
 	}
-	current_time := carbon.NewDateTime(carbon.Now())
-	processName := ""
-	err = facades.Orm().Query().Model(&models.Process{}).Where("id=?", processID).Select("ProcessName").
-		Scan(&processName)
+	auditors, err := s.processAuditors(t, entry, g, processID)
 	if err != nil {
 		return err
 	}
+	fallbackReason := ""
+	if len(auditors) < 1 {
+		// 未找到审批人时按兜底策略处理，跳过或退回时步骤已处理完毕
+		if auditors, fallbackReason, err = s.fallbackAuditors(t, entry, g, branchID, process); err != nil || auditors == nil {
+			return err
+		}
+	}
+	// 依次审批时先只为第一位审批人生成待办
+	if approveMode(process) == ApproveModeSequential {
+		auditors = auditors[:1]
+	}
+	concurrence := s.batchConcurrence(t, entry, processID)
 	for _, auditor := range auditors {
-		err = facades.Orm().Query().Model(&models.Proc{}).Create(&models.Proc{
-			EntryID:     entry.ID,
-			FlowID:      cast.ToInt(entry.FlowID),
-			ProcessID:   cast.ToInt(processID),
-			ProcessName: processName,
-			EmpID:       cast.ToInt(auditor.ID),
-			EmpName:     auditor.Name,
-			DeptName:    auditor.Dept.DeptName,
-			Circle:      entry.Circle,
-			Status:      0,
-			IsRead:      0,
-			Concurrence: current_time,
-		})
-		if err != nil {
+		if err = s.createProc(t, entry, branchID, process, auditor, concurrence); err != nil {
 			return err
 		}
 	}
+	if fallbackReason != "" {
+		err = t.Model(&models.Proc{}).
+			Where("entry_id=?", entry.ID).
+			Where("process_id=?", processID).
+			Where("concurrence=?", concurrence).
+			Update("fallback", fallbackReason).Error
+		if err != nil {
+			return fmt.Errorf("数据库更新错误: %v", err)
+		}
+	}
+	if err = s.moveTo(t, entry, branchID, processID); err != nil {
+		return err
+	}
+	// 子流程所在步骤同步到父流程
+	if entry.Pid > 0 {
+		if err = t.Model(&models.Entry{}).Where("id=?", entry.Pid).Update("child", processID).Error; err != nil {
+			return fmt.Errorf("数据库更新错误: %v", err)
+		}
+	}
+	return s.autoApprove(t, entry, g, process, branchID)
+}
+
+// moveTo 记录当前步骤：主干记录在流程上，并行分支记录在分支上
+func (s *Service) moveTo(t *flowTx, entry *models.Entry, branchID int, processID int) error {
+	event := models.EntryEvent{Action: EventMove, ToProcessID: processID, BranchID: branchID}
+	if branchID > 0 {
+		var branch models.EntryBranch
+		if err := t.First(&branch, branchID).Error; err != nil {
+			return errors.New("并行分支不存在")
+		}
+		if err := t.Model(&models.EntryBranch{}).Where("id=?", branchID).Update("process_id", processID).Error; err != nil {
+			return fmt.Errorf("数据库更新错误: %v", err)
+		}
+		event.FromProcessID = branch.ProcessID
+		return s.logEvent(t, entry, event)
+	}
+	event.FromProcessID = int(entry.ProcessID)
+	entry.ProcessID = uint(processID)
+	if err := t.Model(&models.Entry{}).Where("id=?", entry.ID).Update("process_id", entry.ProcessID).Error; err != nil {
+		return fmt.Errorf("数据库更新错误: %v", err)
+	}
+	return s.logEvent(t, entry, event)
+}
+
+// createProc 为审批人生成待办并通知
+func (s *Service) createProc(t *flowTx, entry *models.Entry, branchID int, process models.Process, auditor models.Emp, concurrence *carbon.Timestamp) error {
+	proc := models.Proc{
+		EntryID:     entry.ID,
+		FlowID:      int(entry.FlowID),
+		ProcessID:   int(process.ID),
+		ProcessName: process.ProcessName,
+		EmpID:       int(auditor.ID),
+		EmpName:     auditor.Name,
+		DeptName:    auditor.Dept.DeptName,
+		Status:      models.ProcStatusPending,
+		IsRead:      0,
+		IsReal:      true,
+		Circle:      entry.Circle,
+		Concurrence: concurrence,
+		Deadline:    procDeadline(process.LimitTime),
+		BranchID:    branchID,
+	}
+	return s.insertProc(t, &proc, process.LimitTime)
+}
+
+// insertProc 保存待办，加入超时队列并通知审批人
+func (s *Service) insertProc(t *flowTx, proc *models.Proc, limitTime int) error {
+	// 审核人设置了委托时，同时记录受托人，两人均可处理
+	s.applyDelegation(t, proc)
+	if err := t.Create(proc).Error; err != nil {
+		return fmt.Errorf("数据库插入错误: %v", err)
+	}
+	s.enqueueDeadline(t, proc.ID, limitTime)
+	auditorID := uint(proc.EmpID)
+	t.afterCommit(func() {
+		_ = s.wf.NotifyNextAuditor(auditorID)
+	})
 	return nil
 }
-*/
 
-/*
-func (w *Workflow) Pass(process_id int, user models.Emp, content string) error {
-	return w.Transfer(process_id, user, content)
+// finish 流程结束，按抄送设置抄送，存在父流程时按子流程设置回到父流程
+func (s *Service) finish(t *flowTx, entry *models.Entry) error {
+	if err := s.transitEntry(t, entry, models.EntryStatusCompleted, "审批通过", nil); err != nil {
+		return err
+	}
+	g, err := s.entryGraph(t, entry)
+	if err != nil {
+		return err
+	}
+	if err = s.carbonCopy(t, entry, g, 0); err != nil {
+		return err
+	}
+	//通知发起人，审批结束
+	initiator := entry.EmpID
+	t.afterCommit(func() {
+		_ = s.wf.NotifySendOne(initiator)
+	})
+	if entry.Pid == 0 {
+		return nil
+	}
+	return s.backToParent(t, entry)
 }
-*/
-/*
 
-func (w *Workflow) UnPass(proc_id int, user models.Emp, content string) {
-	var proc models.Proc
-	query := facades.Orm().Query()
-	var emp models.Emp
-	query.Model(&models.Emp{}).Where("user_id=?", user.ID).Find(&emp)
-	query.Model(&models.Proc{}).Where("id=?", proc_id).With("Entry").Find(&proc)
-	todoProc := models.Proc{}
-	query.Model(&models.Proc{}).
-		Where("entry_id=?", proc.EntryID).
-		Where("process_id=?", proc.ProcessID).
-		Where("circle=?", proc.Entry.Circle).
-		Where("status=?", 0).Find(&todoProc)
-	todoProc.Status = 1
-	todoProc.AuditorID = cast.ToInt(emp.ID)
-	todoProc.AuditorName = user.Name
-	todoProc.AuditorDept = user.Dept.DeptName
-	todoProc.Concurrence = carbon.NewDateTime(carbon.Now())
-	todoProc.Content = content
-	todoProc.IsRead = 1
-	todoProc.Status = -1
-	query.Model(&models.Proc{}).Where("id=?", todoProc.ID).Save(&todoProc)
-	query.Model(&models.Entry{}).Where("id=?", proc.EntryID).Update("status", -1)
-	if proc.Entry.Pid > 0 {
-		var parentEntry models.Entry
-		query.Model(&models.Entry{}).Where("id=?", proc.Entry.Pid).Find(&parentEntry)
-		parentEntry.Child = proc.ProcessID
-		parentEntry.Status = -1
-		query.Model(&models.Entry{}).Where("id=?", parentEntry.ID).Save(&parentEntry)
+// startChild 转入子流程，步骤设置了列表字段时按列表的每一行发起多实例子流程
+func (s *Service) startChild(t *flowTx, entry *models.Entry, proc models.Proc, process models.Process) error {
+	var childFlow models.Flow
+	if err := t.First(&childFlow, process.ChildFlowID).Error; err != nil {
+		return errors.New("子流程不存在")
+	}
+	if !childFlow.IsPublish {
+		return errors.New("子流程未发布，无法发起")
+	}
+	if process.ChildListField != "" {
+		return s.startChildren(t, entry, proc, process, childFlow)
 	}
-	ins := NewBaseWorkflow()
-	ins.NotifySendOne(proc.Entry.EmpID)
+	child, err := s.createChild(t, entry, proc, process, childFlow, 0, nil)
+	if err != nil {
+		return err
+	}
+	return s.startEntry(t, &child)
+}
+
+// createChild 创建子流程，子流程沿用父流程的表单数据，row 为多实例子流程对应行的数据
+func (s *Service) createChild(t *flowTx, entry *models.Entry, proc models.Proc, process models.Process, childFlow models.Flow, seq int, row map[string]string) (models.Entry, error) {
+	child := models.Entry{
+		Title:          entry.Title,
+		FlowID:         childFlow.ID,
+		FlowVersionID:  childFlow.VersionID,
+		EmpID:          entry.EmpID,
+		Status:         models.EntryStatusRunning,
+		Pid:            int(entry.ID),
+		Circle:         entry.Circle,
+		EnterProcessID: int(process.ID),
+		EnterProcID:    int(proc.ID),
+		ChildSeq:       seq,
+	}
+	if err := t.Create(&child).Error; err != nil {
+		return child, fmt.Errorf("数据库插入错误: %v", err)
+	}
+	err := s.logEvent(t, &child, models.EntryEvent{Action: EventStart, ProcID: proc.ID, Content: fmt.Sprintf("由父流程%d发起", entry.ID)})
+	if err != nil {
+		return child, err
+	}
+	data := entryDataMap(t, entry.ID)
+	for field, value := range row {
+		data[field] = value
+	}
+	return child, s.saveEntryData(t, child, data)
+}
+
+// backToParent 子流程结束后，父流程同时结束、进入设置的返回步骤或默认进入下一步骤；
+// 多实例子流程在通过数量满足要求后才回到父流程
+func (s *Service) backToParent(t *flowTx, child *models.Entry) error {
+	var parent models.Entry
+	if err := t.First(&parent, child.Pid).Error; err != nil {
+		return errors.New("父流程不存在")
+	}
+	g, err := s.entryGraph(t, &parent)
+	if err != nil {
+		return err
+	}
+	enterProcess, ok := g.process(child.EnterProcessID)
+	if !ok {
+		return errors.New("子流程进入步骤不存在")
+	}
+	if enterProcess.ChildListField != "" {
+		done, err := s.settleChildren(t, &parent, enterProcess, child.EnterProcID)
+		if err != nil || !done {
+			return err
+		}
+	}
+	return s.leaveChildStep(t, &parent, enterProcess, child.EnterProcID)
+}
+
+// leaveChildStep 子流程步骤完成后，按子流程设置继续父流程
+func (s *Service) leaveChildStep(t *flowTx, parent *models.Entry, enterProcess models.Process, enterProcID int) error {
+	parent.Child = 0
+	if err := t.Model(&models.Entry{}).Where("id=?", parent.ID).Update("child", 0).Error; err != nil {
+		return fmt.Errorf("数据库更新错误: %v", err)
+	}
+	// 子流程在父流程的并行分支中发起时，回到该分支
+	var enterProc models.Proc
+	t.Select("branch_id").Limit(1).Find(&enterProc, enterProcID)
+	if enterProcess.ChildAfter == 1 {
+		if enterProc.BranchID > 0 {
+			return errors.New("并行分支中的子流程不能同时结束父流程")
+		}
+		//同时结束父流程
+		return s.finish(t, parent)
+	}
+	if enterProcess.ChildBackProcess > 0 {
+		//进入设置的父流程步骤
+		return s.goToProcess(t, parent, enterProc.BranchID, enterProcess.ChildBackProcess)
+	}
+	//默认进入父流程步骤下一步
+	flowlink, err := s.nextFlowlink(t, parent, int(enterProcess.ID))
+	if err != nil {
+		return err
+	}
+	return s.goToProcess(t, parent, enterProc.BranchID, flowlink.NextProcessID)
+}
 
+// childRejected 子流程被驳回，父流程随之驳回；多实例子流程剩余数量仍能满足要求时父流程继续等待
+func (s *Service) childRejected(t *flowTx, child *models.Entry, processID int) error {
+	var parent models.Entry
+	if err := t.First(&parent, child.Pid).Error; err != nil {
+		return errors.New("父流程不存在")
+	}
+	g, err := s.entryGraph(t, &parent)
+	if err != nil {
+		return err
+	}
+	if enterProcess, ok := g.process(child.EnterProcessID); ok && enterProcess.ChildListField != "" {
+		holds, err := s.childrenHold(t, &parent, enterProcess, child.EnterProcID)
+		if err != nil || holds {
+			return err
+		}
+	}
+	return s.transitEntry(t, &parent, models.EntryStatusRejected, "子流程已驳回", map[string]interface{}{
+		"child": processID,
+	})
 }
-*/
 
 // 执行插件方法
 func (w *Workflow) ExecPluginMethod(plugin_name string, flowID uint, processID uint) error {
 	ctor := GetCollectorIns()
+	if ctor == nil {
+		return nil
+	}
 	return ctor.DoPluginsExec(plugin_name, flowID, processID)
 }
 
@@ -637,10 +928,16 @@ type Workflow struct {
 	mutex sync.Mutex
 }
 
-func NewService(c *config.Config) *Service {
-	return nil
+func NewService(db *gorm.DB, dq *queue.DelayQueue) *Service {
+	return &Service{
+		db: db,
+		dq: dq,
+		wf: NewBaseWorkflow(),
+	}
 }
 
-var Module = fx.Provide(
-	NewService,
+var Module = fx.Options(
+	fx.Provide(NewService),
+	fx.Invoke(runDeadlineWorker),
+	fx.Invoke(runEntryDataMigration),
 )
@@ -0,0 +1,193 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// 加签方式
+const (
+	SignTypeBefore   = "before"   // 前加签：加签人先处理，通过后回到本人
+	SignTypeAfter    = "after"    // 后加签：本人处理后，加签人通过才算本人通过
+	SignTypeTransfer = "transfer" // 转办：交由他人代替本人处理
+)
+
+// isSigner 是否加签人的待办，加签人的意见并入发起加签的待办，不单独计入审批人数
+func isSigner(proc models.Proc) bool {
+	return proc.SignType == SignTypeBefore || proc.SignType == SignTypeAfter
+}
+
+// tallyMembers 参与审批方式汇总的待办：排除加签人及已取消的待办，转办后的待办代替原待办计入
+func tallyMembers(batch []models.Proc) []models.Proc {
+	members := make([]models.Proc, 0, len(batch))
+	for _, p := range batch {
+		if p.Status == models.ProcStatusCancelled || isSigner(p) {
+			continue
+		}
+		members = append(members, p)
+	}
+	return members
+}
+
+// AddSignBefore 前加签：加签人先处理，加签人通过后待办回到本人
+func (s *Service) AddSignBefore(procID uint, empID uint, signerID uint, reason string) error {
+	return s.sign(procID, empID, signerID, SignTypeBefore, reason, "")
+}
+
+// AddSignAfter 后加签：本人先给出处理意见，加签人通过后本人才算通过
+func (s *Service) AddSignAfter(procID uint, empID uint, signerID uint, reason string, content string) error {
+	return s.sign(procID, empID, signerID, SignTypeAfter, reason, content)
+}
+
+// Reassign 转办：待办交由他人代替本人处理，按步骤的审批方式计入
+func (s *Service) Reassign(procID uint, empID uint, toEmpID uint, reason string) error {
+	return s.sign(procID, empID, toEmpID, SignTypeTransfer, reason, "")
+}
+
+// sign 加签或转办：处理本人的待办，并为加签人或转办人生成同批次的待办
+func (s *Service) sign(procID uint, empID uint, signerID uint, signType string, reason string, content string) error {
+	return s.transaction(func(t *flowTx) error {
+		proc, err := s.pendingProc(t, procID, empID)
+		if err != nil {
+			return err
+		}
+		var emp models.Emp
+		if err = t.Preload("Dept").First(&emp, empID).Error; err != nil {
+			return errors.New("未找到审批人员工信息")
+		}
+		var signer models.Emp
+		if err = t.Preload("Dept").First(&signer, signerID).Error; err != nil {
+			return errors.New("未找到加签人员工信息")
+		}
+		if signer.ID == emp.ID || int(signer.ID) == proc.EmpID {
+			return errors.New("不能加签或转办给自己")
+		}
+		batch, err := s.stepBatch(t, proc)
+		if err != nil {
+			return err
+		}
+		for _, p := range batch {
+			if p.EmpID == int(signer.ID) && (p.Status == models.ProcStatusPending || p.Status == models.ProcStatusSuspended) {
+				return errors.New("该员工已在处理本步骤")
+			}
+		}
+		g, err := s.entryGraph(t, &proc.Entry)
+		if err != nil {
+			return err
+		}
+		process, ok := g.process(proc.ProcessID)
+		if !ok {
+			return errors.New("流程步骤不存在")
+		}
+		t.actAs(emp)
+		event := procEvent(EventSign, proc, fmt.Sprintf("加签给%s：%s", signer.Name, reason))
+		if signType == SignTypeTransfer {
+			event = procEvent(EventReassign, proc, fmt.Sprintf("转办给%s：%s", signer.Name, reason))
+		}
+		if err = s.logEvent(t, &proc.Entry, event); err != nil {
+			return err
+		}
+
+		status, statusReason := models.ProcStatusSuspended, reason
+		updates := map[string]interface{}{
+			"auditor_id":   emp.ID,
+			"auditor_name": emp.Name,
+			"auditor_dept": emp.Dept.DeptName,
+			"is_read":      1,
+			"is_real":      emp.ID == uint(proc.EmpID),
+			"deadline":     0,
+		}
+		switch signType {
+		case SignTypeAfter:
+			updates["content"] = content
+		case SignTypeTransfer:
+			status = models.ProcStatusCancelled
+			updates["content"] = fmt.Sprintf("转办给%s", signer.Name)
+			statusReason = updates["content"].(string)
+		}
+		if err = s.transitProc(t, proc.ID, status, statusReason, updates); err != nil {
+			return err
+		}
+		s.cancelDeadlines(t, proc)
+
+		signed := models.Proc{
+			EntryID:     proc.EntryID,
+			FlowID:      proc.FlowID,
+			ProcessID:   proc.ProcessID,
+			ProcessName: proc.ProcessName,
+			EmpID:       int(signer.ID),
+			EmpName:     signer.Name,
+			DeptName:    signer.Dept.DeptName,
+			Status:      models.ProcStatusPending,
+			IsRead:      0,
+			IsReal:      true,
+			Circle:      proc.Circle,
+			Concurrence: proc.Concurrence,
+			Deadline:    procDeadline(process.LimitTime),
+			SignType:    signType,
+			SignFromID:  int(proc.ID),
+			SignReason:  reason,
+			BranchID:    proc.BranchID,
+		}
+		// 加签人转办时，转办人接替加签人，处理后仍回到发起加签的待办
+		if signType == SignTypeTransfer && isSigner(proc) {
+			signed.SignType = proc.SignType
+			signed.SignFromID = proc.SignFromID
+		}
+		return s.insertProc(t, &signed, process.LimitTime)
+	})
+}
+
+// resumeSigned 加签人通过后回到发起加签的待办：前加签时待办恢复待处理，返回 false；
+// 后加签时发起加签的待办随之通过，逐级向上直到普通待办，返回该待办和 true
+func (s *Service) resumeSigned(t *flowTx, process models.Process, proc models.Proc) (models.Proc, bool, error) {
+	for isSigner(proc) {
+		var origin models.Proc
+		if err := t.First(&origin, proc.SignFromID).Error; err != nil {
+			return proc, false, errors.New("加签来源待办不存在")
+		}
+		if proc.SignType == SignTypeBefore {
+			deadline := procDeadline(process.LimitTime)
+			err := s.transitProc(t, origin.ID, models.ProcStatusPending, "加签人已通过", map[string]interface{}{
+				"is_read":  0,
+				"deadline": deadline,
+			})
+			if err != nil {
+				return proc, false, err
+			}
+			s.enqueueDeadline(t, origin.ID, process.LimitTime)
+			for _, id := range []uint{uint(origin.EmpID), resolveDelegate(t.DB, uint(origin.EmpID), uint(origin.FlowID))} {
+				if id > 0 {
+					auditorID := id
+					t.afterCommit(func() {
+						_ = s.wf.NotifyNextAuditor(auditorID)
+					})
+				}
+			}
+			return origin, false, nil
+		}
+		if err := s.transitProc(t, origin.ID, models.ProcStatusPassed, "加签人已通过", nil); err != nil {
+			return proc, false, err
+		}
+		origin.Status = models.ProcStatusPassed
+		proc = origin
+	}
+	return proc, true, nil
+}
+
+// rejectSigned 加签人驳回时，发起加签的待办逐级随之驳回，返回最初的普通待办
+func (s *Service) rejectSigned(t *flowTx, proc models.Proc) (models.Proc, error) {
+	for isSigner(proc) {
+		var origin models.Proc
+		if err := t.First(&origin, proc.SignFromID).Error; err != nil {
+			return proc, errors.New("加签来源待办不存在")
+		}
+		if err := s.transitProc(t, origin.ID, models.ProcStatusRejected, "加签人已驳回", nil); err != nil {
+			return proc, err
+		}
+		origin.Status = models.ProcStatusRejected
+		proc = origin
+	}
+	return proc, nil
+}
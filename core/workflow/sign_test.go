@@ -0,0 +1,86 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// signFlow 发起 → A(auditors) → 结束
+func signFlow(e *testEnv, process models.Process, auditors string) *models.Entry {
+	flowID := e.flow("sign")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	process.ProcessName = "A"
+	a := e.step(flowID, process, auditors, false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, -1, "")
+	return e.start(flowID, nil)
+}
+
+// 前加签：加签人通过后待办回到本人，本人通过后步骤才通过
+func TestSignBefore(t *testing.T) {
+	e := newTestEnv(t)
+	entry := signFlow(e, models.Process{}, "2")
+	bobProc := e.pending(entry.ID, empBob)
+	if err := e.s.AddSignBefore(bobProc, empBob, empBob, "请确认"); err == nil {
+		t.Fatal("不应可以加签给自己")
+	}
+	e.must(e.s.AddSignBefore(bobProc, empBob, empCarol, "请确认"))
+	if got := e.procStatus(bobProc); got != models.ProcStatusSuspended {
+		t.Fatalf("加签后本人的待办应挂起，实际为%s", got)
+	}
+	e.pass(entry.ID, empCarol)
+	e.expectStatus(entry.ID, models.EntryStatusRunning)
+	if e.pending(entry.ID, empBob) != bobProc {
+		t.Fatalf("加签人通过后应回到本人的待办；待办 %s", e.procs(entry.ID))
+	}
+	e.pass(entry.ID, empBob)
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
+
+// 后加签：加签人通过后本人随之通过
+func TestSignAfter(t *testing.T) {
+	e := newTestEnv(t)
+	entry := signFlow(e, models.Process{}, "2")
+	bobProc := e.pending(entry.ID, empBob)
+	e.must(e.s.AddSignAfter(bobProc, empBob, empCarol, "请复核", "同意"))
+	e.expectStatus(entry.ID, models.EntryStatusRunning)
+	e.pass(entry.ID, empCarol)
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+	var proc models.Proc
+	e.must(e.db.First(&proc, bobProc).Error)
+	if proc.Status != models.ProcStatusPassed || proc.Content != "同意" {
+		t.Fatalf("本人的待办应以加签时的意见通过，实际 %s %q", proc.Status, proc.Content)
+	}
+}
+
+// 加签人驳回时发起加签的待办随之驳回
+func TestSignerReject(t *testing.T) {
+	e := newTestEnv(t)
+	entry := signFlow(e, models.Process{}, "2")
+	bobProc := e.pending(entry.ID, empBob)
+	e.must(e.s.AddSignAfter(bobProc, empBob, empCarol, "请复核", "同意"))
+	e.must(e.s.Reject(e.pending(entry.ID, empCarol), empCarol, "no"))
+	e.expectStatus(entry.ID, models.EntryStatusRejected)
+	if got := e.procStatus(bobProc); got != models.ProcStatusRejected {
+		t.Fatalf("发起加签的待办应随之驳回，实际为%s", got)
+	}
+}
+
+// 转办后由转办人代替本人计入会签
+func TestReassign(t *testing.T) {
+	e := newTestEnv(t)
+	entry := signFlow(e, models.Process{ApproveMode: ApproveModeAll}, "2,3")
+	bobProc := e.pending(entry.ID, empBob)
+	if err := e.s.Reassign(bobProc, empBob, empCarol, "出差"); err == nil {
+		t.Fatal("不应可以转办给已在处理本步骤的员工")
+	}
+	e.must(e.s.Reassign(bobProc, empBob, empDave, "出差"))
+	if got := e.procStatus(bobProc); got != models.ProcStatusCancelled {
+		t.Fatalf("转办后本人的待办应取消，实际为%s", got)
+	}
+	e.pass(entry.ID, empCarol)
+	e.expectStatus(entry.ID, models.EntryStatusRunning)
+	e.pass(entry.ID, empDave)
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
@@ -0,0 +1,378 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow/expression"
+	"strings"
+)
+
+// 模拟经过的步骤类型
+const (
+	SimStepStart   = "start"   // 第一步由发起人提交
+	SimStepApprove = "approve" // 审批步骤
+	SimStepChild   = "child"   // 审批通过后转入子流程
+	SimStepSkip    = "skip"    // 未找到审批人自动跳过
+	SimStepFork    = "fork"    // 并行拆分
+	SimStepJoin    = "join"    // 并行合并
+	SimStepEnd     = "end"     // 流程结束
+)
+
+// maxSimSteps 模拟经过的步骤数上限，避免流转形成回路时无限模拟
+const maxSimSteps = 200
+
+// SimAuditor 模拟解析出的审批人
+type SimAuditor struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	DeptName string `json:"dept_name"`
+}
+
+// SimStep 模拟经过的步骤，按经过顺序排列，并行分支依次展开
+type SimStep struct {
+	ProcessID     int          `json:"process_id"` // 流程结束时为-1
+	ProcessName   string       `json:"process_name"`
+	Kind          string       `json:"kind"`
+	Branch        string       `json:"branch"` // 所在并行分支，如 1、1.2，主干为空
+	Auditors      []SimAuditor `json:"auditors"`
+	ApproveMode   string       `json:"approve_mode,omitempty"`
+	Fallback      string       `json:"fallback,omitempty"` // 未找到审批人时的兜底处理
+	ChildFlowID   int          `json:"child_flow_id,omitempty"`
+	ChildFlowName string       `json:"child_flow_name,omitempty"`
+}
+
+// SimCandidate 一条流转的判断结果
+type SimCandidate struct {
+	FlowlinkID    uint   `json:"flowlink_id"`
+	NextProcessID int    `json:"next_process_id"`
+	Expression    string `json:"expression"`
+	Matched       bool   `json:"matched"`
+	Error         string `json:"error,omitempty"`
+}
+
+// SimDecision 步骤的流转判断，并行拆分时可进入多个步骤
+type SimDecision struct {
+	ProcessID   int            `json:"process_id"`
+	ProcessName string         `json:"process_name"`
+	Branch      string         `json:"branch"`
+	Candidates  []SimCandidate `json:"candidates"`
+	Chosen      []int          `json:"chosen"`
+}
+
+// SimDeadEnd 无法继续流转之处
+type SimDeadEnd struct {
+	ProcessID   int    `json:"process_id"`
+	ProcessName string `json:"process_name"`
+	Branch      string `json:"branch"`
+	Reason      string `json:"reason"`
+}
+
+// Simulation 流程模拟结果
+type Simulation struct {
+	Steps     []SimStep     `json:"steps"`
+	Decisions []SimDecision `json:"decisions"`
+	DeadEnds  []SimDeadEnd  `json:"dead_ends"`
+	Completed bool          `json:"completed"` // 能否到达流程结束
+}
+
+// simulator 模拟时的流程上下文，全程只读不写
+type simulator struct {
+	s      *Service
+	t      *flowTx
+	g      *flowGraph
+	ctx    *AuditorContext
+	env    *expression.Env
+	result *Simulation
+	steps  int
+}
+
+// Simulate 以给定的发起人和表单数据模拟流程（按当前编辑中的定义）：假设每个步骤都审批通过，
+// 沿流转条件及审批人设置走完流程，返回经过的步骤、流转判断、解析出的审批人及无法继续流转之处，不写入任何数据
+func (s *Service) Simulate(flowID uint, initiator models.Emp, data map[string]string) (*Simulation, error) {
+	t := &flowTx{DB: s.db}
+	g, err := loadGraph(t.DB, flowID)
+	if err != nil {
+		return nil, err
+	}
+	starts := g.starts()
+	if len(starts) == 0 {
+		return nil, errors.New("流程未设置第一步骤")
+	}
+	if initiator.Dept.ID == 0 && initiator.DeptID > 0 {
+		t.First(&initiator.Dept, initiator.DeptID)
+	}
+	if data == nil {
+		data = map[string]string{}
+	}
+	entry := models.Entry{
+		Title:  g.Flow.FlowName,
+		FlowID: flowID,
+		EmpID:  initiator.ID,
+		Circle: 1,
+		Status: models.EntryStatusRunning,
+	}
+	m := &simulator{
+		s:      s,
+		t:      t,
+		g:      g,
+		ctx:    &AuditorContext{DB: t.DB, Entry: &entry, initiator: &initiator, data: data},
+		env:    newConditionEnv(t.DB, &entry, initiator, formValues(g, data)),
+		result: &Simulation{Steps: []SimStep{}, Decisions: []SimDecision{}, DeadEnds: []SimDeadEnd{}},
+	}
+	first := starts[0]
+	if len(g.auditorLinks(first.ID)) > 0 {
+		_, m.result.Completed = m.walk(int(first.ID), "")
+		return m.result, nil
+	}
+	// 第一步未指定审批人时由发起人提交
+	m.result.Steps = append(m.result.Steps, SimStep{
+		ProcessID:   int(first.ID),
+		ProcessName: first.ProcessName,
+		Kind:        SimStepStart,
+		Auditors:    []SimAuditor{{ID: initiator.ID, Name: initiator.Name, DeptName: initiator.Dept.DeptName}},
+	})
+	if next, ok := m.next(first, ""); ok {
+		_, m.result.Completed = m.walk(next, "")
+	}
+	return m.result, nil
+}
+
+// walk 从指定步骤开始模拟，到达流程结束时返回 -1；在并行分支中到达合并网关时返回网关步骤；无法继续时返回 false
+func (m *simulator) walk(processID int, branch string) (int, bool) {
+	visited := make(map[int]bool)
+	for {
+		if processID == -1 {
+			if branch != "" {
+				m.deadEnd(models.Process{}, branch, "并行分支未经过合并网关，无法结束流程")
+				return 0, false
+			}
+			m.result.Steps = append(m.result.Steps, SimStep{ProcessID: -1, ProcessName: "结束", Kind: SimStepEnd, Auditors: []SimAuditor{}})
+			return -1, true
+		}
+		process, ok := m.g.process(processID)
+		if !ok {
+			m.deadEnd(models.Process{Model: models.Model{ID: uint(processID)}}, branch, "流程步骤不存在")
+			return 0, false
+		}
+		if visited[processID] {
+			m.deadEnd(process, branch, "流转形成回路，审批通过后会再次进入该步骤")
+			return 0, false
+		}
+		visited[processID] = true
+		if m.steps++; m.steps > maxSimSteps {
+			m.deadEnd(process, branch, "经过的步骤过多，请检查流转设置")
+			return 0, false
+		}
+		switch process.GatewayType {
+		case GatewayFork:
+			if processID, ok = m.fork(process, branch); !ok {
+				return 0, false
+			}
+			continue
+		case GatewayJoin:
+			if branch == "" {
+				m.deadEnd(process, branch, "合并网关不在并行分支中")
+				return 0, false
+			}
+			return processID, true
+		}
+		if !m.approve(process, branch) {
+			return 0, false
+		}
+		if process.ChildFlowID > 0 {
+			if process.ChildAfter == 1 {
+				if branch != "" {
+					m.deadEnd(process, branch, "并行分支中的子流程不能同时结束父流程")
+					return 0, false
+				}
+				processID = -1
+				continue
+			}
+			if process.ChildBackProcess > 0 {
+				processID = process.ChildBackProcess
+				continue
+			}
+		}
+		if processID, ok = m.next(process, branch); !ok {
+			return 0, false
+		}
+	}
+}
+
+// approve 模拟进入审批步骤：解析审批人，未找到时按兜底策略处理；返回能否继续流转
+func (m *simulator) approve(process models.Process, branch string) bool {
+	step := SimStep{
+		ProcessID:   int(process.ID),
+		ProcessName: process.ProcessName,
+		Kind:        SimStepApprove,
+		Branch:      branch,
+		Auditors:    []SimAuditor{},
+		ApproveMode: approveMode(process),
+	}
+	if process.ChildFlowID > 0 {
+		step.Kind = SimStepChild
+		step.ChildFlowID = process.ChildFlowID
+		var childFlow models.Flow
+		m.t.Select("id", "flow_name").Limit(1).Find(&childFlow, process.ChildFlowID)
+		step.ChildFlowName = childFlow.FlowName
+	}
+	auditors, err := m.s.linkAuditors(m.t, m.ctx, m.g.auditorLinks(process.ID))
+	if err == nil && len(auditors) == 0 {
+		switch noAuditorPolicy(m.g.Flow, process) {
+		case NoAuditorSkip:
+			step.Kind = SimStepSkip
+			step.Fallback = "未找到审批人，自动跳过"
+			m.result.Steps = append(m.result.Steps, step)
+			return true
+		case NoAuditorInitiator:
+			step.Fallback = "未找到审批人，退回发起人"
+			err = errors.New("未找到审批人，流程将退回发起人")
+		default:
+			auditors, step.Fallback, err = fallbackTargets(m.t, m.ctx, m.g, process)
+		}
+	}
+	for _, a := range auditors {
+		step.Auditors = append(step.Auditors, SimAuditor{ID: a.ID, Name: a.Name, DeptName: a.Dept.DeptName})
+	}
+	m.result.Steps = append(m.result.Steps, step)
+	if err != nil {
+		m.deadEnd(process, branch, err.Error())
+		return false
+	}
+	return true
+}
+
+// next 按顺序判断步骤的流转条件，进入第一条满足条件的流转
+func (m *simulator) next(process models.Process, branch string) (int, bool) {
+	links := m.g.conditions(process.ID)
+	decision := SimDecision{
+		ProcessID:   int(process.ID),
+		ProcessName: process.ProcessName,
+		Branch:      branch,
+		Candidates:  []SimCandidate{},
+		Chosen:      []int{},
+	}
+	reason := ""
+	for _, l := range links {
+		c := SimCandidate{FlowlinkID: l.ID, NextProcessID: l.NextProcessID, Expression: l.Expression}
+		switch {
+		case len(links) == 1:
+			c.Matched = true
+		case l.Expression == "":
+			c.Error = "未设置流转条件"
+		default:
+			ok, err := matchExpression(l.Expression, m.env)
+			if err != nil {
+				c.Error = err.Error()
+			}
+			c.Matched = ok
+		}
+		// 与实际流转一致：在第一条满足条件的流转之前判断出错时无法流转
+		if len(decision.Chosen) == 0 && reason == "" {
+			if c.Error != "" {
+				reason = "流转条件判断出错：" + c.Error
+			} else if c.Matched {
+				decision.Chosen = append(decision.Chosen, l.NextProcessID)
+			}
+		}
+		decision.Candidates = append(decision.Candidates, c)
+	}
+	if len(links) > 0 {
+		m.result.Decisions = append(m.result.Decisions, decision)
+	}
+	switch {
+	case len(links) == 0:
+		reason = "未设置流转条件，无法流转"
+	case reason == "" && len(decision.Chosen) == 0:
+		reason = "未找到符合条件的流转条件，无法流转"
+	}
+	if reason != "" {
+		m.deadEnd(process, branch, reason)
+		return 0, false
+	}
+	return decision.Chosen[0], true
+}
+
+// fork 模拟并行拆分：依次模拟每个进入的分支，到达合并网关的分支数满足要求时从合并网关继续
+func (m *simulator) fork(process models.Process, branch string) (int, bool) {
+	decision := SimDecision{
+		ProcessID:   int(process.ID),
+		ProcessName: process.ProcessName,
+		Branch:      branch,
+		Candidates:  []SimCandidate{},
+		Chosen:      []int{},
+	}
+	for _, l := range m.g.conditions(process.ID) {
+		c := SimCandidate{FlowlinkID: l.ID, NextProcessID: l.NextProcessID, Expression: l.Expression, Matched: true}
+		if src := strings.TrimSpace(l.Expression); src != "" && src != "1" {
+			ok, err := matchExpression(src, m.env)
+			if err != nil {
+				c.Error = err.Error()
+			}
+			c.Matched = ok
+		}
+		if c.Matched {
+			decision.Chosen = append(decision.Chosen, l.NextProcessID)
+		}
+		decision.Candidates = append(decision.Candidates, c)
+	}
+	m.result.Decisions = append(m.result.Decisions, decision)
+	m.result.Steps = append(m.result.Steps, SimStep{
+		ProcessID:   int(process.ID),
+		ProcessName: process.ProcessName,
+		Kind:        SimStepFork,
+		Branch:      branch,
+		Auditors:    []SimAuditor{},
+	})
+	if len(decision.Chosen) == 0 {
+		m.deadEnd(process, branch, fmt.Sprintf("并行网关[%s]没有满足条件的分支，无法流转", process.ProcessName))
+		return 0, false
+	}
+	joins := make(map[int]int)
+	joinID := 0
+	for i, target := range decision.Chosen {
+		sub := fmt.Sprintf("%d", i+1)
+		if branch != "" {
+			sub = branch + "." + sub
+		}
+		if id, ok := m.walk(target, sub); ok && id > 0 {
+			joins[id]++
+			joinID = id
+		}
+	}
+	if len(joins) == 0 {
+		return 0, false
+	}
+	if len(joins) > 1 {
+		m.deadEnd(process, branch, "并行分支到达了不同的合并网关")
+		return 0, false
+	}
+	join, _ := m.g.process(joinID)
+	need := join.JoinThreshold
+	if need <= 0 || need > len(decision.Chosen) {
+		need = len(decision.Chosen)
+	}
+	if joins[joinID] < need {
+		m.deadEnd(join, branch, fmt.Sprintf("到达合并网关的分支数为%d，少于所需的%d", joins[joinID], need))
+		return 0, false
+	}
+	m.result.Steps = append(m.result.Steps, SimStep{
+		ProcessID:   joinID,
+		ProcessName: join.ProcessName,
+		Kind:        SimStepJoin,
+		Branch:      branch,
+		Auditors:    []SimAuditor{},
+	})
+	return m.next(join, branch)
+}
+
+// deadEnd 记录无法继续流转之处
+func (m *simulator) deadEnd(process models.Process, branch string, reason string) {
+	m.result.DeadEnds = append(m.result.DeadEnds, SimDeadEnd{
+		ProcessID:   int(process.ID),
+		ProcessName: process.ProcessName,
+		Branch:      branch,
+		Reason:      reason,
+	})
+}
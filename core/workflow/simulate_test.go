@@ -0,0 +1,85 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// simulateFlow 发起 → A(发起人部门主管) →(amount > 1000) B(carol) → 结束；withDefault 为 false 时 A 的另一条流转为 amount < 0，没有默认流转
+func simulateFlow(e *testEnv, withDefault bool) uint {
+	tmpl := models.Template{TemplateName: "报销"}
+	e.must(e.db.Create(&tmpl).Error)
+	e.must(e.db.Create(&models.TemplateForm{TemplateID: tmpl.ID, Field: "amount", FieldName: "金额", FieldType: "number"}).Error)
+	flowID := e.flow("simulate")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("template_id", tmpl.ID).Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "", false)
+	e.must(e.db.Create(&models.Flowlink{FlowID: flowID, Type: AuditorDirector, ProcessID: a}).Error)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "3", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, int(b), "amount > 1000")
+	if withDefault {
+		e.link(flowID, a, -1, "")
+	} else {
+		e.link(flowID, a, -1, "amount < 0")
+	}
+	e.link(flowID, b, -1, "")
+	return flowID
+}
+
+// simSteps 模拟经过的步骤摘要：步骤名/审批人
+func simSteps(sim *Simulation) string {
+	items := make([]string, 0, len(sim.Steps))
+	for _, step := range sim.Steps {
+		names := make([]string, 0, len(step.Auditors))
+		for _, a := range step.Auditors {
+			names = append(names, a.Name)
+		}
+		items = append(items, fmt.Sprintf("%s/%s", step.ProcessName, strings.Join(names, ",")))
+	}
+	return strings.Join(items, " ")
+}
+
+// 按表单数据模拟流转路径及审批人，不写入任何数据
+func TestSimulate(t *testing.T) {
+	e := newTestEnv(t)
+	flowID := simulateFlow(e, true)
+	var alice models.Emp
+	e.must(e.db.First(&alice, empAlice).Error)
+	for amount, want := range map[string]string{
+		"500":  "发起/alice A/bob 结束/",
+		"5000": "发起/alice A/bob B/carol 结束/",
+	} {
+		sim, err := e.s.Simulate(flowID, alice, map[string]string{"amount": amount})
+		e.must(err)
+		if got := simSteps(sim); got != want || !sim.Completed {
+			t.Fatalf("金额%s模拟经过 %s，期望 %s", amount, got, want)
+		}
+	}
+	var entries, procs int64
+	e.db.Model(&models.Entry{}).Count(&entries)
+	e.db.Model(&models.Proc{}).Count(&procs)
+	if entries != 0 || procs != 0 {
+		t.Fatal("模拟不应写入流程或待办")
+	}
+}
+
+// 没有满足条件的流转时记录无法继续之处及各条流转的判断结果
+func TestSimulateDeadEnd(t *testing.T) {
+	e := newTestEnv(t)
+	flowID := simulateFlow(e, false)
+	var alice models.Emp
+	e.must(e.db.First(&alice, empAlice).Error)
+	sim, err := e.s.Simulate(flowID, alice, map[string]string{"amount": "10"})
+	e.must(err)
+	if sim.Completed || len(sim.DeadEnds) != 1 || sim.DeadEnds[0].ProcessName != "A" {
+		t.Fatalf("应在步骤A无法继续流转，实际 %+v", sim.DeadEnds)
+	}
+	last := sim.Decisions[len(sim.Decisions)-1]
+	if len(last.Candidates) != 2 || last.Candidates[0].Matched || last.Candidates[1].Matched || len(last.Chosen) != 0 {
+		t.Fatalf("步骤A的流转条件应判断为不满足，实际 %+v", last)
+	}
+}
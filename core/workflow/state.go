@@ -0,0 +1,232 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// entryTransitions 流程状态转换表：当前状态可以变更为的状态，未列出的状态为终态
+var entryTransitions = map[models.EntryStatus][]models.EntryStatus{
+	models.EntryStatusRunning: {
+		models.EntryStatusCompleted,  // 审批通过
+		models.EntryStatusRejected,   // 驳回
+		models.EntryStatusDraft,      // 退回发起人
+		models.EntryStatusWithdrawn,  // 发起人撤回
+		models.EntryStatusSuspended,  // 挂起
+		models.EntryStatusTerminated, // 终止
+	},
+	models.EntryStatusSuspended: {models.EntryStatusRunning, models.EntryStatusTerminated},
+	models.EntryStatusDraft:     {models.EntryStatusRunning, models.EntryStatusTerminated},
+	models.EntryStatusWithdrawn: {models.EntryStatusRunning, models.EntryStatusTerminated},
+}
+
+// procTransitions 待办状态转换表，未列出的状态为终态
+var procTransitions = map[models.ProcStatus][]models.ProcStatus{
+	models.ProcStatusPending: {
+		models.ProcStatusPassed,
+		models.ProcStatusRejected,
+		models.ProcStatusSuspended, // 加签
+		models.ProcStatusCancelled,
+	},
+	models.ProcStatusSuspended: {
+		models.ProcStatusPending, // 加签人处理完成
+		models.ProcStatusPassed,
+		models.ProcStatusRejected,
+		models.ProcStatusCancelled,
+	},
+}
+
+// openProcStatuses 未处理的待办状态
+var openProcStatuses = []models.ProcStatus{models.ProcStatusPending, models.ProcStatusSuspended}
+
+// ErrIllegalTransition 状态转换表不允许的状态变更，可用 errors.Is 判断
+var ErrIllegalTransition = errors.New("非法的状态变更")
+
+// TransitionError 非法的状态变更
+type TransitionError struct {
+	Object string // 流程 或 待办
+	ID     uint
+	From   string
+	To     string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s%d的状态[%s]不能变更为[%s]", e.Object, e.ID, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrIllegalTransition
+}
+
+// canTransit 状态转换表是否允许从 from 变更为 to
+func canTransit[S comparable](table map[S][]S, from S, to S) bool {
+	for _, s := range table[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitEntry 变更流程状态，流程状态的唯一变更入口：按状态转换表校验，记录状态变更并在事务提交后发布事件；
+// fields 为同时更新的其他字段
+func (s *Service) transitEntry(t *flowTx, entry *models.Entry, to models.EntryStatus, reason string, fields map[string]interface{}) error {
+	var current models.Entry
+	if err := t.Select("id", "status").First(&current, entry.ID).Error; err != nil {
+		return errors.New("流程不存在")
+	}
+	if !canTransit(entryTransitions, current.Status, to) {
+		return &TransitionError{Object: "流程", ID: entry.ID, From: current.Status.String(), To: to.String()}
+	}
+	updates := map[string]interface{}{"status": to}
+	for k, v := range fields {
+		updates[k] = v
+	}
+	// 以读取时的状态为条件更新，并发操作已变更状态时不再重复变更
+	res := t.Model(&models.Entry{}).Where("id=?", entry.ID).Where("status=?", current.Status).Updates(updates)
+	if res.Error != nil {
+		return fmt.Errorf("数据库更新错误: %v", res.Error)
+	}
+	if res.RowsAffected != 1 {
+		return &TransitionError{Object: "流程", ID: entry.ID, From: current.Status.String(), To: to.String()}
+	}
+	entry.Status = to
+	err := s.logEvent(t, entry, models.EntryEvent{
+		Action:     EventStatus,
+		FromStatus: int(current.Status),
+		ToStatus:   int(to),
+		Content:    reason,
+	})
+	if err != nil {
+		return err
+	}
+	entryID := entry.ID
+	return s.recordTransition(t, models.StateTransition{
+		EntryID:    entryID,
+		FromStatus: int(current.Status),
+		ToStatus:   int(to),
+		Reason:     reason,
+	}, func() {
+		_ = s.wf.NotifyEntryStatus(entryID)
+	})
+}
+
+// transitEntries 批量变更流程状态
+func (s *Service) transitEntries(t *flowTx, ids []uint, to models.EntryStatus, reason string) error {
+	for _, id := range ids {
+		var entry models.Entry
+		if err := t.First(&entry, id).Error; err != nil {
+			return errors.New("流程不存在")
+		}
+		if err := s.transitEntry(t, &entry, to, reason, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transitProcs 变更符合条件的待办状态，待办状态的唯一变更入口：按状态转换表校验，逐条记录状态变更并在事务提交后发布事件；
+// 返回变更前的待办
+func (s *Service) transitProcs(t *flowTx, scope func(db *gorm.DB) *gorm.DB, to models.ProcStatus, reason string, fields map[string]interface{}) ([]models.Proc, error) {
+	var procs []models.Proc
+	if err := t.Scopes(scope).Order("id asc").Find(&procs).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	if len(procs) == 0 {
+		return procs, nil
+	}
+	ids := make(map[models.ProcStatus][]uint)
+	for _, p := range procs {
+		if !canTransit(procTransitions, p.Status, to) {
+			return nil, &TransitionError{Object: "待办", ID: p.ID, From: p.Status.String(), To: to.String()}
+		}
+		ids[p.Status] = append(ids[p.Status], p.ID)
+	}
+	updates := map[string]interface{}{"status": to}
+	for k, v := range fields {
+		updates[k] = v
+	}
+	// 以读取时的状态为条件更新，并发操作已变更其中的待办时整体失败，避免重复流转
+	for from, group := range ids {
+		res := t.Model(&models.Proc{}).Where("id IN (?)", group).Where("status=?", from).Updates(updates)
+		if res.Error != nil {
+			return nil, fmt.Errorf("数据库更新错误: %v", res.Error)
+		}
+		if res.RowsAffected != int64(len(group)) {
+			return nil, &TransitionError{Object: "待办", ID: group[0], From: from.String(), To: to.String()}
+		}
+	}
+	for _, p := range procs {
+		procID := p.ID
+		err := s.recordTransition(t, models.StateTransition{
+			EntryID:    p.EntryID,
+			ProcID:     procID,
+			FromStatus: int(p.Status),
+			ToStatus:   int(to),
+			Reason:     reason,
+		}, func() {
+			_ = s.wf.NotifyProcStatus(procID)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return procs, nil
+}
+
+// transitProc 变更单个待办的状态
+func (s *Service) transitProc(t *flowTx, procID uint, to models.ProcStatus, reason string, fields map[string]interface{}) error {
+	_, err := s.transitProcs(t, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id=?", procID)
+	}, to, reason, fields)
+	return err
+}
+
+// openProcs 流程中未处理的待办，branchIds 不为空时只含指定并行分支的待办
+func openProcs(entryIds []uint, branchIds ...uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("entry_id IN (?)", entryIds).Where("status IN (?)", openProcStatuses)
+		if len(branchIds) > 0 {
+			db = db.Where("branch_id IN (?)", branchIds)
+		}
+		return db
+	}
+}
+
+// lockEntry 锁定流程行，同一流程的处理依次进行；锁定后以 forUpdate 读取的待办、分支及子流程包含其他事务已提交的变更
+func lockEntry(t *flowTx, entryID uint) (models.Entry, error) {
+	var entry models.Entry
+	if err := t.Scopes(forUpdate).First(&entry, entryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entry, errors.New("流程不存在")
+		}
+		return entry, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return entry, nil
+}
+
+// forUpdate 加锁读取，读到最新提交的数据
+func forUpdate(db *gorm.DB) *gorm.DB {
+	return db.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// recordTransition 追加状态变更记录，事务提交后发布事件
+func (s *Service) recordTransition(t *flowTx, record models.StateTransition, publish func()) error {
+	if err := t.Create(&record).Error; err != nil {
+		return fmt.Errorf("数据库插入错误: %v", err)
+	}
+	t.afterCommit(publish)
+	return nil
+}
+
+// Transitions 流程及其待办的状态变更记录，按发生先后排列
+func (s *Service) Transitions(entryID uint) ([]models.StateTransition, error) {
+	var records []models.StateTransition
+	if err := s.db.Where("entry_id=?", entryID).Order("id asc").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,70 @@
+package workflow
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"gorm.io/gorm"
+)
+
+// raceAfterQuery 在下一次查询指定表后执行 sql，模拟读取与更新之间的并发修改
+func (e *testEnv) raceAfterQuery(table string, sql string, args ...interface{}) {
+	e.t.Helper()
+	armed := true
+	err := e.db.Callback().Query().After("gorm:query").Register("test:race", func(db *gorm.DB) {
+		if !armed || db.Statement.Table != table {
+			return
+		}
+		armed = false
+		db.Session(&gorm.Session{NewDB: true}).Exec(sql, args...)
+	})
+	e.must(err)
+	e.t.Cleanup(func() { _ = e.db.Callback().Query().Remove("test:race") })
+}
+
+func twoStepFlow(e *testEnv) *models.Entry {
+	flowID := e.flow("state")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "3", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, int(b), "")
+	e.link(flowID, b, -1, "")
+	return e.start(flowID, nil)
+}
+
+// 读取后待办已被并发处理时变更失败，不重复记录状态变更
+func TestTransitProcsRejectsConcurrentChange(t *testing.T) {
+	e := newTestEnv(t)
+	entry := twoStepFlow(e)
+	procID := e.pending(entry.ID, empBob)
+	var before int64
+	e.db.Model(&models.StateTransition{}).Count(&before)
+
+	e.raceAfterQuery("procs", "UPDATE procs SET status=? WHERE id=?", models.ProcStatusPassed, procID)
+	err := e.s.transaction(func(t *flowTx) error {
+		return e.s.transitProc(t, procID, models.ProcStatusPassed, "ok", nil)
+	})
+	if !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("并发变更后应返回非法的状态变更，实际 %v", err)
+	}
+	var after int64
+	e.db.Model(&models.StateTransition{}).Count(&after)
+	if after != before {
+		t.Fatalf("变更失败时不应记录状态变更，新增 %d 条", after-before)
+	}
+}
+
+// 读取后流程状态已被并发变更时变更失败
+func TestTransitEntryRejectsConcurrentChange(t *testing.T) {
+	e := newTestEnv(t)
+	entry := twoStepFlow(e)
+	e.raceAfterQuery("entries", "UPDATE entries SET status=? WHERE id=?", models.EntryStatusTerminated, entry.ID)
+	err := e.s.transaction(func(t *flowTx) error {
+		return e.s.transitEntry(t, entry, models.EntryStatusCompleted, "审批通过", nil)
+	})
+	if !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("并发变更后应返回非法的状态变更，实际 %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dromara/carbon/v2"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"strconv"
+	"time"
+)
+
+// DeadlineQueue 待办处理期限的延时队列名称，消息为待办ID
+const DeadlineQueue = "workflow:proc_deadline"
+
+// 超时处理方式
+const (
+	TimeoutActionRemind           = "remind"            // 提醒审批人，仍未处理时每隔限定时间重复提醒
+	TimeoutActionEscalateDirector = "escalate_director" // 转交审批人所在部门主管
+	TimeoutActionEscalateManager  = "escalate_manager"  // 转交审批人所在部门经理
+	TimeoutActionApprove          = "approve"           // 自动通过
+	TimeoutActionReject           = "reject"            // 自动驳回
+	TimeoutActionJump             = "jump"              // 跳转至指定步骤
+)
+
+// procDeadline 步骤限定时间对应的处理期限时间戳，未设置限定时间时为0
+func procDeadline(limitTime int) int64 {
+	if limitTime <= 0 {
+		return 0
+	}
+	return carbon.Now().AddSeconds(limitTime).Timestamp()
+}
+
+// enqueueDeadline 事务提交后将待办加入超时队列，重复加入时以最新期限为准
+func (s *Service) enqueueDeadline(t *flowTx, procID uint, limitTime int) {
+	if s.dq == nil || limitTime <= 0 {
+		return
+	}
+	t.afterCommit(func() {
+		if err := s.dq.Add(DeadlineQueue, strconv.Itoa(int(procID)), time.Duration(limitTime)*time.Second); err != nil {
+			zap.S().Warnf("待办%d加入超时队列失败: %v", procID, err)
+		}
+	})
+}
+
+// cancelDeadlines 待办处理后取消其超时任务
+func (s *Service) cancelDeadlines(t *flowTx, procs ...models.Proc) {
+	if s.dq == nil {
+		return
+	}
+	var ids []uint
+	for _, proc := range procs {
+		if proc.Deadline > 0 {
+			ids = append(ids, proc.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	t.afterCommit(func() {
+		for _, id := range ids {
+			_ = s.dq.Remove(DeadlineQueue, strconv.Itoa(int(id)))
+		}
+	})
+}
+
+// cancelBatchDeadlines 取消同批次全部待办的超时任务
+func (s *Service) cancelBatchDeadlines(t *flowTx, proc models.Proc) error {
+	batch, err := s.stepBatch(t, proc)
+	if err != nil {
+		return err
+	}
+	s.cancelDeadlines(t, batch...)
+	return nil
+}
+
+// HandleTimeout 待办超过处理期限时按步骤的超时设置处理，超时事件与处理在同一事务中记录；
+// 自动通过、驳回及跳转由系统处理，待办记为非本人处理；待办已处理或未到期时忽略
+func (s *Service) HandleTimeout(procID uint) error {
+	var proc models.Proc
+	approved := false
+	err := s.transaction(func(t *flowTx) error {
+		if err := t.Select("id", "entry_id").First(&proc, procID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return fmt.Errorf("数据库查询错误: %v", err)
+		}
+		entry, err := lockEntry(t, proc.EntryID)
+		if err != nil {
+			return err
+		}
+		if err = t.Scopes(forUpdate).First(&proc, procID).Error; err != nil {
+			return fmt.Errorf("数据库查询错误: %v", err)
+		}
+		proc.Entry = entry
+		if proc.Status != models.ProcStatusPending || proc.Entry.Status != models.EntryStatusRunning || proc.Deadline == 0 || proc.Deadline > carbon.Now().Timestamp() {
+			return nil
+		}
+		g, err := s.entryGraph(t, &proc.Entry)
+		if err != nil {
+			return err
+		}
+		process, ok := g.process(proc.ProcessID)
+		if !ok {
+			return errors.New("流程步骤不存在")
+		}
+		if process.TimeoutAction == "" {
+			return nil
+		}
+		t.actAs(systemActor)
+		if err = s.logEvent(t, &proc.Entry, procEvent(EventTimeout, proc, process.TimeoutAction)); err != nil {
+			return err
+		}
+		switch process.TimeoutAction {
+		case TimeoutActionRemind:
+			s.remind(t, proc, process)
+		case TimeoutActionEscalateDirector, TimeoutActionEscalateManager:
+			return s.escalate(t, proc, process)
+		case TimeoutActionApprove:
+			content := "超时未处理，系统自动通过"
+			if err = s.logEvent(t, &proc.Entry, procEvent(EventPass, proc, content)); err != nil {
+				return err
+			}
+			approved = true
+			return s.approve(t, g, proc, systemActor, content, false)
+		case TimeoutActionReject:
+			return s.reject(t, proc, systemActor, "超时未处理，系统自动驳回", false)
+		case TimeoutActionJump:
+			return s.jump(t, proc, process)
+		}
+		return nil
+	})
+	if err != nil || !approved {
+		return err
+	}
+	return s.execPlugin(proc, systemActor)
+}
+
+// remind 提醒审批人待办已超时，仍未处理时每隔步骤限定时间再次提醒
+func (s *Service) remind(t *flowTx, proc models.Proc, process models.Process) {
+	s.notifyTimeout(t, proc)
+	s.enqueueDeadline(t, proc.ID, process.LimitTime)
+}
+
+// notifyTimeout 事务提交后提醒审批人待办已超时
+func (s *Service) notifyTimeout(t *flowTx, proc models.Proc) {
+	empID := uint(proc.EmpID)
+	t.afterCommit(func() {
+		_ = s.wf.NotifyTimeout(empID)
+	})
+}
+
+// escalate 超时转交审批人所在部门主管或经理：原待办取消，为上级生成同批次的转办待办，找不到上级时改为提醒
+func (s *Service) escalate(t *flowTx, proc models.Proc, process models.Process) error {
+	var emp models.Emp
+	if err := t.Preload("Dept").First(&emp, proc.EmpID).Error; err != nil {
+		return errors.New("未找到审批人员工信息")
+	}
+	superiorID := emp.Dept.DirectorID
+	if process.TimeoutAction == TimeoutActionEscalateManager {
+		superiorID = emp.Dept.ManagerID
+	}
+	var superior models.Emp
+	if superiorID <= 0 || superiorID == proc.EmpID || t.Preload("Dept").First(&superior, superiorID).Error != nil {
+		s.remind(t, proc, process)
+		return nil
+	}
+	content := fmt.Sprintf("超时未处理，转交给%s", superior.Name)
+	err := s.transitProc(t, proc.ID, models.ProcStatusCancelled, content, map[string]interface{}{
+		"is_real":  false,
+		"content":  content,
+		"deadline": 0,
+	})
+	if err != nil {
+		return err
+	}
+	s.cancelDeadlines(t, proc)
+	escalated := models.Proc{
+		EntryID:       proc.EntryID,
+		FlowID:        proc.FlowID,
+		ProcessID:     proc.ProcessID,
+		ProcessName:   proc.ProcessName,
+		EmpID:         int(superior.ID),
+		EmpName:       superior.Name,
+		DeptName:      superior.Dept.DeptName,
+		Status:        models.ProcStatusPending,
+		IsRead:        0,
+		IsReal:        true,
+		Circle:        proc.Circle,
+		Concurrence:   proc.Concurrence,
+		Deadline:      procDeadline(process.LimitTime),
+		SignType:      SignTypeTransfer,
+		SignFromID:    int(proc.ID),
+		SignReason:    "超时未处理",
+		BranchID:      proc.BranchID,
+		OriginEmpID:   proc.EmpID,
+		OriginEmpName: proc.EmpName,
+	}
+	// 加签人的待办转交时，上级接替加签人，处理后仍回到发起加签的待办
+	if isSigner(proc) {
+		escalated.SignType = proc.SignType
+		escalated.SignFromID = proc.SignFromID
+	}
+	// 多次转交时保留最初的审核人
+	if proc.OriginEmpID > 0 {
+		escalated.OriginEmpID = proc.OriginEmpID
+		escalated.OriginEmpName = proc.OriginEmpName
+	}
+	return s.insertProc(t, &escalated, process.LimitTime)
+}
+
+// jump 超时跳过当前步骤，进入设置的步骤
+func (s *Service) jump(t *flowTx, proc models.Proc, process models.Process) error {
+	content := "超时未处理，系统自动跳转"
+	event := procEvent(EventJump, proc, content)
+	event.ToProcessID = process.TimeoutProcess
+	if err := s.logEvent(t, &proc.Entry, event); err != nil {
+		return err
+	}
+	_, err := s.transitProcs(t, func(db *gorm.DB) *gorm.DB {
+		return db.Where("entry_id=?", proc.EntryID).
+			Where("process_id=?", proc.ProcessID).
+			Where("circle=?", proc.Circle).
+			Where("concurrence=?", proc.Concurrence).
+			Where("status IN (?)", openProcStatuses)
+	}, models.ProcStatusPassed, content, map[string]interface{}{
+		"is_real": false,
+		"content": content,
+	})
+	if err != nil {
+		return err
+	}
+	if err = s.cancelBatchDeadlines(t, proc); err != nil {
+		return err
+	}
+	return s.goToProcess(t, &proc.Entry, proc.BranchID, process.TimeoutProcess)
+}
+
+// OverdueProcs 已超过处理期限仍未处理的待办，empID 为0时查询全部，按期限先后排列
+func (s *Service) OverdueProcs(empID uint, limit int, offset int) ([]models.Proc, int64, error) {
+	query := s.db.Model(&models.Proc{}).
+		Joins("JOIN entries ON entries.id = procs.entry_id").
+		Where("procs.status=?", models.ProcStatusPending).
+		Where("entries.status=?", models.EntryStatusRunning).
+		Where("procs.deadline > ?", 0).
+		Where("procs.deadline <= ?", carbon.Now().Timestamp())
+	if empID > 0 {
+		query = query.Where("procs.emp_id=?", empID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	var procs []models.Proc
+	if err := query.Preload("Entry").Order("procs.deadline asc").Limit(limit).Offset(offset).Find(&procs).Error; err != nil {
+		return nil, 0, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	currentDelegates(s.db, procs)
+	return procs, count, nil
+}
+
+// 超时处理失败后的重试间隔，每次失败加倍，超过重试次数后放弃
+const (
+	deadlineRetryBase  = 10 * time.Second
+	deadlineRetryMax   = 10 * time.Minute
+	deadlineRetryLimit = 10
+)
+
+// deadlineRetryDelay 第 attempt 次失败后再次处理的间隔，超过重试次数时返回 false
+func deadlineRetryDelay(attempt int) (time.Duration, bool) {
+	if attempt > deadlineRetryLimit {
+		return 0, false
+	}
+	delay := deadlineRetryBase
+	for i := 1; i < attempt && delay < deadlineRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > deadlineRetryMax {
+		delay = deadlineRetryMax
+	}
+	return delay, true
+}
+
+// runDeadlineWorker 消费超时队列，处理失败时按重试间隔重新加入队列
+func runDeadlineWorker(lifecycle fx.Lifecycle, s *Service) {
+	if s.dq == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	lifecycle.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				failures := make(map[int]int)
+				for ctx.Err() == nil {
+					err := s.dq.Poll(DeadlineQueue, func(message string) error {
+						procID, err := strconv.Atoi(message)
+						if err != nil {
+							return nil
+						}
+						if err = s.HandleTimeout(uint(procID)); err == nil {
+							delete(failures, procID)
+							return nil
+						}
+						failures[procID]++
+						delay, ok := deadlineRetryDelay(failures[procID])
+						if !ok {
+							delete(failures, procID)
+							zap.S().Errorf("待办%d超时处理失败，已放弃重试: %v", procID, err)
+							return nil
+						}
+						zap.S().Warnf("待办%d超时处理失败，%s后重试: %v", procID, delay, err)
+						if err = s.dq.Add(DeadlineQueue, message, delay); err != nil {
+							zap.S().Errorf("待办%d重新加入超时队列失败: %v", procID, err)
+						}
+						return nil
+					})
+					if err != nil {
+						time.Sleep(s.dq.PollInterval)
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+}
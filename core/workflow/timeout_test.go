@@ -0,0 +1,179 @@
+package workflow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// timeoutFlow 发起 → A(bob，超时按 action 处理) → B(bob) → 结束，流程设置本轮已审批自动通过
+func timeoutFlow(e *testEnv, action string, timeoutProcess int) (uint, uint, uint) {
+	flowID := e.flow("timeout")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("auto_approve", AutoApproveApproved).Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A", LimitTime: 3600, TimeoutAction: action, TimeoutProcess: timeoutProcess}, "2", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "2", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, int(b), "")
+	e.link(flowID, b, -1, "")
+	return flowID, a, b
+}
+
+// expire 将待办的处理期限改为已过期并触发超时处理
+func (e *testEnv) expire(procID uint) error {
+	e.must(e.db.Model(&models.Proc{}).Where("id=?", procID).Update("deadline", 1).Error)
+	return e.s.HandleTimeout(procID)
+}
+
+func (e *testEnv) events(entryID uint, action string) []models.EntryEvent {
+	var events []models.EntryEvent
+	e.db.Where("entry_id=?", entryID).Where("action=?", action).Find(&events)
+	return events
+}
+
+// 超时自动通过由系统处理，不记为审批人本人通过，也不触发审批人后续步骤的自动通过
+func TestTimeoutApproveIsSystemAction(t *testing.T) {
+	e := newTestEnv(t)
+	flowID, a, b := timeoutFlow(e, TimeoutActionApprove, 0)
+	entry := e.start(flowID, nil)
+	procID := e.pending(entry.ID, empBob)
+	e.must(e.expire(procID))
+
+	var proc models.Proc
+	e.must(e.db.First(&proc, procID).Error)
+	if proc.Status != models.ProcStatusPassed || proc.IsReal || proc.AuditorID != 0 {
+		t.Fatalf("超时通过的待办应为系统处理：状态%s is_real=%v auditor_id=%d", proc.Status, proc.IsReal, proc.AuditorID)
+	}
+	if got := e.entry(entry.ID).ProcessID; got != b {
+		t.Fatalf("流程应进入步骤%d，实际为%d（超时步骤%d）", b, got, a)
+	}
+	if e.pending(entry.ID, empBob) == 0 {
+		t.Fatalf("步骤B不应因系统超时通过而自动通过；待办 %s", e.procs(entry.ID))
+	}
+	if events := e.events(entry.ID, EventTimeout); len(events) != 1 || events[0].ActorID != 0 {
+		t.Fatalf("应记录一条系统超时事件，实际 %+v", events)
+	}
+}
+
+// 超时自动驳回由系统处理
+func TestTimeoutRejectIsSystemAction(t *testing.T) {
+	e := newTestEnv(t)
+	flowID, _, _ := timeoutFlow(e, TimeoutActionReject, 0)
+	entry := e.start(flowID, nil)
+	procID := e.pending(entry.ID, empBob)
+	e.must(e.expire(procID))
+
+	e.expectStatus(entry.ID, models.EntryStatusRejected)
+	var proc models.Proc
+	e.must(e.db.First(&proc, procID).Error)
+	if proc.Status != models.ProcStatusRejected || proc.IsReal || proc.AuditorID != 0 {
+		t.Fatalf("超时驳回的待办应为系统处理：状态%s is_real=%v auditor_id=%d", proc.Status, proc.IsReal, proc.AuditorID)
+	}
+}
+
+// 超时处理失败时不记录超时事件，待办保持不变
+func TestTimeoutEventRolledBackWithAction(t *testing.T) {
+	e := newTestEnv(t)
+	flowID, _, _ := timeoutFlow(e, TimeoutActionJump, 999)
+	entry := e.start(flowID, nil)
+	procID := e.pending(entry.ID, empBob)
+	if err := e.expire(procID); err == nil {
+		t.Fatal("跳转到不存在的步骤应失败")
+	}
+	if events := e.events(entry.ID, EventTimeout); len(events) != 0 {
+		t.Fatalf("超时处理失败时不应记录超时事件，实际 %d 条", len(events))
+	}
+	if e.pending(entry.ID, empBob) != procID {
+		t.Fatalf("超时处理失败时待办应保持待处理；待办 %s", e.procs(entry.ID))
+	}
+}
+
+// 未到期或已处理的待办忽略超时消息
+func TestTimeoutIgnoresHandledProc(t *testing.T) {
+	e := newTestEnv(t)
+	flowID, _, _ := timeoutFlow(e, TimeoutActionReject, 0)
+	entry := e.start(flowID, nil)
+	procID := e.pending(entry.ID, empBob)
+	e.must(e.s.HandleTimeout(procID))
+	e.expectStatus(entry.ID, models.EntryStatusRunning)
+	// 本人通过后步骤B按本轮已审批自动通过，流程结束
+	e.must(e.s.Pass(procID, empBob, "ok"))
+	e.must(e.expire(procID))
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+	if events := e.events(entry.ID, EventTimeout); len(events) != 0 {
+		t.Fatalf("已处理的待办不应记录超时事件，实际 %d 条", len(events))
+	}
+}
+
+// 超时转交上级时原待办取消并记录状态变更，上级代替原审批人处理
+func TestTimeoutEscalateToManager(t *testing.T) {
+	e := newTestEnv(t)
+	flowID := e.flow("escalate")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A", LimitTime: 3600, TimeoutAction: TimeoutActionEscalateManager}, "4", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, -1, "")
+	entry := e.start(flowID, nil)
+	procID := e.pending(entry.ID, empDave)
+	e.must(e.expire(procID))
+
+	var proc models.Proc
+	e.must(e.db.First(&proc, procID).Error)
+	if proc.Status != models.ProcStatusCancelled || proc.IsReal {
+		t.Fatalf("转交后原待办应取消：状态%s is_real=%v", proc.Status, proc.IsReal)
+	}
+	var records int64
+	e.db.Model(&models.StateTransition{}).Where("proc_id=?", procID).Where("to_status=?", models.ProcStatusCancelled).Count(&records)
+	if records != 1 {
+		t.Fatalf("原待办应记录一条取消的状态变更，实际 %d 条", records)
+	}
+	escalated := e.pending(entry.ID, empCarol)
+	if escalated == 0 {
+		t.Fatalf("应为部门经理生成待办；待办 %s", e.procs(entry.ID))
+	}
+	var next models.Proc
+	e.must(e.db.First(&next, escalated).Error)
+	if next.OriginEmpID != int(empDave) {
+		t.Fatalf("转交后的待办应记录原审核人%d，实际为%d", empDave, next.OriginEmpID)
+	}
+	e.must(e.s.Pass(escalated, empCarol, "ok"))
+	e.expectStatus(entry.ID, models.EntryStatusCompleted)
+}
+
+// 超时提醒不改变待办，再次到期时继续提醒
+func TestTimeoutRemindRepeats(t *testing.T) {
+	e := newTestEnv(t)
+	flowID, _, _ := timeoutFlow(e, TimeoutActionRemind, 0)
+	entry := e.start(flowID, nil)
+	procID := e.pending(entry.ID, empBob)
+	e.must(e.expire(procID))
+	e.must(e.s.HandleTimeout(procID))
+	if e.pending(entry.ID, empBob) != procID {
+		t.Fatalf("提醒后待办应保持待处理；待办 %s", e.procs(entry.ID))
+	}
+	if events := e.events(entry.ID, EventTimeout); len(events) != 2 {
+		t.Fatalf("每次到期应记录一次超时事件，实际 %d 条", len(events))
+	}
+}
+
+func TestDeadlineRetryDelay(t *testing.T) {
+	cases := []struct {
+		attempt int
+		delay   time.Duration
+		ok      bool
+	}{
+		{1, 10 * time.Second, true},
+		{2, 20 * time.Second, true},
+		{4, 80 * time.Second, true},
+		{8, 10 * time.Minute, true},
+		{deadlineRetryLimit, 10 * time.Minute, true},
+		{deadlineRetryLimit + 1, 0, false},
+	}
+	for _, c := range cases {
+		delay, ok := deadlineRetryDelay(c.attempt)
+		if delay != c.delay || ok != c.ok {
+			t.Fatalf("第%d次失败后重试间隔为%s(%v)，期望%s(%v)", c.attempt, delay, ok, c.delay, c.ok)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow/expression"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// entryTitle 按流程的标题模板及发起的表单数据生成标题，未设置模板时为空
+func (s *Service) entryTitle(t *flowTx, entry *models.Entry, initiator models.Emp, data map[string]string) (string, error) {
+	g, err := s.entryGraph(t, entry)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(g.Flow.TitleTemplate) == "" {
+		return "", nil
+	}
+	return renderTitle(g.Flow.TitleTemplate, newConditionEnv(t.DB, entry, initiator, formValues(g, data)))
+}
+
+// titleExpressions 标题模板中{}内的表达式
+func titleExpressions(tmpl string) ([]string, error) {
+	var exprs []string
+	for rest := tmpl; ; {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			if strings.Contains(rest, "}") {
+				return nil, errors.New("标题模板的{}不匹配")
+			}
+			return exprs, nil
+		}
+		if strings.Contains(rest[:start], "}") {
+			return nil, errors.New("标题模板的{}不匹配")
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return nil, errors.New("标题模板的{}不匹配")
+		}
+		src := strings.TrimSpace(rest[start+1 : start+end])
+		if src == "" {
+			return nil, errors.New("标题模板的{}内不能为空")
+		}
+		exprs = append(exprs, src)
+		rest = rest[start+end+1:]
+	}
+}
+
+// renderTitle 按标题模板生成流程标题，{}内的表达式按流转条件的求值环境计算
+func renderTitle(tmpl string, env *expression.Env) (string, error) {
+	exprs, err := titleExpressions(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	rest := tmpl
+	for _, src := range exprs {
+		start := strings.Index(rest, "{")
+		end := start + strings.Index(rest[start:], "}")
+		b.WriteString(rest[:start])
+		e, err := expression.Compile(src)
+		if err != nil {
+			return "", fmt.Errorf("标题模板表达式[%s]语法错误: %v", src, err)
+		}
+		v, err := e.Eval(env)
+		if err != nil {
+			return "", fmt.Errorf("标题模板表达式[%s]求值错误: %v", src, err)
+		}
+		b.WriteString(titleText(v))
+		rest = rest[end+1:]
+	}
+	b.WriteString(rest)
+	return b.String(), nil
+}
+
+// titleText 表达式结果在标题中的文本，日期不含零点时间，列表以逗号连接
+func titleText(v interface{}) string {
+	switch val := expression.Normalize(v).(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case time.Time:
+		if val.Hour() == 0 && val.Minute() == 0 && val.Second() == 0 {
+			return val.Format("2006-01-02")
+		}
+		return val.Format("2006-01-02 15:04:05")
+	case []interface{}:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			items = append(items, titleText(item))
+		}
+		return strings.Join(items, ",")
+	case string:
+		return val
+	}
+	return fmt.Sprint(v)
+}
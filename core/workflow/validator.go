@@ -0,0 +1,811 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow/expression"
+	"gorm.io/gorm"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FlowErrorLevelError 阻止发布
+	FlowErrorLevelError = "error"
+	// FlowErrorLevelWarning 仅提示，不阻止发布
+	FlowErrorLevelWarning = "warning"
+)
+
+// FlowError 流程定义校验问题，按步骤或流转定位，便于设计器高亮
+type FlowError struct {
+	Level      string `json:"level"`       // error 或 warning
+	Code       string `json:"code"`        // 问题类型
+	ProcessID  uint   `json:"process_id"`  // 所在步骤，流程级问题为0
+	FlowlinkID uint   `json:"flowlink_id"` // 所在流转，步骤级问题为0
+	Message    string `json:"message"`     // 问题说明
+}
+
+// FlowErrors 流程定义校验结果
+type FlowErrors []FlowError
+
+func (e FlowErrors) Error() string {
+	var msgs []string
+	for _, item := range e {
+		if item.Level == FlowErrorLevelError {
+			msgs = append(msgs, item.Message)
+		}
+	}
+	return "流程校验未通过: " + strings.Join(msgs, "; ")
+}
+
+// HasError 是否包含阻止发布的问题
+func (e FlowErrors) HasError() bool {
+	for _, item := range e {
+		if item.Level == FlowErrorLevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionBuiltinVars 条件表达式中除表单字段外可用的变量
+var conditionBuiltinVars = []string{"initiator", "initiator_dept", "circle", "title", "status"}
+
+// flowValidator 流程定义校验
+type flowValidator struct {
+	db   *gorm.DB
+	g    *flowGraph
+	errs FlowErrors
+}
+
+func (v *flowValidator) add(level, code string, processID, flowlinkID uint, format string, args ...interface{}) {
+	v.errs = append(v.errs, FlowError{
+		Level:      level,
+		Code:       code,
+		ProcessID:  processID,
+		FlowlinkID: flowlinkID,
+		Message:    fmt.Sprintf(format, args...),
+	})
+}
+
+// ValidateFlow 校验流程定义，返回全部问题；数据库错误通过 error 返回
+func (s *Service) ValidateFlow(flowID uint) (FlowErrors, error) {
+	g, err := loadGraph(s.db, flowID)
+	if err != nil {
+		return nil, err
+	}
+	return validateGraph(s.db, g)
+}
+
+// validateGraph 校验流程图结构、流转条件、子流程及审批人设置
+func validateGraph(db *gorm.DB, g *flowGraph) (FlowErrors, error) {
+	v := &flowValidator{db: db, g: g, errs: FlowErrors{}}
+	if len(g.Processes) == 0 {
+		v.add(FlowErrorLevelError, "no_process", 0, 0, "流程未设置任何步骤")
+		return v.errs, nil
+	}
+	v.checkStart()
+	v.checkLinks()
+	v.checkReachable()
+	v.checkEndReachable()
+	v.checkConditions()
+	v.checkApproveModes()
+	v.checkTimeouts()
+	v.checkRecallPolicy()
+	v.checkAutoApprove()
+	v.checkCarbonCopies()
+	v.checkGateways()
+	v.checkFieldPerms()
+	v.checkTableFields()
+	v.checkFieldRules()
+	v.checkReservedFields()
+	v.checkTitleTemplate()
+	if err := v.checkNoAuditor(); err != nil {
+		return nil, err
+	}
+	if err := v.checkChildFlows(); err != nil {
+		return nil, err
+	}
+	if err := v.checkAuditors(); err != nil {
+		return nil, err
+	}
+	return v.errs, nil
+}
+
+// checkStart 有且仅有一个第一步骤
+func (v *flowValidator) checkStart() {
+	starts := v.g.starts()
+	if len(starts) == 0 {
+		v.add(FlowErrorLevelError, "no_start", 0, 0, "流程未设置第一步骤")
+	}
+	if len(starts) > 1 {
+		for _, p := range starts {
+			v.add(FlowErrorLevelError, "multiple_start", p.ID, 0, "步骤[%s]与其他步骤同时被设为第一步骤", p.ProcessName)
+		}
+	}
+}
+
+// checkLinks 流转必须指向本流程内的步骤或结束
+func (v *flowValidator) checkLinks() {
+	for _, p := range v.g.Processes {
+		for _, l := range v.g.conditions(p.ID) {
+			if l.NextProcessID == -1 {
+				continue
+			}
+			if _, ok := v.g.process(l.NextProcessID); !ok {
+				v.add(FlowErrorLevelError, "invalid_next_process", p.ID, l.ID, "步骤[%s]的流转指向不存在的步骤%d", p.ProcessName, l.NextProcessID)
+			}
+		}
+		if p.ChildFlowID > 0 && p.ChildAfter != 1 && p.ChildBackProcess > 0 {
+			if _, ok := v.g.process(p.ChildBackProcess); !ok {
+				v.add(FlowErrorLevelError, "invalid_back_process", p.ID, 0, "步骤[%s]的子流程返回步骤%d不存在", p.ProcessName, p.ChildBackProcess)
+			}
+		}
+	}
+}
+
+// checkReachable 从第一步骤出发无法到达的步骤
+func (v *flowValidator) checkReachable() {
+	starts := v.g.starts()
+	if len(starts) == 0 {
+		return
+	}
+	visited := make(map[int]bool)
+	queue := make([]int, 0, len(starts))
+	for _, p := range starts {
+		queue = append(queue, int(p.ID))
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		p, ok := v.g.process(id)
+		if !ok {
+			continue
+		}
+		queue = append(queue, v.g.successors(p)...)
+	}
+	for _, p := range v.g.Processes {
+		if !visited[int(p.ID)] {
+			v.add(FlowErrorLevelError, "unreachable", p.ID, 0, "步骤[%s]无法从第一步骤到达", p.ProcessName)
+		}
+	}
+}
+
+// checkEndReachable 无法到达流程结束的步骤
+func (v *flowValidator) checkEndReachable() {
+	canEnd := make(map[int]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, p := range v.g.Processes {
+			if canEnd[int(p.ID)] {
+				continue
+			}
+			for _, next := range v.g.successors(p) {
+				if next == -1 || canEnd[next] {
+					canEnd[int(p.ID)] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	for _, p := range v.g.Processes {
+		if canEnd[int(p.ID)] {
+			continue
+		}
+		if len(v.g.successors(p)) == 0 {
+			v.add(FlowErrorLevelError, "no_next", p.ID, 0, "步骤[%s]未设置流转", p.ProcessName)
+		} else {
+			v.add(FlowErrorLevelError, "no_end_path", p.ID, 0, "步骤[%s]的流转无法到达流程结束", p.ProcessName)
+		}
+	}
+}
+
+// checkConditions 校验条件表达式语法、引用字段及默认分支
+func (v *flowValidator) checkConditions() {
+	fields := v.templateFields()
+	for _, p := range v.g.Processes {
+		links := v.g.conditions(p.ID)
+		// 并行拆分网关进入全部满足条件的分支，流转条件可以为空且无需默认流转
+		fork := p.GatewayType == GatewayFork
+		hasDefault := false
+		for _, l := range links {
+			src := strings.TrimSpace(l.Expression)
+			if src == "1" {
+				hasDefault = true
+				continue
+			}
+			if src == "" {
+				if len(links) > 1 && !fork {
+					v.add(FlowErrorLevelError, "empty_expression", p.ID, l.ID, "步骤[%s]存在多个流转，流转条件不能为空", p.ProcessName)
+				}
+				continue
+			}
+			if strings.HasPrefix(src, "[") {
+				legacy, err := legacyExpression(src)
+				if err != nil {
+					v.add(FlowErrorLevelError, "invalid_expression", p.ID, l.ID, "步骤[%s]的流转条件语法错误", p.ProcessName)
+					continue
+				}
+				src = legacy
+			}
+			expr, err := expression.Compile(src)
+			if err != nil {
+				v.add(FlowErrorLevelError, "invalid_expression", p.ID, l.ID, "步骤[%s]的流转条件语法错误: %v", p.ProcessName, err)
+				continue
+			}
+			if fields == nil {
+				continue
+			}
+			for _, name := range expr.Vars() {
+				name = strings.SplitN(name, ".", 2)[0]
+				if !fields[name] {
+					v.add(FlowErrorLevelWarning, "unknown_field", p.ID, l.ID, "步骤[%s]的流转条件引用了表单中不存在的字段[%s]", p.ProcessName, name)
+				}
+			}
+		}
+		if len(links) > 1 && !hasDefault && !fork {
+			v.add(FlowErrorLevelWarning, "no_default_branch", p.ID, 0, "步骤[%s]的条件分支未设置默认流转（条件为1），条件都不满足时流程将无法流转", p.ProcessName)
+		}
+	}
+}
+
+// checkApproveModes 校验多人审批方式及驳回策略设置
+func (v *flowValidator) checkApproveModes() {
+	for _, p := range v.g.Processes {
+		switch approveMode(p) {
+		case ApproveModeAny, ApproveModeAll, ApproveModeSequential:
+		case ApproveModePercent:
+			if p.ApproveThreshold < 1 || p.ApproveThreshold > 100 {
+				v.add(FlowErrorLevelError, "invalid_approve_threshold", p.ID, 0, "步骤[%s]按比例审批的通过比例须在1-100之间", p.ProcessName)
+			}
+		case ApproveModeCount:
+			if p.ApproveThreshold < 1 {
+				v.add(FlowErrorLevelError, "invalid_approve_threshold", p.ID, 0, "步骤[%s]按人数审批的通过人数须大于0", p.ProcessName)
+			}
+		default:
+			v.add(FlowErrorLevelError, "invalid_approve_mode", p.ID, 0, "步骤[%s]的审批方式[%s]无法识别", p.ProcessName, p.ApproveMode)
+		}
+		switch p.RejectPolicy {
+		case "", RejectPolicyAny, RejectPolicyUnreachable:
+		default:
+			v.add(FlowErrorLevelError, "invalid_reject_policy", p.ID, 0, "步骤[%s]的驳回策略[%s]无法识别", p.ProcessName, p.RejectPolicy)
+		}
+		switch p.RejectReturn {
+		case "", RejectReturnResume, RejectReturnDirect:
+		default:
+			v.add(FlowErrorLevelError, "invalid_reject_return", p.ID, 0, "步骤[%s]的退回流转方式[%s]无法识别", p.ProcessName, p.RejectReturn)
+		}
+	}
+}
+
+// checkTimeouts 校验步骤的超时处理设置
+func (v *flowValidator) checkTimeouts() {
+	for _, p := range v.g.Processes {
+		switch p.TimeoutAction {
+		case "":
+			continue
+		case TimeoutActionRemind, TimeoutActionEscalateDirector, TimeoutActionEscalateManager, TimeoutActionApprove, TimeoutActionReject:
+		case TimeoutActionJump:
+			if p.TimeoutProcess == int(p.ID) {
+				v.add(FlowErrorLevelError, "invalid_timeout_process", p.ID, 0, "步骤[%s]超时不能跳转至自身", p.ProcessName)
+			} else if _, ok := v.g.process(p.TimeoutProcess); !ok && p.TimeoutProcess != -1 {
+				v.add(FlowErrorLevelError, "invalid_timeout_process", p.ID, 0, "步骤[%s]超时跳转的步骤%d不存在", p.ProcessName, p.TimeoutProcess)
+			}
+		default:
+			v.add(FlowErrorLevelError, "invalid_timeout_action", p.ID, 0, "步骤[%s]的超时处理方式[%s]无法识别", p.ProcessName, p.TimeoutAction)
+			continue
+		}
+		if p.LimitTime <= 0 {
+			v.add(FlowErrorLevelWarning, "no_limit_time", p.ID, 0, "步骤[%s]设置了超时处理但未设置限定时间，超时处理不会生效", p.ProcessName)
+		}
+	}
+}
+
+// checkRecallPolicy 校验流程的撤回策略
+func (v *flowValidator) checkRecallPolicy() {
+	switch v.g.Flow.RecallPolicy {
+	case "", RecallPolicyUnhandled, RecallPolicyRunning, RecallPolicyNever:
+	default:
+		v.add(FlowErrorLevelError, "invalid_recall_policy", 0, 0, "流程的撤回策略[%s]无法识别", v.g.Flow.RecallPolicy)
+	}
+}
+
+// checkFieldPerms 字段权限须引用表单中的字段且权限可识别
+func (v *flowValidator) checkFieldPerms() {
+	fields := make(map[string]bool, len(v.g.Template.TemplateForms))
+	for _, form := range v.g.Template.TemplateForms {
+		fields[form.Field] = true
+	}
+	for _, p := range v.g.Processes {
+		names := make([]string, 0, len(p.FieldPerms))
+		for field := range p.FieldPerms {
+			names = append(names, field)
+		}
+		sort.Strings(names)
+		for _, field := range names {
+			perm := p.FieldPerms[field]
+			if _, ok := fieldPermRank[perm]; !ok {
+				v.add(FlowErrorLevelError, "invalid_field_perm", p.ID, 0, "步骤[%s]的字段[%s]权限[%s]无法识别", p.ProcessName, field, perm)
+			} else if !fields[field] {
+				v.add(FlowErrorLevelWarning, "field_perm_unknown_field", p.ID, 0, "步骤[%s]设置了表单中不存在的字段[%s]的权限", p.ProcessName, field)
+			}
+		}
+	}
+}
+
+// checkTableFields 明细表格须定义列，列字段不能为空或重复，列不能再是明细表格
+func (v *flowValidator) checkTableFields() {
+	for _, form := range v.g.Template.TemplateForms {
+		if form.FieldType != FieldTypeTable {
+			if len(form.FieldColumns) > 0 {
+				v.add(FlowErrorLevelWarning, "table_columns_ignored", 0, 0, "字段[%s]不是明细表格，设置的列将被忽略", formLabel(form))
+			}
+			continue
+		}
+		if len(form.FieldColumns) == 0 {
+			v.add(FlowErrorLevelError, "table_no_columns", 0, 0, "明细表格[%s]未设置任何列", formLabel(form))
+			continue
+		}
+		seen := make(map[string]bool, len(form.FieldColumns))
+		for _, column := range form.FieldColumns {
+			switch {
+			case strings.TrimSpace(column.Field) == "":
+				v.add(FlowErrorLevelError, "table_column_empty", 0, 0, "明细表格[%s]存在字段名为空的列", formLabel(form))
+			case seen[column.Field]:
+				v.add(FlowErrorLevelError, "table_column_duplicate", 0, 0, "明细表格[%s]的列[%s]重复", formLabel(form), column.Field)
+			case column.FieldType == FieldTypeTable:
+				v.add(FlowErrorLevelError, "table_column_nested", 0, 0, "明细表格[%s]的列[%s]不能是明细表格", formLabel(form), column.Field)
+			}
+			seen[column.Field] = true
+		}
+	}
+}
+
+// checkReservedFields 与条件内置变量同名的字段在流转条件中取不到表单值
+func (v *flowValidator) checkReservedFields() {
+	for _, form := range v.g.Template.TemplateForms {
+		for _, name := range conditionBuiltinVars {
+			if form.Field == name {
+				v.add(FlowErrorLevelWarning, "field_reserved", 0, 0, "字段[%s]与条件内置变量同名，流转条件中按内置变量取值", formLabel(form))
+			}
+		}
+	}
+}
+
+// checkFieldRules 字段及明细表格列的自定义规则须为可识别的规则名，范围的上下限须为数字，正则须能解析
+func (v *flowValidator) checkFieldRules() {
+	for _, form := range v.g.Template.TemplateForms {
+		v.checkFormRules(form, formLabel(form))
+		if form.FieldType != FieldTypeTable {
+			continue
+		}
+		for _, column := range form.FieldColumns {
+			v.checkFormRules(columnForm(column), fmt.Sprintf("%s.%s", formLabel(form), formLabel(columnForm(column))))
+		}
+	}
+}
+
+func (v *flowValidator) checkFormRules(form models.TemplateForm, label string) {
+	for _, rule := range form.FieldRules {
+		name, value := strings.TrimSpace(rule.RuleName), strings.TrimSpace(rule.RuleValue)
+		switch name {
+		case "", FieldRuleRequired:
+		case FieldRuleRange:
+			for _, bound := range strings.SplitN(value, ",", 2) {
+				if bound = strings.TrimSpace(bound); bound == "" {
+					continue
+				}
+				if _, err := strconv.ParseFloat(bound, 64); err != nil {
+					v.add(FlowErrorLevelError, "field_rule_invalid", 0, 0, "字段[%s]的取值范围[%s]不是数字", label, value)
+					break
+				}
+			}
+		case FieldRuleRegex:
+			if _, err := regexp.Compile(value); err != nil {
+				v.add(FlowErrorLevelError, "field_rule_invalid", 0, 0, "字段[%s]的正则[%s]无法解析: %v", label, value, err)
+			}
+		default:
+			if !formValidator.HasTag(name) {
+				v.add(FlowErrorLevelError, "field_rule_unknown", 0, 0, "字段[%s]的校验规则[%s]无法识别", label, name)
+			}
+		}
+	}
+}
+
+// checkTitleTemplate 标题模板的表达式须能解析，引用的变量须在表单中存在
+func (v *flowValidator) checkTitleTemplate() {
+	tmpl := v.g.Flow.TitleTemplate
+	if strings.TrimSpace(tmpl) == "" {
+		return
+	}
+	exprs, err := titleExpressions(tmpl)
+	if err != nil {
+		v.add(FlowErrorLevelError, "invalid_title_template", 0, 0, "%v", err)
+		return
+	}
+	fields := v.templateFields()
+	for _, src := range exprs {
+		expr, err := expression.Compile(src)
+		if err != nil {
+			v.add(FlowErrorLevelError, "invalid_title_template", 0, 0, "标题模板表达式[%s]语法错误: %v", src, err)
+			continue
+		}
+		if fields == nil {
+			continue
+		}
+		for _, name := range expr.Vars() {
+			name = strings.SplitN(name, ".", 2)[0]
+			if !fields[name] {
+				v.add(FlowErrorLevelWarning, "unknown_field", 0, 0, "标题模板引用了表单中不存在的字段[%s]", name)
+			}
+		}
+	}
+}
+
+// checkAutoApprove 校验自动通过规则
+func (v *flowValidator) checkAutoApprove() {
+	for rule := range autoApproveRules(v.g.Flow) {
+		switch rule {
+		case AutoApproveInitiator, AutoApproveApproved, AutoApproveConsecutive:
+		default:
+			v.add(FlowErrorLevelError, "invalid_auto_approve", 0, 0, "流程的自动通过规则[%s]无法识别", rule)
+		}
+	}
+}
+
+// checkCarbonCopies 校验抄送设置：抄送步骤存在、抄送人可解析、抄送条件语法正确
+func (v *flowValidator) checkCarbonCopies() {
+	for _, c := range v.g.CarbonCopies {
+		name := "流程结束"
+		if c.ProcessID > 0 {
+			p, ok := v.g.process(int(c.ProcessID))
+			if !ok {
+				v.add(FlowErrorLevelError, "invalid_cc_process", c.ProcessID, 0, "抄送设置%d的步骤%d不存在", c.ID, c.ProcessID)
+				continue
+			}
+			name = p.ProcessName
+		}
+		switch c.Type {
+		case "Sys":
+			switch strings.TrimSpace(c.Recipient) {
+			case "-1000", "-1001", "-1002":
+			default:
+				v.add(FlowErrorLevelError, "invalid_cc_recipient", c.ProcessID, 0, "[%s]的系统抄送人[%s]无法识别", name, c.Recipient)
+			}
+		case "Emp", "Dept":
+			if len(splitIds(c.Recipient)) == 0 {
+				v.add(FlowErrorLevelError, "invalid_cc_recipient", c.ProcessID, 0, "[%s]的抄送设置未指定抄送人", name)
+			}
+		default:
+			if msg := v.resolverValueError(c.Type, c.Recipient); msg != "" {
+				v.add(FlowErrorLevelError, "invalid_cc_recipient", c.ProcessID, 0, "[%s]的抄送设置有误：%s", name, msg)
+			}
+		}
+		src := strings.TrimSpace(c.Expression)
+		if src == "" || src == "1" {
+			continue
+		}
+		if strings.HasPrefix(src, "[") {
+			legacy, err := legacyExpression(src)
+			if err != nil {
+				v.add(FlowErrorLevelError, "invalid_cc_expression", c.ProcessID, 0, "[%s]的抄送条件语法错误", name)
+				continue
+			}
+			src = legacy
+		}
+		if _, err := expression.Compile(src); err != nil {
+			v.add(FlowErrorLevelError, "invalid_cc_expression", c.ProcessID, 0, "[%s]的抄送条件语法错误: %v", name, err)
+		}
+	}
+}
+
+// checkNoAuditor 校验未找到审批人时的兜底策略，转交流程管理员时须设置管理员
+func (v *flowValidator) checkNoAuditor() error {
+	valid := func(policy string) bool {
+		switch policy {
+		case NoAuditorError, NoAuditorSkip, NoAuditorAdmin, NoAuditorSuperior, NoAuditorInitiator:
+			return true
+		}
+		return false
+	}
+	if v.g.Flow.NoAuditor != "" && !valid(v.g.Flow.NoAuditor) {
+		v.add(FlowErrorLevelError, "invalid_no_auditor", 0, 0, "流程未找到审批人时的处理方式[%s]无法识别", v.g.Flow.NoAuditor)
+	}
+	useAdmin := false
+	for _, p := range v.g.Processes {
+		if p.NoAuditor != "" && !valid(p.NoAuditor) {
+			v.add(FlowErrorLevelError, "invalid_no_auditor", p.ID, 0, "步骤[%s]未找到审批人时的处理方式[%s]无法识别", p.ProcessName, p.NoAuditor)
+			continue
+		}
+		if p.GatewayType == "" && p.Position != 0 && noAuditorPolicy(v.g.Flow, p) == NoAuditorAdmin {
+			useAdmin = true
+		}
+	}
+	if !useAdmin {
+		return nil
+	}
+	if v.g.Flow.AdminID <= 0 {
+		v.add(FlowErrorLevelError, "no_flow_admin", 0, 0, "未找到审批人时转交流程管理员，但流程未设置管理员")
+		return nil
+	}
+	var count int64
+	if err := v.db.Model(&models.Emp{}).Where("id=?", v.g.Flow.AdminID).Count(&count).Error; err != nil {
+		return fmt.Errorf("数据库查询错误: %v", err)
+	}
+	if count == 0 {
+		v.add(FlowErrorLevelError, "no_flow_admin", 0, 0, "流程管理员%d不存在", v.g.Flow.AdminID)
+	}
+	return nil
+}
+
+// checkGateways 校验并行网关：拆分后的每个分支须先到达合并网关，不能直接结束流程
+func (v *flowValidator) checkGateways() {
+	for _, p := range v.g.Processes {
+		switch p.GatewayType {
+		case "":
+			continue
+		case GatewayFork, GatewayJoin:
+		default:
+			v.add(FlowErrorLevelError, "invalid_gateway", p.ID, 0, "步骤[%s]的网关类型[%s]无法识别", p.ProcessName, p.GatewayType)
+			continue
+		}
+		if p.Position == 0 {
+			v.add(FlowErrorLevelError, "gateway_start", p.ID, 0, "第一步骤[%s]不能设为网关", p.ProcessName)
+		}
+		if p.ChildFlowID > 0 {
+			v.add(FlowErrorLevelError, "gateway_child_flow", p.ID, 0, "网关[%s]不能设置子流程", p.ProcessName)
+		}
+		if p.JoinThreshold < 0 {
+			v.add(FlowErrorLevelError, "invalid_join_threshold", p.ID, 0, "合并网关[%s]的合并分支数不能小于0", p.ProcessName)
+		}
+		if p.GatewayType != GatewayFork {
+			continue
+		}
+		links := v.g.conditions(p.ID)
+		if len(links) < 2 {
+			v.add(FlowErrorLevelWarning, "fork_single_branch", p.ID, 0, "并行网关[%s]只有一个分支", p.ProcessName)
+		}
+		for _, l := range links {
+			if v.branchEnds(l.NextProcessID) {
+				v.add(FlowErrorLevelError, "fork_without_join", p.ID, l.ID, "并行网关[%s]的分支未经合并网关即结束流程", p.ProcessName)
+			}
+		}
+	}
+}
+
+// branchEnds 从分支的第一步出发，不经过合并网关能否到达流程结束
+func (v *flowValidator) branchEnds(processID int) bool {
+	visited := make(map[int]bool)
+	queue := []int{processID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == -1 {
+			return true
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		p, ok := v.g.process(id)
+		if !ok || p.GatewayType == GatewayJoin {
+			continue
+		}
+		queue = append(queue, v.g.successors(p)...)
+	}
+	return false
+}
+
+// templateFields 条件表达式可引用的变量，流程未关联表单时返回 nil 不做检查
+func (v *flowValidator) templateFields() map[string]bool {
+	forms := v.g.Template.TemplateForms
+	if len(forms) == 0 {
+		return nil
+	}
+	fields := make(map[string]bool)
+	for _, name := range conditionBuiltinVars {
+		fields[name] = true
+	}
+	for _, form := range forms {
+		fields[form.Field] = true
+		fields[form.FieldName] = true
+	}
+	// 只出现在一个明细表格中的列可直接以列名引用
+	columns := make(map[string]int)
+	for _, form := range forms {
+		if form.FieldType != FieldTypeTable {
+			continue
+		}
+		seen := make(map[string]bool, len(form.FieldColumns))
+		for _, column := range form.FieldColumns {
+			if !seen[column.Field] {
+				seen[column.Field] = true
+				columns[column.Field]++
+			}
+		}
+	}
+	for column, n := range columns {
+		if n == 1 {
+			fields[column] = true
+		}
+	}
+	return fields
+}
+
+// checkChildFlows 子流程必须存在且已发布
+func (v *flowValidator) checkChildFlows() error {
+	for _, p := range v.g.Processes {
+		if p.ChildFlowID <= 0 {
+			if p.Position == 2 {
+				v.add(FlowErrorLevelError, "child_flow_missing", p.ID, 0, "步骤[%s]为子流程步骤，但未设置子流程", p.ProcessName)
+			}
+			continue
+		}
+		if uint(p.ChildFlowID) == v.g.Flow.ID {
+			v.add(FlowErrorLevelError, "child_flow_self", p.ID, 0, "步骤[%s]的子流程不能是流程自身", p.ProcessName)
+			continue
+		}
+		var child models.Flow
+		if err := v.db.First(&child, p.ChildFlowID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				v.add(FlowErrorLevelError, "child_flow_missing", p.ID, 0, "步骤[%s]的子流程%d不存在", p.ProcessName, p.ChildFlowID)
+				continue
+			}
+			return fmt.Errorf("数据库查询错误: %v", err)
+		}
+		if !child.IsPublish {
+			v.add(FlowErrorLevelError, "child_flow_unpublished", p.ID, 0, "步骤[%s]的子流程[%s]尚未发布", p.ProcessName, child.FlowName)
+		}
+		v.checkMultiChild(p)
+	}
+	return nil
+}
+
+// checkMultiChild 校验多实例子流程设置
+func (v *flowValidator) checkMultiChild(p models.Process) {
+	if p.ChildListField == "" {
+		return
+	}
+	switch childMode(p) {
+	case ChildModeParallel, ChildModeSequential:
+	default:
+		v.add(FlowErrorLevelError, "invalid_child_mode", p.ID, 0, "步骤[%s]的多实例子流程执行方式[%s]无法识别", p.ProcessName, p.ChildMode)
+	}
+	if p.ChildQuorum < 0 {
+		v.add(FlowErrorLevelError, "invalid_child_quorum", p.ID, 0, "步骤[%s]的多实例子流程通过数量不能小于0", p.ProcessName)
+	}
+	if fields := v.templateFields(); fields != nil && !fields[p.ChildListField] {
+		v.add(FlowErrorLevelWarning, "unknown_field", p.ID, 0, "步骤[%s]的多实例列表字段[%s]在表单中不存在", p.ProcessName, p.ChildListField)
+	}
+}
+
+// checkAuditors 除第一步骤和网关外，每个步骤的审批人设置须能解析出审批人；未设置审批人的步骤按兜底策略处理时仅提示
+func (v *flowValidator) checkAuditors() error {
+	for _, p := range v.g.Processes {
+		if p.GatewayType != "" {
+			continue
+		}
+		links := v.g.auditorLinks(p.ID)
+		if len(links) == 0 {
+			if p.Position == 0 {
+				continue
+			}
+			// 设置了兜底策略的步骤运行时按策略处理，仅提示
+			if policy := noAuditorPolicy(v.g.Flow, p); policy != NoAuditorError {
+				v.add(FlowErrorLevelWarning, "no_auditor", p.ID, 0, "步骤[%s]未设置审批人，将按未找到审批人的处理方式[%s]处理", p.ProcessName, policy)
+			} else {
+				v.add(FlowErrorLevelError, "no_auditor", p.ID, 0, "步骤[%s]未设置审批人", p.ProcessName)
+			}
+			continue
+		}
+		for _, l := range links {
+			if err := v.checkAuditorLink(p, l); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkAuditorLink 校验单个审批人设置
+func (v *flowValidator) checkAuditorLink(p models.Process, l models.Flowlink) error {
+	switch l.Type {
+	case "Sys":
+		switch strings.TrimSpace(l.Auditor) {
+		case "-1000", "-1001", "-1002":
+		default:
+			v.add(FlowErrorLevelError, "invalid_auditor", p.ID, l.ID, "步骤[%s]的系统审批人[%s]无法识别", p.ProcessName, l.Auditor)
+		}
+	case "Emp":
+		ids := splitIds(l.Auditor)
+		if len(ids) == 0 {
+			v.add(FlowErrorLevelError, "invalid_auditor", p.ID, l.ID, "步骤[%s]未指定审批员工", p.ProcessName)
+			return nil
+		}
+		var emps []models.Emp
+		if err := v.db.Where("id IN (?)", ids).Find(&emps).Error; err != nil {
+			return fmt.Errorf("数据库查询错误: %v", err)
+		}
+		found := make(map[int]bool)
+		for _, emp := range emps {
+			found[int(emp.ID)] = true
+			if emp.Leave == 1 {
+				v.add(FlowErrorLevelWarning, "auditor_left", p.ID, l.ID, "步骤[%s]的审批员工[%s]已离职", p.ProcessName, emp.Name)
+			}
+		}
+		for _, id := range ids {
+			if !found[id] {
+				v.add(FlowErrorLevelError, "invalid_auditor", p.ID, l.ID, "步骤[%s]的审批员工%d不存在", p.ProcessName, id)
+			}
+		}
+	case "Dept":
+		ids := splitIds(l.Auditor)
+		if len(ids) == 0 {
+			v.add(FlowErrorLevelError, "invalid_auditor", p.ID, l.ID, "步骤[%s]未指定审批部门", p.ProcessName)
+			return nil
+		}
+		var depts []models.Dept
+		if err := v.db.Where("id IN (?)", ids).Find(&depts).Error; err != nil {
+			return fmt.Errorf("数据库查询错误: %v", err)
+		}
+		found := make(map[int]bool)
+		for _, dept := range depts {
+			found[int(dept.ID)] = true
+			if dept.DirectorID <= 0 {
+				v.add(FlowErrorLevelError, "invalid_auditor", p.ID, l.ID, "步骤[%s]的审批部门[%s]未设置主管", p.ProcessName, dept.DeptName)
+				continue
+			}
+			var count int64
+			if err := v.db.Model(&models.Emp{}).Where("id=?", dept.DirectorID).Count(&count).Error; err != nil {
+				return fmt.Errorf("数据库查询错误: %v", err)
+			}
+			if count == 0 {
+				v.add(FlowErrorLevelError, "invalid_auditor", p.ID, l.ID, "步骤[%s]的审批部门[%s]主管不存在", p.ProcessName, dept.DeptName)
+			}
+		}
+		for _, id := range ids {
+			if !found[id] {
+				v.add(FlowErrorLevelError, "invalid_auditor", p.ID, l.ID, "步骤[%s]的审批部门%d不存在", p.ProcessName, id)
+			}
+		}
+	default:
+		if msg := v.resolverValueError(l.Type, l.Auditor); msg != "" {
+			v.add(FlowErrorLevelError, "invalid_auditor", p.ID, l.ID, "步骤[%s]的审批人设置有误：%s", p.ProcessName, msg)
+		}
+	}
+	return nil
+}
+
+// resolverValueError 校验 Sys、Emp、Dept 以外的人员设置，返回问题描述，无问题时为空
+func (v *flowValidator) resolverValueError(linkType string, value string) string {
+	if _, ok := lookupAuditorResolver(linkType); !ok {
+		return fmt.Sprintf("类型[%s]未注册", linkType)
+	}
+	value = strings.TrimSpace(value)
+	switch linkType {
+	case AuditorSuperior:
+		if n, err := strconv.Atoi(value); value != "" && (err != nil || n < 1) {
+			return fmt.Sprintf("上级主管级数[%s]无效", value)
+		}
+	case AuditorRole:
+		if len(splitIds(value)) == 0 {
+			return "未指定角色"
+		}
+	case AuditorField:
+		if value == "" {
+			return "未指定表单字段"
+		}
+		if fields := v.templateFields(); fields != nil && !fields[value] {
+			return fmt.Sprintf("引用的表单字段[%s]不存在", value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,176 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/pkg/plugin/types"
+)
+
+// 字段的自定义规则在发布时校验，无法识别的规则不留到填写表单时才报错
+func TestValidateFlowFieldRules(t *testing.T) {
+	e := newTestEnv(t)
+	tmpl := models.Template{TemplateName: "规则"}
+	e.must(e.db.Create(&tmpl).Error)
+	rule := func(name, value string) types.Rule {
+		return types.Rule{{RuleName: name, RuleValue: value}}
+	}
+	forms := []models.TemplateForm{
+		{Field: "email", FieldType: "text", FieldRules: types.Rule{{RuleName: "required"}, {RuleName: "email"}}},
+		{Field: "code", FieldType: "text", FieldRules: rule("regex", `^[A-Z]\d+$`)},
+		{Field: "amount", FieldType: "number", FieldRules: rule("range", "1,")},
+		{Field: "unknown", FieldType: "text", FieldRules: rule("no_such_rule", "")},
+		{Field: "joined", FieldType: "text", FieldRules: rule("email,required", "")},
+		{Field: "bounds", FieldType: "number", FieldRules: rule("range", "a,10")},
+		{Field: "pattern", FieldType: "text", FieldRules: rule("regex", "[")},
+		{Field: "items", FieldType: FieldTypeTable, FieldColumns: types.FieldColumns{
+			{Field: "price", FieldType: "number", FieldRules: rule("gte", "0")},
+			{Field: "memo", FieldType: "text", FieldRules: rule("bogus", "")},
+		}},
+	}
+	for i := range forms {
+		forms[i].TemplateID = tmpl.ID
+		e.must(e.db.Create(&forms[i]).Error)
+	}
+	flowID := e.flow("rules")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("template_id", tmpl.ID).Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, -1, "")
+
+	errs, err := e.s.ValidateFlow(flowID)
+	e.must(err)
+	var got []string
+	for _, item := range errs {
+		if strings.HasPrefix(item.Code, "field_rule") {
+			got = append(got, item.Message)
+		}
+	}
+	want := []string{"[unknown]", "[joined]", "[bounds]", "[pattern]", "[items.memo]"}
+	if len(got) != len(want) {
+		t.Fatalf("规则问题应为 %d 条，实际 %q", len(want), got)
+	}
+	for i, field := range want {
+		if !strings.Contains(got[i], field) {
+			t.Fatalf("第%d条问题应为字段%s，实际 %q", i+1, field, got[i])
+		}
+	}
+}
+
+// 与条件内置变量同名的字段提示为警告，不阻止发布
+func TestValidateFlowReservedFields(t *testing.T) {
+	e := newTestEnv(t)
+	tmpl := models.Template{TemplateName: "保留字段"}
+	e.must(e.db.Create(&tmpl).Error)
+	for _, field := range []string{"initiator", "amount"} {
+		e.must(e.db.Create(&models.TemplateForm{TemplateID: tmpl.ID, Field: field, FieldType: "number"}).Error)
+	}
+	flowID := e.flow("reserved")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("template_id", tmpl.ID).Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	e.link(flowID, start, -1, "")
+
+	errs, err := e.s.ValidateFlow(flowID)
+	e.must(err)
+	var got []FlowError
+	for _, item := range errs {
+		if item.Code == "field_reserved" {
+			got = append(got, item)
+		}
+	}
+	if len(got) != 1 || got[0].Level != FlowErrorLevelWarning || !strings.Contains(got[0].Message, "initiator") {
+		t.Fatalf("应仅对字段initiator给出警告，实际 %+v", got)
+	}
+}
+
+// 未设置审批人的步骤有兜底策略时仅提示，按流程默认的报错处理时不能发布
+func TestValidateFlowNoAuditorFallback(t *testing.T) {
+	e := newTestEnv(t)
+	flowID := e.flow("fallback")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A", NoAuditor: NoAuditorSkip}, "", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, int(b), "")
+	e.link(flowID, b, -1, "")
+
+	levels := func() map[uint]string {
+		errs, err := e.s.ValidateFlow(flowID)
+		e.must(err)
+		got := make(map[uint]string)
+		for _, item := range errs {
+			if item.Code == "no_auditor" {
+				got[item.ProcessID] = item.Level
+			}
+		}
+		return got
+	}
+	if got := levels(); got[a] != FlowErrorLevelWarning || got[b] != FlowErrorLevelError {
+		t.Fatalf("步骤A应为警告、步骤B应为错误，实际 %v", got)
+	}
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("no_auditor", NoAuditorInitiator).Error)
+	if got := levels(); got[a] != FlowErrorLevelWarning || got[b] != FlowErrorLevelWarning {
+		t.Fatalf("流程设置兜底策略后应均为警告，实际 %v", got)
+	}
+}
+
+// 流程结构、流转条件及审批人设置的问题定位到所在步骤，有错误时不能发布
+func TestValidateFlowStructure(t *testing.T) {
+	e := newTestEnv(t)
+	tmpl := models.Template{TemplateName: "结构"}
+	e.must(e.db.Create(&tmpl).Error)
+	e.must(e.db.Create(&models.TemplateForm{TemplateID: tmpl.ID, Field: "amount", FieldType: "number"}).Error)
+	flowID := e.flow("structure")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("template_id", tmpl.ID).Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2,99", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "3", false)
+	c := e.step(flowID, models.Process{ProcessName: "C"}, "4", false)
+	d := e.step(flowID, models.Process{ProcessName: "D"}, "4", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, int(b), "amount >")
+	e.link(flowID, a, int(b), "missing > 1")
+	e.link(flowID, b, 99, "")
+	e.link(flowID, b, int(d), "")
+	e.link(flowID, c, -1, "")
+
+	errs, err := e.s.ValidateFlow(flowID)
+	e.must(err)
+	got := make(map[string]string)
+	for _, item := range errs {
+		got[fmt.Sprintf("%s@%d", item.Code, item.ProcessID)] = item.Level
+	}
+	want := map[string]string{
+		fmt.Sprintf("invalid_auditor@%d", a):      FlowErrorLevelError,
+		fmt.Sprintf("invalid_expression@%d", a):   FlowErrorLevelError,
+		fmt.Sprintf("unknown_field@%d", a):        FlowErrorLevelWarning,
+		fmt.Sprintf("no_default_branch@%d", a):    FlowErrorLevelWarning,
+		fmt.Sprintf("invalid_next_process@%d", b): FlowErrorLevelError,
+		fmt.Sprintf("no_end_path@%d", b):          FlowErrorLevelError,
+		fmt.Sprintf("unreachable@%d", c):          FlowErrorLevelError,
+		fmt.Sprintf("no_next@%d", d):              FlowErrorLevelError,
+	}
+	for key, level := range want {
+		if got[key] != level {
+			t.Fatalf("应有%s级别的问题%s，实际 %v", level, key, got)
+		}
+	}
+	if _, ok := got[fmt.Sprintf("unreachable@%d", d)]; ok {
+		t.Fatalf("步骤D可从第一步骤到达，实际 %v", got)
+	}
+
+	_, err = e.s.Publish(flowID, "")
+	var flowErrs FlowErrors
+	if !errors.As(err, &flowErrs) || !flowErrs.HasError() {
+		t.Fatalf("校验有错误时发布应返回校验结果，实际 %v", err)
+	}
+	var versions int64
+	e.db.Model(&models.FlowVersion{}).Where("flow_id=?", flowID).Count(&versions)
+	if versions != 0 {
+		t.Fatal("校验未通过时不应生成流程版本")
+	}
+}
@@ -0,0 +1,337 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hulutech-web/workflow-engine/app/models"
+	"github.com/hulutech-web/workflow-engine/core/workflow/official_plugin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+	"sort"
+)
+
+// FieldChange 字段变更
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// ItemChange 步骤、流转、表单字段、插件配置或抄送设置的变更，Action 为 added、removed 或 changed
+type ItemChange struct {
+	ID      uint          `json:"id"`
+	Name    string        `json:"name"`
+	Action  string        `json:"action"`
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// VersionDiff 两个流程版本之间的差异
+type VersionDiff struct {
+	FlowID        uint          `json:"flow_id"`
+	From          int           `json:"from"`
+	To            int           `json:"to"`
+	Flow          []FieldChange `json:"flow"`
+	Processes     []ItemChange  `json:"processes"`
+	Flowlinks     []ItemChange  `json:"flowlinks"`
+	TemplateForms []ItemChange  `json:"template_forms"`
+	PluginConfigs []ItemChange  `json:"plugin_configs"`
+	CarbonCopies  []ItemChange  `json:"carbon_copies"`
+}
+
+// Publish 校验通过后发布流程，生成新的不可变版本，新发起的流程按该版本流转；校验未通过时返回 FlowErrors
+func (s *Service) Publish(flowID uint, remark string) (*models.FlowVersion, error) {
+	var version models.FlowVersion
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		g, err := loadGraph(tx, flowID)
+		if err != nil {
+			return err
+		}
+		errs, err := validateGraph(tx, g)
+		if err != nil {
+			return err
+		}
+		if errs.HasError() {
+			return errs
+		}
+		version, err = createVersion(tx, g, remark)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// createVersion 保存流程定义快照为新版本，并设为流程当前版本
+func createVersion(tx *gorm.DB, g *flowGraph, remark string) (models.FlowVersion, error) {
+	snapshot, err := json.Marshal(g)
+	if err != nil {
+		return models.FlowVersion{}, fmt.Errorf("流程版本快照生成错误: %v", err)
+	}
+	var last int
+	err = tx.Model(&models.FlowVersion{}).Where("flow_id=?", g.Flow.ID).Select("COALESCE(MAX(version), 0)").Scan(&last).Error
+	if err != nil {
+		return models.FlowVersion{}, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	version := models.FlowVersion{
+		FlowID:   g.Flow.ID,
+		Version:  last + 1,
+		Remark:   remark,
+		Snapshot: string(snapshot),
+	}
+	if err = tx.Create(&version).Error; err != nil {
+		return version, fmt.Errorf("数据库插入错误: %v", err)
+	}
+	err = tx.Model(&models.Flow{}).Where("id=?", g.Flow.ID).Updates(map[string]interface{}{
+		"is_publish": true,
+		"version_id": version.ID,
+	}).Error
+	if err != nil {
+		return version, fmt.Errorf("数据库更新错误: %v", err)
+	}
+	return version, nil
+}
+
+// Versions 流程的发布版本列表，不含快照内容
+func (s *Service) Versions(flowID uint) ([]models.FlowVersion, error) {
+	var versions []models.FlowVersion
+	err := s.db.Omit("snapshot").Where("flow_id=?", flowID).Order("version desc").Find(&versions).Error
+	if err != nil {
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return versions, nil
+}
+
+// Version 流程版本详情，包含快照内容
+func (s *Service) Version(versionID uint) (*models.FlowVersion, error) {
+	var version models.FlowVersion
+	if err := s.db.First(&version, versionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("流程版本不存在")
+		}
+		return nil, fmt.Errorf("数据库查询错误: %v", err)
+	}
+	return &version, nil
+}
+
+// DiffVersions 比较同一流程的两个版本
+func (s *Service) DiffVersions(fromID uint, toID uint) (*VersionDiff, error) {
+	from, err := s.Version(fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.Version(toID)
+	if err != nil {
+		return nil, err
+	}
+	if from.FlowID != to.FlowID {
+		return nil, errors.New("只能比较同一流程的版本")
+	}
+	fg, err := s.versionGraph(s.db, fromID)
+	if err != nil {
+		return nil, err
+	}
+	tg, err := s.versionGraph(s.db, toID)
+	if err != nil {
+		return nil, err
+	}
+	return &VersionDiff{
+		FlowID: from.FlowID,
+		From:   from.Version,
+		To:     to.Version,
+		Flow:   diffFields(fg.Flow, tg.Flow, "version_id", "is_publish"),
+		Processes: diffItems(fg.Processes, tg.Processes, func(p models.Process) (uint, string) {
+			return p.ID, p.ProcessName
+		}),
+		Flowlinks: diffItems(fg.Flowlinks, tg.Flowlinks, func(l models.Flowlink) (uint, string) {
+			return l.ID, l.Type
+		}),
+		TemplateForms: diffItems(fg.Template.TemplateForms, tg.Template.TemplateForms, func(f models.TemplateForm) (uint, string) {
+			return f.ID, f.FieldName
+		}),
+		PluginConfigs: diffItems(fg.PluginConfigs, tg.PluginConfigs, func(c official_plugin.PluginConfig) (uint, string) {
+			return c.ID, fmt.Sprintf("%d", c.PluginID)
+		}),
+		CarbonCopies: diffItems(fg.CarbonCopies, tg.CarbonCopies, func(c models.CarbonCopy) (uint, string) {
+			return c.ID, c.Type
+		}),
+	}, nil
+}
+
+// diffItems 按ID比较两组定义
+func diffItems[T any](from []T, to []T, key func(T) (uint, string)) []ItemChange {
+	changes := []ItemChange{}
+	old := make(map[uint]T, len(from))
+	for _, item := range from {
+		id, _ := key(item)
+		old[id] = item
+	}
+	seen := make(map[uint]bool, len(to))
+	for _, item := range to {
+		id, name := key(item)
+		seen[id] = true
+		prev, ok := old[id]
+		if !ok {
+			changes = append(changes, ItemChange{ID: id, Name: name, Action: "added"})
+			continue
+		}
+		if fields := diffFields(prev, item); len(fields) > 0 {
+			changes = append(changes, ItemChange{ID: id, Name: name, Action: "changed", Changes: fields})
+		}
+	}
+	for _, item := range from {
+		if id, name := key(item); !seen[id] {
+			changes = append(changes, ItemChange{ID: id, Name: name, Action: "removed"})
+		}
+	}
+	return changes
+}
+
+// diffFields 比较两个定义的字段，忽略时间戳及关联数据
+func diffFields(from interface{}, to interface{}, ignore ...string) []FieldChange {
+	fm, tm := fieldMap(from), fieldMap(to)
+	skip := map[string]bool{"id": true, "created_at": true, "updated_at": true}
+	for _, field := range ignore {
+		skip[field] = true
+	}
+	changes := []FieldChange{}
+	for _, field := range sortedKeys(tm) {
+		if skip[field] {
+			continue
+		}
+		if !reflect.DeepEqual(fm[field], tm[field]) {
+			changes = append(changes, FieldChange{Field: field, From: fm[field], To: tm[field]})
+		}
+	}
+	return changes
+}
+
+// fieldMap 定义的字段值，关联对象不参与比较
+func fieldMap(v interface{}) map[string]interface{} {
+	data, _ := json.Marshal(v)
+	m := make(map[string]interface{})
+	_ = json.Unmarshal(data, &m)
+	for k, val := range m {
+		if _, ok := val.(map[string]interface{}); ok {
+			delete(m, k)
+		}
+	}
+	return m
+}
+
+// Rollback 回滚到指定版本：以该版本快照恢复流程定义，并发布为新版本，进行中的流程不受影响
+func (s *Service) Rollback(versionID uint) (*models.FlowVersion, error) {
+	target, err := s.Version(versionID)
+	if err != nil {
+		return nil, err
+	}
+	g, err := s.versionGraph(s.db, versionID)
+	if err != nil {
+		return nil, err
+	}
+	var version models.FlowVersion
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := restoreGraph(tx, g); err != nil {
+			return err
+		}
+		version, err = createVersion(tx, g, fmt.Sprintf("回滚至版本%d", target.Version))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// restoreGraph 以快照覆盖流程当前定义，步骤与流转保留原ID；表单模板仅在未被其他流程使用时恢复
+func restoreGraph(tx *gorm.DB, g *flowGraph) error {
+	flowID := g.Flow.ID
+	err := tx.Model(&models.Flow{}).Where("id=?", flowID).Updates(map[string]interface{}{
+		"flow_name":      g.Flow.FlowName,
+		"template_id":    g.Flow.TemplateID,
+		"type_id":        g.Flow.TypeID,
+		"flowchart":      g.Flow.Flowchart,
+		"jsplumb":        g.Flow.Jsplumb,
+		"recall_policy":  g.Flow.RecallPolicy,
+		"no_auditor":     g.Flow.NoAuditor,
+		"admin_id":       g.Flow.AdminID,
+		"auto_approve":   g.Flow.AutoApprove,
+		"title_template": g.Flow.TitleTemplate,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("数据库更新错误: %v", err)
+	}
+	if err = tx.Where("flow_id=?", flowID).Delete(&models.Flowlink{}).Error; err != nil {
+		return fmt.Errorf("数据库删除错误: %v", err)
+	}
+	if err = tx.Where("flow_id=?", flowID).Delete(&models.Process{}).Error; err != nil {
+		return fmt.Errorf("数据库删除错误: %v", err)
+	}
+	if err = tx.Where("flow_id=?", flowID).Delete(&official_plugin.PluginConfig{}).Error; err != nil {
+		return fmt.Errorf("数据库删除错误: %v", err)
+	}
+	if err = tx.Where("flow_id=?", flowID).Delete(&models.CarbonCopy{}).Error; err != nil {
+		return fmt.Errorf("数据库删除错误: %v", err)
+	}
+	// 快照被缓存共享，写入副本，避免零值被默认值覆盖时修改快照
+	processes := append([]models.Process(nil), g.Processes...)
+	if len(processes) > 0 {
+		if err = tx.Omit(clause.Associations).Create(&processes).Error; err != nil {
+			return fmt.Errorf("数据库插入错误: %v", err)
+		}
+		for _, p := range g.Processes {
+			if p.Position == 0 {
+				if err = tx.Model(&models.Process{}).Where("id=?", p.ID).Update("position", 0).Error; err != nil {
+					return fmt.Errorf("数据库更新错误: %v", err)
+				}
+			}
+		}
+	}
+	flowlinks := append([]models.Flowlink(nil), g.Flowlinks...)
+	if len(flowlinks) > 0 {
+		if err = tx.Omit(clause.Associations).Create(&flowlinks).Error; err != nil {
+			return fmt.Errorf("数据库插入错误: %v", err)
+		}
+	}
+	configs := append([]official_plugin.PluginConfig(nil), g.PluginConfigs...)
+	if len(configs) > 0 {
+		if err = tx.Omit(clause.Associations).Create(&configs).Error; err != nil {
+			return fmt.Errorf("数据库插入错误: %v", err)
+		}
+	}
+	ccs := append([]models.CarbonCopy(nil), g.CarbonCopies...)
+	if len(ccs) > 0 {
+		if err = tx.Omit(clause.Associations).Create(&ccs).Error; err != nil {
+			return fmt.Errorf("数据库插入错误: %v", err)
+		}
+	}
+	if g.Flow.TemplateID <= 0 || g.Template.ID == 0 {
+		return nil
+	}
+	var shared int64
+	tx.Model(&models.Flow{}).Where("template_id=?", g.Flow.TemplateID).Where("id != ?", flowID).Count(&shared)
+	if shared > 0 {
+		return nil
+	}
+	if err = tx.Where("template_id=?", g.Template.ID).Delete(&models.TemplateForm{}).Error; err != nil {
+		return fmt.Errorf("数据库删除错误: %v", err)
+	}
+	forms := append([]models.TemplateForm(nil), g.Template.TemplateForms...)
+	if len(forms) > 0 {
+		if err = tx.Omit(clause.Associations).Create(&forms).Error; err != nil {
+			return fmt.Errorf("数据库插入错误: %v", err)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
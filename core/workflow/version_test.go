@@ -0,0 +1,72 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/hulutech-web/workflow-engine/app/models"
+)
+
+// 回滚恢复版本快照中的流程设置
+func TestRollbackRestoresFlowSettings(t *testing.T) {
+	e := newTestEnv(t)
+	flowID := e.flow("version")
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("title_template", "报销{1}").Error)
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, -1, "")
+	v1, err := e.s.Publish(flowID, "v1")
+	e.must(err)
+
+	e.must(e.db.Model(&models.Flow{}).Where("id=?", flowID).Update("title_template", "请假").Error)
+	_, err = e.s.Publish(flowID, "v2")
+	e.must(err)
+	_, err = e.s.Rollback(v1.ID)
+	e.must(err)
+
+	var flow models.Flow
+	e.must(e.db.First(&flow, flowID).Error)
+	if flow.TitleTemplate != "报销{1}" {
+		t.Fatalf("回滚后标题模板为%q，期望%q", flow.TitleTemplate, "报销{1}")
+	}
+}
+
+// 发起的流程按发起时的版本流转，之后发布的修改只影响新发起的流程
+func TestEntriesPinnedToVersion(t *testing.T) {
+	e := newTestEnv(t)
+	flowID := e.flow("pinned")
+	start := e.step(flowID, models.Process{ProcessName: "发起"}, "", true)
+	a := e.step(flowID, models.Process{ProcessName: "A"}, "2", false)
+	b := e.step(flowID, models.Process{ProcessName: "B"}, "3", false)
+	e.link(flowID, start, int(a), "")
+	e.link(flowID, a, int(b), "")
+	e.link(flowID, b, -1, "")
+	v1, err := e.s.Publish(flowID, "v1")
+	e.must(err)
+	first := e.start(flowID, nil)
+	if first.FlowVersionID != v1.ID {
+		t.Fatalf("流程应记录发起时的版本%d，实际为%d", v1.ID, first.FlowVersionID)
+	}
+
+	e.must(e.db.Model(&models.Flowlink{}).Where("process_id=?", b).Where("type=?", "Emp").Update("auditor", "4").Error)
+	v2, err := e.s.Publish(flowID, "v2")
+	e.must(err)
+	if v2.Version != v1.Version+1 {
+		t.Fatalf("新版本号为%d，期望%d", v2.Version, v1.Version+1)
+	}
+	second := e.start(flowID, nil)
+	e.pass(first.ID, empBob)
+	e.pass(second.ID, empBob)
+	if e.pending(first.ID, empCarol) == 0 {
+		t.Fatalf("已发起的流程应按原版本交由carol审批；待办 %s", e.procs(first.ID))
+	}
+	if e.pending(second.ID, empDave) == 0 {
+		t.Fatalf("新发起的流程应按新版本交由dave审批；待办 %s", e.procs(second.ID))
+	}
+
+	diff, err := e.s.DiffVersions(v1.ID, v2.ID)
+	e.must(err)
+	if len(diff.Flowlinks) != 1 || diff.Flowlinks[0].Action != "changed" || len(diff.Processes) != 0 {
+		t.Fatalf("两个版本应只有一条流转变更，实际 %+v", diff)
+	}
+}
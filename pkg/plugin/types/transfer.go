@@ -31,3 +31,54 @@ func (t *FieldValue) Scan(value interface{}) error {
 func (t FieldValue) Value() (driver.Value, error) {
 	return json.Marshal(t)
 }
+
+// FieldPerms 步骤的表单字段权限，字段英文名 => hidden readonly editable required
+type FieldPerms map[string]string
+
+func (t *FieldPerms) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	}
+	return nil
+}
+
+func (t FieldPerms) Value() (driver.Value, error) {
+	if t == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+// FieldColumn 明细表格的列定义
+type FieldColumn struct {
+	Field      string     `json:"field" form:"field"`
+	FieldName  string     `json:"field_name" form:"field_name"`
+	FieldType  string     `json:"field_type" form:"field_type"`
+	FieldValue FieldValue `json:"field_value" form:"field_value"`
+	FieldRules Rule       `json:"field_rules" form:"field_rules"`
+}
+
+// FieldColumns 明细表格的全部列
+type FieldColumns []FieldColumn
+
+func (t *FieldColumns) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	}
+	return nil
+}
+
+func (t FieldColumns) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(t)
+	return string(b), err
+}
@@ -0,0 +1,31 @@
+package validate
+
+import (
+	"github.com/go-playground/validator/v10"
+	"regexp"
+)
+
+// Regex 正则校验，规则参数为正则表达式，如 regex=^[A-Z]\d+$，参数中的逗号和竖线需写作 0x2C、0x7C
+type Regex struct{}
+
+func (v Regex) Func() validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		re, err := regexp.Compile(fl.Param())
+		if err != nil {
+			return false
+		}
+		return re.MatchString(fl.Field().String())
+	}
+}
+
+func (v Regex) Message() string {
+	return "{0}格式不正确"
+}
+
+func (v Regex) Tag() string {
+	return "regex"
+}
+
+func init() {
+	Register(Regex{})
+}